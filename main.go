@@ -4,13 +4,17 @@ import (
 	"context"
 	"flag"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/agent"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/controller"
+	intentcache "github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent/cache"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/k8sutil"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/sigcontext"
 	"github.com/pkg/errors"
 	"k8s.io/client-go/kubernetes"
@@ -21,6 +25,92 @@ var (
 	flagController = flag.Bool("controller", false, "Run controller component")
 	flagLogDebug   = flag.Bool("debug", false, "")
 	flagNodeName   = flag.String("nodeName", "", "nodeName of the Node that this process is running on")
+
+	flagOrderByLaunchTime = flag.Bool("orderByLaunchTime", false, "Update the longest running nodes first (controller)")
+	flagIntentCacheTTL    = flag.Duration("intentCacheTTL", intentcache.DefaultTTL, "Duration handled intents are remembered to skip duplicates (controller)")
+	flagResyncPeriod      = flag.Duration("resyncPeriod", nodestream.DefaultResyncPeriod, "Duration between resynchronizations of the managed nodes, reconsidering nodes held back by policy, at least -intentCacheTTL (controller)")
+	flagAutoLabelSelector = flag.String("autoLabelSelector", "", "Label selector of joining nodes to label for management, disabled when empty (controller)")
+	flagAutoLabelVersion  = flag.String("autoLabelInterfaceVersion", "2.0.0", "Updater interface version given to automatically labeled nodes (controller)")
+	flagManagedSelector   = flag.String("managedSelector", "", "Label selector of nodes expected to be managed, reporting those missing the management label, disabled when empty (controller)")
+	flagNodeSelector      = flag.String("nodeSelector", "", "Label selector limiting the managed nodes acted on to those matching, selecting all when empty (controller)")
+	flagLabelUnmarked     = flag.Bool("labelUnmarked", false, "Label the nodes reported by -managedSelector for management (controller)")
+	flagKeepCordonedLabel = flag.String("keepCordonedLabel", marker.KeepCordonedKey, "Label of nodes to leave cordoned after they're updated (controller)")
+	flagDrainGraceSel     = flag.String("drainGraceSelector", "", "Label selector of pods given -drainGracePeriod to terminate when drained (controller)")
+	flagDrainGracePeriod  = flag.Duration("drainGracePeriod", 0, "Termination grace period of pods matching -drainGraceSelector (controller)")
+	flagDoNotDrain        = flag.String("doNotDrainAnnotation", marker.DoNotDrainKey, "Annotation of pods to leave in place when their node is drained (controller)")
+	flagDrainFailure      = flag.String("drainFailureAction", controller.DrainFailureProceed, "Action taken when a node fails to drain: proceed, skip, or halt (controller)")
+	flagIncompleteView    = flag.String("incompleteViewAction", controller.IncompleteViewProceed, "Action taken when the policy's view of the cluster is incomplete: proceed, deny, or retry (controller)")
+	flagResumeRamp        = flag.Duration("resumeRamp", 0, "Duration over which concurrent updates ramp up after a paused rollout resumes, requires -batchSize above 1 or -batchPercent (controller)")
+	flagUnknownGrace      = flag.Duration("unknownIntentGrace", 0, "Duration after which nodes with an unknown intent are reset, disabled when zero (controller)")
+	flagBusyTimeout       = flag.Duration("busyTimeout", 0, "Duration after which nodes busy with the same action are treated as stuck and reset, disabled when zero (controller)")
+	flagStatusSinkURL     = flag.String("statusSinkURL", "", "URL to periodically post node update status to as JSON, disabled when empty (controller)")
+	flagStatusInterval    = flag.Duration("statusInterval", time.Minute, "Duration between posts of node update status to -statusSinkURL (controller)")
+	flagStartupSettle     = flag.Duration("startupSettle", 0, "Duration after starting during which node events are coalesced before being handled (controller)")
+	flagBatchSize         = flag.Int("batchSize", 0, "Most nodes permitted to update at once, one when neither this nor -batchPercent is set (controller)")
+	flagBatchPercent      = flag.Int("batchPercent", 0, "Percent of nodes permitted to update at once, rounded up and at least one, exclusive of -batchSize (controller)")
+	flagBatchQuorum       = flag.Float64("batchQuorum", 0, "Fraction of a batch that must be healthy after updating before the next batch begins, disabled when zero (controller)")
+	flagVerifyDelay       = flag.Duration("verifyDelay", 0, "Time after a node passes its health check during which it's rechecked before its update is successful (controller)")
+	flagWorkloadTimeout   = flag.Duration("workloadSettleTimeout", time.Minute, "Longest time given for drained pods to be scheduled and ready after a node is uncordoned (controller)")
+	flagVerifyBootID      = flag.Bool("verifyBootID", false, "Fail updates of nodes whose boot ID is unchanged after rebooting (controller)")
+	flagHealthTimeout     = flag.Duration("healthCheckTimeout", 5*time.Minute, "Longest time a node is given to report itself ready after updating (controller)")
+	flagHealthInterval    = flag.Duration("healthCheckInterval", 10*time.Second, "Time between checks of a node's readiness after updating (controller)")
+	flagReadinessTaints   = flag.String("readinessTaints", "", "Comma separated keys of taints, such as node.kubernetes.io/network-unavailable, a node must not have to pass its health check after updating (controller)")
+	flagReadinessConds    = flag.String("readinessConditions", "", "Comma separated conditions, as Type or Type=Status, a node must report to pass its health check after updating (controller)")
+	flagSkipHealthCheck   = flag.Bool("skipHealthCheck", false, "Skip waiting for nodes to be ready after updating, relying on external health monitoring (controller)")
+	flagDrainCapacity     = flag.Bool("checkDrainCapacity", false, "Defer updating a node until other nodes have the capacity for the pods its drain would evict (controller)")
+	flagMaxCordoned       = flag.Int("maxCordoned", 0, "Most nodes permitted to be cordoned at once, including nodes cordoned outside of an update, disabled when zero (controller)")
+	flagMinRebootInterval = flag.Duration("minRebootInterval", 0, "Least time between the reboots of any two nodes, disabled when zero (controller)")
+	flagMaxUpdateStarts   = flag.Int("maxUpdateStarts", 0, "Most nodes permitted to begin updating within -updateStartWindow across the cluster, disabled when zero (controller)")
+	flagUpdateStartWindow = flag.Duration("updateStartWindow", time.Hour, "Rolling window that -maxUpdateStarts applies to (controller)")
+	flagConcurrencyRamp   = flag.Int("concurrencyRampStep", 0, "Consecutive successful updates after which one more node may update at once, starting from one up to -batchSize, disabled when zero (controller)")
+	flagCanaryRollout     = flag.Bool("canaryRollout", false, "Update nodes labeled with -canaryLabel ahead of the rest of the cluster, blocking further updates when a canary fails (controller)")
+	flagCanaryLabel       = flag.String("canaryLabel", marker.CanaryKey, "Label of nodes to update first with -canaryRollout (controller)")
+	flagCanarySoak        = flag.Duration("canarySoak", 0, "Time after the last canary updates before other nodes may begin updating (controller)")
+	flagRollback          = flag.Bool("rollbackOnFailure", false, "Roll back nodes that fail their health check within -healthCheckTimeout to their prior version (controller)")
+	flagMaintWindows      = flag.String("maintenanceWindows", "", "Comma separated daily windows, as HH:MM-HH:MM, during which nodes may begin updating, disabled when empty (controller)")
+	flagMaintTimezone     = flag.String("maintenanceTimezone", "UTC", "Time zone the -maintenanceWindows are observed in (controller)")
+	flagSeparateAntiAff   = flag.Bool("separateAntiAffine", false, "Avoid updating nodes hosting anti-affine replicas of the same workload at the same time (controller)")
+	flagLeaderElect       = flag.Bool("leaderElect", false, "Act only while holding the -leaseName lease, standing by while another replica holds it (controller)")
+	flagLeaseName         = flag.String("leaseName", "bottlerocket-update-operator-controller", "Name of the lease held by the leading replica (controller)")
+	flagLeaseNamespace    = flag.String("leaseNamespace", "bottlerocket", "Namespace of the lease held by the leading replica (controller)")
+	flagLeaseDuration     = flag.Duration("leaseDuration", 15*time.Second, "Time standby replicas wait, after the lease was last renewed, before taking it over (controller)")
+	flagLeaseRenew        = flag.Duration("leaseRenewDeadline", 10*time.Second, "Time the leading replica retries renewing the lease before giving it up (controller)")
+	flagLeaseRetry        = flag.Duration("leaseRetryPeriod", 2*time.Second, "Time between attempts to acquire or renew the lease (controller)")
+	flagHoldLabel         = flag.String("holdLabel", marker.HoldKey, "Label of nodes to hold at their current update step")
+	flagReportEvents      = flag.Bool("reportEvents", false, "Write update lifecycle events to stdout as JSON records")
+	flagEventHistory      = flag.Int("eventHistory", 0, "Number of recent update lifecycle events served at /events alongside metrics, disabled when zero (controller)")
+	flagMetricsAddr       = flag.String("metricsAddr", "", "Address to serve Prometheus metrics on, disabled when empty")
+	flagHealthAddr        = flag.String("healthAddr", "", "Address to serve the /healthz and /readyz probes on, disabled when empty")
+	flagNotifyURL         = flag.String("notifyURL", "", "Webhook URL to post JSON notifications to as nodes begin, complete, or fail their update, disabled when empty")
+	flagSNSTopicARN       = flag.String("snsTopicARN", "", "Amazon SNS topic ARN to publish JSON notifications to as nodes begin, complete, or fail their update, disabled when empty")
+	flagCloudWatchNS      = flag.String("cloudWatchNamespace", "", "CloudWatch namespace to publish update metrics to as custom metrics, disabled when empty")
+	flagCloudWatchRegion  = flag.String("cloudWatchRegion", "", "AWS region to publish CloudWatch metrics in, taken from the environment when empty")
+	flagTracingEndpoint   = flag.String("tracingEndpoint", "", "OTLP/HTTP traces endpoint of an OpenTelemetry collector, such as http://collector:4318/v1/traces, to export update spans to, disabled when empty")
+
+	flagPinVersion        = flag.String("pinVersion", "", "Only update to this version (agent)")
+	flagBlockVersions     = flag.String("blockVersions", "", "Comma separated versions to never update to (agent)")
+	flagVersionConstraint = flag.String("versionConstraint", "", "Semver constraint that versions must satisfy to be updated to (agent)")
+	flagAllowPrerelease   = flag.Bool("allowPrerelease", false, "Permit updating to prerelease versions (agent)")
+	flagNoUpdateUpToDate  = flag.Bool("noUpdateUpToDate", false, "Treat finding no update to prepare as being up to date rather than an error (agent)")
+	flagAnnotateUpToDate  = flag.Bool("annotateUpToDate", false, "Annotate nodes with the version they're up to date with and when they last checked for an update (agent)")
+	flagPreUpdateCommand  = flag.String("preUpdateCommand", "", "Command run before rebooting into an update, failing the update if it fails (agent)")
+	flagPostUpdateCommand = flag.String("postUpdateCommand", "", "Command run once stabilized after rebooting into an update (agent)")
+	flagHookTimeout       = flag.Duration("hookTimeout", 5*time.Minute, "Longest time -preUpdateCommand and -postUpdateCommand may run (agent)")
+	flagDryRun            = flag.Bool("dryRun", false, "Log the update and reboot actions that would be taken without taking them (agent)")
+	flagCheckIdle         = flag.Bool("checkIdle", false, "Defer preparing an update while the platform is busy with an out of band update command (agent)")
+	flagPollInterval      = flag.Duration("updatePollInterval", 30*time.Minute, "Time between periodic checks for an update (agent)")
+	flagInitialPollDelay  = flag.Duration("initialPollDelay", 0, "Time after starting before the first periodic check for an update, half of -updatePollInterval when zero (agent)")
+	flagRefreshInterval   = flag.Duration("refreshInterval", 0, "Time between refreshes of the available updates, refreshed as updates are checked for when zero (agent)")
+	flagCheckFailures     = flag.Int("checkFailureThreshold", 0, "Consecutive failed update checks after which the failure is posted on the node, disabled when zero (agent)")
+	flagResumeGrace       = flag.Duration("resumeGrace", 0, "Time after starting an action during which a restarted agent resumes the action rather than resetting, disabled when zero (agent)")
+	flagDetectorCommand   = flag.String("updateDetectorCommand", "", "Command printing available versions, one per line, used to detect updates (agent)")
+	flagDetectorURL       = flag.String("updateDetectorURL", "", "URL serving a JSON list of available versions used to detect updates (agent)")
+	flagAPITimeout        = flag.Duration("apiTimeout", 10*time.Second, "Longest time a request to the update API may take (agent)")
+	flagAPIMaxAttempts    = flag.Int("apiMaxAttempts", 5, "Most attempts of a request to the update API while it's busy or unreachable (agent)")
+	flagAPIRetryDelay     = flag.Duration("apiRetryDelay", 2*time.Second, "Time waited before first retrying a request to the update API, doubled for each retry after (agent)")
+	flagAPIRetryDeadline  = flag.Duration("apiRetryDeadline", time.Minute, "Longest time spent retrying a request to the update API (agent)")
+	flagAPISocket         = flag.String("apiSocket", "/run/api.sock", "Path of the update API's unix socket (agent)")
+	flagMinimumOSVersion  = flag.String("minimumOSVersion", "0.4.1", "Lowest Bottlerocket OS version to update through the update API, at least 0.4.1 (agent)")
 )
 
 func main() {
@@ -79,7 +169,75 @@ func main() {
 
 func runController(ctx context.Context, kube kubernetes.Interface, nodeName string) error {
 	log := logging.New("controller")
-	c, err := controller.New(log, kube, nodeName)
+	access := k8sutil.ControllerAccess
+	if *flagLeaderElect {
+		access = append(append([]k8sutil.Access(nil), access...), k8sutil.LeaderElectionAccess...)
+	}
+	checkAccess(log, kube, access)
+	c, err := controller.New(log, kube, nodeName, controller.Config{
+		OrderByLaunchTime: *flagOrderByLaunchTime,
+		IntentCacheTTL:    *flagIntentCacheTTL,
+		ResyncPeriod:      *flagResyncPeriod,
+
+		AutoLabelSelector:         *flagAutoLabelSelector,
+		AutoLabelInterfaceVersion: *flagAutoLabelVersion,
+		ManagedSelector:           *flagManagedSelector,
+		LabelUnmarked:             *flagLabelUnmarked,
+		NodeSelector:              *flagNodeSelector,
+
+		KeepCordonedLabel:     *flagKeepCordonedLabel,
+		DrainGraceSelector:    *flagDrainGraceSel,
+		DrainGracePeriod:      *flagDrainGracePeriod,
+		DoNotDrainAnnotation:  *flagDoNotDrain,
+		DrainFailureAction:    *flagDrainFailure,
+		IncompleteViewAction:  *flagIncompleteView,
+		CheckDrainCapacity:    *flagDrainCapacity,
+		MaxCordoned:           *flagMaxCordoned,
+		VerifyDelay:           *flagVerifyDelay,
+		WorkloadSettleTimeout: *flagWorkloadTimeout,
+		VerifyBootID:          *flagVerifyBootID,
+		HealthCheckTimeout:    *flagHealthTimeout,
+		HealthCheckInterval:   *flagHealthInterval,
+		ReadinessTaints:       splitList(*flagReadinessTaints),
+		ReadinessConditions:   splitList(*flagReadinessConds),
+		SkipHealthCheck:       *flagSkipHealthCheck,
+		ResumeRamp:            *flagResumeRamp,
+		UnknownIntentGrace:    *flagUnknownGrace,
+		BusyTimeout:           *flagBusyTimeout,
+		StartupSettle:         *flagStartupSettle,
+		BatchSize:             *flagBatchSize,
+		BatchPercent:          *flagBatchPercent,
+		BatchQuorum:           *flagBatchQuorum,
+		MinRebootInterval:     *flagMinRebootInterval,
+		MaxUpdateStarts:       *flagMaxUpdateStarts,
+		UpdateStartWindow:     *flagUpdateStartWindow,
+		SeparateAntiAffine:    *flagSeparateAntiAff,
+		ConcurrencyRampStep:   *flagConcurrencyRamp,
+		CanaryRollout:         *flagCanaryRollout,
+		CanaryLabel:           *flagCanaryLabel,
+		CanarySoak:            *flagCanarySoak,
+		RollbackOnFailure:     *flagRollback,
+		MaintenanceWindows:    *flagMaintWindows,
+		MaintenanceTimezone:   *flagMaintTimezone,
+		StatusSinkURL:         *flagStatusSinkURL,
+		StatusInterval:        *flagStatusInterval,
+		NotifyURL:             *flagNotifyURL,
+		SNSTopicARN:           *flagSNSTopicARN,
+		CloudWatchNamespace:   *flagCloudWatchNS,
+		CloudWatchRegion:      *flagCloudWatchRegion,
+		HoldLabel:             *flagHoldLabel,
+		MetricsAddr:           *flagMetricsAddr,
+		HealthAddr:            *flagHealthAddr,
+		TracingEndpoint:       *flagTracingEndpoint,
+		ReportEvents:          *flagReportEvents,
+		EventHistory:          *flagEventHistory,
+		LeaderElection:        *flagLeaderElect,
+		LeaseName:             *flagLeaseName,
+		LeaseNamespace:        *flagLeaseNamespace,
+		LeaseDuration:         *flagLeaseDuration,
+		LeaseRenewDeadline:    *flagLeaseRenew,
+		LeaseRetryPeriod:      *flagLeaseRetry,
+	})
 	if err != nil {
 		return errors.WithMessage(err, "initialization error")
 	}
@@ -88,10 +246,67 @@ func runController(ctx context.Context, kube kubernetes.Interface, nodeName stri
 
 func runAgent(ctx context.Context, kube kubernetes.Interface, nodeName string) error {
 	log := logging.New("agent")
-	a, err := agent.New(log, kube, nodeName)
+	checkAccess(log, kube, k8sutil.AgentAccess)
+	a, err := agent.New(log, kube, nodeName, agent.Config{
+		PinnedVersion:         *flagPinVersion,
+		BlockedVersions:       splitList(*flagBlockVersions),
+		VersionConstraint:     *flagVersionConstraint,
+		AllowPrerelease:       *flagAllowPrerelease,
+		DetectorCommand:       strings.Fields(*flagDetectorCommand),
+		DetectorURL:           *flagDetectorURL,
+		MetricsAddr:           *flagMetricsAddr,
+		HealthAddr:            *flagHealthAddr,
+		TracingEndpoint:       *flagTracingEndpoint,
+		NotifyURL:             *flagNotifyURL,
+		SNSTopicARN:           *flagSNSTopicARN,
+		CloudWatchNamespace:   *flagCloudWatchNS,
+		CloudWatchRegion:      *flagCloudWatchRegion,
+		NoUpdateUpToDate:      *flagNoUpdateUpToDate,
+		AnnotateUpToDate:      *flagAnnotateUpToDate,
+		CheckIdle:             *flagCheckIdle,
+		DryRun:                *flagDryRun,
+		PreUpdateCommand:      strings.Fields(*flagPreUpdateCommand),
+		PostUpdateCommand:     strings.Fields(*flagPostUpdateCommand),
+		HookTimeout:           *flagHookTimeout,
+		UpdatePollInterval:    *flagPollInterval,
+		InitialPollDelay:      *flagInitialPollDelay,
+		RefreshInterval:       *flagRefreshInterval,
+		ResumeGrace:           *flagResumeGrace,
+		CheckFailureThreshold: *flagCheckFailures,
+		ReportEvents:          *flagReportEvents,
+		HoldLabel:             *flagHoldLabel,
+		APITimeout:            *flagAPITimeout,
+		APIMaxAttempts:        *flagAPIMaxAttempts,
+		APIRetryDelay:         *flagAPIRetryDelay,
+		APIRetryDeadline:      *flagAPIRetryDeadline,
+		MinimumOSVersion:      *flagMinimumOSVersion,
+		APISocket:             *flagAPISocket,
+	})
 	if err != nil {
 		return err
 	}
 
 	return errors.WithMessage(a.Run(ctx), "run error")
 }
+
+// checkAccess logs any of the needed access that is missing. Startup continues
+// regardless, the access may be granted later or not be needed at all.
+func checkAccess(log logging.Logger, kube kubernetes.Interface, needed []k8sutil.Access) {
+	err := k8sutil.CheckAccess(kube.AuthorizationV1().SelfSubjectAccessReviews(), needed)
+	if err != nil {
+		log.WithError(err).Error("RBAC self-check failed")
+		return
+	}
+	log.Debug("RBAC self-check passed")
+}
+
+// splitList splits a comma separated list, dropping empty elements.
+func splitList(list string) []string {
+	var elems []string
+	for _, elem := range strings.Split(list, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
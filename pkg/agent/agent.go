@@ -4,37 +4,72 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"syscall"
 	"time"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/cloudwatch"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/health"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent/cache"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/jitter"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/logfields"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/k8sutil"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/api"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/detector"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/updog"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/tracing"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/workgroup"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 const (
-	initialPollDelay   = updatePollInterval / 2
-	updatePollInterval = time.Minute * 30
+	// defaultUpdatePollInterval is the time between update checks when not
+	// configured, the first check is made after half of the poll interval.
+	defaultUpdatePollInterval = time.Minute * 30
+	// pollJitter is the fraction of the poll delay that each check is
+	// randomly moved earlier or later by, spreading the checks of Agents
+	// started together.
+	pollJitter = 0.2
+	// stalePolls is the number of poll intervals the update checks may fail
+	// for before the Agent isn't ready.
+	stalePolls = 3
+
+	// terminateTimeout is the time the Agent is given to stop gracefully once
+	// the host has accepted the command to reboot, it's killed after.
+	terminateTimeout = 30 * time.Second
+	// killAttempts and killRetryDelay bound the attempts made to kill the
+	// Agent when it fails to stop gracefully.
+	killAttempts   = 3
+	killRetryDelay = 5 * time.Second
+
+	// idlePollInterval is the time between checks of a busy platform while
+	// preparing an update is deferred.
+	idlePollInterval = 30 * time.Second
 )
 
 var (
 	errInvalidProgress = errors.New("intended to make invalid progress")
+	errKubeBackoff     = errors.New("backing off from unavailable kubernetes api")
 )
 
+// unknownVersion is reported as the version of a Node whose platform doesn't
+// report its version.
+const unknownVersion = "unknown"
+
 // Agent is a privileged on-host process that acts on communicated Intents from
 // the controller. Its event loop hinges off of a Kubernetes Informer which
 // feeds it metadata and Intent data.
@@ -53,6 +88,89 @@ type Agent struct {
 
 	lastCache cache.LastCache
 	tracker   *postTracker
+	filter    *updateFilter
+	skips     skipLog
+	// errHistory is the Node's recent errors, seeded from the Node during
+	// preflight.
+	errHistory errorHistory
+	clock      clock.Clock
+	metrics    *metrics.Server
+	// health tracks the Agent's informer and update checks, served by the
+	// healthServer when configured.
+	health       *health.Status
+	healthServer *metrics.Server
+	// reporter writes the Node's lifecycle events as structured records, when
+	// configured.
+	reporter *report.Reporter
+	// noUpdateUpToDate treats finding no update to prepare as the Node being up
+	// to date.
+	noUpdateUpToDate bool
+	// annotateUpToDate annotates the Node with the version it's up to date
+	// with and when it last checked for an update.
+	annotateUpToDate bool
+	// checkIdle defers preparing an update while the platform is busy.
+	checkIdle bool
+	// refreshInterval is the time between refreshes of the platform's source
+	// of updates, when it's refreshed separately from listing them.
+	refreshInterval time.Duration
+	// initialPollDelay is the time before the first update check and
+	// pollInterval is the time between the following checks.
+	initialPollDelay time.Duration
+	pollInterval     time.Duration
+	// resumeGrace is how long after starting an action that the action is
+	// resumed, rather than reset, when the Agent is restarted mid-action.
+	resumeGrace time.Duration
+	// checkFailureThreshold is the number of consecutive failed update checks
+	// after which the failure is posted on the Node.
+	checkFailureThreshold int
+	// checkFailures counts the consecutive failed update checks, and
+	// checkFailing is the failure posted on the Node, if any.
+	checkFailures int
+	checkFailing  string
+	// postedPartitions is the partitions' markers last posted on the Node,
+	// which aren't posted again while they're unchanged.
+	postedPartitions marker.Annotations
+	// postedChosenUpdate is the chosen update's markers last posted on the
+	// Node, which aren't posted again while they're unchanged.
+	postedChosenUpdate marker.Annotations
+	// holdLabel is the Node label that holds the Node at its current step.
+	holdLabel string
+	// dryRun skips the platform actions that update and reboot the host.
+	dryRun bool
+	// preUpdate and postUpdate are run around the Node's reboot into its
+	// update, when configured.
+	preUpdate  *hook
+	postUpdate *hook
+	// postUpdatePending is set when the Agent starts after rebooting into an
+	// update, until the post-update hook is run.
+	postUpdatePending bool
+	// targetVersion is the version the Node is directed to update to by its
+	// annotation, the preferred update is used when it's empty.
+	targetVersion string
+	// notifier notifies the Node's failed update actions, when configured,
+	// with the Node's runningVersion as it was last posted.
+	notifier       *notify.Notifier
+	runningVersion string
+	// tracer exports the spans of the Node's actions, when configured. They
+	// join the trace of the Controller's Intent through its traceParent.
+	tracer      *tracing.Tracer
+	traceParent string
+	// cloudWatch publishes the Node's failed update actions to CloudWatch,
+	// when configured.
+	cloudWatch *cloudwatch.Publisher
+	// rollbackVersion is the version the Node is directed to roll back from by
+	// its annotation, it isn't updated to again while set. rolledBack is the
+	// rollback version last handled, each version is rolled back from once.
+	rollbackVersion string
+	rolledBack      string
+	// ctx is the context of the running Agent, it's used to handle events
+	// received from outside of its workers and is canceled as it stops.
+	ctx context.Context
+	// presence tracks whether the Node resource exists.
+	presence *nodePresence
+	// kubeBackoff tracks the availability of the Kubernetes API for periodic
+	// update checks.
+	kubeBackoff kubeBackoff
 
 	progress progression
 }
@@ -61,16 +179,24 @@ type Agent struct {
 // the appropriate resource.
 type poster interface {
 	Post(*intent.Intent) error
+	// PostMarkers writes out informational markers, which are not part of an
+	// Intent, for the named resource.
+	PostMarkers(nodeName string, markers marker.Container) error
 }
 
 // proc interposes the self-terminate kill signaling allowing for an Agent to
 // terminate itself from the outside. Signals are trapped and handled elsewhere
 // within the application.
 type proc interface {
+	// TerminateProcess asks the process to stop gracefully, canceling the
+	// running Agent's context so that its workers finish.
+	TerminateProcess() error
+	// KillProcess kills the process without cleaning up, it's the last
+	// resort should the process fail to stop gracefully.
 	KillProcess() error
 }
 
-func New(log logging.Logger, kube kubernetes.Interface, nodeName string) (*Agent, error) {
+func New(log logging.Logger, kube kubernetes.Interface, nodeName string, config Config) (*Agent, error) {
 	if nodeName == "" {
 		return nil, errors.New("nodeName must be provided for Agent to manage")
 	}
@@ -96,21 +222,116 @@ func New(log logging.Logger, kube kubernetes.Interface, nodeName string) (*Agent
 			return nil, errors.WithMessage(err, "could not setup Updog platform for agent")
 		}
 	case "2.0.0":
-		platform, err = api.New()
+		platform, err = api.New(config.apiConfig())
 		if err != nil {
 			return nil, errors.WithMessage(err, "could not setup Update API platform for agent")
 		}
 	}
 
+	updateDetector, err := config.detector()
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid update detector")
+	}
+	if updateDetector != nil {
+		log.Info("using custom detector for available updates")
+		platform = detector.New(log.WithField("worker", "detector"), platform, updateDetector)
+	}
+
+	var metricsServer *metrics.Server
+	if config.MetricsAddr != "" {
+		metricsServer = metrics.NewServer(log.WithField("worker", "metrics"), config.MetricsAddr)
+		metricsServer.Handle(StatusPath, &statusHandler{platform: platform})
+	}
+
+	a, err := newAgent(log, nodeName, platform, &k8sPoster{log, nodeclient}, &osProc{}, clock.RealClock{}, config)
+	if err != nil {
+		return nil, err
+	}
+	a.kube = kube
+	a.metrics = metricsServer
+	sinks, err := config.notifySinks()
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid notification sink")
+	}
+	a.notifier = notify.New(log.WithField("worker", "notifier"), "agent", sinks...)
+	a.tracer, err = tracing.NewTracer(log.WithField("worker", "tracing"), "agent", config.TracingEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	a.cloudWatch, err = cloudwatch.New(log.WithField("worker", "cloudwatch"), config.CloudWatchNamespace, config.CloudWatchRegion)
+	if err != nil {
+		return nil, err
+	}
+	if config.HealthAddr != "" {
+		a.health = health.NewStatus(clock.RealClock{})
+		a.healthServer = metrics.NewHandlerServer(log.WithField("worker", "health"), config.HealthAddr, "health")
+		a.healthServer.Handle(health.LivePath, a.health.LiveHandler())
+		a.healthServer.Handle(health.ReadyPath, a.health.ReadyHandler())
+	}
+	if config.ReportEvents {
+		a.reporter = report.New(os.Stdout, "agent")
+	}
+	return a, nil
+}
+
+// newAgent constructs an Agent for the named Node that acts through the given
+// platform, poster, and proc. The Agent's Kubernetes client and metrics server
+// are left to the caller to provide.
+func newAgent(log logging.Logger, nodeName string, plat platform.Platform, posts poster, procs proc, clk clock.Clock, config Config) (*Agent, error) {
+	filter, err := newUpdateFilter(config)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid update filter")
+	}
+
+	pollInterval, err := config.pollInterval()
+	if err != nil {
+		return nil, err
+	}
+	initialPollDelay, err := config.initialPollDelay()
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(logrus.Fields{
+		"initial-delay": initialPollDelay,
+		"interval":      pollInterval,
+	}).Info("checking for updates periodically")
+
+	var refreshInterval time.Duration
+	if config.RefreshInterval > 0 {
+		if r, ok := plat.(platform.Refresher); ok {
+			r.RefreshSeparately()
+			refreshInterval = config.RefreshInterval
+		} else {
+			log.Warn("platform doesn't support a separate refresh interval, refreshing as updates are listed")
+		}
+	}
+
 	return &Agent{
 		log:       log,
-		kube:      kube,
-		platform:  platform,
-		poster:    &k8sPoster{log, nodeclient},
-		proc:      &osProc{},
+		platform:  plat,
+		poster:    posts,
+		proc:      procs,
 		nodeName:  nodeName,
 		lastCache: cache.NewLastCache(),
 		tracker:   newPostTracker(),
+		presence:  newNodePresence(),
+		ctx:       context.Background(),
+		filter:    filter,
+		clock:     clk,
+
+		noUpdateUpToDate: config.NoUpdateUpToDate,
+		annotateUpToDate: config.AnnotateUpToDate,
+		checkIdle:        config.CheckIdle,
+		refreshInterval:  refreshInterval,
+		initialPollDelay: initialPollDelay,
+		pollInterval:     pollInterval,
+		resumeGrace:      config.ResumeGrace,
+		holdLabel:        config.holdLabel(),
+		dryRun:           config.DryRun,
+		preUpdate:        newHook("pre-update", config.PreUpdateCommand, config.HookTimeout),
+		postUpdate:       newHook("post-update", config.PostUpdateCommand, config.HookTimeout),
+
+		checkFailureThreshold: config.CheckFailureThreshold,
 	}, nil
 }
 
@@ -130,82 +351,242 @@ func (a *Agent) Run(ctx context.Context) error {
 	}
 	a.log.Debug("starting")
 	defer a.log.Debug("finished")
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	a.ctx = ctx
 	group := workgroup.WithContext(ctx)
 
 	ns := nodestream.New(a.log.WithField("worker", "informer"), a.kube, nodestream.Config{
 		NodeName: a.nodeName,
 	}, a.handler())
 
-	err := a.checkNodePreflight()
+	err := a.checkNodePreflight(ctx)
 	if err != nil {
 		return err
 	}
 
-	group.Work(ns.Run)
-	group.Work(a.periodicUpdateChecker)
-
-	<-ctx.Done()
+	a.health.Synced("informer", ns.GetInformer().HasSynced)
+	group.Work(a.health.Work("informer", 0, ns.Run))
+	// The update checks are expected to succeed within a few polls.
+	group.Work(a.health.Work("update-checker", a.initialPollDelay+stalePolls*a.pollInterval, a.periodicUpdateChecker))
+	if a.refreshInterval > 0 {
+		group.Work(a.periodicRefresher)
+	}
+	if a.metrics != nil {
+		group.Work(a.metrics.Run)
+	}
+	if a.healthServer != nil {
+		group.Work(a.healthServer.Run)
+	}
+	group.Work(a.tracer.Run)
+	group.Work(a.notifier.Run)
+	group.Work(a.cloudWatch.Run)
+
+	select {
+	case <-ctx.Done():
+	case <-a.presence.Gone():
+		// The Node is gone, there's nothing left for the Agent to do.
+		stop()
+	}
 	a.log.Info("waiting on workers to finish")
 	return group.Wait()
 }
 
+// randJitterFunc returns a random fraction in [0, 1) used to jitter the update
+// checks.
+var randJitterFunc = jitter.Float64
+
+// jitterPoll randomly moves the delay earlier or later by up to pollJitter of
+// the delay, keeping the average delay unchanged.
+func jitterPoll(delay time.Duration) time.Duration {
+	return jitter.Delay(delay, pollJitter, randJitterFunc())
+}
+
 // periodicUpdateChecker regularly checks for available updates and posts this
 // status on the Node resource.
 func (a *Agent) periodicUpdateChecker(ctx context.Context) error {
-	timer := time.NewTimer(initialPollDelay)
-	defer timer.Stop()
-
 	log := a.log.WithField("worker", "update-checker")
 
+	delay := a.initialPollDelay
 	for {
+		timer := a.clock.NewTimer(jitterPoll(delay))
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			log.Debug("finished")
 			return nil
-		case <-timer.C:
+		case <-timer.C():
 			log.Info("checking for update")
-			err := a.checkPostUpdate(a.log)
+			err := a.checkPostUpdate(ctx, a.log)
 			if err != nil {
+				a.health.Failed("update-checker", err)
+			} else {
+				a.health.Succeeded("update-checker")
+			}
+			if err != nil && a.kubeBackoff.Unavailable() {
+				// The unavailable API was already reported.
+				log.WithError(err).Debug("update check failed")
+			} else if err != nil {
 				log.WithError(err).Error("update check failed")
 			}
 		}
 
-		timer.Reset(updatePollInterval)
+		delay = a.pollInterval
+	}
+}
+
+// periodicRefresher refreshes the platform's source of updates every refresh
+// interval, independently of the update checks made against it.
+func (a *Agent) periodicRefresher(ctx context.Context) error {
+	log := a.log.WithField("worker", "refresher")
+	refresher, ok := a.platform.(platform.Refresher)
+	if !ok {
+		return errors.New("platform does not support refreshing separately")
+	}
+
+	for {
+		log.Debug("refreshing updates")
+		if err := refresher.Refresh(ctx); err != nil {
+			log.WithError(err).Error("refresh failed")
+		}
+
+		timer := a.clock.NewTimer(a.refreshInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Debug("finished")
+			return nil
+		case <-timer.C():
+		}
 	}
 }
 
 // checkUpdate queries for available updates.
-func (a *Agent) checkUpdate() (bool, error) {
-	available, err := a.platform.ListAvailable()
+func (a *Agent) checkUpdate(ctx context.Context) (bool, error) {
+	ups, err := a.availableUpdates(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	if len(available.Updates()) > 0 {
+	if len(ups) > 0 {
 		return true, nil
 	}
 
 	return false, nil
 }
 
+// availableUpdates lists the platform's available updates that are permitted
+// by the Agent's update filter. When the filter restricts the versions
+// updated to, the highest permitted version is preferred. Otherwise only the
+// platform's preferred update is listed, unless the Node is directed to a
+// target version, so that the platform's choice of update is respected.
+// An update rolled back from is passed over for the next preferred.
+func (a *Agent) availableUpdates(ctx context.Context) ([]platform.Update, error) {
+	available, err := a.platform.ListAvailable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var permitted []platform.Update
+	skipped := map[string]skipReason{}
+	for _, up := range available.Updates() {
+		reason := a.filter.skipReason(up)
+		if reason == skipNone && rolledBackUpdate(up, a.rollbackVersion) {
+			reason = skipRolledBack
+		}
+		if reason != skipNone {
+			skipped[fmt.Sprint(up.Identifier())] = reason
+			continue
+		}
+		permitted = append(permitted, up)
+	}
+	// The same updates are skipped on every check, they're logged as they
+	// change.
+	for _, id := range a.skips.Changed(skipped) {
+		a.log.WithFields(logrus.Fields{
+			"update": id,
+			"reason": string(skipped[id]),
+		}).Infof("skipping update: %s", skipped[id])
+	}
+	switch {
+	case a.filter.configured():
+		sortByVersion(permitted)
+	case a.targetVersion == "" && len(permitted) > 1:
+		permitted = permitted[:1]
+	}
+	return permitted, nil
+}
+
 // checkPostUpdate checks for and posts the status of an available update.
-func (a *Agent) checkPostUpdate(log logging.Logger) error {
-	hasUpdate, err := a.checkUpdate()
+func (a *Agent) checkPostUpdate(ctx context.Context, log logging.Logger) error {
+	ups, err := a.availableUpdates(ctx)
+	if perr := a.observeCheck(err); perr != nil {
+		log.WithError(perr).Warn("unable to post update check failure")
+	}
 	if err != nil {
 		return err
 	}
 
-	if err = a.postUpdateAvailable(hasUpdate); err != nil {
-		log.WithError(err).Error("post failed")
+	if err = a.postUpdateAvailable(ctx, len(ups) > 0); err != nil {
+		if !a.kubeBackoff.Unavailable() {
+			log.WithError(err).Error("post failed")
+		}
+		return err
+	}
+
+	if err = a.postPartitions(ctx); err != nil {
+		log.WithError(err).Error("partitions post failed")
+		return err
+	}
+
+	if err = a.postChosenUpdate(ups); err != nil {
+		log.WithError(err).Error("chosen update post failed")
 		return err
 	}
 
 	return nil
 }
 
+// observeCheck counts the consecutive failed update checks, posting the
+// failure on the Node once the count reaches the threshold and clearing it once
+// a check succeeds.
+func (a *Agent) observeCheck(checkErr error) error {
+	if checkErr == nil {
+		a.checkFailures = 0
+		metrics.UpdateCheckFailures.Set(0)
+		if a.checkFailing == "" {
+			return nil
+		}
+		a.log.Info("update checks recovered")
+		return a.postCheckFailing("")
+	}
+	a.checkFailures++
+	metrics.UpdateCheckFailures.Set(float64(a.checkFailures))
+	if a.checkFailureThreshold <= 0 || a.checkFailures < a.checkFailureThreshold {
+		return nil
+	}
+	failing := checkErr.Error()
+	if failing == a.checkFailing {
+		return nil
+	}
+	a.log.WithError(checkErr).WithField("failures", a.checkFailures).Error("update checks failing repeatedly")
+	return a.postCheckFailing(failing)
+}
+
+// postCheckFailing posts the update check failure on the Node, clearing it
+// when empty.
+func (a *Agent) postCheckFailing(failing string) error {
+	err := a.poster.PostMarkers(a.nodeName, marker.Annotations{marker.UpdateCheckFailingKey: failing})
+	if err != nil {
+		return err
+	}
+	a.checkFailing = failing
+	return nil
+}
+
 // postUpdateAvailable posts the available update status to the Kubernetes Node
 // resource.
-func (a *Agent) postUpdateAvailable(available bool) error {
+func (a *Agent) postUpdateAvailable(ctx context.Context, available bool) error {
 	// TODO: handle brief race condition internally - this needs to be improved,
 	// though the kubernetes control plane will reject out of order updates by
 	// way of resource versioning C-A-S operations.
@@ -214,26 +595,197 @@ func (a *Agent) postUpdateAvailable(available bool) error {
 		return errors.New("kubernetes client is required to fetch node resource")
 	}
 
+	if !a.kubeBackoff.Ready(a.clock.Now()) {
+		return errKubeBackoff
+	}
 	node, err := a.kube.CoreV1().Nodes().Get(a.nodeName, v1meta.GetOptions{})
 	if err != nil {
+		delay, unavailable := a.kubeBackoff.Failed(a.clock.Now())
+		if unavailable {
+			a.log.WithError(err).WithField("retry-delay", delay).
+				Warn("kubernetes api unavailable, backing off until it recovers")
+		}
 		return errors.WithMessage(err, "unable to get node")
 	}
-	in := intent.Given(node).SetUpdateAvailable(available)
+	if a.kubeBackoff.Succeeded() {
+		a.log.Info("kubernetes api available again")
+	}
+	in := intent.Given(node)
+	posted := in.UpdateAvailable
+	in.SetUpdateAvailable(available)
+	if in.UpdateAvailable == posted {
+		a.log.WithField("update-available", posted).Debug("update availability unchanged, skipping post")
+	} else {
+		// Use poster to skip recording posted intent.
+		err = a.poster.Post(in)
+		if err != nil {
+			return fmt.Errorf("failed to post: %w", err)
+		}
+		if in.HasUpdateAvailable() {
+			a.reporter.Report(report.UpdateAvailable, in, nil)
+		}
+	}
+	// The condition is a convenience for tooling, the controller relies on the
+	// annotation alone.
+	if err := a.postUpdateCondition(node, available); err != nil {
+		a.log.WithError(err).Warn("unable to post update available condition")
+	}
 
-	// Use poster to skip recording posted intent.
-	err = a.poster.Post(in)
+	if a.annotateUpToDate {
+		return a.postUpToDate(ctx, available)
+	}
+	return nil
+}
+
+// postUpToDate annotates the Node with the version it's up to date with, when
+// no update is available, and the time it last checked for an update. This
+// distinguishes an up to date Node from one that isn't checking at all.
+func (a *Agent) postUpToDate(ctx context.Context, available bool) error {
+	annos := marker.Annotations{
+		marker.UpToDateKey:      "",
+		marker.UpdateCheckedKey: a.clock.Now().UTC().Format(time.RFC3339),
+	}
+	if !available {
+		version, err := a.activeVersion(ctx)
+		if err != nil {
+			return err
+		}
+		annos[marker.UpToDateKey] = version
+	}
+	return a.poster.PostMarkers(a.nodeName, annos)
+}
+
+// activeVersion is the version of the Node's active partition.
+func (a *Agent) activeVersion(ctx context.Context) (string, error) {
+	ps, err := partitions(ctx, a.platform)
 	if err != nil {
-		return fmt.Errorf("failed to post: %w", err)
+		return "", err
 	}
+	if ps != nil {
+		if active := ps.ActivePartition(); active != nil && active.Version != "" {
+			return active.Version, nil
+		}
+	}
+	return unknownVersion, nil
+}
 
+// postPartitions posts the platform's partitions to the Kubernetes Node
+// resource when they've changed since last posted. Platforms that don't report
+// on their partitions are skipped.
+func (a *Agent) postPartitions(ctx context.Context) error {
+	ps, err := partitions(ctx, a.platform)
+	if err != nil {
+		return err
+	}
+	if ps == nil {
+		return nil
+	}
+	annos := partitionMarkers(ps)
+	if sameMarkers(annos, a.postedPartitions) {
+		a.log.Debug("partitions unchanged, skipping post")
+		return nil
+	}
+	if err := a.poster.PostMarkers(a.nodeName, annos); err != nil {
+		return err
+	}
+	a.postedPartitions = annos
 	return nil
 }
 
+// partitions reports the platform's partitions, nil when it's unable to report
+// them.
+func partitions(ctx context.Context, p platform.Platform) (platform.PartitionStatus, error) {
+	pr, ok := p.(platform.PartitionReporter)
+	if !ok {
+		return nil, nil
+	}
+	ps, err := pr.Partitions(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to get platform partitions")
+	}
+	return ps, nil
+}
+
+// sameMarkers reports whether the markers hold the same annotations.
+func sameMarkers(a, b marker.Annotations) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// partitionMarkers describes the reported partitions as annotations. Each of
+// the partition annotations is set, cleared if unreported, to avoid leaving
+// stale values behind.
+func partitionMarkers(ps platform.PartitionStatus) marker.Annotations {
+	annos := marker.Annotations{
+		marker.ActivePartitionKey:  "",
+		marker.StagingPartitionKey: "",
+		marker.NextToBootKey:       "",
+	}
+	if active := ps.ActivePartition(); active != nil {
+		annos[marker.ActivePartitionKey] = active.Version
+		if active.NextToBoot {
+			annos[marker.NextToBootKey] = marker.NodePartitionActive
+		}
+	}
+	if staging := ps.StagingPartition(); staging != nil {
+		annos[marker.StagingPartitionKey] = staging.Version
+		if staging.NextToBoot {
+			annos[marker.NextToBootKey] = marker.NodePartitionStaging
+		}
+	}
+	return annos
+}
+
+// postChosenUpdate posts the chosen update's markers to the Kubernetes Node
+// resource when they've changed since last posted.
+func (a *Agent) postChosenUpdate(ups []platform.Update) error {
+	annos := chosenUpdateMarkers(ups)
+	if sameMarkers(annos, a.postedChosenUpdate) {
+		a.log.Debug("chosen update unchanged, skipping post")
+		return nil
+	}
+	if err := a.poster.PostMarkers(a.nodeName, annos); err != nil {
+		return err
+	}
+	a.postedChosenUpdate = annos
+	return nil
+}
+
+// chosenUpdateMarkers describes the image of the update the Agent would update
+// to, the first of the available updates, as annotations. Each of the
+// annotations is set, cleared if unknown, to avoid leaving stale values behind.
+func chosenUpdateMarkers(ups []platform.Update) marker.Annotations {
+	annos := marker.Annotations{
+		marker.ChosenUpdateVersionKey: "",
+		marker.ChosenUpdateArchKey:    "",
+		marker.ChosenUpdateVariantKey: "",
+	}
+	if len(ups) == 0 {
+		return annos
+	}
+	if vu, ok := ups[0].(platform.VersionedUpdate); ok {
+		annos[marker.ChosenUpdateVersionKey] = vu.TargetVersion()
+	}
+	if iu, ok := ups[0].(platform.ImageUpdate); ok {
+		annos[marker.ChosenUpdateArchKey] = iu.TargetArch()
+		annos[marker.ChosenUpdateVariantKey] = iu.TargetVariant()
+	}
+	return annos
+}
+
 // handler is the entrypoint for the Kubernetes Informer to schedule handling of
 // events for the Node to act on.
 func (a *Agent) handler() nodestream.Handler {
 	return &nodestream.HandlerFuncs{
 		OnAddFunc: func(n *v1.Node) {
+			a.handleAdd()
 			a.handleEvent(n)
 		},
 		// we don't mind the diff between old and new, so handle the new
@@ -242,7 +794,7 @@ func (a *Agent) handler() nodestream.Handler {
 			a.handleEvent(n)
 		},
 		OnDeleteFunc: func(_ *v1.Node) {
-			panic("we were deleted, panic. everyone panic. 😱")
+			a.handleDelete()
 		},
 	}
 }
@@ -254,6 +806,30 @@ func (a *Agent) handleEvent(node intent.Input) {
 
 	log := a.log.WithFields(logfields.Intent(in))
 
+	if _, held := node.GetLabels()[a.holdLabel]; held {
+		log.WithField("label", a.holdLabel).Info("node is held, not advancing")
+		return
+	}
+
+	if a.presence.IsDeleted() {
+		log.Debug("node resource is deleted, not acting on intent")
+		return
+	}
+
+	a.targetVersion = node.GetAnnotations()[marker.TargetVersionKey]
+	a.traceParent = node.GetAnnotations()[marker.TraceParentKey]
+	a.runningVersion = node.GetAnnotations()[marker.ActivePartitionKey]
+	a.rollbackVersion = node.GetAnnotations()[marker.RollbackKey]
+	if a.rollbackVersion != "" && a.rollbackVersion != a.rolledBack {
+		rebooting, err := a.rollback(a.ctx, log)
+		if err != nil {
+			log.WithError(err).Error("unable to roll back")
+		}
+		if rebooting {
+			return
+		}
+	}
+
 	if a.skipIntentEvent(in) {
 		return
 	}
@@ -261,7 +837,7 @@ func (a *Agent) handleEvent(node intent.Input) {
 	if activeIntent(in) {
 		a.lastCache.Record(in)
 		log.Debug("active intent received")
-		if err := a.realize(in); err != nil {
+		if err := a.realize(a.ctx, in); err != nil {
 			log.WithError(err).Error("unable to realize intent")
 		}
 		return
@@ -276,6 +852,7 @@ func (a *Agent) skipIntentEvent(in *intent.Intent) bool {
 		return true
 	}
 	if intent.Equivalent(a.lastCache.Last(in), in) {
+		metrics.AgentDuplicateIntents.Inc()
 		log.Debug("skipping duplicate received event")
 		return true
 	}
@@ -297,7 +874,7 @@ func activeIntent(i *intent.Intent) bool {
 }
 
 // realize acts on an Intent to achieve, or realize, the Intent's intent.
-func (a *Agent) realize(in *intent.Intent) error {
+func (a *Agent) realize(ctx context.Context, in *intent.Intent) (err error) {
 	log := a.log.WithFields(logrus.Fields{
 		"worker": "handler",
 		"intent": in.DisplayString(),
@@ -305,10 +882,25 @@ func (a *Agent) realize(in *intent.Intent) error {
 
 	log.Debug("handling intent")
 
-	var err error
+	span := a.tracer.Start("agent.realize", tracing.ParseTraceparent(a.traceParent))
+	span.SetAttribute("k8s.node.name", a.nodeName)
+	span.SetAttribute("intent.wanted", in.Wanted)
+	defer func() { span.End(err) }()
 
 	// TODO: Run a quick check of the Nodes posted progress before proceeding
 
+	if in.Wanted == marker.NodeActionPrepareUpdate && a.checkIdle {
+		if err := a.waitIdle(ctx, log); err != nil {
+			// The intent is left unacknowledged and forgotten, so that it's
+			// handled again once it's next received rather than skipped as
+			// a duplicate.
+			a.lastCache.Forget(in)
+			return err
+		}
+	}
+
+	priorAvailable := in.UpdateAvailable
+
 	// ACK the wanted action.
 	in.Active = in.Wanted
 	in.State = marker.NodeStateBusy
@@ -316,6 +908,11 @@ func (a *Agent) realize(in *intent.Intent) error {
 	if err != nil {
 		return err
 	}
+	if a.resumeGrace > 0 {
+		if err := a.postActionStarted(); err != nil {
+			log.WithError(err).Warn("could not record action start")
+		}
+	}
 
 	// TODO: Propagate status from realization and periodically
 	switch in.Wanted {
@@ -323,18 +920,36 @@ func (a *Agent) realize(in *intent.Intent) error {
 		a.progress.Reset()
 
 	case marker.NodeActionPrepareUpdate:
-		var ups platform.Available
-		ups, err = a.platform.ListAvailable()
+		var ups []platform.Update
+		ups, err = a.availableUpdates(ctx)
 		if err != nil {
 			break
 		}
-		if len(ups.Updates()) == 0 {
+		if len(ups) == 0 {
+			if a.noUpdateUpToDate {
+				log.Info("no update to prepare, node is up to date")
+				return a.finishUpToDate(in)
+			}
 			err = errInvalidProgress
 			break
 		}
-		a.progress.SetTarget(ups.Updates()[0])
+		target := ups[0]
+		if a.targetVersion != "" {
+			target, err = selectTargetVersion(ups, a.targetVersion)
+			if err != nil {
+				break
+			}
+			log.WithField("version", a.targetVersion).Info("updating to target version")
+		}
+		a.progress.SetTarget(target)
 		log.Debug("preparing update")
-		err = a.platform.Prepare(a.progress.GetTarget())
+		if a.dryRun {
+			a.logDryRun(log, "prepare")
+			break
+		}
+		step := span.Child("agent.prepare")
+		err = a.platform.Prepare(ctx, a.progress.GetTarget())
+		step.End(err)
 
 	case marker.NodeActionPerformUpdate:
 		if !a.progress.Valid() {
@@ -342,15 +957,22 @@ func (a *Agent) realize(in *intent.Intent) error {
 			break
 		}
 		log.Debug("updating")
-		err = a.platform.Update(a.progress.GetTarget())
+		if a.dryRun {
+			a.logDryRun(log, "update")
+			break
+		}
+		step := span.Child("agent.update")
+		err = a.platform.Update(ctx, a.progress.GetTarget())
+		step.End(err)
 
 	case marker.NodeActionUnknown, marker.NodeActionStabilize:
+		a.runPostUpdate(log)
 		log.Debug("sitrep")
-		err = platform.Ping(a.platform)
+		err = platform.Ping(ctx, a.platform)
 		if err != nil {
 			break
 		}
-		hasUpdate, err := a.checkUpdate()
+		hasUpdate, err := a.checkUpdate(ctx)
 		if err != nil {
 			log.WithError(err).Error("update check failed")
 		}
@@ -362,24 +984,48 @@ func (a *Agent) realize(in *intent.Intent) error {
 			break
 		}
 		log.Debug("rebooting")
+		if a.dryRun {
+			// The Node is reported ready as if it came back from the reboot
+			// so that the controller completes the update.
+			a.logDryRun(log, "reboot")
+			break
+		}
+		if err = a.preUpdate.Run(log); err != nil {
+			err = errors.WithMessage(err, "not rebooting")
+			break
+		}
 		log.Info("Rebooting Node to complete update")
 		// TODO: ensure Node is setup to be validated on boot (ie: kubelet will
 		// run agent again before we let other Pods get scheduled)
-		err = a.platform.BootUpdate(a.progress.GetTarget(), true)
-		// Shortcircuit to terminate.
-
-		// TODO: actually handle shutdown.
+		step := span.Child("agent.boot-update")
+		err = a.platform.BootUpdate(ctx, a.progress.GetTarget(), true)
+		step.End(err)
 		if err == nil {
-			if a.proc != nil {
-				defer a.proc.KillProcess()
+			// The reboot was accepted and the host is going down, the
+			// Node's progress is picked up again once it's back.
+			a.reporter.Report(report.Rebooted, in, nil)
+			// The spans are exported before the host goes down with them.
+			span.End(nil)
+			if flushErr := a.tracer.Flush(ctx); flushErr != nil {
+				log.WithError(flushErr).Warn("unable to export spans before rebooting")
 			}
-			return err
+			a.terminate(log)
+			return nil
 		}
+		err = errors.WithMessage(err, "reboot command failed")
 	}
 
 	if err != nil {
 		log.WithError(err).Error("could not realize intent")
 		in.State = marker.NodeStateError
+		if histErr := a.recordError(in.Wanted, err); histErr != nil {
+			log.WithError(histErr).Warn("could not post error history")
+		}
+		a.reporter.Report(report.Failure, in, err)
+		a.notifyFailure(in, err)
+		if isUpdateAction(in.Wanted) {
+			a.cloudWatch.Add(cloudwatch.UpdateActionFailures, 1)
+		}
 	} else {
 		log.Debug("realized intent")
 		in.State = marker.NodeStateReady
@@ -390,9 +1036,197 @@ func (a *Agent) realize(in *intent.Intent) error {
 		log.WithError(postErr).Error("could not update intent")
 	}
 
+	// Progress may have changed the partitions' contents, report them as they
+	// are now.
+	if err == nil {
+		a.reportRealized(in, priorAvailable)
+		if partErr := a.postPartitions(ctx); partErr != nil {
+			log.WithError(partErr).Warn("could not post partitions")
+		}
+	}
+
 	return err
 }
 
+// rollback boots the Node back into the image it ran before updating to the
+// rollback version, provided it's still running that version. The return
+// indicates whether the host is rebooting. A failed rollback isn't retried
+// until the Agent restarts.
+func (a *Agent) rollback(ctx context.Context, log logging.Logger) (bool, error) {
+	version := a.rollbackVersion
+	a.rolledBack = version
+	log = log.WithField("version", version)
+	active, err := a.activeVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	// Platforms that don't report their version can't tell whether they've
+	// already rolled back, they're left in place.
+	if !platform.SameVersion(active, version) {
+		log.WithField("active-version", active).Debug("not running the rolled back version")
+		return false, nil
+	}
+	if a.dryRun {
+		log.Info("dry run, skipping rollback")
+		return false, nil
+	}
+	log.Warn("rolling back failed update, rebooting into prior image")
+	if err := a.platform.Rollback(ctx); err != nil {
+		return false, errors.WithMessage(err, "rollback command failed")
+	}
+	a.terminate(log)
+	return true, nil
+}
+
+// reportRealized reports the lifecycle events of the realized Intent, given the
+// Node's update availability before it was realized.
+func (a *Agent) reportRealized(in *intent.Intent, priorAvailable marker.NodeUpdate) {
+	switch in.Wanted {
+	case marker.NodeActionPrepareUpdate:
+		a.reporter.Report(report.Prepared, in, nil)
+	case marker.NodeActionUnknown, marker.NodeActionStabilize:
+		if in.HasUpdateAvailable() && priorAvailable != marker.NodeUpdateAvailable {
+			a.reporter.Report(report.UpdateAvailable, in, nil)
+		}
+	}
+}
+
+// isUpdateAction is true for the actions that are part of an update.
+func isUpdateAction(action marker.NodeAction) bool {
+	switch action {
+	case marker.NodeActionPrepareUpdate, marker.NodeActionPerformUpdate, marker.NodeActionRebootUpdate:
+		return true
+	}
+	return false
+}
+
+// notifyFailure notifies the failure of the Node's update action, failures of
+// other actions aren't part of an update.
+func (a *Agent) notifyFailure(in *intent.Intent, err error) {
+	if !isUpdateAction(in.Wanted) {
+		return
+	}
+	note := notify.Notification{
+		Event:       report.Failure,
+		Node:        a.nodeName,
+		FromVersion: a.runningVersion,
+		Error:       err.Error(),
+	}
+	if up, ok := a.progress.GetTarget().(platform.VersionedUpdate); ok {
+		note.ToVersion = up.TargetVersion()
+	}
+	a.notifier.Notify(note)
+}
+
+// runPostUpdate runs the post-update hook once the Node is stabilized after
+// rebooting into its update.
+func (a *Agent) runPostUpdate(log logging.Logger) {
+	if !a.postUpdatePending {
+		return
+	}
+	a.postUpdatePending = false
+	if err := a.postUpdate.Run(log); err != nil {
+		log.WithError(err).Error("post-update hook failed")
+	}
+}
+
+// logDryRun logs the platform action skipped as a dry run.
+func (a *Agent) logDryRun(log logging.Logger, action string) {
+	log = log.WithField("action", action)
+	if up, ok := a.progress.GetTarget().(platform.VersionedUpdate); ok {
+		log = log.WithField("version", up.TargetVersion())
+	}
+	log.Info("dry run, skipping platform action")
+}
+
+// terminate stops the Agent after the host accepted the command to reboot. The
+// Agent is asked to stop gracefully, its Intent having already been posted,
+// and is killed should it not stop within the terminate timeout. The host is
+// rebooting regardless, so failing to stop isn't an error of the update.
+func (a *Agent) terminate(log logging.Logger) {
+	if a.proc == nil {
+		return
+	}
+	if err := a.proc.TerminateProcess(); err != nil {
+		log.WithError(err).Warn("reboot accepted, unable to stop agent gracefully")
+	} else {
+		select {
+		case <-a.ctx.Done():
+			log.Info("reboot accepted, agent stopping")
+			return
+		case <-a.clock.After(terminateTimeout):
+			log.WithField("timeout", terminateTimeout).Warn("reboot accepted, agent did not stop gracefully")
+		}
+	}
+	for attempt := 1; attempt <= killAttempts; attempt++ {
+		err := a.proc.KillProcess()
+		if err == nil {
+			return
+		}
+		log.WithError(err).WithField("attempt", attempt).Warn("reboot accepted, unable to stop agent")
+		if attempt < killAttempts {
+			a.clock.Sleep(killRetryDelay)
+		}
+	}
+	log.Error("reboot accepted, agent still running while waiting on host to reboot")
+}
+
+// platformIdle reports whether the platform is free to begin an update,
+// platforms unable to report that are assumed to be.
+func (a *Agent) platformIdle(ctx context.Context) (bool, error) {
+	checker, ok := a.platform.(platform.IdleChecker)
+	if !ok {
+		return true, nil
+	}
+	return checker.Idle(ctx)
+}
+
+// waitIdle defers preparing an update while the platform is busy, checking it
+// again every idle poll interval until it's idle.
+func (a *Agent) waitIdle(ctx context.Context, log logging.Logger) error {
+	for {
+		idle, err := a.platformIdle(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "unable to check platform is idle")
+		}
+		if idle {
+			return nil
+		}
+		log.WithField("retry-in", idlePollInterval).Info("platform is busy with an update command, deferring prepare")
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "stopped waiting for platform to be idle")
+		case <-a.clock.After(idlePollInterval):
+		}
+	}
+}
+
+// finishUpToDate completes the Intent's update early as the Node is already up
+// to date, returning it to its stabilized state.
+func (a *Agent) finishUpToDate(in *intent.Intent) error {
+	a.progress.Reset()
+	in.Wanted = marker.NodeActionStabilize
+	in.Active = marker.NodeActionStabilize
+	in.State = marker.NodeStateReady
+	in.SetUpdateAvailable(false)
+	return a.postIntent(in)
+}
+
+// recordError adds the error to the Node's error history and posts the
+// updated history.
+func (a *Agent) recordError(action marker.NodeAction, err error) error {
+	a.errHistory = a.errHistory.append(errorRecord{
+		Time:   a.clock.Now().UTC(),
+		Action: action,
+		Error:  err.Error(),
+	})
+	markers, err := a.errHistory.markers()
+	if err != nil {
+		return err
+	}
+	return a.poster.PostMarkers(a.nodeName, markers)
+}
+
 func (a *Agent) postIntent(in *intent.Intent) error {
 	err := a.poster.Post(in)
 	if err != nil {
@@ -405,7 +1239,7 @@ func (a *Agent) postIntent(in *intent.Intent) error {
 
 // checkNodePreflight runs checks against the current Node resource and prepares
 // it for use by the Agent and Controller.
-func (a *Agent) checkNodePreflight() error {
+func (a *Agent) checkNodePreflight(ctx context.Context) error {
 	// TODO: Run a check of the Node Resource and reset appropriately
 
 	// TODO: Inform controller for taint removal
@@ -423,6 +1257,18 @@ func (a *Agent) checkNodePreflight() error {
 
 	log := a.log.WithField("init-intent", in.DisplayString())
 
+	// Continue the error history from where it was left.
+	a.errHistory, err = parseErrorHistory(n.GetAnnotations()[marker.ErrorHistoryKey])
+	if err != nil {
+		log.WithError(err).Warn("discarding error history")
+	}
+
+	// The Node was directed to reboot into its update before the Agent
+	// started, the update is complete once the Node is stabilized.
+	if in.Wanted == marker.NodeActionRebootUpdate && in.Active == marker.NodeActionRebootUpdate {
+		a.postUpdatePending = a.postUpdate != nil
+	}
+
 	// TODO: check that we're properly reseting, for now its not needed to mark
 	// our work "done"
 	switch {
@@ -437,9 +1283,14 @@ func (a *Agent) checkNodePreflight() error {
 		in = in.Reset()
 		log.Debug("in inconsistent state; resetting")
 	default:
-		// there's not a good way to re-prime ourselves in the prior state.
-		in = in.Reset()
-		log.Debug("repriming state")
+		var resumed bool
+		a.targetVersion = n.GetAnnotations()[marker.TargetVersionKey]
+		in, resumed = a.reprime(ctx, in, n.GetAnnotations()[marker.ActionStartedKey])
+		if resumed {
+			log.WithField("action", in.Wanted).Info("resuming interrupted action")
+		} else {
+			log.Debug("repriming state")
+		}
 	}
 
 	log.WithField("preflight-intent", in.DisplayString()).
@@ -451,17 +1302,120 @@ func (a *Agent) checkNodePreflight() error {
 		return err
 	}
 
+	if err := a.postSupport(ctx); err != nil {
+		log.WithError(err).Warn("could not report os support")
+	}
+
 	return nil
 }
 
+// resumeFrom is the step an interrupted action is resumed from, only the
+// actions listed are resumable.
+var resumeFrom = map[marker.NodeAction]marker.NodeAction{
+	marker.NodeActionPrepareUpdate: marker.NodeActionStabilize,
+	marker.NodeActionPerformUpdate: marker.NodeActionPrepareUpdate,
+}
+
+// reprime re-primes an Intent whose action was interrupted, as happens when
+// the Agent is restarted mid-action. Resumable actions started within the
+// resume grace are retried by stepping the Intent back to the step before
+// them, otherwise the Intent is reset.
+func (a *Agent) reprime(ctx context.Context, in *intent.Intent, started string) (*intent.Intent, bool) {
+	prior, ok := resumeFrom[in.Wanted]
+	if !ok || a.resumeGrace <= 0 || in.Wanted != in.Active {
+		return in.Reset(), false
+	}
+	at, err := time.Parse(time.RFC3339, started)
+	if err != nil {
+		a.log.WithError(err).Debug("action start unknown, not resuming")
+		return in.Reset(), false
+	}
+	if a.clock.Since(at) > a.resumeGrace {
+		a.log.WithField("started", started).Info("interrupted action started outside of grace, resetting")
+		return in.Reset(), false
+	}
+	if in.Wanted == marker.NodeActionPerformUpdate {
+		// The prepared update's progress is lost with the prior process, it's
+		// rediscovered in order to perform it, honoring a targeted version.
+		ups, err := a.availableUpdates(ctx)
+		if err != nil {
+			a.log.WithError(err).Warn("unable to recover prepared update, resetting")
+			return in.Reset(), false
+		}
+		if len(ups) == 0 {
+			a.log.Warn("prepared update is no longer available, resetting")
+			return in.Reset(), false
+		}
+		target := ups[0]
+		if a.targetVersion != "" {
+			target, err = selectTargetVersion(ups, a.targetVersion)
+			if err != nil {
+				a.log.WithError(err).Warn("prepared target version is no longer available, resetting")
+				return in.Reset(), false
+			}
+		}
+		a.progress.SetTarget(target)
+	}
+	p := in.Clone()
+	p.Active = prior
+	p.State = marker.NodeStateReady
+	return p, true
+}
+
+// postActionStarted records the time the Agent began realizing its current
+// action, which bounds when the action is resumed after a restart.
+func (a *Agent) postActionStarted() error {
+	return a.poster.PostMarkers(a.nodeName, marker.Annotations{
+		marker.ActionStartedKey: a.clock.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// postSupport reports whether the host's OS version is supported by the
+// platform. Unsupported Nodes are marked as needing manual intervention as
+// the Agent is unable to update them.
+func (a *Agent) postSupport(ctx context.Context) error {
+	status, err := a.platform.Status(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "unable to get platform status")
+	}
+	ss, ok := status.(platform.SupportStatus)
+	if !ok {
+		return nil
+	}
+	annos := marker.Annotations{marker.UnsupportedOSKey: ""}
+	if ss.Supported() {
+		metrics.UnsupportedOS.Set(0)
+	} else {
+		a.log.WithFields(logrus.Fields{
+			"os-version":         ss.OSVersion(),
+			"minimum-os-version": ss.MinimumOSVersion(),
+		}).Error("os version is too old to be updated by the agent, node must be updated manually")
+		metrics.UnsupportedOS.Set(1)
+		annos[marker.UnsupportedOSKey] = ss.OSVersion()
+	}
+	return a.poster.PostMarkers(a.nodeName, annos)
+}
+
 // osProc encapsulates host interactions in order to kill the current process.
 type osProc struct{}
 
+// TerminateProcess signals the current process to terminate, the signal is
+// trapped to cancel the running Agent's context and exit cleanly.
+func (*osProc) TerminateProcess() error {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return errors.Wrap(err, "unable to find agent process")
+	}
+	return errors.Wrap(p.Signal(syscall.SIGTERM), "unable to terminate agent process")
+}
+
 // KillProcess kills the current process.
 func (*osProc) KillProcess() error {
-	p, _ := os.FindProcess(os.Getpid())
-	go p.Kill()
-	return nil
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return errors.Wrap(err, "unable to find agent process")
+	}
+	return errors.Wrap(p.Kill(), "unable to kill agent process")
 }
 
 // k8sPoster captures the functionality of the posting of a Node resource
@@ -485,3 +1439,14 @@ func (k *k8sPoster) Post(i *intent.Intent) error {
 	log.Debugf("posted intent")
 	return nil
 }
+
+// PostMarkers writes out the informational markers to the Kubernetes Node
+// resource.
+func (k *k8sPoster) PostMarkers(nodeName string, markers marker.Container) error {
+	err := k8sutil.PostMetadata(k.nodeclient, nodeName, markers)
+	if err != nil {
+		return err
+	}
+	k.log.WithField("node", nodeName).Debug("posted markers")
+	return nil
+}
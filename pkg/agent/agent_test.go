@@ -1,18 +1,35 @@
 package agent
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/health"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
-	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent/cache"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/mock"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/tracing"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestActiveIntent(t *testing.T) {
@@ -83,18 +100,12 @@ func testAgent(t *testing.T) (*Agent, *testHooks) {
 		Poster:   &testPoster{},
 		Platform: &testPlatform{},
 		Proc:     &testProc{},
+		Clock:    clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)),
 	}
 	log := testoutput.Logger(t, logging.New("agent"))
-	a := &Agent{
-		log:       log,
-		kube:      nil,
-		platform:  hooks.Platform,
-		poster:    hooks.Poster,
-		proc:      hooks.Proc,
-		nodeName:  intents.NodeName,
-		lastCache: cache.NewLastCache(),
-		tracker:   newPostTracker(),
-	}
+	a, err := newAgent(log, intents.NodeName, hooks.Platform, hooks.Poster, hooks.Proc, hooks.Clock, Config{})
+	assert.NilError(t, err)
+	a.ctx, hooks.Proc.stop = context.WithCancel(context.Background())
 	return a, hooks
 }
 
@@ -102,10 +113,12 @@ type testHooks struct {
 	Poster   *testPoster
 	Proc     *testProc
 	Platform *testPlatform
+	Clock    *clock.FakeClock
 }
 
 type testPoster struct {
 	calledIntents []intent.Intent
+	calledMarkers []marker.Container
 	fn            func(i *intent.Intent) error
 }
 
@@ -117,25 +130,57 @@ func (p *testPoster) Post(i *intent.Intent) error {
 	return nil
 }
 
+func (p *testPoster) PostMarkers(_ string, markers marker.Container) error {
+	p.calledMarkers = append(p.calledMarkers, markers)
+	return nil
+}
+
 type testProc struct {
-	Killed bool
+	Terminated  bool
+	TerminateFn func() error
+	Killed      bool
+	Attempts    int
+	KillFn      func(attempt int) error
+	// stop cancels the Agent's context as the process would on terminating.
+	stop context.CancelFunc
+}
+
+func (p *testProc) TerminateProcess() error {
+	if p.TerminateFn != nil {
+		if err := p.TerminateFn(); err != nil {
+			return err
+		}
+	}
+	p.Terminated = true
+	if p.stop != nil {
+		p.stop()
+	}
+	return nil
 }
 
 func (p *testProc) KillProcess() error {
+	p.Attempts++
+	if p.KillFn != nil {
+		if err := p.KillFn(p.Attempts); err != nil {
+			return err
+		}
+	}
 	p.Killed = true
 	return nil
 }
 
 type testPlatform struct {
 	StatusFn        func() (platform.Status, error)
+	PartitionsFn    func() (platform.PartitionStatus, error)
 	ListAvailableFn func() (platform.Available, error)
 	PrepareFn       func(target platform.Update) error
 	UpdateFn        func(target platform.Update) error
 	BootUpdateFn    func(target platform.Update, rebootNow bool) error
+	RollbackFn      func() error
 }
 
 // Status reports the underlying platform's health and metadata.
-func (p *testPlatform) Status() (platform.Status, error) {
+func (p *testPlatform) Status(_ context.Context) (platform.Status, error) {
 	if p.StatusFn != nil {
 		return p.StatusFn()
 	}
@@ -143,6 +188,14 @@ func (p *testPlatform) Status() (platform.Status, error) {
 	return &status, nil
 }
 
+// Partitions reports the host's partitions, none are reported unless set.
+func (p *testPlatform) Partitions(_ context.Context) (platform.PartitionStatus, error) {
+	if p.PartitionsFn != nil {
+		return p.PartitionsFn()
+	}
+	return nil, nil
+}
+
 type testStatus bool
 
 func (s *testStatus) OK() bool {
@@ -151,7 +204,7 @@ func (s *testStatus) OK() bool {
 
 // ListAvailable provides the list of updates that a platform is offering
 // for use. The list MUST be ordered in preference as well as recency.
-func (p *testPlatform) ListAvailable() (platform.Available, error) {
+func (p *testPlatform) ListAvailable(_ context.Context) (platform.Available, error) {
 	if p.ListAvailableFn != nil {
 		return p.ListAvailableFn()
 	}
@@ -179,7 +232,7 @@ func (s *testUpdate) Identifier() interface{} {
 // committing to it. For example, a platform may require steps to preform
 // pre-flight checkss or initialization migrations prior to executing an
 // update.
-func (p *testPlatform) Prepare(target platform.Update) error {
+func (p *testPlatform) Prepare(_ context.Context, target platform.Update) error {
 	if p.PrepareFn != nil {
 		return p.PrepareFn(target)
 	}
@@ -188,7 +241,7 @@ func (p *testPlatform) Prepare(target platform.Update) error {
 
 // Update causes the platform to commit to an update taking potentially
 // irreversible steps to do so.
-func (p *testPlatform) Update(target platform.Update) error {
+func (p *testPlatform) Update(_ context.Context, target platform.Update) error {
 	if p.UpdateFn != nil {
 		return p.UpdateFn(target)
 	}
@@ -198,13 +251,22 @@ func (p *testPlatform) Update(target platform.Update) error {
 // BootUpdate causes the platform to configure itself to use the update on
 // next boot. Optionally, the caller may indicate that the update should be
 // immediately rebooted to use.
-func (p *testPlatform) BootUpdate(target platform.Update, rebootNow bool) error {
+func (p *testPlatform) BootUpdate(_ context.Context, target platform.Update, rebootNow bool) error {
 	if p.BootUpdateFn != nil {
 		return p.BootUpdateFn(target, rebootNow)
 	}
 	return nil
 }
 
+// Rollback causes the platform to boot back into the image it ran before its
+// most recent update, rebooting the host to do so.
+func (p *testPlatform) Rollback(_ context.Context) error {
+	if p.RollbackFn != nil {
+		return p.RollbackFn()
+	}
+	return nil
+}
+
 func TestAgentRealize(t *testing.T) {
 	t.Run("stabilize", func(t *testing.T) {
 		a, hooks := testAgent(t)
@@ -218,7 +280,7 @@ func TestAgentRealize(t *testing.T) {
 			status := testStatus(true)
 			return &status, nil
 		}
-		a.realize(intents.PendingStabilizing())
+		a.realize(context.Background(), intents.PendingStabilizing())
 		assert.Check(t, platformStatus == true)
 	})
 
@@ -245,24 +307,24 @@ func TestAgentRealize(t *testing.T) {
 
 		// Call with prepare-update to kick off.
 		{
-			err := a.realize(intents.PendingPrepareUpdate())
+			err := a.realize(context.Background(), intents.PendingPrepareUpdate())
 			assert.Check(t, err == nil)
 			assert.Check(t, platformPrepare == true)
 		}
 		// Then perform-update to apply the update
 		{
-			err := a.realize(intents.PendingUpdate())
+			err := a.realize(context.Background(), intents.PendingUpdate())
 			assert.Check(t, err == nil)
 			assert.Check(t, platformUpdate == true)
 		}
 		// Then reboot-update to boot into the update
 		{
-			err := a.realize(intents.PendingRebootUpdate())
+			err := a.realize(context.Background(), intents.PendingRebootUpdate())
 			assert.Check(t, err == nil)
 			assert.Check(t, platformBoot == true)
 		}
-		// The process should have died to do this all.
-		assert.Check(t, hooks.Proc.Killed == true)
+		// The process should have been stopped to do this all.
+		assert.Check(t, hooks.Proc.Terminated == true)
 	})
 
 	t.Run("out-of-order", func(t *testing.T) {
@@ -275,8 +337,1205 @@ func TestAgentRealize(t *testing.T) {
 			platformUpdate = true
 			return nil
 		}
-		err := a.realize(intents.PendingUpdate())
+		err := a.realize(context.Background(), intents.PendingUpdate())
 		assert.Check(t, err != nil)
 		assert.Check(t, platformUpdate == false)
 	})
 }
+
+func TestRealizeActions(t *testing.T) {
+	cases := []struct {
+		Name string
+		// Prior are the actions realized before the intent.
+		Prior  []marker.NodeAction
+		Intent *intent.Intent
+		// Fail fails the platform's method.
+		Fail      mock.Method
+		Available []string
+		Err       string
+		Calls     []mock.Call
+		State     mock.State
+		Running   string
+	}{
+		{
+			Name:   "unknown",
+			Intent: intents.Unknown(),
+			Calls:  []mock.Call{{Method: mock.MethodStatus}, {Method: mock.MethodListAvailable}, {Method: mock.MethodPartitions}},
+		},
+		{
+			Name:   "stabilize",
+			Intent: intents.PendingStabilizing(),
+			Calls:  []mock.Call{{Method: mock.MethodStatus}, {Method: mock.MethodListAvailable}, {Method: mock.MethodPartitions}},
+		},
+		{
+			Name:   "stabilize-failed",
+			Intent: intents.PendingStabilizing(),
+			Fail:   mock.MethodStatus,
+			Err:    "could not retrieve platform status",
+			Calls:  []mock.Call{{Method: mock.MethodStatus}},
+		},
+		{
+			Name:   "reset",
+			Prior:  []marker.NodeAction{marker.NodeActionPrepareUpdate},
+			Intent: intents.Stabilized(intents.Pending(marker.NodeActionReset)),
+			Calls:  []mock.Call{{Method: mock.MethodPartitions}},
+			State:  mock.StateStaged,
+		},
+		{
+			Name:   "prepare",
+			Intent: intents.PendingPrepareUpdate(),
+			Calls:  []mock.Call{{Method: mock.MethodListAvailable}, {Method: mock.MethodPrepare, Version: "1.1.0"}, {Method: mock.MethodPartitions}},
+			State:  mock.StateStaged,
+		},
+		{
+			Name:      "prepare-none-available",
+			Intent:    intents.PendingPrepareUpdate(),
+			Available: []string{"1.0.0"},
+			Err:       errInvalidProgress.Error(),
+			Calls:     []mock.Call{{Method: mock.MethodListAvailable}},
+		},
+		{
+			Name:   "prepare-failed",
+			Intent: intents.PendingPrepareUpdate(),
+			Fail:   mock.MethodPrepare,
+			Err:    "mock failure",
+			Calls:  []mock.Call{{Method: mock.MethodListAvailable}, {Method: mock.MethodPrepare, Version: "1.1.0"}},
+		},
+		{
+			Name:   "perform",
+			Prior:  []marker.NodeAction{marker.NodeActionPrepareUpdate},
+			Intent: intents.PendingUpdate(),
+			Calls:  []mock.Call{{Method: mock.MethodUpdate, Version: "1.1.0"}, {Method: mock.MethodPartitions}},
+			State:  mock.StateReady,
+		},
+		{
+			Name:   "perform-unprepared",
+			Intent: intents.PendingUpdate(),
+			Err:    errInvalidProgress.Error(),
+		},
+		{
+			Name:   "perform-failed",
+			Prior:  []marker.NodeAction{marker.NodeActionPrepareUpdate},
+			Intent: intents.PendingUpdate(),
+			Fail:   mock.MethodUpdate,
+			Err:    "mock failure",
+			Calls:  []mock.Call{{Method: mock.MethodUpdate, Version: "1.1.0"}},
+			State:  mock.StateStaged,
+		},
+		{
+			Name:    "reboot",
+			Prior:   []marker.NodeAction{marker.NodeActionPrepareUpdate, marker.NodeActionPerformUpdate},
+			Intent:  intents.PendingRebootUpdate(),
+			Calls:   []mock.Call{{Method: mock.MethodBootUpdate, Version: "1.1.0", RebootNow: true}},
+			Running: "1.1.0",
+		},
+		{
+			Name:   "reboot-unprepared",
+			Intent: intents.PendingRebootUpdate(),
+			Err:    errInvalidProgress.Error(),
+		},
+		{
+			Name:   "reboot-failed",
+			Prior:  []marker.NodeAction{marker.NodeActionPrepareUpdate, marker.NodeActionPerformUpdate},
+			Intent: intents.PendingRebootUpdate(),
+			Fail:   mock.MethodBootUpdate,
+			Err:    "reboot command failed: mock failure",
+			Calls:  []mock.Call{{Method: mock.MethodBootUpdate, Version: "1.1.0", RebootNow: true}},
+			State:  mock.StateReady,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			p := mock.New("1.0.0", "1.1.0")
+			a, hooks := testAgent(t)
+			a.platform = p
+			for _, action := range tc.Prior {
+				assert.NilError(t, a.realize(context.Background(), intents.Stabilized(intents.Pending(action))))
+			}
+			prior := len(p.Calls())
+			if tc.Available != nil {
+				p.SetAvailable(tc.Available...)
+			}
+			if tc.Fail != "" {
+				p.Fail(tc.Fail, errors.New("mock failure"))
+			}
+
+			err := a.realize(context.Background(), tc.Intent)
+			if tc.Err != "" {
+				assert.ErrorContains(t, err, tc.Err)
+				posted := hooks.Poster.calledIntents[len(hooks.Poster.calledIntents)-1]
+				assert.Equal(t, posted.State, marker.NodeStateError)
+			} else {
+				assert.NilError(t, err)
+			}
+			calls := p.Calls()[prior:]
+			if len(tc.Calls) == 0 {
+				assert.Equal(t, len(calls), 0)
+			} else {
+				assert.DeepEqual(t, calls, tc.Calls)
+			}
+			state := tc.State
+			if state == "" {
+				state = mock.StateIdle
+			}
+			assert.Equal(t, p.State(), state)
+			running := tc.Running
+			if running == "" {
+				running = "1.0.0"
+			}
+			assert.Equal(t, p.Running(), running)
+			assert.Equal(t, hooks.Proc.Terminated, tc.Running != "", "agent should only stop to reboot")
+		})
+	}
+}
+
+type testPartitionStatus struct {
+	active  *platform.Partition
+	staging *platform.Partition
+}
+
+func (s *testPartitionStatus) ActivePartition() *platform.Partition {
+	return s.active
+}
+
+func (s *testPartitionStatus) StagingPartition() *platform.Partition {
+	return s.staging
+}
+
+func TestPostPartitions(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   *testPartitionStatus
+		expected marker.Annotations
+	}{
+		{
+			name: "staged",
+			status: &testPartitionStatus{
+				active:  &platform.Partition{Version: "0.3.4", NextToBoot: true},
+				staging: &platform.Partition{Version: "0.4.0", NextToBoot: false},
+			},
+			expected: marker.Annotations{
+				marker.ActivePartitionKey:  "0.3.4",
+				marker.StagingPartitionKey: "0.4.0",
+				marker.NextToBootKey:       marker.NodePartitionActive,
+			},
+		},
+		{
+			name: "ready",
+			status: &testPartitionStatus{
+				active:  &platform.Partition{Version: "0.3.4", NextToBoot: false},
+				staging: &platform.Partition{Version: "0.4.0", NextToBoot: true},
+			},
+			expected: marker.Annotations{
+				marker.ActivePartitionKey:  "0.3.4",
+				marker.StagingPartitionKey: "0.4.0",
+				marker.NextToBootKey:       marker.NodePartitionStaging,
+			},
+		},
+		{
+			name: "unstaged",
+			status: &testPartitionStatus{
+				active: &platform.Partition{Version: "0.4.0", NextToBoot: true},
+			},
+			expected: marker.Annotations{
+				marker.ActivePartitionKey:  "0.4.0",
+				marker.StagingPartitionKey: "",
+				marker.NextToBootKey:       marker.NodePartitionActive,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, hooks := testAgent(t)
+			hooks.Platform.PartitionsFn = func() (platform.PartitionStatus, error) {
+				return tc.status, nil
+			}
+			err := a.postPartitions(context.Background())
+			assert.NilError(t, err)
+			assert.Assert(t, len(hooks.Poster.calledMarkers) == 1)
+			assert.DeepEqual(t, hooks.Poster.calledMarkers[0], tc.expected)
+
+			// The unchanged partitions aren't posted again.
+			assert.NilError(t, a.postPartitions(context.Background()))
+			assert.Equal(t, len(hooks.Poster.calledMarkers), 1)
+		})
+	}
+}
+
+func TestPostUpdateAvailable(t *testing.T) {
+	cases := []struct {
+		name      string
+		posted    marker.NodeUpdate
+		available bool
+		expected  bool
+	}{
+		{name: "unchanged-available", posted: marker.NodeUpdateAvailable, available: true, expected: false},
+		{name: "unchanged-unavailable", posted: marker.NodeUpdateUnavailable, available: false, expected: false},
+		{name: "became-available", posted: marker.NodeUpdateUnavailable, available: true, expected: true},
+		{name: "became-unavailable", posted: marker.NodeUpdateAvailable, available: false, expected: true},
+		{name: "unknown", posted: marker.NodeUpdateUnknown, available: false, expected: true},
+		{name: "unposted", posted: "", available: true, expected: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, hooks := testAgent(t)
+			node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+				Name:        a.nodeName,
+				Annotations: intents.Stabilized(intents.WithUpdateAvailable(tc.posted)).GetAnnotations(),
+			}}
+			a.kube = fake.NewSimpleClientset(node)
+
+			err := a.postUpdateAvailable(context.Background(), tc.available)
+			assert.NilError(t, err)
+			if !tc.expected {
+				assert.Equal(t, len(hooks.Poster.calledIntents), 0)
+				return
+			}
+			assert.Equal(t, len(hooks.Poster.calledIntents), 1)
+			assert.Equal(t, hooks.Poster.calledIntents[0].UpdateAvailable == marker.NodeUpdateAvailable, tc.available)
+		})
+	}
+}
+
+func TestRealizeNoUpdate(t *testing.T) {
+	noUpdates := func() (platform.Available, error) {
+		return testAvailable{}, nil
+	}
+
+	t.Run("error", func(t *testing.T) {
+		a, hooks := testAgent(t)
+		hooks.Platform.ListAvailableFn = noUpdates
+
+		err := a.realize(context.Background(), intents.PendingPrepareUpdate())
+		assert.Equal(t, err, errInvalidProgress)
+		posted := hooks.Poster.calledIntents[len(hooks.Poster.calledIntents)-1]
+		assert.Equal(t, posted.State, marker.NodeStateError)
+	})
+
+	t.Run("up-to-date", func(t *testing.T) {
+		a, hooks := testAgent(t)
+		a.noUpdateUpToDate = true
+		hooks.Platform.ListAvailableFn = noUpdates
+
+		err := a.realize(context.Background(), intents.PendingPrepareUpdate())
+		assert.NilError(t, err)
+		posted := hooks.Poster.calledIntents[len(hooks.Poster.calledIntents)-1]
+		assert.Equal(t, posted.Wanted, marker.NodeActionStabilize)
+		assert.Equal(t, posted.Active, marker.NodeActionStabilize)
+		assert.Equal(t, posted.State, marker.NodeStateReady)
+		assert.Equal(t, posted.UpdateAvailable, marker.NodeUpdateUnavailable)
+		assert.Check(t, !posted.Errored())
+		assert.Equal(t, len(a.errHistory), 0)
+	})
+}
+
+// testIdlePlatform is a testPlatform able to report whether it's idle.
+type testIdlePlatform struct {
+	*testPlatform
+	idle bool
+}
+
+func (p *testIdlePlatform) Idle(_ context.Context) (bool, error) {
+	return p.idle, nil
+}
+
+func TestRealizeCheckIdle(t *testing.T) {
+	a, hooks := testAgent(t)
+	a.checkIdle = true
+	idler := &testIdlePlatform{testPlatform: hooks.Platform}
+	a.platform = idler
+	prepared := false
+	hooks.Platform.PrepareFn = func(platform.Update) error {
+		prepared = true
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.realize(context.Background(), intents.PendingPrepareUpdate())
+	}()
+	for !hooks.Clock.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Check(t, !prepared, "busy platform should defer prepare")
+	assert.Equal(t, len(hooks.Poster.calledIntents), 0, "deferred intent should not be acknowledged")
+
+	// The deferred prepare is retried once the platform is idle, without
+	// waiting on another event.
+	idler.idle = true
+	hooks.Clock.Step(idlePollInterval)
+	assert.NilError(t, <-done)
+	assert.Check(t, prepared)
+	posted := hooks.Poster.calledIntents[len(hooks.Poster.calledIntents)-1]
+	assert.Equal(t, posted.State, marker.NodeStateReady)
+}
+
+func TestRealizeCheckIdleStopped(t *testing.T) {
+	a, hooks := testAgent(t)
+	a.checkIdle = true
+	a.platform = &testIdlePlatform{testPlatform: hooks.Platform}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in := intents.PendingPrepareUpdate()
+	a.lastCache.Record(in)
+	assert.ErrorContains(t, a.realize(ctx, in), "stopped waiting for platform to be idle")
+	assert.Equal(t, len(hooks.Poster.calledIntents), 0, "deferred intent should not be acknowledged")
+	assert.Check(t, !a.skipIntentEvent(in), "deferred intent should be handled again")
+}
+
+func TestReprime(t *testing.T) {
+	interrupted := func(action marker.NodeAction) *intent.Intent {
+		return &intent.Intent{
+			NodeName:        intents.NodeName,
+			Wanted:          action,
+			Active:          action,
+			State:           marker.NodeStateBusy,
+			UpdateAvailable: marker.NodeUpdateAvailable,
+		}
+	}
+	noUpdates := func() (platform.Available, error) {
+		return testAvailable{}, nil
+	}
+	versioned := func() (platform.Available, error) {
+		return testAvailable{testVersionedUpdate("1.2.0"), testVersionedUpdate("1.1.0")}, nil
+	}
+
+	cases := []struct {
+		name      string
+		in        *intent.Intent
+		grace     time.Duration
+		started   time.Duration
+		unstarted bool
+		available func() (platform.Available, error)
+		target    string
+		prepared  string
+		resumed   bool
+		active    marker.NodeAction
+	}{
+		{name: "prepare", in: interrupted(marker.NodeActionPrepareUpdate), grace: 10 * time.Minute, started: time.Minute, resumed: true, active: marker.NodeActionStabilize},
+		{name: "perform", in: interrupted(marker.NodeActionPerformUpdate), grace: 10 * time.Minute, started: time.Minute, resumed: true, active: marker.NodeActionPrepareUpdate},
+		{name: "perform-unavailable", in: interrupted(marker.NodeActionPerformUpdate), grace: 10 * time.Minute, started: time.Minute, available: noUpdates},
+		{name: "perform-targeted", in: interrupted(marker.NodeActionPerformUpdate), grace: 10 * time.Minute, started: time.Minute, available: versioned, target: "1.1.0", prepared: "1.1.0", resumed: true, active: marker.NodeActionPrepareUpdate},
+		{name: "perform-target-unavailable", in: interrupted(marker.NodeActionPerformUpdate), grace: 10 * time.Minute, started: time.Minute, available: versioned, target: "1.0.0"},
+		{name: "grace-elapsed", in: interrupted(marker.NodeActionPrepareUpdate), grace: 10 * time.Minute, started: time.Hour},
+		{name: "grace-disabled", in: interrupted(marker.NodeActionPrepareUpdate), started: time.Minute},
+		{name: "start-unknown", in: interrupted(marker.NodeActionPrepareUpdate), grace: 10 * time.Minute, unstarted: true},
+		{name: "stabilize", in: interrupted(marker.NodeActionStabilize), grace: 10 * time.Minute, started: time.Minute},
+		{name: "reset", in: interrupted(marker.NodeActionReset), grace: 10 * time.Minute, started: time.Minute},
+		{name: "mismatched", in: &intent.Intent{NodeName: intents.NodeName, Wanted: marker.NodeActionPerformUpdate, Active: marker.NodeActionPrepareUpdate, State: marker.NodeStateBusy}, grace: 10 * time.Minute, started: time.Minute},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, hooks := testAgent(t)
+			a.resumeGrace = tc.grace
+			a.targetVersion = tc.target
+			hooks.Platform.ListAvailableFn = tc.available
+			var started string
+			if !tc.unstarted {
+				started = hooks.Clock.Now().Add(-tc.started).Format(time.RFC3339)
+			}
+
+			in, resumed := a.reprime(context.Background(), tc.in, started)
+			assert.Equal(t, resumed, tc.resumed)
+			if !tc.resumed {
+				assert.DeepEqual(t, in, tc.in.Reset())
+				return
+			}
+			assert.Equal(t, in.Wanted, tc.in.Wanted)
+			assert.Equal(t, in.Active, tc.active)
+			assert.Equal(t, in.State, marker.NodeStateReady)
+			assert.Check(t, in.InProgress(), "resumed intent should be handled again")
+			if in.Wanted == marker.NodeActionPerformUpdate {
+				assert.Check(t, a.progress.Valid(), "prepared update should be recovered")
+			}
+			if tc.prepared != "" {
+				assert.Equal(t, a.progress.GetTarget().Identifier(), tc.prepared)
+			}
+		})
+	}
+}
+
+func TestRealizeActionStarted(t *testing.T) {
+	a, hooks := testAgent(t)
+	assert.NilError(t, a.realize(context.Background(), intents.PendingPrepareUpdate()))
+	assert.Equal(t, len(hooks.Poster.calledMarkers), 0, "action start is only recorded when resumable")
+
+	a.resumeGrace = time.Minute
+	assert.NilError(t, a.realize(context.Background(), intents.PendingPrepareUpdate()))
+	assert.Equal(t, len(hooks.Poster.calledMarkers), 1)
+	assert.Equal(t, hooks.Poster.calledMarkers[0].GetAnnotations()[marker.ActionStartedKey],
+		hooks.Clock.Now().UTC().Format(time.RFC3339))
+}
+
+// testSupportStatus is the status of a platform supporting a minimum OS
+// version.
+type testSupportStatus struct {
+	version string
+}
+
+func (s *testSupportStatus) OK() bool                 { return s.Supported() }
+func (s *testSupportStatus) Supported() bool          { return s.version != "0.3.4" }
+func (s *testSupportStatus) OSVersion() string        { return s.version }
+func (s *testSupportStatus) MinimumOSVersion() string { return "0.4.1" }
+
+func TestPostSupport(t *testing.T) {
+	cases := []struct {
+		version     string
+		unsupported string
+	}{
+		{version: "0.3.4", unsupported: "0.3.4"},
+		{version: "1.0.0", unsupported: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.version, func(t *testing.T) {
+			a, hooks := testAgent(t)
+			hooks.Platform.StatusFn = func() (platform.Status, error) {
+				return &testSupportStatus{version: tc.version}, nil
+			}
+			assert.NilError(t, a.postSupport(context.Background()))
+			assert.Equal(t, len(hooks.Poster.calledMarkers), 1)
+			annos := hooks.Poster.calledMarkers[0].GetAnnotations()
+			value, ok := annos[marker.UnsupportedOSKey]
+			assert.Check(t, ok, "support should always be reported")
+			assert.Equal(t, value, tc.unsupported)
+			assert.Equal(t, testutil.ToFloat64(metrics.UnsupportedOS) == 1, tc.unsupported != "")
+		})
+	}
+}
+
+func TestRealizeReboot(t *testing.T) {
+	rebooting := func(t *testing.T) (*Agent, *testHooks) {
+		a, hooks := testAgent(t)
+		update := testUpdate("test")
+		a.progress.SetTarget(&update)
+		return a, hooks
+	}
+
+	t.Run("reboot-failed", func(t *testing.T) {
+		a, hooks := rebooting(t)
+		hooks.Platform.BootUpdateFn = func(platform.Update, bool) error {
+			return errors.New("api unavailable")
+		}
+		err := a.realize(context.Background(), intents.PendingRebootUpdate())
+		assert.ErrorContains(t, err, "reboot command failed")
+		assert.Equal(t, hooks.Proc.Attempts, 0, "agent should keep running")
+		posted := hooks.Poster.calledIntents[len(hooks.Poster.calledIntents)-1]
+		assert.Equal(t, posted.State, marker.NodeStateError)
+	})
+
+	t.Run("terminated", func(t *testing.T) {
+		a, hooks := rebooting(t)
+		assert.NilError(t, a.realize(context.Background(), intents.PendingRebootUpdate()))
+		assert.Check(t, hooks.Proc.Terminated)
+		assert.Check(t, a.ctx.Err() != nil, "agent should be stopped")
+		assert.Equal(t, hooks.Proc.Attempts, 0, "agent stopped gracefully should not be killed")
+		assert.Equal(t, len(hooks.Poster.calledIntents), 1)
+		assert.Equal(t, hooks.Poster.calledIntents[0].State, marker.NodeStateBusy)
+	})
+
+	t.Run("terminate-timeout", func(t *testing.T) {
+		a, hooks := rebooting(t)
+		// The process is signaled but the Agent doesn't stop.
+		hooks.Proc.stop = nil
+		done := make(chan error)
+		go func() {
+			done <- a.realize(context.Background(), intents.PendingRebootUpdate())
+		}()
+		for !hooks.Clock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+		hooks.Clock.Step(terminateTimeout)
+		assert.NilError(t, <-done)
+		assert.Check(t, hooks.Proc.Terminated)
+		assert.Check(t, hooks.Proc.Killed, "agent should be killed once the timeout passes")
+	})
+
+	t.Run("kill-retried", func(t *testing.T) {
+		a, hooks := rebooting(t)
+		hooks.Proc.TerminateFn = func() error {
+			return errors.New("unable to signal")
+		}
+		hooks.Proc.KillFn = func(attempt int) error {
+			if attempt < killAttempts {
+				return errors.New("still running")
+			}
+			return nil
+		}
+		assert.NilError(t, a.realize(context.Background(), intents.PendingRebootUpdate()))
+		assert.Check(t, hooks.Proc.Killed)
+		assert.Equal(t, hooks.Proc.Attempts, killAttempts)
+		// Only the acknowledgement is posted, the reboot isn't an error.
+		assert.Equal(t, len(hooks.Poster.calledIntents), 1)
+		assert.Equal(t, hooks.Poster.calledIntents[0].State, marker.NodeStateBusy)
+	})
+
+	t.Run("kill-exhausted", func(t *testing.T) {
+		a, hooks := rebooting(t)
+		hooks.Proc.TerminateFn = func() error {
+			return errors.New("unable to signal")
+		}
+		hooks.Proc.KillFn = func(int) error {
+			return errors.New("still running")
+		}
+		assert.NilError(t, a.realize(context.Background(), intents.PendingRebootUpdate()))
+		assert.Equal(t, hooks.Proc.Attempts, killAttempts)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 1, "accepted reboot should not be errored")
+		assert.Equal(t, len(a.errHistory), 0)
+	})
+}
+
+type testNotifySink struct {
+	sent chan notify.Notification
+}
+
+func (s *testNotifySink) Send(_ context.Context, note notify.Notification) error {
+	s.sent <- note
+	return nil
+}
+
+func TestRealizeNotifyFailure(t *testing.T) {
+	a, hooks := testAgent(t)
+	sink := &testNotifySink{sent: make(chan notify.Notification, 1)}
+	a.notifier = notify.New(testoutput.Logger(t, logging.New("notifier")), "agent", sink)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.notifier.Run(ctx)
+	a.runningVersion = "1.0.0"
+	update := testUpdate("test")
+	a.progress.SetTarget(&update)
+	hooks.Platform.BootUpdateFn = func(platform.Update, bool) error {
+		return errors.New("api unavailable")
+	}
+
+	assert.Check(t, a.realize(context.Background(), intents.PendingRebootUpdate()) != nil)
+	note := <-sink.sent
+	assert.Equal(t, note.Event, report.Failure)
+	assert.Equal(t, note.Component, "agent")
+	assert.Equal(t, note.Node, intents.NodeName)
+	assert.Equal(t, note.FromVersion, "1.0.0")
+	assert.Equal(t, note.Error, "reboot command failed: api unavailable")
+
+	// Failures outside of an update aren't notified.
+	a.notifyFailure(intents.Stabilized(), errors.New("ping failed"))
+	a.notifyFailure(intents.PendingPrepareUpdate(), errors.New("prepare failed"))
+	note = <-sink.sent
+	assert.Equal(t, note.Error, "prepare failed")
+}
+
+func TestRealizeTraced(t *testing.T) {
+	type exportedSpan struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+	}
+	var spans []exportedSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		assert.Check(t, json.NewDecoder(r.Body).Decode(&req))
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	a, hooks := testAgent(t)
+	tracer, err := tracing.NewTracer(testoutput.Logger(t, logging.New("tracing")), "agent", collector.URL+"/v1/traces")
+	assert.NilError(t, err)
+	a.tracer = tracer
+	a.traceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	update := testUpdate("test")
+	a.progress.SetTarget(&update)
+
+	assert.NilError(t, a.realize(context.Background(), intents.PendingRebootUpdate()))
+	assert.Check(t, hooks.Proc.Terminated)
+	// The spans are exported before the Agent is stopped for the reboot.
+	assert.Equal(t, len(spans), 2)
+	boot, realize := spans[0], spans[1]
+	assert.Equal(t, boot.Name, "agent.boot-update")
+	assert.Equal(t, boot.ParentSpanID, realize.SpanID)
+	assert.Equal(t, realize.Name, "agent.realize")
+	assert.Equal(t, realize.TraceID, "0af7651916cd43dd8448eb211c80319c", "realize should join the controller's trace")
+	assert.Equal(t, realize.ParentSpanID, "b7ad6b7169203331")
+}
+
+func TestHandleEventHeld(t *testing.T) {
+	a, hooks := testAgent(t)
+	prepared := false
+	hooks.Platform.PrepareFn = func(_ platform.Update) error {
+		prepared = true
+		return nil
+	}
+
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        a.nodeName,
+		Labels:      map[string]string{marker.HoldKey: ""},
+		Annotations: intents.PendingPrepareUpdate().GetAnnotations(),
+	}}
+	a.handleEvent(node)
+	assert.Check(t, !prepared, "held node should not advance")
+	assert.Equal(t, len(hooks.Poster.calledIntents), 0)
+
+	delete(node.Labels, marker.HoldKey)
+	a.handleEvent(node)
+	assert.Check(t, prepared, "node should advance once released")
+}
+
+func TestHandleEventRollback(t *testing.T) {
+	rollbackNode := func(a *Agent, version string) *v1.Node {
+		return &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+			Name:        a.nodeName,
+			Annotations: map[string]string{marker.RollbackKey: version},
+		}}
+	}
+	running := func(version string) func() (platform.PartitionStatus, error) {
+		return func() (platform.PartitionStatus, error) {
+			return &testPartitionStatus{
+				active:  &platform.Partition{Version: version, NextToBoot: true},
+				staging: &platform.Partition{Version: "1.1.0"},
+			}, nil
+		}
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		a, hooks := testAgent(t)
+		hooks.Platform.PartitionsFn = running("1.2.0")
+		rollbacks := 0
+		hooks.Platform.RollbackFn = func() error {
+			rollbacks++
+			return nil
+		}
+
+		a.handleEvent(rollbackNode(a, "1.2.0"))
+		assert.Equal(t, rollbacks, 1)
+		assert.Check(t, hooks.Proc.Terminated, "agent should stop as the host reboots")
+
+		// The version is rolled back from once.
+		a.handleEvent(rollbackNode(a, "1.2.0"))
+		assert.Equal(t, rollbacks, 1)
+	})
+
+	t.Run("rolled-back", func(t *testing.T) {
+		a, hooks := testAgent(t)
+		hooks.Platform.PartitionsFn = running("1.1.0")
+		hooks.Platform.RollbackFn = func() error {
+			t.Error("node no longer running the version should not be rolled back")
+			return nil
+		}
+		a.handleEvent(rollbackNode(a, "1.2.0"))
+		assert.Equal(t, a.rollbackVersion, "1.2.0")
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		a, hooks := testAgent(t)
+		a.dryRun = true
+		hooks.Platform.PartitionsFn = running("1.2.0")
+		hooks.Platform.RollbackFn = func() error {
+			t.Error("dry run should not roll back")
+			return nil
+		}
+		a.handleEvent(rollbackNode(a, "1.2.0"))
+	})
+}
+
+func TestSkipIntentEventDuplicateMetric(t *testing.T) {
+	a, _ := testAgent(t)
+	before := testutil.ToFloat64(metrics.AgentDuplicateIntents)
+
+	in := intents.PendingPrepareUpdate()
+	assert.Check(t, !a.skipIntentEvent(in))
+	assert.Equal(t, testutil.ToFloat64(metrics.AgentDuplicateIntents), before)
+
+	a.lastCache.Record(in)
+	assert.Check(t, a.skipIntentEvent(in.Clone()))
+	assert.Check(t, a.skipIntentEvent(in.Clone()))
+	assert.Equal(t, testutil.ToFloat64(metrics.AgentDuplicateIntents), before+2)
+}
+
+func TestPeriodicUpdateChecker(t *testing.T) {
+	// Checks are made without jitter to step the clock exactly.
+	defer func(f func() float64) { randJitterFunc = f }(randJitterFunc)
+	randJitterFunc = func() float64 { return 0.5 }
+	a, hooks := testAgent(t)
+	checked := make(chan struct{}, 1)
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		checked <- struct{}{}
+		return &testListAvailable{}, nil
+	}
+
+	// waitForTimer blocks until the checker is waiting on its poll timer.
+	waitForTimer := func() {
+		for !hooks.Clock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.periodicUpdateChecker(ctx)
+	}()
+
+	waitForTimer()
+	hooks.Clock.Step(defaultUpdatePollInterval/2 - time.Second)
+	select {
+	case <-checked:
+		t.Fatal("checked for update before the initial poll delay")
+	default:
+	}
+	hooks.Clock.Step(time.Second)
+	<-checked
+
+	for i := 0; i < 2; i++ {
+		waitForTimer()
+		hooks.Clock.Step(defaultUpdatePollInterval)
+		<-checked
+	}
+
+	waitForTimer()
+	cancel()
+	assert.NilError(t, <-done)
+}
+
+func TestPeriodicUpdateCheckerConfigured(t *testing.T) {
+	// Checks are made without jitter to step the clock exactly.
+	defer func(f func() float64) { randJitterFunc = f }(randJitterFunc)
+	randJitterFunc = func() float64 { return 0.5 }
+	_, hooks := testAgent(t)
+	a, err := newAgent(testoutput.Logger(t, logging.New("agent")), intents.NodeName, hooks.Platform, hooks.Poster, hooks.Proc, hooks.Clock, Config{
+		UpdatePollInterval: 5 * time.Minute,
+		InitialPollDelay:   time.Minute,
+	})
+	assert.NilError(t, err)
+	checked := make(chan struct{}, 1)
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		checked <- struct{}{}
+		return &testListAvailable{}, nil
+	}
+
+	waitForTimer := func() {
+		for !hooks.Clock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// notChecked fails the test if an update check was made.
+	notChecked := func(msg string) {
+		select {
+		case <-checked:
+			t.Fatal(msg)
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.periodicUpdateChecker(ctx)
+	}()
+
+	waitForTimer()
+	hooks.Clock.Step(time.Minute - time.Second)
+	notChecked("checked for update before the initial poll delay")
+	hooks.Clock.Step(time.Second)
+	<-checked
+
+	for i := 0; i < 2; i++ {
+		waitForTimer()
+		hooks.Clock.Step(5*time.Minute - time.Second)
+		notChecked("checked for update before the poll interval")
+		hooks.Clock.Step(time.Second)
+		<-checked
+	}
+
+	waitForTimer()
+	cancel()
+	assert.NilError(t, <-done)
+}
+
+func TestPeriodicUpdateCheckerHealth(t *testing.T) {
+	defer func(f func() float64) { randJitterFunc = f }(randJitterFunc)
+	randJitterFunc = func() float64 { return 0.5 }
+	a, hooks := testAgent(t)
+	a.health = health.NewStatus(hooks.Clock)
+	a.kube = fake.NewSimpleClientset(&v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: a.nodeName}})
+	checked := make(chan struct{}, 1)
+	var listErr error
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		checked <- struct{}{}
+		return &testListAvailable{}, listErr
+	}
+	waitForTimer := func() {
+		for !hooks.Clock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.health.Work("update-checker", time.Hour, a.periodicUpdateChecker)(ctx)
+	}()
+
+	listErr = errors.New("update API unavailable")
+	for _, delay := range []time.Duration{defaultUpdatePollInterval / 2, defaultUpdatePollInterval, defaultUpdatePollInterval} {
+		waitForTimer()
+		hooks.Clock.Step(delay)
+		<-checked
+	}
+	waitForTimer()
+	assert.ErrorContains(t, a.health.Ready(), "update-checker has not succeeded in 1h15m0s")
+	assert.NilError(t, a.health.Live())
+
+	listErr = nil
+	hooks.Clock.Step(defaultUpdatePollInterval)
+	<-checked
+	waitForTimer()
+	assert.NilError(t, a.health.Ready())
+
+	cancel()
+	assert.NilError(t, <-done)
+}
+
+func TestJitterPoll(t *testing.T) {
+	defer func(f func() float64) { randJitterFunc = f }(randJitterFunc)
+	for _, tc := range []struct {
+		random   float64
+		expected time.Duration
+	}{
+		{0, 8 * time.Minute},
+		{0.25, 9 * time.Minute},
+		{0.5, 10 * time.Minute},
+		{0.75, 11 * time.Minute},
+	} {
+		randJitterFunc = func() float64 { return tc.random }
+		assert.Equal(t, jitterPoll(10*time.Minute), tc.expected, "with %v", tc.random)
+	}
+
+	randJitterFunc = func() float64 { return 0.9999 }
+	assert.Check(t, jitterPoll(10*time.Minute) < 12*time.Minute)
+}
+
+func TestPollInterval(t *testing.T) {
+	interval, err := (&Config{}).pollInterval()
+	assert.NilError(t, err)
+	assert.Equal(t, interval, defaultUpdatePollInterval)
+	delay, err := (&Config{}).initialPollDelay()
+	assert.NilError(t, err)
+	assert.Equal(t, delay, defaultUpdatePollInterval/2)
+
+	// The initial delay follows the configured interval unless it's set.
+	delay, err = (&Config{UpdatePollInterval: 10 * time.Minute}).initialPollDelay()
+	assert.NilError(t, err)
+	assert.Equal(t, delay, 5*time.Minute)
+	delay, err = (&Config{UpdatePollInterval: 10 * time.Minute, InitialPollDelay: time.Minute}).initialPollDelay()
+	assert.NilError(t, err)
+	assert.Equal(t, delay, time.Minute)
+
+	_, err = (&Config{UpdatePollInterval: -time.Minute}).pollInterval()
+	assert.ErrorContains(t, err, "invalid update poll interval")
+	_, err = (&Config{InitialPollDelay: -time.Minute}).initialPollDelay()
+	assert.ErrorContains(t, err, "invalid initial poll delay")
+	_, err = newAgent(testoutput.Logger(t, logging.New("agent")), intents.NodeName, &testPlatform{}, &testPoster{}, &testProc{},
+		clock.NewFakeClock(time.Now()), Config{UpdatePollInterval: -time.Minute})
+	assert.ErrorContains(t, err, "invalid update poll interval")
+}
+
+// testRefreshPlatform is a platform whose source of updates is refreshed
+// separately from listing them.
+type testRefreshPlatform struct {
+	*testPlatform
+	separate  bool
+	refreshed chan struct{}
+}
+
+func (p *testRefreshPlatform) Refresh(_ context.Context) error {
+	p.refreshed <- struct{}{}
+	return nil
+}
+
+func (p *testRefreshPlatform) RefreshSeparately() {
+	p.separate = true
+}
+
+func TestPeriodicRefresher(t *testing.T) {
+	hooks := &testHooks{
+		Poster:   &testPoster{},
+		Platform: &testPlatform{},
+		Proc:     &testProc{},
+		Clock:    clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)),
+	}
+	refresher := &testRefreshPlatform{testPlatform: hooks.Platform, refreshed: make(chan struct{}, 1)}
+	listed := 0
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		listed++
+		return &testListAvailable{}, nil
+	}
+	interval := 10 * time.Minute
+	a, err := newAgent(testoutput.Logger(t, logging.New("agent")), intents.NodeName, refresher, hooks.Poster, hooks.Proc, hooks.Clock, Config{RefreshInterval: interval})
+	assert.NilError(t, err)
+	assert.Check(t, refresher.separate, "platform should leave refreshing to the agent")
+	assert.Equal(t, a.refreshInterval, interval)
+
+	waitForTimer := func() {
+		for !hooks.Clock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- a.periodicRefresher(ctx)
+	}()
+
+	// Refreshed when starting and then every interval.
+	<-refresher.refreshed
+	for i := 0; i < 2; i++ {
+		waitForTimer()
+		hooks.Clock.Step(interval - time.Second)
+		select {
+		case <-refresher.refreshed:
+			t.Fatal("refreshed before the refresh interval")
+		default:
+		}
+		hooks.Clock.Step(time.Second)
+		<-refresher.refreshed
+	}
+	assert.Equal(t, listed, 0, "refreshing should not list updates")
+
+	// Checking for updates doesn't refresh them.
+	_, err = a.checkUpdate(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, listed, 1)
+	select {
+	case <-refresher.refreshed:
+		t.Fatal("checking for updates refreshed them")
+	default:
+	}
+
+	waitForTimer()
+	cancel()
+	assert.NilError(t, <-done)
+
+	// Platforms that can't be refreshed separately are refreshed as they're
+	// listed.
+	a, err = newAgent(testoutput.Logger(t, logging.New("agent")), intents.NodeName, hooks.Platform, hooks.Poster, hooks.Proc, hooks.Clock, Config{RefreshInterval: interval})
+	assert.NilError(t, err)
+	assert.Equal(t, a.refreshInterval, time.Duration(0))
+}
+
+func TestPostUpToDate(t *testing.T) {
+	noUpdates := func() (platform.Available, error) {
+		return testAvailable{}, nil
+	}
+	partitions := func() (platform.PartitionStatus, error) {
+		return &testPartitionStatus{
+			active: &platform.Partition{Version: "1.2.0", NextToBoot: true},
+		}, nil
+	}
+	upToDateAgent := func(t *testing.T) (*Agent, *testHooks) {
+		a, hooks := testAgent(t)
+		a.kube = fake.NewSimpleClientset(&v1.Node{ObjectMeta: v1meta.ObjectMeta{
+			Name:        a.nodeName,
+			Annotations: intents.Stabilized().GetAnnotations(),
+		}})
+		a.annotateUpToDate = true
+		hooks.Platform.PartitionsFn = partitions
+		return a, hooks
+	}
+	// upToDateMarkers finds the posted up to date markers.
+	upToDateMarkers := func(hooks *testHooks) marker.Container {
+		for _, markers := range hooks.Poster.calledMarkers {
+			if _, ok := markers.GetAnnotations()[marker.UpdateCheckedKey]; ok {
+				return markers
+			}
+		}
+		return nil
+	}
+
+	t.Run("no-update", func(t *testing.T) {
+		a, hooks := upToDateAgent(t)
+		hooks.Platform.ListAvailableFn = noUpdates
+
+		assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+		markers := upToDateMarkers(hooks)
+		assert.Assert(t, markers != nil)
+		assert.DeepEqual(t, markers.GetAnnotations(), map[string]string{
+			marker.UpToDateKey:      "1.2.0",
+			marker.UpdateCheckedKey: "2020-07-10T00:00:00Z",
+		})
+	})
+
+	t.Run("unknown-version", func(t *testing.T) {
+		a, hooks := upToDateAgent(t)
+		hooks.Platform.ListAvailableFn = noUpdates
+		hooks.Platform.PartitionsFn = nil
+
+		assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+		markers := upToDateMarkers(hooks)
+		assert.Assert(t, markers != nil)
+		assert.Equal(t, markers.GetAnnotations()[marker.UpToDateKey], unknownVersion)
+	})
+
+	t.Run("update-available", func(t *testing.T) {
+		a, hooks := upToDateAgent(t)
+
+		assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+		markers := upToDateMarkers(hooks)
+		assert.Assert(t, markers != nil)
+		// The Node isn't up to date, so the annotation is cleared.
+		assert.Equal(t, markers.GetAnnotations()[marker.UpToDateKey], "")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		a, hooks := upToDateAgent(t)
+		a.annotateUpToDate = false
+		hooks.Platform.ListAvailableFn = noUpdates
+
+		assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+		assert.Check(t, upToDateMarkers(hooks) == nil)
+	})
+}
+
+// testImageUpdate is an update describing the image it updates to.
+type testImageUpdate struct {
+	testVersionedUpdate
+	arch    string
+	variant string
+}
+
+func (u testImageUpdate) TargetArch() string {
+	return u.arch
+}
+
+func (u testImageUpdate) TargetVariant() string {
+	return u.variant
+}
+
+func TestChosenUpdateMarkers(t *testing.T) {
+	cases := []struct {
+		name     string
+		updates  []platform.Update
+		expected marker.Annotations
+	}{
+		{
+			name: "image",
+			updates: []platform.Update{
+				testImageUpdate{testVersionedUpdate: "1.1.0", arch: "aarch64", variant: "aws-k8s-1.17"},
+				testImageUpdate{testVersionedUpdate: "1.0.1", arch: "aarch64", variant: "aws-k8s-1.17"},
+			},
+			expected: marker.Annotations{
+				marker.ChosenUpdateVersionKey: "1.1.0",
+				marker.ChosenUpdateArchKey:    "aarch64",
+				marker.ChosenUpdateVariantKey: "aws-k8s-1.17",
+			},
+		},
+		{
+			name:    "versioned",
+			updates: []platform.Update{testVersionedUpdate("1.1.0")},
+			expected: marker.Annotations{
+				marker.ChosenUpdateVersionKey: "1.1.0",
+				marker.ChosenUpdateArchKey:    "",
+				marker.ChosenUpdateVariantKey: "",
+			},
+		},
+		{
+			name: "none",
+			expected: marker.Annotations{
+				marker.ChosenUpdateVersionKey: "",
+				marker.ChosenUpdateArchKey:    "",
+				marker.ChosenUpdateVariantKey: "",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.DeepEqual(t, chosenUpdateMarkers(tc.updates), tc.expected)
+		})
+	}
+}
+
+func TestCheckPostChosenUpdate(t *testing.T) {
+	a, hooks := testAgent(t)
+	a.kube = fake.NewSimpleClientset(&v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        a.nodeName,
+		Annotations: intents.Stabilized().GetAnnotations(),
+	}})
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		return testAvailable{
+			testImageUpdate{testVersionedUpdate: "1.1.0", arch: "x86_64", variant: "aws-ecs-1"},
+		}, nil
+	}
+
+	chosenPosts := func() []map[string]string {
+		var posts []map[string]string
+		for _, markers := range hooks.Poster.calledMarkers {
+			if _, ok := markers.GetAnnotations()[marker.ChosenUpdateVersionKey]; ok {
+				posts = append(posts, markers.GetAnnotations())
+			}
+		}
+		return posts
+	}
+
+	assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+	posts := chosenPosts()
+	assert.Equal(t, len(posts), 1)
+	assert.DeepEqual(t, posts[0], map[string]string{
+		marker.ChosenUpdateVersionKey: "1.1.0",
+		marker.ChosenUpdateArchKey:    "x86_64",
+		marker.ChosenUpdateVariantKey: "aws-ecs-1",
+	})
+
+	// The unchanged chosen update isn't posted again.
+	assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+	assert.Equal(t, len(chosenPosts()), 1)
+}
+
+// reported decodes the records written by a Reporter.
+func reported(t *testing.T, buf *bytes.Buffer) []report.Record {
+	var records []report.Record
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec report.Record
+		assert.NilError(t, dec.Decode(&rec))
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestRealizeReport(t *testing.T) {
+	a, hooks := testAgent(t)
+	var buf bytes.Buffer
+	a.reporter = report.New(&buf, "agent")
+	named := intents.WithNodeName(intents.NodeName)
+
+	assert.NilError(t, a.realize(context.Background(), intents.Stabilized(named, intents.WithUpdateAvailable(marker.NodeUpdateUnavailable))))
+	assert.NilError(t, a.realize(context.Background(), intents.PendingPrepareUpdate(named)))
+	hooks.Platform.UpdateFn = func(platform.Update) error {
+		return errors.New("update failed")
+	}
+	assert.Check(t, a.realize(context.Background(), intents.PendingUpdate(named)) != nil)
+	assert.NilError(t, a.realize(context.Background(), intents.PendingRebootUpdate(named)))
+
+	records := reported(t, &buf)
+	var events []report.Event
+	for _, rec := range records {
+		assert.Equal(t, rec.Component, "agent")
+		assert.Equal(t, rec.Node, intents.NodeName)
+		events = append(events, rec.Event)
+	}
+	assert.DeepEqual(t, events, []report.Event{report.UpdateAvailable, report.Prepared, report.Failure, report.Rebooted})
+	assert.Equal(t, records[1].Active, marker.NodeActionPrepareUpdate)
+	assert.Equal(t, records[2].Wanted, marker.NodeActionPerformUpdate)
+	assert.Equal(t, records[2].State, marker.NodeStateError)
+	assert.Equal(t, records[2].Error, "update failed")
+	assert.Equal(t, records[3].Wanted, marker.NodeActionRebootUpdate)
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"encoding/json"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// updateCondition is the Node condition reporting whether an update is
+// available.
+func updateCondition(available bool, now v1meta.Time) v1.NodeCondition {
+	condition := v1.NodeCondition{
+		Type:               marker.UpdateAvailableCondition,
+		Status:             v1.ConditionFalse,
+		Reason:             "NoUpdateAvailable",
+		Message:            "No update is available",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if available {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = "UpdateAvailable"
+		condition.Message = "An update is available"
+	}
+	return condition
+}
+
+// postUpdateCondition sets the Node's update available condition, skipping the
+// post when the Node already reports the availability.
+func (a *Agent) postUpdateCondition(node *v1.Node, available bool) error {
+	condition := updateCondition(available, v1meta.NewTime(a.clock.Now()))
+	for _, c := range node.Status.Conditions {
+		if c.Type == condition.Type && c.Status == condition.Status {
+			a.log.WithField("condition", c.Status).Debug("update available condition unchanged, skipping post")
+			return nil
+		}
+	}
+	// The conditions are merged by their type, leaving the kubelet's
+	// conditions in place.
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to encode update available condition")
+	}
+	_, err = a.kube.CoreV1().Nodes().PatchStatus(a.nodeName, patch)
+	if err != nil {
+		return errors.WithMessage(err, "unable to post update available condition")
+	}
+	a.log.WithField("condition", condition.Status).Debug("posted update available condition")
+	return nil
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// updateConditionStatus is the status of the Node's update available
+// condition, empty when the condition isn't reported.
+func updateConditionStatus(t *testing.T, client *fake.Clientset, nodeName string) v1.ConditionStatus {
+	node, err := client.CoreV1().Nodes().Get(nodeName, v1meta.GetOptions{})
+	assert.NilError(t, err)
+	for _, c := range node.Status.Conditions {
+		if c.Type == marker.UpdateAvailableCondition {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+func TestPostUpdateCondition(t *testing.T) {
+	a, _ := testAgent(t)
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        a.nodeName,
+		Annotations: intents.Stabilized(intents.WithUpdateAvailable(marker.NodeUpdateUnavailable)).GetAnnotations(),
+	}}
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	client := fake.NewSimpleClientset(node)
+	a.kube = client
+	patches := func() int {
+		var count int
+		for _, action := range client.Actions() {
+			if action.GetVerb() == "patch" && action.GetSubresource() == "status" {
+				count++
+			}
+		}
+		return count
+	}
+
+	assert.NilError(t, a.postUpdateAvailable(context.Background(), false))
+	assert.Equal(t, updateConditionStatus(t, client, a.nodeName), v1.ConditionFalse, "condition should be reported though the annotation is unchanged")
+	assert.Equal(t, patches(), 1)
+
+	assert.NilError(t, a.postUpdateAvailable(context.Background(), true))
+	assert.Equal(t, updateConditionStatus(t, client, a.nodeName), v1.ConditionTrue)
+	assert.Equal(t, patches(), 2)
+
+	// Unchanged availability isn't posted again.
+	assert.NilError(t, a.postUpdateAvailable(context.Background(), true))
+	assert.Equal(t, patches(), 2)
+
+	// The kubelet's conditions are left in place.
+	updated, err := client.CoreV1().Nodes().Get(a.nodeName, v1meta.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(updated.Status.Conditions), 2)
+}
@@ -0,0 +1,189 @@
+package agent
+
+import (
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/api"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/detector"
+	"github.com/pkg/errors"
+)
+
+// Config is the configuration of the Agent's handling of updates.
+type Config struct {
+	// PinnedVersion, when set, limits updates to only this version.
+	PinnedVersion string
+	// BlockedVersions are versions that must not be updated to.
+	BlockedVersions []string
+	// VersionConstraint, when set, is a semver constraint that versions must
+	// satisfy to be updated to (for example: ">= 1.0.0, < 2.0.0").
+	VersionConstraint string
+	// AllowPrerelease permits updating to prerelease versions.
+	AllowPrerelease bool
+	// DetectorCommand, when set, is a command run to detect the available
+	// updates in place of the platform's own listing. The command prints the
+	// available versions, one per line, most preferred first.
+	DetectorCommand []string
+	// DetectorURL, when set, is fetched to detect the available updates in
+	// place of the platform's own listing. It responds with a JSON list of the
+	// available versions, most preferred first.
+	DetectorURL string
+	// MetricsAddr, when set, is the address metrics are served on.
+	MetricsAddr string
+	// HealthAddr, when set, is the address liveness and readiness are served
+	// on for Kubernetes to probe.
+	HealthAddr string
+	// NotifyURL, when set, is a webhook URL that the Node's failed update
+	// actions are posted to as JSON.
+	NotifyURL string
+	// SNSTopicARN, when set, is an Amazon SNS topic that the Node's failed
+	// update actions are published to.
+	SNSTopicARN string
+	// CloudWatchNamespace, when set, is the CloudWatch namespace that the
+	// Node's failed update actions are counted in as a custom metric.
+	CloudWatchNamespace string
+	// CloudWatchRegion is the region the CloudWatch metrics are published
+	// in, found by the AWS SDK's default provider chain when unset.
+	CloudWatchRegion string
+	// TracingEndpoint, when set, is the OTLP/HTTP traces endpoint of the
+	// OpenTelemetry collector that the spans of the Node's actions are
+	// exported to.
+	TracingEndpoint string
+	// NoUpdateUpToDate, when set, treats finding no update to prepare as the
+	// Node being up to date rather than as an error.
+	NoUpdateUpToDate bool
+	// AnnotateUpToDate, when set, annotates the Node with the version it's up
+	// to date with, when no update is available, and the time it last checked
+	// for an update.
+	AnnotateUpToDate bool
+	// CheckIdle, when set, defers preparing an update while the platform is
+	// busy with an update command made out of band.
+	CheckIdle bool
+	// RefreshInterval, when set, is the time between refreshes of the
+	// platform's source of updates, refreshed on its own schedule rather than
+	// each time the updates are listed.
+	RefreshInterval time.Duration
+	// UpdatePollInterval is the time between periodic checks for an update,
+	// defaulting to 30 minutes. It must not be negative.
+	UpdatePollInterval time.Duration
+	// InitialPollDelay is the time after starting before the first periodic
+	// check for an update, defaulting to half of the UpdatePollInterval. It
+	// must not be negative.
+	InitialPollDelay time.Duration
+	// ResumeGrace, when set, is how long after an action was started that an
+	// Agent restarted mid-action resumes the action rather than resetting the
+	// Node's Intent.
+	ResumeGrace time.Duration
+	// CheckFailureThreshold, when set, is the number of consecutive update
+	// checks that fail to list the available updates before the failure is
+	// posted on the Node.
+	CheckFailureThreshold int
+	// ReportEvents, when set, writes the Node's update lifecycle events to
+	// stdout as structured JSON records.
+	ReportEvents bool
+	// PreUpdateCommand, when set, is a command run before the Node reboots
+	// into its update. The update fails, without rebooting, if the command
+	// fails.
+	PreUpdateCommand []string
+	// PostUpdateCommand, when set, is a command run once the Node is
+	// stabilized after rebooting into its update. The command failing is
+	// logged and doesn't fail the update.
+	PostUpdateCommand []string
+	// HookTimeout bounds the run time of the update commands, defaulting to 5
+	// minutes.
+	HookTimeout time.Duration
+	// DryRun, when set, logs the platform actions the Agent would take to
+	// realize each Intent without taking them. The Intent's progress is still
+	// posted so that the update coordination can be observed end to end.
+	DryRun bool
+	// HoldLabel is the label that, when present on the Node, holds the Node at
+	// its current step, defaulting to marker.HoldKey.
+	HoldLabel string
+	// APITimeout bounds each request to the update API, defaulting to 10
+	// seconds.
+	APITimeout time.Duration
+	// APIMaxAttempts is the most times a request to the update API is
+	// attempted while the API is busy or unreachable, defaulting to 5.
+	APIMaxAttempts int
+	// APIRetryDelay is the time waited before first retrying a request to the
+	// update API, doubled for each retry after, defaulting to 2 seconds.
+	APIRetryDelay time.Duration
+	// APIRetryDeadline bounds the time spent retrying a request to the update
+	// API, defaulting to 1 minute.
+	APIRetryDeadline time.Duration
+	// MinimumOSVersion, when set, is the lowest host OS version the Agent
+	// updates through the update API, defaulting to the version the API was
+	// added in.
+	MinimumOSVersion string
+	// APISocket, when set, is the path of the update API's socket, defaulting
+	// to the host's /run/api.sock.
+	APISocket string
+}
+
+// detector returns the configured update detector, if any.
+func (c *Config) detector() (detector.Detector, error) {
+	switch {
+	case len(c.DetectorCommand) != 0 && c.DetectorURL != "":
+		return nil, errors.New("only one of a detector command or URL may be used")
+	case len(c.DetectorCommand) != 0:
+		return &detector.Command{Path: c.DetectorCommand[0], Args: c.DetectorCommand[1:]}, nil
+	case c.DetectorURL != "":
+		return &detector.HTTP{URL: c.DetectorURL}, nil
+	}
+	return nil, nil
+}
+
+func (c *Config) apiConfig() api.ClientConfig {
+	return api.ClientConfig{
+		Timeout:          c.APITimeout,
+		MaxAttempts:      c.APIMaxAttempts,
+		RetryDelay:       c.APIRetryDelay,
+		RetryDeadline:    c.APIRetryDeadline,
+		MinimumOSVersion: c.MinimumOSVersion,
+		SocketPath:       c.APISocket,
+	}
+}
+
+func (c *Config) pollInterval() (time.Duration, error) {
+	if c.UpdatePollInterval < 0 {
+		return 0, errors.Errorf("invalid update poll interval %s, must be positive", c.UpdatePollInterval)
+	}
+	if c.UpdatePollInterval == 0 {
+		return defaultUpdatePollInterval, nil
+	}
+	return c.UpdatePollInterval, nil
+}
+
+func (c *Config) initialPollDelay() (time.Duration, error) {
+	if c.InitialPollDelay < 0 {
+		return 0, errors.Errorf("invalid initial poll delay %s, must not be negative", c.InitialPollDelay)
+	}
+	if c.InitialPollDelay == 0 {
+		interval, err := c.pollInterval()
+		return interval / 2, err
+	}
+	return c.InitialPollDelay, nil
+}
+
+func (c *Config) notifySinks() ([]notify.Sink, error) {
+	var sinks []notify.Sink
+	if c.NotifyURL != "" {
+		sinks = append(sinks, &notify.Webhook{URL: c.NotifyURL})
+	}
+	if c.SNSTopicARN != "" {
+		topic, err := notify.NewSNS(c.SNSTopicARN)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, topic)
+	}
+	return sinks, nil
+}
+
+func (c *Config) holdLabel() string {
+	if c.HoldLabel == "" {
+		return marker.HoldKey
+	}
+	return c.HoldLabel
+}
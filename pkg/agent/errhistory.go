@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/pkg/errors"
+)
+
+// maxErrorHistory is the number of most recent errors kept in a Node's error
+// history.
+const maxErrorHistory = 5
+
+// errorRecord is an entry in a Node's error history.
+type errorRecord struct {
+	Time   time.Time         `json:"time"`
+	Action marker.NodeAction `json:"action"`
+	Error  string            `json:"error"`
+}
+
+// errorHistory is a bounded list of a Node's most recent errors, oldest
+// first.
+type errorHistory []errorRecord
+
+// parseErrorHistory reads the error history as posted on a Node.
+func parseErrorHistory(value string) (errorHistory, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var history errorHistory
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, errors.Wrap(err, "invalid error history")
+	}
+	return history.truncated(), nil
+}
+
+// append adds the record to the history, dropping the oldest records beyond
+// the bound.
+func (h errorHistory) append(record errorRecord) errorHistory {
+	return append(h, record).truncated()
+}
+
+func (h errorHistory) truncated() errorHistory {
+	if len(h) <= maxErrorHistory {
+		return h
+	}
+	return append(errorHistory(nil), h[len(h)-maxErrorHistory:]...)
+}
+
+// markers describes the history as annotations to be posted on the Node.
+func (h errorHistory) markers() (marker.Annotations, error) {
+	value, err := json.Marshal(h)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode error history")
+	}
+	return marker.Annotations{marker.ErrorHistoryKey: string(value)}, nil
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+
+	"gotest.tools/assert"
+)
+
+func testErrorRecord(n int) errorRecord {
+	return errorRecord{
+		Time:   time.Date(2020, 7, 10, 0, n, 0, 0, time.UTC),
+		Action: marker.NodeActionPrepareUpdate,
+		Error:  fmt.Sprintf("error %d", n),
+	}
+}
+
+func TestErrorHistoryAppend(t *testing.T) {
+	var history errorHistory
+	for i := 0; i < maxErrorHistory; i++ {
+		history = history.append(testErrorRecord(i))
+		assert.Equal(t, len(history), i+1)
+	}
+	assert.Equal(t, history[0], testErrorRecord(0))
+	assert.Equal(t, history[maxErrorHistory-1], testErrorRecord(maxErrorHistory-1))
+}
+
+func TestErrorHistoryTruncate(t *testing.T) {
+	var history errorHistory
+	total := maxErrorHistory + 3
+	for i := 0; i < total; i++ {
+		history = history.append(testErrorRecord(i))
+	}
+	assert.Equal(t, len(history), maxErrorHistory)
+	// The oldest errors are dropped.
+	assert.Equal(t, history[0], testErrorRecord(total-maxErrorHistory))
+	assert.Equal(t, history[maxErrorHistory-1], testErrorRecord(total-1))
+}
+
+func TestErrorHistoryRoundTrip(t *testing.T) {
+	history := errorHistory{testErrorRecord(1), testErrorRecord(2)}
+	markers, err := history.markers()
+	assert.NilError(t, err)
+
+	parsed, err := parseErrorHistory(markers[marker.ErrorHistoryKey])
+	assert.NilError(t, err)
+	assert.DeepEqual(t, parsed, history)
+
+	parsed, err = parseErrorHistory("")
+	assert.NilError(t, err)
+	assert.Equal(t, len(parsed), 0)
+
+	_, err = parseErrorHistory("not json")
+	assert.Check(t, err != nil)
+}
+
+func TestRealizeRecordsErrors(t *testing.T) {
+	a, hooks := testAgent(t)
+	hooks.Platform.PrepareFn = func(_ platform.Update) error {
+		return fmt.Errorf("prepare failed")
+	}
+
+	err := a.realize(context.Background(), intents.PendingPrepareUpdate())
+	assert.Check(t, err != nil)
+	assert.Equal(t, len(a.errHistory), 1)
+	assert.Equal(t, a.errHistory[0].Action, marker.NodeActionPrepareUpdate)
+	assert.Equal(t, a.errHistory[0].Error, "prepare failed")
+	assert.Equal(t, a.errHistory[0].Time, hooks.Clock.Now().UTC())
+
+	posted := hooks.Poster.calledMarkers[len(hooks.Poster.calledMarkers)-1]
+	assert.Check(t, posted.GetAnnotations()[marker.ErrorHistoryKey] != "")
+}
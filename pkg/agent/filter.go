@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+)
+
+// skipReason describes why an update was filtered out.
+type skipReason string
+
+const (
+	skipNone       skipReason = ""
+	skipPinned     skipReason = "updates are pinned to another version"
+	skipBlocked    skipReason = "version is blocked"
+	skipOutOfRange skipReason = "version is outside of the permitted range"
+	skipPrerelease skipReason = "version is a prerelease"
+	skipUnparsable skipReason = "version is not valid semver"
+	skipRolledBack skipReason = "version was rolled back"
+)
+
+// updateFilter excludes updates that the Agent is configured not to apply.
+type updateFilter struct {
+	pinned     *semver.Version
+	blocked    []*semver.Version
+	constraint *semver.Constraints
+	prerelease bool
+}
+
+func newUpdateFilter(config Config) (*updateFilter, error) {
+	f := &updateFilter{prerelease: config.AllowPrerelease}
+	if config.PinnedVersion != "" {
+		v, err := semver.NewVersion(config.PinnedVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pinned version %q", config.PinnedVersion)
+		}
+		f.pinned = v
+	}
+	for _, blocked := range config.BlockedVersions {
+		v, err := semver.NewVersion(blocked)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid blocked version %q", blocked)
+		}
+		f.blocked = append(f.blocked, v)
+	}
+	if config.VersionConstraint != "" {
+		c, err := semver.NewConstraint(config.VersionConstraint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version constraint %q", config.VersionConstraint)
+		}
+		f.constraint = c
+	}
+	return f, nil
+}
+
+// configured reports whether the filter restricts the versions updated to.
+func (f *updateFilter) configured() bool {
+	return f.pinned != nil || len(f.blocked) != 0 || f.constraint != nil
+}
+
+// sortByVersion orders the updates from the highest version to the lowest.
+// Updates that don't identify a valid version are ordered last, in their
+// given order.
+func sortByVersion(updates []platform.Update) {
+	version := func(u platform.Update) *semver.Version {
+		vu, ok := u.(platform.VersionedUpdate)
+		if !ok {
+			return nil
+		}
+		v, err := semver.NewVersion(vu.TargetVersion())
+		if err != nil {
+			return nil
+		}
+		return v
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		vi, vj := version(updates[i]), version(updates[j])
+		switch {
+		case vi == nil:
+			return false
+		case vj == nil:
+			return true
+		case vi.Equal(vj):
+			// Equal versions may be written differently, such as with a "v"
+			// prefix, order them consistently.
+			return vi.Original() < vj.Original()
+		}
+		return vi.GreaterThan(vj)
+	})
+}
+
+// skipReason returns the reason the update must be skipped, skipNone is
+// returned when the update is permitted. Updates that don't identify their
+// version can't be filtered and are permitted.
+func (f *updateFilter) skipReason(u platform.Update) skipReason {
+	vu, ok := u.(platform.VersionedUpdate)
+	if !ok {
+		return skipNone
+	}
+	v, err := semver.NewVersion(vu.TargetVersion())
+	if err != nil {
+		return skipUnparsable
+	}
+	if f.pinned != nil && !v.Equal(f.pinned) {
+		return skipPinned
+	}
+	for _, blocked := range f.blocked {
+		if v.Equal(blocked) {
+			return skipBlocked
+		}
+	}
+	// A pinned prerelease is an explicit request for it.
+	if !f.prerelease && f.pinned == nil && v.Prerelease() != "" {
+		return skipPrerelease
+	}
+	if f.constraint != nil && !f.constraint.Check(v) {
+		return skipOutOfRange
+	}
+	return skipNone
+}
+
+// skipLog tracks the reason each update was last skipped for, so that a skip
+// is logged when it's first seen or its reason changes rather than on every
+// check for updates.
+type skipLog struct {
+	mu      sync.Mutex
+	reasons map[string]skipReason
+}
+
+// Changed records the updates skipped by a check, by their identifier, and
+// returns the identifiers of those not skipped for the same reason before,
+// in order.
+func (l *skipLog) Changed(skipped map[string]skipReason) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var changed []string
+	for id, reason := range skipped {
+		if l.reasons[id] != reason {
+			changed = append(changed, id)
+		}
+	}
+	sort.Strings(changed)
+	l.reasons = skipped
+	return changed
+}
+
+// rolledBackUpdate reports whether the update is to the version the Node was
+// rolled back from.
+func rolledBackUpdate(u platform.Update, version string) bool {
+	if version == "" {
+		return false
+	}
+	vu, ok := u.(platform.VersionedUpdate)
+	return ok && platform.SameVersion(vu.TargetVersion(), version)
+}
+
+// selectTargetVersion returns the update to the target version. An error is
+// returned when the version isn't among the updates rather than falling back
+// to another update.
+func selectTargetVersion(updates []platform.Update, version string) (platform.Update, error) {
+	var versions []string
+	for _, u := range updates {
+		vu, ok := u.(platform.VersionedUpdate)
+		if !ok {
+			continue
+		}
+		if platform.SameVersion(vu.TargetVersion(), version) {
+			return u, nil
+		}
+		versions = append(versions, vu.TargetVersion())
+	}
+	return nil, errors.Errorf("target version %s is not available, available versions: [%s]", version, strings.Join(versions, ", "))
+}
@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+
+	"gotest.tools/assert"
+)
+
+type testVersionedUpdate string
+
+func (u testVersionedUpdate) Identifier() interface{} {
+	return string(u)
+}
+
+func (u testVersionedUpdate) TargetVersion() string {
+	return string(u)
+}
+
+type testAvailable []platform.Update
+
+func (a testAvailable) Updates() []platform.Update {
+	return a
+}
+
+func TestUpdateFilterSkipReason(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   Config
+		version  string
+		expected skipReason
+	}{
+		{name: "unfiltered", config: Config{}, version: "1.0.0", expected: skipNone},
+		{name: "pinned", config: Config{PinnedVersion: "1.0.0"}, version: "1.0.0", expected: skipNone},
+		{name: "pinned-other", config: Config{PinnedVersion: "1.0.0"}, version: "1.1.0", expected: skipPinned},
+		{name: "blocked", config: Config{BlockedVersions: []string{"0.9.0", "1.1.0"}}, version: "1.1.0", expected: skipBlocked},
+		{name: "in-range", config: Config{VersionConstraint: "< 2.0.0"}, version: "1.1.0", expected: skipNone},
+		{name: "out-of-range", config: Config{VersionConstraint: "< 1.1.0"}, version: "1.1.0", expected: skipOutOfRange},
+		{name: "prerelease", config: Config{}, version: "1.1.0-rc1", expected: skipPrerelease},
+		{name: "prerelease-allowed", config: Config{AllowPrerelease: true}, version: "1.1.0-rc1", expected: skipNone},
+		{name: "prerelease-pinned", config: Config{PinnedVersion: "1.1.0-rc1"}, version: "1.1.0-rc1", expected: skipNone},
+		{name: "unparsable", config: Config{}, version: "latest", expected: skipUnparsable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := newUpdateFilter(tc.config)
+			assert.NilError(t, err)
+			assert.Equal(t, f.skipReason(testVersionedUpdate(tc.version)), tc.expected)
+		})
+	}
+}
+
+func TestUpdateFilterBuildMetadata(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   Config
+		version  string
+		expected skipReason
+	}{
+		{name: "pinned", config: Config{PinnedVersion: "1.28.0"}, version: "1.28.0+abc", expected: skipNone},
+		{name: "pinned-metadata", config: Config{PinnedVersion: "1.28.0+abc"}, version: "1.28.0", expected: skipNone},
+		{name: "pinned-other-metadata", config: Config{PinnedVersion: "1.28.0+abc"}, version: "1.28.0+def", expected: skipNone},
+		{name: "blocked", config: Config{BlockedVersions: []string{"1.28.0"}}, version: "1.28.0+abc", expected: skipBlocked},
+		{name: "blocked-metadata", config: Config{BlockedVersions: []string{"1.28.0+abc"}}, version: "1.28.0", expected: skipBlocked},
+		{name: "constrained", config: Config{VersionConstraint: "<= 1.28.0"}, version: "1.28.0+abc", expected: skipNone},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := newUpdateFilter(tc.config)
+			assert.NilError(t, err)
+			assert.Equal(t, f.skipReason(testVersionedUpdate(tc.version)), tc.expected)
+		})
+	}
+}
+
+func TestUpdateFilterReasonsDistinct(t *testing.T) {
+	reasons := []skipReason{skipPinned, skipBlocked, skipOutOfRange, skipPrerelease, skipUnparsable, skipRolledBack}
+	seen := map[skipReason]bool{}
+	for _, reason := range reasons {
+		assert.Check(t, reason != skipNone)
+		assert.Check(t, !seen[reason], "reason %q is not distinct", reason)
+		seen[reason] = true
+	}
+}
+
+func TestUpdateFilterInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{PinnedVersion: "not-a-version"},
+		{BlockedVersions: []string{"1.0.0", "not-a-version"}},
+		{VersionConstraint: "~> not-a-version"},
+	} {
+		_, err := newUpdateFilter(config)
+		assert.Check(t, err != nil)
+	}
+}
+
+func TestAvailableUpdatesFiltered(t *testing.T) {
+	a, hooks := testAgent(t)
+	f, err := newUpdateFilter(Config{BlockedVersions: []string{"1.1.0"}})
+	assert.NilError(t, err)
+	a.filter = f
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		return testAvailable{testVersionedUpdate("1.1.0"), testVersionedUpdate("1.0.0")}, nil
+	}
+
+	ups, err := a.availableUpdates(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(ups), 1)
+	assert.Equal(t, ups[0].Identifier(), "1.0.0")
+}
+
+func TestSkipLogChanged(t *testing.T) {
+	var l skipLog
+	assert.DeepEqual(t, l.Changed(map[string]skipReason{"1.2.0": skipBlocked, "1.1.0": skipPrerelease}), []string{"1.1.0", "1.2.0"})
+	// The same skips aren't logged again.
+	assert.Check(t, len(l.Changed(map[string]skipReason{"1.2.0": skipBlocked, "1.1.0": skipPrerelease})) == 0)
+	assert.DeepEqual(t, l.Changed(map[string]skipReason{"1.2.0": skipOutOfRange}), []string{"1.2.0"})
+	// An update skipped again after being permitted is logged anew.
+	assert.Check(t, len(l.Changed(map[string]skipReason{})) == 0)
+	assert.DeepEqual(t, l.Changed(map[string]skipReason{"1.2.0": skipOutOfRange}), []string{"1.2.0"})
+}
+
+func TestAvailableUpdatesRolledBack(t *testing.T) {
+	a, hooks := testAgent(t)
+	a.rollbackVersion = "1.1.0"
+	hooks.Platform.ListAvailableFn = func() (platform.Available, error) {
+		return testAvailable{testVersionedUpdate("v1.1.0"), testVersionedUpdate("1.0.0")}, nil
+	}
+
+	ups, err := a.availableUpdates(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(ups), 1)
+	assert.Equal(t, ups[0].Identifier(), "1.0.0")
+}
+
+func TestAvailableUpdatesHighest(t *testing.T) {
+	listed := []string{"1.1.0", "1.3.0-rc1", "0.9.0", "latest", "1.2.0"}
+	cases := []struct {
+		name     string
+		config   Config
+		target   string
+		expected []string
+	}{
+		// Only the platform's preference is listed without filters.
+		{name: "unfiltered", config: Config{}, expected: []string{"1.1.0"}},
+		// A target version may be any of the updates.
+		{name: "targeted", config: Config{}, target: "1.2.0", expected: []string{"1.1.0", "0.9.0", "1.2.0"}},
+		{name: "blocked", config: Config{BlockedVersions: []string{"1.2.0"}}, expected: []string{"1.1.0", "0.9.0"}},
+		{name: "constrained", config: Config{VersionConstraint: ">= 1.0.0"}, expected: []string{"1.2.0", "1.1.0"}},
+		{name: "pinned", config: Config{PinnedVersion: "0.9.0"}, expected: []string{"0.9.0"}},
+		{name: "prerelease", config: Config{BlockedVersions: []string{"1.2.0"}, AllowPrerelease: true}, expected: []string{"1.3.0-rc1", "1.1.0", "0.9.0"}},
+		{name: "none-permitted", config: Config{VersionConstraint: "> 2.0.0"}, expected: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := newUpdateFilter(tc.config)
+			assert.NilError(t, err)
+
+			available := func(versions []string) func() (platform.Available, error) {
+				return func() (platform.Available, error) {
+					var updates testAvailable
+					for _, v := range versions {
+						updates = append(updates, testVersionedUpdate(v))
+					}
+					return updates, nil
+				}
+			}
+			identifiers := func(ups []platform.Update) []string {
+				var ids []string
+				for _, up := range ups {
+					ids = append(ids, up.Identifier().(string))
+				}
+				return ids
+			}
+
+			a, hooks := testAgent(t)
+			a.filter = f
+			a.targetVersion = tc.target
+			hooks.Platform.ListAvailableFn = available(listed)
+			ups, err := a.availableUpdates(context.Background())
+			assert.NilError(t, err)
+			assert.DeepEqual(t, identifiers(ups), tc.expected)
+
+			if !f.configured() {
+				return
+			}
+			// The selection doesn't depend on the platform's order.
+			reversed := make([]string, len(listed))
+			for i, v := range listed {
+				reversed[len(listed)-1-i] = v
+			}
+			hooks.Platform.ListAvailableFn = available(reversed)
+			ups, err = a.availableUpdates(context.Background())
+			assert.NilError(t, err)
+			assert.DeepEqual(t, identifiers(ups), tc.expected)
+		})
+	}
+}
+
+func TestSortByVersion(t *testing.T) {
+	updates := []platform.Update{
+		testVersionedUpdate("1.0.0"),
+		testVersionedUpdate("not-a-version"),
+		testVersionedUpdate("v1.10.0"),
+		testVersionedUpdate("1.10.0"),
+		testVersionedUpdate("1.9.0"),
+	}
+	sortByVersion(updates)
+	var versions []string
+	for _, u := range updates {
+		versions = append(versions, u.Identifier().(string))
+	}
+	assert.DeepEqual(t, versions, []string{"1.10.0", "v1.10.0", "1.9.0", "1.0.0", "not-a-version"})
+
+	// Versions differing only in build metadata are equal, ordered
+	// consistently regardless of the order given.
+	for _, given := range [][]string{{"1.28.0+def", "1.27.0", "1.28.0+abc"}, {"1.28.0+abc", "1.28.0+def", "1.27.0"}} {
+		updates = nil
+		for _, v := range given {
+			updates = append(updates, testVersionedUpdate(v))
+		}
+		sortByVersion(updates)
+		versions = nil
+		for _, u := range updates {
+			versions = append(versions, u.Identifier().(string))
+		}
+		assert.DeepEqual(t, versions, []string{"1.28.0+abc", "1.28.0+def", "1.27.0"})
+	}
+}
+
+func TestSelectTargetVersion(t *testing.T) {
+	updates := []platform.Update{
+		testVersionedUpdate("1.2.0"),
+		testVersionedUpdate("1.1.0+abc"),
+	}
+	u, err := selectTargetVersion(updates, "1.1.0")
+	assert.NilError(t, err)
+	assert.Equal(t, u.Identifier(), "1.1.0+abc")
+
+	_, err = selectTargetVersion(updates, "1.0.0")
+	assert.Error(t, err, "target version 1.0.0 is not available, available versions: [1.2.0, 1.1.0+abc]")
+}
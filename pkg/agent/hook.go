@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/pkg/errors"
+)
+
+const defaultHookTimeout = 5 * time.Minute
+
+// hook is a command run around the Node's update, such as to quiesce a
+// workload before the Node reboots.
+type hook struct {
+	name    string
+	path    string
+	args    []string
+	timeout time.Duration
+}
+
+// newHook creates the named hook running the command, there's no hook when
+// the command is empty.
+func newHook(name string, command []string, timeout time.Duration) *hook {
+	if len(command) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	return &hook{name: name, path: command[0], args: command[1:], timeout: timeout}
+}
+
+// Run runs the hook's command, logging its output. The command failing, by
+// exiting non-zero or running past the timeout, is returned as an error.
+func (h *hook) Run(log logging.Logger) error {
+	if h == nil {
+		return nil
+	}
+	log = log.WithField("hook", h.name)
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	log.WithField("command", h.path).Info("running hook")
+	cmd := exec.CommandContext(ctx, h.path, h.args...)
+	out, err := cmd.CombinedOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		log.WithField("output", scanner.Text()).Info("hook output")
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("%s hook timed out after %s", h.name, h.timeout)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "%s hook failed", h.name)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"gotest.tools/assert"
+)
+
+func TestHook(t *testing.T) {
+	log := testoutput.Logger(t, logging.New("agent"))
+
+	var none *hook
+	assert.Check(t, newHook("pre-update", nil, 0) == nil)
+	assert.NilError(t, none.Run(log))
+
+	h := newHook("pre-update", []string{"true"}, 0)
+	assert.Equal(t, h.timeout, defaultHookTimeout)
+	assert.NilError(t, h.Run(log))
+
+	err := newHook("pre-update", []string{"false"}, 0).Run(log)
+	assert.ErrorContains(t, err, "pre-update hook failed")
+
+	err = newHook("pre-update", []string{"sleep", "5"}, 10*time.Millisecond).Run(log)
+	assert.ErrorContains(t, err, "timed out")
+}
+
+func TestHookOutput(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logs := logtest.NewLocal(logger)
+
+	h := newHook("post-update", []string{"printf", "quiesced\nvalidated\n"}, 0)
+	assert.NilError(t, h.Run(logrus.NewEntry(logger)))
+	var output []interface{}
+	for _, entry := range logs.AllEntries() {
+		if line, ok := entry.Data["output"]; ok {
+			assert.Equal(t, entry.Data["hook"], "post-update")
+			output = append(output, line)
+		}
+	}
+	assert.DeepEqual(t, output, []interface{}{"quiesced", "validated"})
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"time"
+)
+
+const (
+	// kubeUnavailableThreshold is the number of consecutive failures to reach
+	// the Kubernetes API after which it's considered unavailable.
+	kubeUnavailableThreshold = 3
+	// kubeBackoffBase and kubeBackoffMax bound the time waited before trying
+	// an unavailable Kubernetes API again.
+	kubeBackoffBase = defaultUpdatePollInterval
+	kubeBackoffMax  = 8 * defaultUpdatePollInterval
+)
+
+// kubeBackoff tracks consecutive failures to reach the Kubernetes API so that
+// sustained unavailability is backed off from, and reported once, rather than
+// failing noisily on every attempt.
+type kubeBackoff struct {
+	failures int
+	retryAt  time.Time
+}
+
+// Ready reports whether the API should be tried.
+func (k *kubeBackoff) Ready(now time.Time) bool {
+	return !now.Before(k.retryAt)
+}
+
+// Unavailable reports whether the API has failed persistently.
+func (k *kubeBackoff) Unavailable() bool {
+	return k.failures >= kubeUnavailableThreshold
+}
+
+// Failed records a failure to reach the API, returning the time waited before
+// the API is tried again and whether the API just became unavailable.
+func (k *kubeBackoff) Failed(now time.Time) (time.Duration, bool) {
+	k.failures++
+	if !k.Unavailable() {
+		return 0, false
+	}
+	delay := kubeBackoffBase
+	for i := kubeUnavailableThreshold; i < k.failures && delay < kubeBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > kubeBackoffMax {
+		delay = kubeBackoffMax
+	}
+	k.retryAt = now.Add(delay)
+	return delay, k.failures == kubeUnavailableThreshold
+}
+
+// Succeeded records reaching the API, returning whether it was previously
+// unavailable.
+func (k *kubeBackoff) Succeeded() bool {
+	recovered := k.Unavailable()
+	k.failures = 0
+	k.retryAt = time.Time{}
+	return recovered
+}
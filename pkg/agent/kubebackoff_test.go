@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKubeBackoffDelays(t *testing.T) {
+	now := time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC)
+	var k kubeBackoff
+	for i := 1; i < kubeUnavailableThreshold; i++ {
+		delay, unavailable := k.Failed(now)
+		assert.Equal(t, delay, time.Duration(0))
+		assert.Check(t, !unavailable)
+		assert.Check(t, k.Ready(now))
+	}
+
+	expected := []time.Duration{kubeBackoffBase, 2 * kubeBackoffBase, 4 * kubeBackoffBase, kubeBackoffMax, kubeBackoffMax}
+	for i, expectedDelay := range expected {
+		delay, unavailable := k.Failed(now)
+		assert.Equal(t, delay, expectedDelay)
+		// Only the first failure past the threshold is newly unavailable.
+		assert.Equal(t, unavailable, i == 0)
+		assert.Check(t, !k.Ready(now))
+		assert.Check(t, k.Ready(now.Add(delay)))
+	}
+
+	assert.Check(t, k.Succeeded())
+	assert.Check(t, !k.Unavailable())
+	assert.Check(t, k.Ready(now))
+	assert.Check(t, !k.Succeeded(), "already available")
+}
+
+func TestPostUpdateAvailableBackoff(t *testing.T) {
+	a, hooks := testAgent(t)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logs := logtest.NewLocal(logger)
+	a.log = logrus.NewEntry(logger)
+
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        a.nodeName,
+		Annotations: intents.Stabilized().GetAnnotations(),
+	}}
+	client := fake.NewSimpleClientset(node)
+	down := true
+	gets := 0
+	client.PrependReactor("get", "nodes", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		gets++
+		if down {
+			return true, nil, errors.New("connection refused")
+		}
+		return false, nil, nil
+	})
+	a.kube = client
+
+	const polls = 10
+	for i := 0; i < polls; i++ {
+		hooks.Clock.Step(defaultUpdatePollInterval)
+		assert.Check(t, a.checkPostUpdate(context.Background(), a.log) != nil)
+	}
+	assert.Check(t, gets < polls, "expected backoff, api tried %d times", gets)
+
+	levels := map[logrus.Level]int{}
+	for _, entry := range logs.AllEntries() {
+		levels[entry.Level]++
+	}
+	assert.Equal(t, levels[logrus.WarnLevel], 1)
+	assert.Equal(t, levels[logrus.ErrorLevel], kubeUnavailableThreshold-1)
+
+	down = false
+	hooks.Clock.Step(kubeBackoffMax)
+	assert.NilError(t, a.checkPostUpdate(context.Background(), a.log))
+	assert.Check(t, !a.kubeBackoff.Unavailable())
+	recovered := false
+	for _, entry := range logs.AllEntries() {
+		recovered = recovered || entry.Message == "kubernetes api available again"
+	}
+	assert.Check(t, recovered, "recovery should be reported")
+}
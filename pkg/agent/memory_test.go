@@ -0,0 +1,435 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// memoryPoster is a poster that keeps the Node in memory. Posted Intents and
+// markers are applied to the Node, which can be handed back to the Agent as
+// the next event, and are recorded in the order they were posted.
+type memoryPoster struct {
+	mu      sync.Mutex
+	node    *v1.Node
+	intents []intent.Intent
+	markers []marker.Container
+}
+
+func newMemoryPoster(nodeName string) *memoryPoster {
+	return &memoryPoster{
+		node: &v1.Node{
+			ObjectMeta: v1meta.ObjectMeta{
+				Name:        nodeName,
+				Annotations: map[string]string{},
+				Labels:      map[string]string{},
+			},
+		},
+	}
+}
+
+func (p *memoryPoster) Post(i *intent.Intent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	marker.OverwriteFrom(i, p.node)
+	p.intents = append(p.intents, *i.Clone())
+	return nil
+}
+
+func (p *memoryPoster) PostMarkers(_ string, markers marker.Container) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	marker.OverwriteFrom(markers, p.node)
+	p.markers = append(p.markers, markers)
+	return nil
+}
+
+// Want sets the Node's wanted action as the Controller would, it isn't
+// recorded as a post.
+func (p *memoryPoster) Want(action marker.NodeAction) *v1.Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.node.Annotations[marker.NodeActionWanted] = action
+	return p.node.DeepCopy()
+}
+
+// Reset resets the Node's intent as the Controller does to bring it back to
+// stabilize, it isn't recorded as a post.
+func (p *memoryPoster) Reset() *v1.Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	marker.OverwriteFrom(intent.Given(p.node).Reset(), p.node)
+	return p.node.DeepCopy()
+}
+
+// Node returns a copy of the Node as it is now.
+func (p *memoryPoster) Node() *v1.Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.node.DeepCopy()
+}
+
+// Posted returns the Intents posted since the last call.
+func (p *memoryPoster) Posted() []intent.Intent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	posted := p.intents
+	p.intents = nil
+	return posted
+}
+
+// memoryProc is a proc that records its terminations and kills rather than
+// stopping the process, a termination cancels the Agent's context.
+type memoryProc struct {
+	mu           sync.Mutex
+	terminations int
+	kills        int
+	stop         context.CancelFunc
+}
+
+func (p *memoryProc) TerminateProcess() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.terminations++
+	p.stop()
+	return nil
+}
+
+func (p *memoryProc) Terminations() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.terminations
+}
+
+func (p *memoryProc) KillProcess() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.kills++
+	return nil
+}
+
+func (p *memoryProc) Kills() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.kills
+}
+
+// memoryAgent constructs an Agent that posts to, and is killed through,
+// in-memory implementations.
+func memoryAgent(t *testing.T, config Config) (*Agent, *memoryPoster, *memoryProc) {
+	poster := newMemoryPoster(intents.NodeName)
+	proc := &memoryProc{}
+	log := testoutput.Logger(t, logging.New("agent"))
+	clk := clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC))
+	a, err := newAgent(log, intents.NodeName, &testPlatform{}, poster, proc, clk, config)
+	assert.NilError(t, err)
+	a.ctx, proc.stop = context.WithCancel(context.Background())
+	return a, poster, proc
+}
+
+func TestMemoryAgentUpdate(t *testing.T) {
+	a, poster, proc := memoryAgent(t, Config{})
+	assert.NilError(t, poster.Post(intents.Stabilized(intents.WithUpdateAvailable())))
+	poster.Posted()
+
+	steps := []marker.NodeAction{
+		marker.NodeActionPrepareUpdate,
+		marker.NodeActionPerformUpdate,
+	}
+	for _, action := range steps {
+		a.handleEvent(poster.Want(action))
+		posted := poster.Posted()
+		assert.Equal(t, len(posted), 2, "%s should be acknowledged and then realized", action)
+		assert.Equal(t, posted[0].Active, action)
+		assert.Equal(t, posted[0].State, marker.NodeStateBusy)
+		assert.Equal(t, posted[1].Active, action)
+		assert.Equal(t, posted[1].State, marker.NodeStateReady)
+	}
+
+	a.handleEvent(poster.Want(marker.NodeActionRebootUpdate))
+	posted := poster.Posted()
+	assert.Equal(t, len(posted), 1, "reboot should only be acknowledged")
+	assert.Equal(t, posted[0].State, marker.NodeStateBusy)
+	assert.Equal(t, proc.Terminations(), 1)
+	assert.Equal(t, proc.Kills(), 0, "agent stopped gracefully should not be killed")
+}
+
+func TestMemoryAgentDryRun(t *testing.T) {
+	a, poster, proc := memoryAgent(t, Config{DryRun: true})
+	skipped := func(action string) func(platform.Update) error {
+		return func(platform.Update) error {
+			t.Errorf("%s should be skipped as a dry run", action)
+			return nil
+		}
+	}
+	a.platform = &testPlatform{
+		PrepareFn: skipped("prepare"),
+		UpdateFn:  skipped("update"),
+		BootUpdateFn: func(platform.Update, bool) error {
+			t.Error("reboot should be skipped as a dry run")
+			return nil
+		},
+	}
+	assert.NilError(t, poster.Post(intents.Stabilized(intents.WithUpdateAvailable())))
+	poster.Posted()
+
+	// Each step is acknowledged and realized as if it were taken, including
+	// the reboot.
+	steps := []marker.NodeAction{
+		marker.NodeActionPrepareUpdate,
+		marker.NodeActionPerformUpdate,
+		marker.NodeActionRebootUpdate,
+	}
+	for _, action := range steps {
+		a.handleEvent(poster.Want(action))
+		posted := poster.Posted()
+		assert.Equal(t, len(posted), 2, "%s should be acknowledged and then realized", action)
+		assert.Equal(t, posted[0].Active, action)
+		assert.Equal(t, posted[0].State, marker.NodeStateBusy)
+		assert.Equal(t, posted[1].Active, action)
+		assert.Equal(t, posted[1].State, marker.NodeStateReady)
+	}
+	assert.Equal(t, proc.Terminations(), 0, "agent should keep running")
+}
+
+func TestMemoryAgentPreUpdateFailed(t *testing.T) {
+	a, poster, proc := memoryAgent(t, Config{PreUpdateCommand: []string{"false"}})
+	a.platform = &testPlatform{
+		BootUpdateFn: func(platform.Update, bool) error {
+			t.Error("node should not reboot after the pre-update hook failed")
+			return nil
+		},
+	}
+	assert.NilError(t, poster.Post(intents.Stabilized(intents.WithUpdateAvailable())))
+	a.handleEvent(poster.Want(marker.NodeActionPrepareUpdate))
+	a.handleEvent(poster.Want(marker.NodeActionPerformUpdate))
+	poster.Posted()
+
+	a.handleEvent(poster.Want(marker.NodeActionRebootUpdate))
+	posted := poster.Posted()
+	assert.Equal(t, len(posted), 2)
+	assert.Equal(t, posted[1].Active, marker.NodeActionRebootUpdate)
+	assert.Equal(t, posted[1].State, marker.NodeStateError)
+	assert.Equal(t, proc.Terminations(), 0)
+}
+
+func TestMemoryAgentTargetVersion(t *testing.T) {
+	a, poster, _ := memoryAgent(t, Config{})
+	var prepared []string
+	a.platform = &testPlatform{
+		ListAvailableFn: func() (platform.Available, error) {
+			return testAvailable{testVersionedUpdate("1.2.0"), testVersionedUpdate("1.1.0")}, nil
+		},
+		PrepareFn: func(target platform.Update) error {
+			prepared = append(prepared, target.(platform.VersionedUpdate).TargetVersion())
+			return nil
+		},
+	}
+	want := func(target string) *v1.Node {
+		n := poster.Want(marker.NodeActionPrepareUpdate)
+		n.Annotations[marker.TargetVersionKey] = target
+		return n
+	}
+	assert.NilError(t, poster.Post(intents.Stabilized(intents.WithUpdateAvailable())))
+	poster.Posted()
+
+	a.handleEvent(want("1.1.0"))
+	posted := poster.Posted()
+	assert.Equal(t, len(posted), 2)
+	assert.Equal(t, posted[1].State, marker.NodeStateReady)
+	assert.DeepEqual(t, prepared, []string{"1.1.0"})
+
+	// The newest update isn't substituted for an unavailable target.
+	a.handleEvent(poster.Reset())
+	poster.Posted()
+	a.handleEvent(want("1.0.0"))
+	posted = poster.Posted()
+	assert.Equal(t, len(posted), 2)
+	assert.Equal(t, posted[1].State, marker.NodeStateError)
+	assert.DeepEqual(t, prepared, []string{"1.1.0"})
+}
+
+func TestMemoryAgentPostUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "post-update")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	ran := filepath.Join(dir, "ran")
+	a, poster, _ := memoryAgent(t, Config{PostUpdateCommand: []string{"touch", ran}})
+	// The Agent starts after rebooting into its update.
+	rebooted := intents.BusyRebootUpdate()
+	a.kube = fake.NewSimpleClientset(&v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        a.nodeName,
+		Annotations: rebooted.GetAnnotations(),
+	}})
+	assert.NilError(t, poster.Post(rebooted))
+	assert.NilError(t, a.checkNodePreflight(context.Background()))
+	assert.Check(t, a.postUpdatePending)
+	_, err = os.Stat(ran)
+	assert.Check(t, os.IsNotExist(err), "hook should wait for the node to stabilize")
+
+	a.handleEvent(poster.Reset())
+	_, err = os.Stat(ran)
+	assert.NilError(t, err, "hook should run once the node is stabilized")
+	assert.Check(t, !a.postUpdatePending)
+}
+
+func TestMemoryAgentDedup(t *testing.T) {
+	a, poster, _ := memoryAgent(t, Config{})
+	assert.NilError(t, poster.Post(intents.Stabilized(intents.WithUpdateAvailable())))
+	poster.Posted()
+
+	wanted := poster.Want(marker.NodeActionPrepareUpdate)
+	a.handleEvent(wanted)
+	assert.Equal(t, len(poster.Posted()), 2)
+
+	// The Agent's own posts come back as events.
+	own := poster.Node()
+	a.handleEvent(own)
+	assert.Equal(t, len(poster.Posted()), 0, "posted intent should be skipped")
+	assert.Check(t, a.tracker.matchesPost(intent.Given(own)))
+
+	// The Controller's intent is redelivered.
+	a.handleEvent(wanted)
+	assert.Equal(t, len(poster.Posted()), 0, "duplicate intent should be skipped")
+
+	// The Controller's next intent is handled and the tracked posts are
+	// cleared.
+	a.handleEvent(poster.Want(marker.NodeActionPerformUpdate))
+	assert.Equal(t, len(poster.Posted()), 2)
+	assert.Check(t, !a.tracker.matchesPost(intent.Given(own)))
+}
+
+func TestMemoryAgentNodeDeleted(t *testing.T) {
+	a, poster, _ := memoryAgent(t, Config{})
+	clk := a.clock.(*clock.FakeClock)
+	assert.NilError(t, poster.Post(intents.Stabilized(intents.WithUpdateAvailable())))
+	poster.Posted()
+
+	a.handler().OnDelete(poster.Node())
+	a.handleEvent(poster.Want(marker.NodeActionPrepareUpdate))
+	assert.Equal(t, len(poster.Posted()), 0, "intent should be ignored while the node is deleted")
+
+	// Re-added within the grace period, the Agent resumes.
+	a.handler().OnAdd(poster.Want(marker.NodeActionPrepareUpdate))
+	assert.Equal(t, len(poster.Posted()), 2, "intent should be handled once the node is re-added")
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Step(nodeDeletedGrace)
+	select {
+	case <-a.presence.Gone():
+		t.Fatal("re-added node should not be gone")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// Left deleted past the grace period, the node is gone.
+	a.handler().OnDelete(poster.Node())
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Step(nodeDeletedGrace - time.Second)
+	select {
+	case <-a.presence.Gone():
+		t.Fatal("node should not be gone before the grace period ends")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Step(time.Second)
+	select {
+	case <-a.presence.Gone():
+	case <-time.After(time.Second):
+		t.Fatal("node should be gone after the grace period ends")
+	}
+}
+
+func TestMemoryAgentNodeDeletedStopped(t *testing.T) {
+	a, poster, _ := memoryAgent(t, Config{})
+	clk := a.clock.(*clock.FakeClock)
+	ctx, cancel := context.WithCancel(context.Background())
+	a.ctx = ctx
+
+	// The grace period isn't waited out once the Agent is stopped.
+	a.handler().OnDelete(poster.Node())
+	for !clk.HasWaiters() {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	clk.Step(nodeDeletedGrace)
+	select {
+	case <-a.presence.Gone():
+		t.Fatal("stopped agent should not wait out the grace period")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestMemoryAgentCheckFailing(t *testing.T) {
+	a, poster, _ := memoryAgent(t, Config{CheckFailureThreshold: 3})
+	failing := true
+	a.platform = &testPlatform{
+		ListAvailableFn: func() (platform.Available, error) {
+			if failing {
+				return nil, errors.New("update source unreachable")
+			}
+			return &testListAvailable{}, nil
+		},
+	}
+	check := func() {
+		// The Node isn't posted to without a Kubernetes client, the update
+		// check's own failure is what's observed.
+		a.checkPostUpdate(context.Background(), a.log)
+	}
+
+	for i := 1; i < 3; i++ {
+		check()
+		_, posted := poster.Node().Annotations[marker.UpdateCheckFailingKey]
+		assert.Check(t, !posted, "failure %d is below the threshold", i)
+		assert.Equal(t, testutil.ToFloat64(metrics.UpdateCheckFailures), float64(i))
+	}
+
+	check()
+	assert.Equal(t, poster.Node().Annotations[marker.UpdateCheckFailingKey], "update source unreachable")
+	assert.Equal(t, testutil.ToFloat64(metrics.UpdateCheckFailures), float64(3))
+	check()
+	assert.Equal(t, len(poster.markers), 1, "unchanged failure should only be posted once")
+
+	failing = false
+	check()
+	assert.Equal(t, poster.Node().Annotations[marker.UpdateCheckFailingKey], "")
+	assert.Equal(t, testutil.ToFloat64(metrics.UpdateCheckFailures), float64(0))
+	assert.Equal(t, len(poster.markers), 2)
+	check()
+	assert.Equal(t, len(poster.markers), 2, "recovery should only be posted once")
+}
+
+func TestMemoryAgentCheckFailingDisabled(t *testing.T) {
+	a, poster, _ := memoryAgent(t, Config{})
+	a.platform = &testPlatform{
+		ListAvailableFn: func() (platform.Available, error) {
+			return nil, errors.New("update source unreachable")
+		},
+	}
+	for i := 0; i < 5; i++ {
+		a.checkPostUpdate(context.Background(), a.log)
+	}
+	assert.Equal(t, len(poster.markers), 0)
+	assert.Equal(t, testutil.ToFloat64(metrics.UpdateCheckFailures), float64(5))
+}
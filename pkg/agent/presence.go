@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// nodeDeletedGrace is the time the Agent waits for its deleted Node resource
+// to be re-added before stopping.
+const nodeDeletedGrace = 5 * time.Minute
+
+// nodePresence tracks whether the Agent's Node resource exists. The resource
+// may be deleted and re-added during informer churn, so its deletion isn't
+// taken as the Node being gone until it stays deleted.
+type nodePresence struct {
+	mu      sync.Mutex
+	deleted bool
+	// deletions counts the times the Node was deleted, distinguishing each
+	// deletion's grace period.
+	deletions int
+	// gone is closed once the Node stayed deleted past its grace period.
+	gone     chan struct{}
+	goneOnce sync.Once
+}
+
+func newNodePresence() *nodePresence {
+	return &nodePresence{gone: make(chan struct{})}
+}
+
+// Deleted notes that the Node resource was deleted, returning the deletion to
+// check on once its grace period ends.
+func (p *nodePresence) Deleted() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deleted = true
+	p.deletions++
+	return p.deletions
+}
+
+// Added notes that the Node resource exists, returning whether it had been
+// deleted.
+func (p *nodePresence) Added() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	readded := p.deleted
+	p.deleted = false
+	return readded
+}
+
+// IsDeleted reports whether the Node resource is deleted.
+func (p *nodePresence) IsDeleted() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.deleted
+}
+
+// Expire marks the Node as gone if its resource remains deleted since the
+// given deletion, returning whether it's gone.
+func (p *nodePresence) Expire(deletion int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.deleted || p.deletions != deletion {
+		return false
+	}
+	p.goneOnce.Do(func() { close(p.gone) })
+	return true
+}
+
+// Gone is closed once the Node's resource stayed deleted past its grace
+// period.
+func (p *nodePresence) Gone() <-chan struct{} {
+	return p.gone
+}
+
+// handleDelete stops acting on the Node's intents once its resource is
+// deleted, and stops the Agent if the resource isn't re-added within the
+// grace period.
+func (a *Agent) handleDelete() {
+	deletion := a.presence.Deleted()
+	log := a.log.WithField("grace", nodeDeletedGrace)
+	log.Warn("node resource deleted, not acting on intents until it's re-added")
+	ctx := a.ctx
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-a.clock.After(nodeDeletedGrace):
+			if a.presence.Expire(deletion) {
+				log.Error("node resource was not re-added, stopping agent")
+			}
+		}
+	}()
+}
+
+// handleAdd resumes acting on the Node's intents once its deleted resource is
+// re-added.
+func (a *Agent) handleAdd() {
+	if a.presence.Added() {
+		a.log.Info("node resource re-added, resuming")
+	}
+}
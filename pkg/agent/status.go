@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+)
+
+// StatusPath is the HTTP path that the host's partitions are served on,
+// alongside metrics.
+const StatusPath = "/status"
+
+// PartitionStatus describes one of the host's partitions.
+type PartitionStatus struct {
+	Version    string `json:"version"`
+	Variant    string `json:"variant,omitempty"`
+	Arch       string `json:"arch,omitempty"`
+	NextToBoot bool   `json:"nextToBoot"`
+}
+
+type statusPayload struct {
+	ActivePartition  *PartitionStatus `json:"activePartition"`
+	StagingPartition *PartitionStatus `json:"stagingPartition"`
+}
+
+// statusHandler serves the host's active and staging partitions as the
+// platform reports them, confirming which version is next to boot.
+type statusHandler struct {
+	platform platform.Platform
+}
+
+func (h *statusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ps, err := partitions(r.Context(), h.platform)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if ps == nil {
+		http.Error(w, "platform does not report its partitions", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statusPayload{
+		ActivePartition:  partitionStatus(ps.ActivePartition()),
+		StagingPartition: partitionStatus(ps.StagingPartition()),
+	})
+}
+
+func partitionStatus(p *platform.Partition) *PartitionStatus {
+	if p == nil {
+		return nil
+	}
+	return &PartitionStatus{
+		Version:    p.Version,
+		Variant:    p.Variant,
+		Arch:       p.Arch,
+		NextToBoot: p.NextToBoot,
+	}
+}
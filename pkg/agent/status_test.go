@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+)
+
+func TestStatusHandler(t *testing.T) {
+	t.Run("staged", func(t *testing.T) {
+		p := &testPlatform{PartitionsFn: func() (platform.PartitionStatus, error) {
+			return &testPartitionStatus{
+				active:  &platform.Partition{Version: "0.3.4", Variant: "aws-k8s-1.15", Arch: "x86_64", NextToBoot: false},
+				staging: &platform.Partition{Version: "0.4.0", Variant: "aws-k8s-1.15", Arch: "x86_64", NextToBoot: true},
+			}, nil
+		}}
+		rec := httptest.NewRecorder()
+		(&statusHandler{platform: p}).ServeHTTP(rec, httptest.NewRequest("GET", StatusPath, nil))
+		assert.Equal(t, rec.Code, http.StatusOK)
+
+		var payload statusPayload
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &payload))
+		assert.DeepEqual(t, payload, statusPayload{
+			ActivePartition:  &PartitionStatus{Version: "0.3.4", Variant: "aws-k8s-1.15", Arch: "x86_64", NextToBoot: false},
+			StagingPartition: &PartitionStatus{Version: "0.4.0", Variant: "aws-k8s-1.15", Arch: "x86_64", NextToBoot: true},
+		})
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		p := &testPlatform{PartitionsFn: func() (platform.PartitionStatus, error) {
+			return nil, errors.New("update status unavailable")
+		}}
+		rec := httptest.NewRecorder()
+		(&statusHandler{platform: p}).ServeHTTP(rec, httptest.NewRequest("GET", StatusPath, nil))
+		assert.Equal(t, rec.Code, http.StatusServiceUnavailable)
+	})
+
+	t.Run("unreported", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		(&statusHandler{platform: &testPlatform{}}).ServeHTTP(rec, httptest.NewRequest("GET", StatusPath, nil))
+		assert.Equal(t, rec.Code, http.StatusNotFound)
+	})
+}
@@ -0,0 +1,244 @@
+// Package cloudwatch publishes the operator's update metrics to Amazon
+// CloudWatch as custom metrics, complementing the metrics served to
+// Prometheus.
+package cloudwatch
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+)
+
+const (
+	// NodesUpdating is the number of Nodes updating, as a gauge.
+	NodesUpdating = "NodesUpdating"
+	// UpdatesSucceeded and UpdatesFailed count the Nodes' completed and
+	// failed updates.
+	UpdatesSucceeded = "UpdatesSucceeded"
+	UpdatesFailed    = "UpdatesFailed"
+	// UpdateDuration observes the time Nodes took to update, from beginning
+	// their update to completing it.
+	UpdateDuration = "UpdateDuration"
+	// UpdateActionFailures counts the update actions that Agents failed to
+	// take.
+	UpdateActionFailures = "UpdateActionFailures"
+)
+
+const (
+	// publishInterval is the time between publishes, matching CloudWatch's
+	// standard resolution.
+	publishInterval = time.Minute
+	// publishTimeout bounds each request to publish the metrics.
+	publishTimeout = 10 * time.Second
+	// maxDatums is the most metric data CloudWatch accepts in each request.
+	maxDatums = 20
+)
+
+// Publisher collects the update metrics and publishes them to CloudWatch in
+// batches, once each interval. Publishing is best-effort, metrics that fail
+// to publish are logged and dropped. A nil Publisher publishes nothing.
+type Publisher struct {
+	log       logging.Logger
+	namespace string
+	client    cloudwatchiface.CloudWatchAPI
+	clock     clock.Clock
+
+	mu sync.Mutex
+	// gauges are the latest value of each gauge, published every interval.
+	gauges map[string]float64
+	// gaugeFuncs provide the value of their gauges, taken as they're
+	// published.
+	gaugeFuncs map[string]func() float64
+	// counts are the counters' increments since they were last published,
+	// counters are published every interval once they're first counted.
+	counts map[string]float64
+	// observed are the observations since they were last published.
+	observed map[string]*cloudwatch.StatisticSet
+}
+
+// New creates a Publisher of the metrics under the CloudWatch namespace, in the
+// region when given. Otherwise, the region and the credentials are found by
+// the AWS SDK's default provider chain. No Publisher is created without a
+// namespace.
+func New(log logging.Logger, namespace string, region string) (*Publisher, error) {
+	if namespace == "" {
+		return nil, nil
+	}
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AWS session")
+	}
+	if aws.StringValue(sess.Config.Region) == "" {
+		return nil, errors.New("no region configured to publish CloudWatch metrics to")
+	}
+	return newPublisher(log, namespace, cloudwatch.New(sess)), nil
+}
+
+func newPublisher(log logging.Logger, namespace string, client cloudwatchiface.CloudWatchAPI) *Publisher {
+	return &Publisher{
+		log:        log,
+		namespace:  namespace,
+		client:     client,
+		clock:      clock.RealClock{},
+		gauges:     map[string]float64{},
+		gaugeFuncs: map[string]func() float64{},
+		counts:     map[string]float64{},
+		observed:   map[string]*cloudwatch.StatisticSet{},
+	}
+}
+
+// Set sets the gauge to the value.
+func (p *Publisher) Set(name string, value float64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gauges[name] = value
+}
+
+// SetFunc sets the gauge to the value given by fn, called each interval as the
+// metrics are published.
+func (p *Publisher) SetFunc(name string, fn func() float64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gaugeFuncs[name] = fn
+}
+
+// Add increments the counter by the value.
+func (p *Publisher) Add(name string, value float64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts[name] += value
+}
+
+// Observe observes the duration, CloudWatch derives its average, minimum, and
+// maximum from the observations.
+func (p *Publisher) Observe(name string, d time.Duration) {
+	if p == nil {
+		return
+	}
+	seconds := d.Seconds()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats, ok := p.observed[name]
+	if !ok {
+		p.observed[name] = &cloudwatch.StatisticSet{
+			SampleCount: aws.Float64(1),
+			Sum:         aws.Float64(seconds),
+			Minimum:     aws.Float64(seconds),
+			Maximum:     aws.Float64(seconds),
+		}
+		return
+	}
+	*stats.SampleCount++
+	*stats.Sum += seconds
+	if seconds < *stats.Minimum {
+		*stats.Minimum = seconds
+	}
+	if seconds > *stats.Maximum {
+		*stats.Maximum = seconds
+	}
+}
+
+// Run publishes the metrics each interval until the context is canceled,
+// publishing those remaining before it returns.
+func (p *Publisher) Run(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			p.publish(context.Background())
+			return nil
+		case <-p.clock.After(publishInterval):
+			p.publish(ctx)
+		}
+	}
+}
+
+// publish publishes the metrics collected since they were last published.
+func (p *Publisher) publish(ctx context.Context) {
+	data := p.collect()
+	for start := 0; start < len(data); start += maxDatums {
+		end := start + maxDatums
+		if end > len(data) {
+			end = len(data)
+		}
+		putCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		_, err := p.client.PutMetricDataWithContext(putCtx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(p.namespace),
+			MetricData: data[start:end],
+		})
+		cancel()
+		if err != nil {
+			p.log.WithError(err).WithField("namespace", p.namespace).Warn("unable to publish CloudWatch metrics")
+		}
+	}
+}
+
+// collect takes the metrics to publish as metric data, in order by name,
+// resetting the counters and observations.
+func (p *Publisher) collect() []*cloudwatch.MetricDatum {
+	now := aws.Time(p.clock.Now())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, fn := range p.gaugeFuncs {
+		p.gauges[name] = fn()
+	}
+	var data []*cloudwatch.MetricDatum
+	for name, value := range p.gauges {
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String(name),
+			Timestamp:  now,
+			Unit:       aws.String(cloudwatch.StandardUnitCount),
+			Value:      aws.Float64(value),
+		})
+	}
+	for name, value := range p.counts {
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String(name),
+			Timestamp:  now,
+			Unit:       aws.String(cloudwatch.StandardUnitCount),
+			Value:      aws.Float64(value),
+		})
+		p.counts[name] = 0
+	}
+	for name, stats := range p.observed {
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName:      aws.String(name),
+			Timestamp:       now,
+			Unit:            aws.String(cloudwatch.StandardUnitSeconds),
+			StatisticValues: stats,
+		})
+		delete(p.observed, name)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return aws.StringValue(data[i].MetricName) < aws.StringValue(data[j].MetricName)
+	})
+	return data
+}
@@ -0,0 +1,106 @@
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+)
+
+type testClient struct {
+	cloudwatchiface.CloudWatchAPI
+	put []*cloudwatch.PutMetricDataInput
+	err error
+}
+
+func (c *testClient) PutMetricDataWithContext(_ aws.Context, input *cloudwatch.PutMetricDataInput, _ ...request.Option) (*cloudwatch.PutMetricDataOutput, error) {
+	c.put = append(c.put, input)
+	return &cloudwatch.PutMetricDataOutput{}, c.err
+}
+
+func testPublisher(t *testing.T) (*Publisher, *testClient) {
+	client := &testClient{}
+	return newPublisher(testoutput.Logger(t, logging.New("cloudwatch")), "Bottlerocket/Updates", client), client
+}
+
+func TestPublish(t *testing.T) {
+	p, client := testPublisher(t)
+	p.Set(NodesUpdating, 2)
+	p.Add(UpdatesSucceeded, 1)
+	p.Add(UpdatesSucceeded, 1)
+	p.Observe(UpdateDuration, 3*time.Minute)
+	p.Observe(UpdateDuration, time.Minute)
+	p.publish(context.Background())
+
+	assert.Equal(t, len(client.put), 1)
+	assert.Equal(t, aws.StringValue(client.put[0].Namespace), "Bottlerocket/Updates")
+	data := client.put[0].MetricData
+	assert.Equal(t, len(data), 3)
+	assert.Equal(t, aws.StringValue(data[0].MetricName), NodesUpdating)
+	assert.Equal(t, aws.Float64Value(data[0].Value), 2.0)
+	assert.Equal(t, aws.StringValue(data[1].MetricName), UpdateDuration)
+	assert.Equal(t, aws.StringValue(data[1].Unit), cloudwatch.StandardUnitSeconds)
+	stats := data[1].StatisticValues
+	assert.Equal(t, aws.Float64Value(stats.SampleCount), 2.0)
+	assert.Equal(t, aws.Float64Value(stats.Sum), 240.0)
+	assert.Equal(t, aws.Float64Value(stats.Minimum), 60.0)
+	assert.Equal(t, aws.Float64Value(stats.Maximum), 180.0)
+	assert.Equal(t, aws.StringValue(data[2].MetricName), UpdatesSucceeded)
+	assert.Equal(t, aws.Float64Value(data[2].Value), 2.0)
+
+	// Gauges and counters are published again, without the observations.
+	p.publish(context.Background())
+	assert.Equal(t, len(client.put), 2)
+	data = client.put[1].MetricData
+	assert.Equal(t, len(data), 2)
+	assert.Equal(t, aws.Float64Value(data[0].Value), 2.0)
+	assert.Equal(t, aws.StringValue(data[1].MetricName), UpdatesSucceeded)
+	assert.Equal(t, aws.Float64Value(data[1].Value), 0.0)
+}
+
+func TestPublishGaugeFunc(t *testing.T) {
+	p, client := testPublisher(t)
+	updating := 1.0
+	p.SetFunc(NodesUpdating, func() float64 { return updating })
+	p.publish(context.Background())
+	updating = 3
+	p.publish(context.Background())
+
+	assert.Equal(t, len(client.put), 2)
+	assert.Equal(t, aws.StringValue(client.put[0].MetricData[0].MetricName), NodesUpdating)
+	assert.Equal(t, aws.Float64Value(client.put[0].MetricData[0].Value), 1.0)
+	assert.Equal(t, aws.Float64Value(client.put[1].MetricData[0].Value), 3.0)
+}
+
+func TestPublishBatches(t *testing.T) {
+	p, client := testPublisher(t)
+	for i := 0; i < maxDatums+5; i++ {
+		p.Set(fmt.Sprintf("gauge-%02d", i), float64(i))
+	}
+	client.err = errors.New("throttled")
+	p.publish(context.Background())
+	assert.Equal(t, len(client.put), 2)
+	assert.Equal(t, len(client.put[0].MetricData), maxDatums)
+	assert.Equal(t, len(client.put[1].MetricData), 5)
+}
+
+func TestPublisherDisabled(t *testing.T) {
+	p, err := New(testoutput.Logger(t, logging.New("cloudwatch")), "", "us-west-2")
+	assert.NilError(t, err)
+	assert.Assert(t, p == nil)
+	p.Set(NodesUpdating, 1)
+	p.SetFunc(NodesUpdating, func() float64 { return 1 })
+	p.Add(UpdatesFailed, 1)
+	p.Observe(UpdateDuration, time.Minute)
+	assert.NilError(t, p.Run(context.Background()))
+}
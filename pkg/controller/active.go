@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ActiveNode is a Node counted as active against the limit of Nodes updating
+// at once, with the Intent that makes it active.
+type ActiveNode struct {
+	NodeName        string            `json:"node"`
+	Wanted          marker.NodeAction `json:"wanted"`
+	Active          marker.NodeAction `json:"active"`
+	State           marker.NodeState  `json:"state"`
+	UpdateAvailable marker.NodeUpdate `json:"updateAvailable"`
+}
+
+// activeNodes lists the Nodes that policy checks count as active, ordered by
+// name.
+func activeNodes(resources []interface{}) []ActiveNode {
+	active := []ActiveNode{}
+	for _, res := range resources {
+		node, ok := res.(*v1.Node)
+		if !ok {
+			continue
+		}
+		in := intent.Given(node)
+		if !isClusterActive(in) {
+			continue
+		}
+		active = append(active, ActiveNode{
+			NodeName:        node.Name,
+			Wanted:          in.Wanted,
+			Active:          in.Active,
+			State:           in.State,
+			UpdateAvailable: in.UpdateAvailable,
+		})
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].NodeName < active[j].NodeName
+	})
+	return active
+}
+
+// activeHandler serves the Nodes counted as active, explaining why new
+// updates are held.
+type activeHandler struct {
+	manager *actionManager
+}
+
+type activePayload struct {
+	MaxActive        int          `json:"maxActive"`
+	MaxActivePercent int          `json:"maxActivePercent,omitempty"`
+	MaxCordoned      int          `json:"maxCordoned,omitempty"`
+	Nodes            []ActiveNode `json:"nodes"`
+}
+
+func (h *activeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.manager.storer == nil {
+		http.Error(w, "cluster state not yet available", http.StatusServiceUnavailable)
+		return
+	}
+	resources := h.manager.storer.GetStore().List()
+	clusterCount := 0
+	for _, res := range resources {
+		if _, ok := res.(*v1.Node); ok {
+			clusterCount++
+		}
+	}
+	payload := activePayload{
+		MaxCordoned: h.manager.maxCordoned,
+		Nodes:       activeNodes(resources),
+	}
+	// The limit is the one currently enforced, lowered while the rollout is
+	// paused or ramping up.
+	if policy, ok := h.manager.policy.(*defaultPolicy); ok {
+		payload.MaxActive = policy.allowedActive(clusterCount)
+		payload.MaxActivePercent = policy.maxActivePercent
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		h.manager.log.WithError(err).Warn("unable to write active nodes")
+	}
+}
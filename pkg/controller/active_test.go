@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestActiveNodes(t *testing.T) {
+	now := time.Now()
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, node := range []*v1.Node{
+		testNode(intents.BusyRebootUpdate(intents.WithNodeName("rebooting")), now),
+		testNode(intents.Stabilized(intents.WithNodeName("idle"), intents.WithUpdateAvailable()), now),
+		testNode(intents.PendingPrepareUpdate(intents.WithNodeName("preparing")), now),
+		testNode(intents.Unknown(intents.WithNodeName("stuck")), now),
+	} {
+		assert.NilError(t, store.Add(node))
+	}
+
+	active := activeNodes(store.List())
+	assert.Equal(t, len(active), 2)
+	assert.Equal(t, active[0].NodeName, "preparing")
+	assert.Equal(t, active[0].Wanted, marker.NodeActionPrepareUpdate)
+	assert.Equal(t, active[1].NodeName, "rebooting")
+	assert.Equal(t, active[1].Wanted, marker.NodeActionRebootUpdate)
+	assert.Equal(t, active[1].Active, marker.NodeActionRebootUpdate)
+	assert.Equal(t, active[1].State, marker.NodeStateBusy)
+
+	t.Run("counted", func(t *testing.T) {
+		m, _ := testManager(t)
+		assert.Equal(t, m.countUpdating(), 0.0)
+		m.storer = &testStorer{store}
+		assert.Equal(t, m.countUpdating(), 2.0)
+	})
+
+	t.Run("served", func(t *testing.T) {
+		m, _ := testManager(t)
+		m.storer = &testStorer{store}
+		m.policy = &defaultPolicy{maxActive: 2}
+		h := &activeHandler{manager: m}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", ActivePath, nil))
+		assert.Equal(t, rec.Code, http.StatusOK)
+
+		var payload activePayload
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &payload))
+		assert.Equal(t, payload.MaxActive, 2)
+		assert.DeepEqual(t, payload.Nodes, active)
+	})
+
+	t.Run("percent", func(t *testing.T) {
+		m, _ := testManager(t)
+		m.storer = &testStorer{store}
+		m.policy = &defaultPolicy{maxActivePercent: 50}
+		h := &activeHandler{manager: m}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", ActivePath, nil))
+		assert.Equal(t, rec.Code, http.StatusOK)
+
+		var payload activePayload
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &payload))
+		assert.Equal(t, payload.MaxActive, 2)
+		assert.Equal(t, payload.MaxActivePercent, 50)
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		m, _ := testManager(t)
+		m.storer = &testStorer{store}
+		m.policy = &defaultPolicy{maxActive: 2, gate: m.gate}
+		m.maxCordoned = 3
+		m.gate.Pause("rebooting")
+		h := &activeHandler{manager: m}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", ActivePath, nil))
+		assert.Equal(t, rec.Code, http.StatusOK)
+
+		var payload activePayload
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &payload))
+		assert.Equal(t, payload.MaxActive, 0)
+		assert.Equal(t, payload.MaxCordoned, 3)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		m, _ := testManager(t)
+		h := &activeHandler{manager: m}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", ActivePath, nil))
+		assert.Equal(t, rec.Code, http.StatusServiceUnavailable)
+	})
+}
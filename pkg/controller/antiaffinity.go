@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// hostnameTopologyKey is the topology key of anti-affinity terms that spread
+// Pods across Nodes.
+const hostnameTopologyKey = "kubernetes.io/hostname"
+
+// antiAffinityGuard finds Nodes hosting anti-affine replicas of the same
+// workload, such as the members of an HA pair, so that they're not updated at
+// the same time.
+type antiAffinityGuard struct {
+	// pods provides the cluster's Pods.
+	pods storer
+}
+
+// podStream runs an informer for the cluster's Pods.
+type podStream struct {
+	informer cache.SharedIndexInformer
+}
+
+func newPodStream(kube kubernetes.Interface) *podStream {
+	factory := informers.NewSharedInformerFactory(kube, 0)
+	return &podStream{informer: factory.Core().V1().Pods().Informer()}
+}
+
+func (ps *podStream) GetStore() cache.Store {
+	return ps.informer.GetStore()
+}
+
+func (ps *podStream) Run(ctx context.Context) error {
+	ps.informer.Run(ctx.Done())
+	return nil
+}
+
+// Conflict returns an active Node that hosts an anti-affine replica of a
+// workload that also has a replica on the named Node.
+func (g *antiAffinityGuard) Conflict(nodeName string, active []string) (string, bool) {
+	if g == nil || g.pods == nil || len(active) == 0 {
+		return "", false
+	}
+	isActive := map[string]bool{}
+	for _, name := range active {
+		if name != nodeName {
+			isActive[name] = true
+		}
+	}
+	// workloads are the anti-affine workloads with replicas on each of the
+	// Nodes considered.
+	workloads := map[string]map[string]bool{}
+	for _, obj := range g.pods.GetStore().List() {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || podTerminated(pod) {
+			continue
+		}
+		node := pod.Spec.NodeName
+		if node != nodeName && !isActive[node] {
+			continue
+		}
+		workload, ok := antiAffineWorkload(pod)
+		if !ok {
+			continue
+		}
+		if workloads[node] == nil {
+			workloads[node] = map[string]bool{}
+		}
+		workloads[node][workload] = true
+	}
+	for _, name := range active {
+		if !isActive[name] {
+			continue
+		}
+		for workload := range workloads[name] {
+			if workloads[nodeName][workload] {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// antiAffineWorkload identifies the workload that the Pod is a replica of when
+// the Pod's anti-affinity spreads it apart from its own replicas across Nodes.
+func antiAffineWorkload(pod *v1.Pod) (string, bool) {
+	owner := v1meta.GetControllerOf(pod)
+	if owner == nil {
+		return "", false
+	}
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.PodAntiAffinity == nil {
+		return "", false
+	}
+	anti := pod.Spec.Affinity.PodAntiAffinity
+	terms := append([]v1.PodAffinityTerm(nil), anti.RequiredDuringSchedulingIgnoredDuringExecution...)
+	for _, weighted := range anti.PreferredDuringSchedulingIgnoredDuringExecution {
+		terms = append(terms, weighted.PodAffinityTerm)
+	}
+	for _, term := range terms {
+		if term.TopologyKey != hostnameTopologyKey || term.LabelSelector == nil {
+			continue
+		}
+		selector, err := v1meta.LabelSelectorAsSelector(term.LabelSelector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			return pod.Namespace + "/" + owner.Kind + "/" + owner.Name, true
+		}
+	}
+	return "", false
+}
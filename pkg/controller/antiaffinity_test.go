@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// replicaPod is a replica of the named ReplicaSet scheduled to the Node, the
+// replica is spread from its others when antiAffine.
+func replicaPod(name, replicaSet, nodeName string, antiAffine bool) *v1.Pod {
+	isController := true
+	pod := &v1.Pod{
+		ObjectMeta: v1meta.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{"app": replicaSet},
+			OwnerReferences: []v1meta.OwnerReference{{
+				Kind:       "ReplicaSet",
+				Name:       replicaSet,
+				Controller: &isController,
+			}},
+		},
+		Spec: v1.PodSpec{NodeName: nodeName},
+	}
+	if antiAffine {
+		pod.Spec.Affinity = &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{{
+				LabelSelector: &v1meta.LabelSelector{MatchLabels: map[string]string{"app": replicaSet}},
+				TopologyKey:   hostnameTopologyKey,
+			}},
+		}}
+	}
+	return pod
+}
+
+func testPodStore(t *testing.T, pods ...*v1.Pod) storer {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, pod := range pods {
+		assert.NilError(t, store.Add(pod))
+	}
+	return &testStorer{store}
+}
+
+func TestAntiAffinityConflict(t *testing.T) {
+	guard := &antiAffinityGuard{pods: testPodStore(t,
+		replicaPod("db-0", "db", "node-a", true),
+		replicaPod("db-1", "db", "node-b", true),
+		// Replicas without anti-affinity may be disrupted together.
+		replicaPod("web-0", "web", "node-a", false),
+		replicaPod("web-1", "web", "node-c", false),
+		// Replicas of other workloads aren't related.
+		replicaPod("cache-0", "cache", "node-d", true),
+	)}
+
+	conflict, ok := guard.Conflict("node-a", []string{"node-b"})
+	assert.Check(t, ok)
+	assert.Equal(t, conflict, "node-b")
+
+	_, ok = guard.Conflict("node-a", []string{"node-c", "node-d"})
+	assert.Check(t, !ok)
+	_, ok = guard.Conflict("node-a", []string{"node-a"})
+	assert.Check(t, !ok, "node doesn't conflict with itself")
+	_, ok = guard.Conflict("node-a", nil)
+	assert.Check(t, !ok)
+
+	var disabled *antiAffinityGuard
+	_, ok = disabled.Conflict("node-a", []string{"node-b"})
+	assert.Check(t, !ok)
+}
+
+func TestPolicyAntiAffinity(t *testing.T) {
+	policy := defaultPolicy{
+		log:       testoutput.Logger(t, logging.New("policy-check")),
+		maxActive: 3,
+		antiAffinity: &antiAffinityGuard{pods: testPodStore(t,
+			replicaPod("db-0", "db", "node-a", true),
+			replicaPod("db-1", "db", "node-b", true),
+		)},
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	launched := time.Now()
+	assert.NilError(t, store.Add(testNode(intents.PendingUpdate(intents.WithNodeName("node-a")), launched)))
+	assert.NilError(t, store.Add(testNode(intents.Stabilized(intents.WithNodeName("node-b"), intents.WithUpdateAvailable()), launched)))
+	assert.NilError(t, store.Add(testNode(intents.Stabilized(intents.WithNodeName("node-c"), intents.WithUpdateAvailable()), launched)))
+
+	starting := func(nodeName string) bool {
+		in := intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()).SetBeginUpdate()
+		ck, err := newPolicyCheck(in, store)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, ck.Active, []string{"node-a"})
+		permit, _, err := policy.Check(ck)
+		assert.NilError(t, err)
+		return permit
+	}
+
+	assert.Check(t, !starting("node-b"), "node hosting the other replica should wait")
+	assert.Check(t, starting("node-c"), "unrelated node should be permitted")
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/k8sutil"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+var _ nodestream.Handler = (*autoLabeler)(nil)
+
+// autoLabeler labels Nodes that match its selector for management by the
+// operator as they are added to the cluster.
+type autoLabeler struct {
+	log      logging.Logger
+	selector labels.Selector
+	version  marker.PlatformVersion
+	poster   markerPoster
+}
+
+type markerPoster interface {
+	PostMarkers(nodeName string, markers marker.Container) error
+}
+
+type k8sMarkerPoster struct {
+	nodeclient corev1.NodeInterface
+}
+
+func (k *k8sMarkerPoster) PostMarkers(nodeName string, markers marker.Container) error {
+	return k8sutil.PostMetadata(k.nodeclient, nodeName, markers)
+}
+
+func newAutoLabeler(log logging.Logger, config Config, poster markerPoster) (*autoLabeler, error) {
+	selector, err := labels.Parse(config.AutoLabelSelector)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid auto-label selector")
+	}
+	if selector.Empty() {
+		return nil, errors.New("auto-label selector must not select all nodes")
+	}
+	return &autoLabeler{
+		log:      log,
+		selector: selector,
+		version:  config.autoLabelInterfaceVersion(),
+		poster:   poster,
+	}, nil
+}
+
+// streamConfig is the nodestream configuration needed to observe the Nodes
+// the autoLabeler may label.
+func (al *autoLabeler) streamConfig() nodestream.Config {
+	return nodestream.Config{
+		Unmanaged:          true,
+		LabelSelectorExtra: al.selector.String(),
+	}
+}
+
+// wants reports whether the Node should be labeled.
+func (al *autoLabeler) wants(node *v1.Node) bool {
+	if _, ok := node.Labels[marker.NodeSelectorLabel]; ok {
+		return false
+	}
+	return al.selector.Matches(labels.Set(node.Labels))
+}
+
+func (al *autoLabeler) OnAdd(node *v1.Node) {
+	if !al.wants(node) {
+		return
+	}
+	log := al.log.WithField("node", node.GetName())
+	err := al.poster.PostMarkers(node.GetName(), marker.Labels{
+		marker.NodeSelectorLabel: al.version,
+	})
+	if err != nil {
+		log.WithError(err).Error("unable to label node for management")
+		return
+	}
+	log.Info("labeled node for management")
+}
+
+func (al *autoLabeler) OnUpdate(_ *v1.Node, _ *v1.Node) {}
+
+func (al *autoLabeler) OnDelete(_ *v1.Node) {}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testLabelNode(name string, labels map[string]string) *v1.Node {
+	return &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestAutoLabelerOnAdd(t *testing.T) {
+	cases := []struct {
+		name    string
+		labels  map[string]string
+		labeled bool
+	}{
+		{name: "matching", labels: map[string]string{"os": "bottlerocket"}, labeled: true},
+		{name: "other", labels: map[string]string{"os": "linux"}},
+		{name: "unlabeled"},
+		{name: "managed", labels: map[string]string{"os": "bottlerocket", marker.NodeSelectorLabel: "1.0.0"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			poster := &testingPoster{}
+			al, err := newAutoLabeler(testoutput.Logger(t, logging.New("labeler")), Config{
+				AutoLabelSelector: "os=bottlerocket",
+			}, poster)
+			assert.NilError(t, err)
+
+			al.OnAdd(testLabelNode(tc.name, tc.labels))
+			if !tc.labeled {
+				assert.Equal(t, len(poster.calledMarkers), 0)
+				return
+			}
+			assert.DeepEqual(t, poster.markedNodes, []string{tc.name})
+			assert.DeepEqual(t, poster.calledMarkers[0], marker.Labels{
+				marker.NodeSelectorLabel: defaultAutoLabelInterfaceVersion,
+			})
+		})
+	}
+}
+
+func TestAutoLabelerInvalidSelector(t *testing.T) {
+	for _, selector := range []string{"os in (", " "} {
+		_, err := newAutoLabeler(testoutput.Logger(t, logging.New("labeler")), Config{
+			AutoLabelSelector: selector,
+		}, &testingPoster{})
+		assert.Check(t, err != nil, "selector %q should be rejected", selector)
+	}
+}
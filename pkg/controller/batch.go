@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"math"
+	"sync"
+)
+
+// batchGate holds back the next batch of updates until a quorum of the
+// current batch has passed its health check. A batch is the set of Nodes that
+// began updating since the previous batch was released, it closes once it has
+// as many Nodes as are permitted to update at once.
+type batchGate struct {
+	mu sync.Mutex
+	// quorum is the fraction of a batch that must be healthy to release the
+	// next batch.
+	quorum float64
+	size   int
+	// members are the current batch's Nodes and whether they've passed their
+	// health check.
+	members map[string]bool
+}
+
+func newBatchGate(size int, quorum float64) *batchGate {
+	if quorum <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = maxClusterActive
+	}
+	return &batchGate{quorum: quorum, size: size, members: map[string]bool{}}
+}
+
+// Open reports whether the Node may begin its update as part of the current
+// batch.
+func (b *batchGate) Open(nodeName string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.members[nodeName]; ok {
+		return true
+	}
+	return len(b.members) < b.size
+}
+
+// Join adds the Node, which is beginning its update, to the current batch.
+func (b *batchGate) Join(nodeName string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.members[nodeName]; !ok {
+		b.members[nodeName] = false
+	}
+}
+
+// Healthy notes the Node passed its health check, releasing the next batch
+// once a quorum of the current batch is healthy. The return indicates
+// whether the next batch was released.
+func (b *batchGate) Healthy(nodeName string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.members[nodeName]; !ok {
+		return false
+	}
+	b.members[nodeName] = true
+	return b.releaseQuorate()
+}
+
+// Forget drops the Node from the current batch.
+func (b *batchGate) Forget(nodeName string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.members, nodeName)
+	b.releaseQuorate()
+}
+
+// releaseQuorate starts a new batch if a quorum of the current, closed, batch
+// is healthy.
+func (b *batchGate) releaseQuorate() bool {
+	if len(b.members) < b.size {
+		return false
+	}
+	healthy := 0
+	for _, ok := range b.members {
+		if ok {
+			healthy++
+		}
+	}
+	needed := int(math.Ceil(b.quorum * float64(len(b.members))))
+	if healthy < needed {
+		return false
+	}
+	b.members = map[string]bool{}
+	return true
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"gotest.tools/assert"
+)
+
+func TestBatchGateQuorum(t *testing.T) {
+	cases := []struct {
+		quorum float64
+		// needed is the number of the batch's 4 Nodes that must be healthy to
+		// release the next batch.
+		needed int
+	}{
+		{quorum: 0.25, needed: 1},
+		{quorum: 0.5, needed: 2},
+		{quorum: 0.6, needed: 3},
+		{quorum: 0.75, needed: 3},
+		{quorum: 1, needed: 4},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("quorum(%v)", tc.quorum), func(t *testing.T) {
+			batch := newBatchGate(4, tc.quorum)
+			members := []string{"node-a", "node-b", "node-c", "node-d"}
+			for _, nodeName := range members {
+				assert.Check(t, batch.Open(nodeName))
+				batch.Join(nodeName)
+			}
+			assert.Check(t, !batch.Open("node-e"), "batch should be closed once full")
+			assert.Check(t, batch.Open("node-a"), "members remain admitted")
+
+			for i, nodeName := range members {
+				released := batch.Healthy(nodeName)
+				assert.Equal(t, released, i+1 == tc.needed, "after %d healthy", i+1)
+				if released {
+					break
+				}
+				assert.Check(t, !batch.Open("node-e"))
+			}
+			assert.Check(t, batch.Open("node-e"), "next batch should be released")
+		})
+	}
+}
+
+func TestBatchGateDisabled(t *testing.T) {
+	batch := newBatchGate(2, 0)
+	assert.Check(t, batch == nil)
+	batch.Join("node-a")
+	batch.Join("node-b")
+	assert.Check(t, batch.Open("node-c"))
+	assert.Check(t, !batch.Healthy("node-a"))
+}
+
+func TestBatchGateForget(t *testing.T) {
+	batch := newBatchGate(2, 1)
+	batch.Join("node-a")
+	batch.Join("node-b")
+	assert.Check(t, !batch.Open("node-c"))
+	batch.Healthy("node-a")
+
+	// A removed Node makes room in the batch for another.
+	batch.Forget("node-b")
+	assert.Check(t, batch.Open("node-c"))
+}
+
+func TestPolicyBatchQuorum(t *testing.T) {
+	batch := newBatchGate(2, 0.5)
+	policy := defaultPolicy{
+		log:       testoutput.Logger(t, logging.New("policy-check")),
+		maxActive: 2,
+		batch:     batch,
+	}
+	starting := func(nodeName string, active int) bool {
+		permit, _, err := policy.Check(&PolicyCheck{
+			Intent:        intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()).SetBeginUpdate(),
+			ClusterActive: active,
+			ClusterCount:  10,
+		})
+		assert.NilError(t, err)
+		return permit
+	}
+
+	assert.Check(t, starting("node-a", 0))
+	assert.Check(t, starting("node-b", 1))
+	// The batch's Nodes finished updating, but none have been found healthy.
+	assert.Check(t, !starting("node-c", 0), "next batch should wait on quorum")
+
+	assert.Check(t, batch.Healthy("node-a"))
+	assert.Check(t, starting("node-c", 0))
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// budgetBlock is a PodDisruptionBudget that permits no further disruptions,
+// blocking the eviction of the drained Pods it covers.
+type budgetBlock struct {
+	// Budget is the PodDisruptionBudget's namespaced name.
+	Budget string
+	// Pods are the namespaced names of the blocked Pods.
+	Pods []string
+}
+
+func (b budgetBlock) String() string {
+	return fmt.Sprintf("%s (pods %s)", b.Budget, strings.Join(b.Pods, ", "))
+}
+
+// drainBlockedError reports the PodDisruptionBudgets blocking a Node's drain.
+type drainBlockedError struct {
+	blocks []budgetBlock
+}
+
+func (e *drainBlockedError) Error() string {
+	blocks := make([]string, len(e.blocks))
+	for i, block := range e.blocks {
+		blocks[i] = block.String()
+	}
+	return "drain blocked by pod disruption budgets: " + strings.Join(blocks, "; ")
+}
+
+// blockingBudgets finds the PodDisruptionBudgets that permit no disruptions
+// and cover any of the Pods to be evicted. Such budgets block the drain until
+// they permit disruptions again, which may never happen when they're
+// misconfigured, such as a budget requiring all of a workload's replicas to be
+// available.
+func blockingBudgets(pods []v1.Pod, budgets []policyv1beta1.PodDisruptionBudget) []budgetBlock {
+	var blocks []budgetBlock
+	for i := range budgets {
+		budget := &budgets[i]
+		if budget.Status.PodDisruptionsAllowed > 0 {
+			continue
+		}
+		sel := budget.Spec.Selector
+		// An empty selector matches no Pods.
+		if sel == nil || (len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0) {
+			continue
+		}
+		selector, err := v1meta.LabelSelectorAsSelector(sel)
+		if err != nil {
+			continue
+		}
+		var blocked []string
+		for j := range pods {
+			pod := &pods[j]
+			if pod.Namespace != budget.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			blocked = append(blocked, pod.Namespace+"/"+pod.Name)
+		}
+		if len(blocked) != 0 {
+			blocks = append(blocks, budgetBlock{
+				Budget: budget.Namespace + "/" + budget.Name,
+				Pods:   blocked,
+			})
+		}
+	}
+	return blocks
+}
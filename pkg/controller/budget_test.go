@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testBudget(namespace, name string, allowed int32, matchLabels map[string]string) policyv1beta1.PodDisruptionBudget {
+	return policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: v1meta.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       policyv1beta1.PodDisruptionBudgetSpec{Selector: &v1meta.LabelSelector{MatchLabels: matchLabels}},
+		Status:     policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: allowed},
+	}
+}
+
+func TestBlockingBudgets(t *testing.T) {
+	pod := func(namespace, name string, labels map[string]string) v1.Pod {
+		return v1.Pod{ObjectMeta: v1meta.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+	}
+	pods := []v1.Pod{
+		pod("default", "web-1", map[string]string{"app": "web"}),
+		pod("default", "web-2", map[string]string{"app": "web"}),
+		pod("default", "db-1", map[string]string{"app": "db"}),
+		pod("other", "web-1", map[string]string{"app": "web"}),
+	}
+	budgets := []policyv1beta1.PodDisruptionBudget{
+		testBudget("default", "web", 0, map[string]string{"app": "web"}),
+		// Budgets permitting disruptions don't block.
+		testBudget("default", "db", 1, map[string]string{"app": "db"}),
+		// Budgets only cover Pods in their namespace.
+		testBudget("elsewhere", "web", 0, map[string]string{"app": "web"}),
+		// Empty selectors match no Pods.
+		testBudget("other", "all", 0, nil),
+	}
+	blocks := blockingBudgets(pods, budgets)
+	assert.DeepEqual(t, blocks, []budgetBlock{
+		{Budget: "default/web", Pods: []string{"default/web-1", "default/web-2"}},
+	})
+
+	err := &drainBlockedError{blocks: blocks}
+	assert.Error(t, err, "drain blocked by pod disruption budgets: default/web (pods default/web-1, default/web-2)")
+
+	assert.Equal(t, len(blockingBudgets(nil, budgets)), 0)
+}
+
+func TestDrainBlocked(t *testing.T) {
+	t.Run("blocked", func(t *testing.T) {
+		m, hooks := testManager(t)
+		hooks.NodeManager.BlockersFn = func(_ string) ([]budgetBlock, error) {
+			return []budgetBlock{{Budget: "default/web", Pods: []string{"default/web-1"}}}, nil
+		}
+		cordoned, drained := false, false
+		hooks.NodeManager.CordonFn = trackFn(&cordoned)
+		hooks.NodeManager.DrainFn = trackDrainFn(&drained, 1)
+		before := testutil.ToFloat64(metrics.DrainsBlocked.WithLabelValues("node-a"))
+
+		err := m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a"))))
+		blockedErr, ok := errors.Cause(err).(*drainBlockedError)
+		assert.Assert(t, ok, "expected drain blocked error, got: %v", err)
+		assert.Equal(t, blockedErr.blocks[0].Budget, "default/web")
+		assert.Check(t, !cordoned, "node should not be cordoned while its drain is blocked")
+		assert.Check(t, !drained)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 0, "update should be deferred")
+		assert.Equal(t, testutil.ToFloat64(metrics.DrainsBlocked.WithLabelValues("node-a")), before+1)
+	})
+
+	t.Run("unchecked", func(t *testing.T) {
+		m, hooks := testManager(t)
+		hooks.NodeManager.BlockersFn = func(_ string) ([]budgetBlock, error) {
+			return nil, errors.New("forbidden")
+		}
+		drained := false
+		hooks.NodeManager.DrainFn = trackDrainFn(&drained, 1)
+
+		err := m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a"))))
+		assert.NilError(t, err)
+		assert.Check(t, drained, "drain should proceed when budgets can't be checked")
+	})
+}
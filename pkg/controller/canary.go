@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+)
+
+// canaryGate updates the canary Nodes, those with the canary label, ahead of
+// the rest of the cluster. Other Nodes are held back until every canary has
+// updated, passed its health check, and soaked for the soak period. A canary
+// failing its update blocks all further updates until the canary is
+// relabeled, updates successfully, or is removed. A nil canaryGate holds back
+// no Nodes.
+type canaryGate struct {
+	mu    sync.Mutex
+	label string
+	// soak is the time after the last canary updated before other Nodes may
+	// begin updating.
+	soak  time.Duration
+	clock clock.Clock
+	// canaries are the labeled Nodes by name.
+	canaries map[string]canaryState
+	// failed are the canaries that failed their update and why.
+	failed map[string]string
+}
+
+// canaryState is a canary's progress through its update.
+type canaryState struct {
+	// pending indicates the canary has an update available or is updating.
+	pending bool
+	// updated is when the canary last completed an update.
+	updated time.Time
+}
+
+func newCanaryGate(label string, soak time.Duration, clk clock.Clock) *canaryGate {
+	if label == "" {
+		return nil
+	}
+	return &canaryGate{
+		label:    label,
+		soak:     soak,
+		clock:    clk,
+		canaries: map[string]canaryState{},
+		failed:   map[string]string{},
+	}
+}
+
+// Observe tracks the Node's progress when it's labeled as a canary. Removing
+// the label from a failed canary lifts the block on further updates.
+func (g *canaryGate) Observe(node *v1.Node) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	name := node.GetName()
+	if _, ok := node.GetLabels()[g.label]; !ok {
+		g.forget(name)
+		return
+	}
+	in := intent.Given(node)
+	state := g.canaries[name]
+	state.pending = isClusterActive(in) || in.HasUpdateAvailable()
+	// The completed update is recorded on the Node, keeping the soak across
+	// restarts of the controller.
+	if value := node.GetAnnotations()[marker.LastUpdatedKey]; value != "" {
+		if updated, err := time.Parse(time.RFC3339, value); err == nil && updated.After(state.updated) {
+			state.updated = updated
+		}
+	}
+	g.canaries[name] = state
+}
+
+// Forget stops tracking the Node.
+func (g *canaryGate) Forget(nodeName string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.forget(nodeName)
+}
+
+func (g *canaryGate) forget(nodeName string) {
+	delete(g.canaries, nodeName)
+	delete(g.failed, nodeName)
+	metrics.CanariesFailed.Set(float64(len(g.failed)))
+}
+
+// IsCanary reports whether the Node is labeled as a canary.
+func (g *canaryGate) IsCanary(nodeName string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.canaries[nodeName]
+	return ok
+}
+
+// Passed notes the canary completed its update and passed its health check,
+// starting the soak. The return indicates whether the Node is a canary.
+func (g *canaryGate) Passed(nodeName string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.canaries[nodeName]; !ok {
+		return false
+	}
+	g.canaries[nodeName] = canaryState{updated: g.clock.Now()}
+	delete(g.failed, nodeName)
+	metrics.CanariesFailed.Set(float64(len(g.failed)))
+	return true
+}
+
+// Failed notes the canary failed its update, blocking further updates. The
+// return indicates whether the Node is a canary.
+func (g *canaryGate) Failed(nodeName string, err error) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.canaries[nodeName]; !ok {
+		return false
+	}
+	g.failed[nodeName] = err.Error()
+	metrics.CanariesFailed.Set(float64(len(g.failed)))
+	return true
+}
+
+// Open reports whether the Node may begin its update and, when it may not,
+// why. Canaries may begin unless a canary has failed, other Nodes wait for
+// the canaries to update and soak.
+func (g *canaryGate) Open(nodeName string) (bool, string) {
+	if g == nil {
+		return true, ""
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.failed) != 0 {
+		names := make([]string, 0, len(g.failed))
+		for name := range g.failed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return false, fmt.Sprintf("canary %s failed its update: %s", names[0], g.failed[names[0]])
+	}
+	if _, ok := g.canaries[nodeName]; ok {
+		return true, ""
+	}
+	names := make([]string, 0, len(g.canaries))
+	for name := range g.canaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var last time.Time
+	for _, name := range names {
+		state := g.canaries[name]
+		if state.pending {
+			return false, fmt.Sprintf("canary %s has yet to update", name)
+		}
+		if state.updated.After(last) {
+			last = state.updated
+		}
+	}
+	if soaked := last.Add(g.soak); !last.IsZero() && g.clock.Now().Before(soaked) {
+		return false, fmt.Sprintf("canaries soaking until %s", soaked.UTC().Format(time.RFC3339))
+	}
+	return true, ""
+}
+
+// Soaking returns the time remaining before the updated canaries finish
+// soaking, there's no wait while a canary has yet to update or has failed.
+func (g *canaryGate) Soaking() time.Duration {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.failed) != 0 {
+		return 0
+	}
+	var last time.Time
+	for _, state := range g.canaries {
+		if state.pending {
+			return 0
+		}
+		if state.updated.After(last) {
+			last = state.updated
+		}
+	}
+	if last.IsZero() {
+		return 0
+	}
+	remaining := last.Add(g.soak).Sub(g.clock.Now())
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// testCanary is a Node labeled as a canary with the given intent.
+func testCanary(in *intent.Intent) *v1.Node {
+	node := testNode(in, time.Time{})
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[marker.CanaryKey] = ""
+	return node
+}
+
+func TestCanaryGate(t *testing.T) {
+	clk := newTestClock()
+	canary := newCanaryGate(marker.CanaryKey, time.Hour, clk)
+	canary.Observe(testCanary(intents.Stabilized(intents.WithNodeName("canary-a"), intents.WithUpdateAvailable())))
+	canary.Observe(testNode(intents.Stabilized(intents.WithNodeName("node-b"), intents.WithUpdateAvailable()), time.Time{}))
+
+	assert.Check(t, canary.IsCanary("canary-a"))
+	assert.Check(t, !canary.IsCanary("node-b"))
+	open, _ := canary.Open("canary-a")
+	assert.Check(t, open, "canaries should update first")
+	open, reason := canary.Open("node-b")
+	assert.Check(t, !open, "other nodes should wait on the canaries")
+	assert.Equal(t, reason, "canary canary-a has yet to update")
+
+	// Still updating.
+	canary.Observe(testCanary(intents.BusyRebootUpdate(intents.WithNodeName("canary-a"))))
+	open, _ = canary.Open("node-b")
+	assert.Check(t, !open)
+	assert.Equal(t, canary.Soaking(), time.Duration(0), "soak should wait on the canaries to update")
+
+	assert.Check(t, canary.Passed("canary-a"))
+	assert.Check(t, !canary.Passed("node-b"))
+	canary.Observe(testCanary(intents.Stabilized(intents.WithNodeName("canary-a"), intents.WithUpdateAvailable(marker.NodeUpdateUnavailable))))
+	open, reason = canary.Open("node-b")
+	assert.Check(t, !open, "other nodes should wait out the soak")
+	assert.Equal(t, reason, "canaries soaking until 2020-07-10T01:00:00Z")
+	assert.Equal(t, canary.Soaking(), time.Hour)
+
+	clk.Step(time.Hour)
+	open, _ = canary.Open("node-b")
+	assert.Check(t, open)
+	assert.Equal(t, canary.Soaking(), time.Duration(0))
+}
+
+func TestCanaryGateSoakRecorded(t *testing.T) {
+	clk := newTestClock()
+	now := clk.Now()
+	canary := newCanaryGate(marker.CanaryKey, time.Hour, clk)
+	node := testCanary(intents.Stabilized(intents.WithNodeName("canary-a"), intents.WithUpdateAvailable(marker.NodeUpdateUnavailable)))
+	node.Annotations[marker.LastUpdatedKey] = now.Add(-30 * time.Minute).Format(time.RFC3339)
+	canary.Observe(node)
+
+	// The soak continues from the update recorded on the Node.
+	open, reason := canary.Open("node-b")
+	assert.Check(t, !open)
+	assert.Equal(t, reason, "canaries soaking until 2020-07-10T00:30:00Z")
+}
+
+func TestCanaryGateFailed(t *testing.T) {
+	canary := newCanaryGate(marker.CanaryKey, 0, newTestClock())
+	canary.Observe(testCanary(intents.PendingRebootUpdate(intents.WithNodeName("canary-a"))))
+	canary.Observe(testCanary(intents.Stabilized(intents.WithNodeName("canary-b"), intents.WithUpdateAvailable())))
+
+	assert.Check(t, !canary.Failed("node-c", errors.New("not a canary")))
+	assert.Check(t, canary.Failed("canary-a", errors.New("node not ready")))
+	assert.Equal(t, testutil.ToFloat64(metrics.CanariesFailed), float64(1))
+	for _, nodeName := range []string{"canary-a", "canary-b", "node-c"} {
+		open, reason := canary.Open(nodeName)
+		assert.Check(t, !open, "%s should be blocked by the failed canary", nodeName)
+		assert.Equal(t, reason, "canary canary-a failed its update: node not ready")
+	}
+
+	// Removing the label, once the failure is investigated, lifts the block.
+	canary.Observe(testNode(intents.Stabilized(intents.WithNodeName("canary-a")), time.Time{}))
+	assert.Equal(t, testutil.ToFloat64(metrics.CanariesFailed), float64(0))
+	open, _ := canary.Open("canary-b")
+	assert.Check(t, open)
+}
+
+func TestCanaryGateDisabled(t *testing.T) {
+	canary := newCanaryGate("", time.Hour, clock.RealClock{})
+	assert.Check(t, canary == nil)
+	canary.Observe(testCanary(intents.Stabilized(intents.WithNodeName("canary-a"), intents.WithUpdateAvailable())))
+	assert.Check(t, !canary.IsCanary("canary-a"))
+	assert.Check(t, !canary.Failed("canary-a", errors.New("node not ready")))
+	open, _ := canary.Open("node-b")
+	assert.Check(t, open)
+}
+
+func TestPolicyCanaryRollout(t *testing.T) {
+	canary := newCanaryGate(marker.CanaryKey, 0, newTestClock())
+	canary.Observe(testCanary(intents.Stabilized(intents.WithNodeName("canary-a"), intents.WithUpdateAvailable())))
+	policy := defaultPolicy{
+		log:       testoutput.Logger(t, logging.New("policy-check")),
+		maxActive: 2,
+		canary:    canary,
+	}
+	starting := func(nodeName string) bool {
+		permit, _, err := policy.Check(&PolicyCheck{
+			Intent:       intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()).SetBeginUpdate(),
+			ClusterCount: 10,
+		})
+		assert.NilError(t, err)
+		return permit
+	}
+
+	assert.Check(t, !starting("node-b"), "other nodes should wait on the canaries")
+	assert.Check(t, starting("canary-a"))
+
+	// Nodes already updating are permitted to finish.
+	permit, _, err := policy.Check(&PolicyCheck{
+		Intent:        intents.PendingRebootUpdate(intents.WithNodeName("node-c")),
+		ClusterActive: 1,
+		ClusterCount:  10,
+	})
+	assert.NilError(t, err)
+	assert.Check(t, permit)
+
+	canary.Passed("canary-a")
+	canary.Observe(testCanary(intents.Stabilized(intents.WithNodeName("canary-a"), intents.WithUpdateAvailable(marker.NodeUpdateUnavailable))))
+	assert.Check(t, starting("node-b"))
+}
+
+func TestManagerCanaryFailed(t *testing.T) {
+	m, hooks := testManager(t)
+	fake := testRecorder(m)
+	m.canary = newCanaryGate(marker.CanaryKey, 0, hooks.Clock)
+	m.canary.Observe(testCanary(intents.PendingRebootUpdate(intents.WithNodeName("node-a"))))
+	hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+		return false, nil
+	}
+
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	events := recordedEvents(fake)
+	assert.Equal(t, events[1], "Warning CanaryFailed Canary node failed its update, blocking all further updates: node not ready after 5m0s, last observed ready condition False")
+	open, reason := m.canary.Open("node-b")
+	assert.Check(t, !open)
+	assert.Equal(t, reason, "canary node-a failed its update: node not ready after 5m0s, last observed ready condition False")
+}
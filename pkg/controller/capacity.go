@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// capacityResources are the resources compared when checking that the
+// cluster can schedule a drained Node's Pods.
+var capacityResources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// sufficientCapacity reports whether the ready and schedulable Nodes have
+// enough spare allocatable resources, after the requests of the Pods already
+// scheduled to them, to schedule the evicted Pods. The resources that fall
+// short are returned with the amount they're short by.
+//
+// Capacity is considered across the Nodes as a whole, the evicted Pods may
+// not fit on any one Node even when there's capacity overall.
+func sufficientCapacity(evicted []v1.Pod, nodes []*v1.Node, scheduled []v1.Pod) (bool, v1.ResourceList) {
+	spare := v1.ResourceList{}
+	eligible := map[string]bool{}
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !nodeReady(node) {
+			continue
+		}
+		eligible[node.GetName()] = true
+		addResources(spare, node.Status.Allocatable)
+	}
+	for i := range scheduled {
+		pod := &scheduled[i]
+		if !eligible[pod.Spec.NodeName] || podTerminated(pod) {
+			continue
+		}
+		subResources(spare, podRequests(pod))
+	}
+
+	needed := v1.ResourceList{}
+	for i := range evicted {
+		addResources(needed, podRequests(&evicted[i]))
+	}
+
+	short := v1.ResourceList{}
+	for _, name := range capacityResources {
+		need, ok := needed[name]
+		if !ok || need.IsZero() {
+			continue
+		}
+		have := spare[name]
+		if need.Cmp(have) > 0 {
+			shortfall := need.DeepCopy()
+			shortfall.Sub(have)
+			short[name] = shortfall
+		}
+	}
+	return len(short) == 0, short
+}
+
+// podRequests sums the resources requested by the Pod's containers.
+func podRequests(pod *v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+	}
+	return requests
+}
+
+// formatResources formats the resources for logging.
+func formatResources(resources v1.ResourceList) string {
+	var formatted []string
+	for _, name := range capacityResources {
+		if q, ok := resources[name]; ok {
+			formatted = append(formatted, fmt.Sprintf("%s=%s", name, q.String()))
+		}
+	}
+	return strings.Join(formatted, ",")
+}
+
+func podTerminated(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+func addResources(total v1.ResourceList, add v1.ResourceList) {
+	for _, name := range capacityResources {
+		q, ok := add[name]
+		if !ok {
+			continue
+		}
+		sum := total[name].DeepCopy()
+		sum.Add(q)
+		total[name] = sum
+	}
+}
+
+func subResources(total v1.ResourceList, sub v1.ResourceList) {
+	for _, name := range capacityResources {
+		q, ok := sub[name]
+		if !ok {
+			continue
+		}
+		diff := total[name].DeepCopy()
+		diff.Sub(q)
+		total[name] = diff
+	}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func capacityNode(name string, cpu, memory string, ready bool) *v1.Node {
+	status := v1.ConditionTrue
+	if !ready {
+		status = v1.ConditionFalse
+	}
+	return &v1.Node{
+		ObjectMeta: v1meta.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}},
+		},
+	}
+}
+
+func capacityPod(nodeName string, cpu, memory string) v1.Pod {
+	return v1.Pod{Spec: v1.PodSpec{
+		NodeName: nodeName,
+		Containers: []v1.Container{{
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			}},
+		}},
+	}}
+}
+
+func TestSufficientCapacity(t *testing.T) {
+	nodes := []*v1.Node{
+		capacityNode("node-b", "2", "4Gi", true),
+		capacityNode("node-c", "2", "4Gi", true),
+		// Nodes that can't schedule Pods don't contribute capacity.
+		capacityNode("node-d", "8", "16Gi", false),
+	}
+	scheduled := []v1.Pod{
+		capacityPod("node-b", "1500m", "1Gi"),
+		capacityPod("node-c", "1", "1Gi"),
+		capacityPod("node-d", "1", "1Gi"),
+	}
+	finished := capacityPod("node-c", "1", "1Gi")
+	finished.Status.Phase = v1.PodSucceeded
+	scheduled = append(scheduled, finished)
+
+	t.Run("sufficient", func(t *testing.T) {
+		ok, short := sufficientCapacity([]v1.Pod{capacityPod("node-a", "1500m", "4Gi")}, nodes, scheduled)
+		assert.Check(t, ok)
+		assert.Equal(t, len(short), 0)
+	})
+
+	t.Run("insufficient", func(t *testing.T) {
+		ok, short := sufficientCapacity([]v1.Pod{
+			capacityPod("node-a", "1", "2Gi"),
+			capacityPod("node-a", "1", "2Gi"),
+		}, nodes, scheduled)
+		assert.Check(t, !ok)
+		assert.Equal(t, len(short), 1, "only cpu should be short")
+		cpu := short[v1.ResourceCPU]
+		assert.Equal(t, cpu.String(), "500m")
+	})
+
+	t.Run("unschedulable", func(t *testing.T) {
+		cordoned := capacityNode("node-b", "2", "4Gi", true)
+		cordoned.Spec.Unschedulable = true
+		ok, _ := sufficientCapacity([]v1.Pod{capacityPod("node-a", "1500m", "1Gi")}, []*v1.Node{cordoned}, nil)
+		assert.Check(t, !ok)
+	})
+}
+
+func TestDrainCapacityCheck(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	assert.NilError(t, store.Add(capacityNode("node-a", "2", "4Gi", true)))
+	assert.NilError(t, store.Add(capacityNode("node-b", "2", "4Gi", true)))
+
+	t.Run("insufficient", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.checkCapacity = true
+		m.storer = &testStorer{store}
+		var considered []string
+		hooks.NodeManager.CapacityFn = func(_ string, others []*v1.Node) (bool, v1.ResourceList, error) {
+			for _, node := range others {
+				considered = append(considered, node.GetName())
+			}
+			return false, v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}, nil
+		}
+		cordoned := false
+		hooks.NodeManager.CordonFn = trackFn(&cordoned)
+
+		err := m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a"))))
+		assert.Equal(t, errors.Cause(err), errInsufficientCapacity)
+		assert.DeepEqual(t, considered, []string{"node-b"})
+		assert.Check(t, !cordoned, "node should not be cordoned without capacity")
+		assert.Equal(t, len(hooks.Poster.calledIntents), 0, "update should be deferred")
+	})
+
+	t.Run("sufficient", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.checkCapacity = true
+		m.storer = &testStorer{store}
+		drained := false
+		hooks.NodeManager.DrainFn = trackDrainFn(&drained, 1)
+
+		err := m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a"))))
+		assert.NilError(t, err)
+		assert.Check(t, drained)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 1)
+	})
+}
+
+func TestPendingWorkloads(t *testing.T) {
+	pod := func(nodeName string, phase v1.PodPhase, ready bool) v1.Pod {
+		status := v1.ConditionFalse
+		if ready {
+			status = v1.ConditionTrue
+		}
+		return v1.Pod{
+			Spec: v1.PodSpec{NodeName: nodeName},
+			Status: v1.PodStatus{
+				Phase:      phase,
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: status}},
+			},
+		}
+	}
+	terminating := pod("node-a", v1.PodRunning, false)
+	terminating.DeletionTimestamp = &v1meta.Time{}
+
+	assert.Equal(t, pendingWorkloads("node-a", []v1.Pod{
+		pod("node-a", v1.PodRunning, true),
+		pod("node-a", v1.PodSucceeded, false),
+		pod("node-b", v1.PodPending, false),
+		terminating,
+	}), 0)
+	assert.Equal(t, pendingWorkloads("node-a", []v1.Pod{
+		pod("node-a", v1.PodRunning, true),
+		pod("node-a", v1.PodRunning, false),
+		pod("node-a", v1.PodPending, false),
+		pod("", v1.PodPending, false),
+	}), 3)
+}
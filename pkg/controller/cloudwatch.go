@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/cloudwatch"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+)
+
+// publishUpdateDuration publishes the time the Node took to complete its
+// update, from the time recorded on the Node as its update began.
+func (am *actionManager) publishUpdateDuration(nodeName string) {
+	if am.cloudWatch == nil {
+		return
+	}
+	node, ok := am.storedNode(nodeName)
+	if !ok {
+		return
+	}
+	started, err := time.Parse(time.RFC3339, node.GetAnnotations()[marker.UpdateStartedAtKey])
+	if err != nil {
+		return
+	}
+	am.cloudWatch.Observe(cloudwatch.UpdateDuration, am.clock.Since(started))
+}
+
+// countUpdating counts the stored Nodes that are counted as active against the
+// limit of Nodes updating at once.
+func (am *actionManager) countUpdating() float64 {
+	if am.storer == nil {
+		return 0
+	}
+	return float64(len(activeNodes(am.storer.GetStore().List())))
+}
@@ -0,0 +1,832 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	intentcache "github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent/cache"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
+)
+
+const (
+	// ConfigPath is the HTTP path that the effective configuration is served
+	// on, alongside metrics.
+	ConfigPath = "/config"
+	// EventsPath is the HTTP path that the recent update lifecycle events are
+	// served on, alongside metrics.
+	EventsPath = "/events"
+	// ActivePath is the HTTP path that the Nodes counted as active against the
+	// limit of Nodes updating at once are served on, alongside metrics.
+	ActivePath = "/active"
+
+	defaultAutoLabelInterfaceVersion marker.PlatformVersion = "2.0.0"
+	defaultKeepCordonedLabel                                = marker.KeepCordonedKey
+	defaultDoNotDrainAnnotation                             = marker.DoNotDrainKey
+	defaultCanaryLabel                                      = marker.CanaryKey
+	defaultWorkloadSettleTimeout                            = time.Minute
+	defaultHealthCheckTimeout                               = 5 * time.Minute
+	defaultHealthCheckInterval                              = 10 * time.Second
+	defaultUpdateStartWindow                                = time.Hour
+)
+
+// DrainFailureAction is the action taken when a Node fails to drain.
+type DrainFailureAction = string
+
+const (
+	// DrainFailureProceed continues updating the Node regardless of the
+	// failed drain.
+	DrainFailureProceed DrainFailureAction = "proceed"
+	// DrainFailureSkip uncordons the Node and resets it, skipping its update so
+	// that the rollout continues with other Nodes.
+	DrainFailureSkip DrainFailureAction = "skip"
+	// DrainFailureHalt leaves the Node cordoned and halts the rollout so that
+	// the failure may be investigated.
+	DrainFailureHalt DrainFailureAction = "halt"
+)
+
+// IncompleteViewAction is the action taken when a policy check is made with an
+// incomplete view of the cluster.
+type IncompleteViewAction = string
+
+const (
+	// IncompleteViewProceed checks the policy with the Nodes that are in view.
+	IncompleteViewProceed IncompleteViewAction = "proceed"
+	// IncompleteViewDeny denies the intent, failing closed. The intent is
+	// considered again when it's next handled.
+	IncompleteViewDeny IncompleteViewAction = "deny"
+	// IncompleteViewRetry denies the intent and checks it again after a delay.
+	IncompleteViewRetry IncompleteViewAction = "retry"
+)
+
+// Config is the configuration of the Controller's handling of Nodes.
+type Config struct {
+	// OrderByLaunchTime, when set, orders Nodes eligible to begin an update by
+	// their launch time so that the longest running Nodes are updated first.
+	OrderByLaunchTime bool
+	// IntentCacheTTL is the longest duration that a handled Intent is
+	// remembered to deduplicate equivalent Intents. Intents are reconsidered
+	// once they expire.
+	IntentCacheTTL time.Duration
+	// ResyncPeriod is the time between resynchronizations of the managed
+	// Nodes, when each is reconsidered, defaulting to
+	// nodestream.DefaultResyncPeriod. Intents denied by policy are
+	// reconsidered sooner with a shorter period, at the cost of handling every
+	// Node that much more often. It may not be shorter than the
+	// IntentCacheTTL, which would dedupe the resynchronized Intents.
+	ResyncPeriod time.Duration
+	// AutoLabelSelector, when set, is a label selector for Nodes that are
+	// automatically labeled for management by the operator when they join the
+	// cluster. Nodes not matching the selector are never labeled.
+	AutoLabelSelector string
+	// AutoLabelInterfaceVersion is the updater interface version that
+	// automatically labeled Nodes are given.
+	AutoLabelInterfaceVersion marker.PlatformVersion
+	// ManagedSelector, when set, is a label selector for Nodes that are
+	// expected to be managed by the operator. Matching Nodes that are missing
+	// the operator's marker label are reported.
+	ManagedSelector string
+	// LabelUnmarked, when set, labels the reported Nodes for management, given
+	// the AutoLabelInterfaceVersion.
+	LabelUnmarked bool
+	// NodeSelector, when set, is a label selector limiting the managed Nodes
+	// the controller acts on, and counts toward its limits, to those
+	// matching. Instances given disjoint selectors may manage separate groups
+	// of Nodes in the same cluster.
+	NodeSelector string
+	// MetricsAddr, when set, is the address metrics are served on.
+	MetricsAddr string
+	// HealthAddr, when set, is the address liveness and readiness are served
+	// on for Kubernetes to probe.
+	HealthAddr string
+	// TracingEndpoint, when set, is the OTLP/HTTP traces endpoint of the
+	// OpenTelemetry collector that the spans of the Nodes' updates are
+	// exported to.
+	TracingEndpoint string
+	// KeepCordonedLabel is the label that, when present on a Node, leaves the
+	// Node cordoned after it is successfully updated.
+	KeepCordonedLabel string
+	// DrainGraceSelector, when set, is a label selector for Pods that are
+	// given DrainGracePeriod to terminate when drained in place of their own
+	// termination grace period.
+	DrainGraceSelector string
+	// DrainGracePeriod is the termination grace period given to Pods matching
+	// DrainGraceSelector.
+	DrainGracePeriod time.Duration
+	// DoNotDrainAnnotation is the annotation that, when set to true on a Pod,
+	// leaves the Pod in place when its Node is drained, defaulting to
+	// marker.DoNotDrainKey.
+	DoNotDrainAnnotation string
+	// DrainFailureAction is the action taken when a Node fails to drain,
+	// defaulting to DrainFailureProceed.
+	DrainFailureAction DrainFailureAction
+	// IncompleteViewAction is the action taken when a policy check is made
+	// with an incomplete view of the cluster, defaulting to
+	// IncompleteViewProceed.
+	IncompleteViewAction IncompleteViewAction
+	// ResumeRamp is the duration, after a paused rollout resumes, over which
+	// the number of Nodes permitted to update at once increases from one to
+	// the maximum. The maximum is permitted immediately when unset. The ramp
+	// requires a BatchSize above one, or a BatchPercent, to ramp up to.
+	ResumeRamp time.Duration
+	// HoldLabel is the label that, when present on a Node, holds the Node at
+	// its current step, defaulting to marker.HoldKey.
+	HoldLabel string
+	// UnknownIntentGrace, when set, is the duration after which a Node whose
+	// intent remains unknown, such as from missing or corrupted markers, is
+	// reset to stabilize. Such Nodes are otherwise left in place.
+	UnknownIntentGrace time.Duration
+	// BusyTimeout, when set, is the duration after which a Node that remains
+	// busy with the same action, such as after its Agent stopped partway
+	// through the action, is treated as stuck and reset to stabilize. Such
+	// Nodes otherwise hold their place among the Nodes permitted to update.
+	BusyTimeout time.Duration
+	// StartupSettle, when set, is the window after the controller starts
+	// during which Node events are coalesced, handling each Node's latest
+	// state once the window ends rather than every Node as it's added.
+	StartupSettle time.Duration
+	// BatchSize is the most Nodes permitted to be updating at once,
+	// defaulting to one when unset. It must not be negative.
+	BatchSize int
+	// BatchPercent, when set, is the percentage of the cluster's Nodes
+	// permitted to be updating at once, rounded up and at least one. It's
+	// recomputed as Nodes join or leave the cluster and can't be used with
+	// BatchSize.
+	BatchPercent int
+	// BatchQuorum, when set, is the fraction of a batch of Nodes that must
+	// pass their health check after updating before the next batch may begin.
+	// Nodes are otherwise considered independently.
+	BatchQuorum float64
+	// VerifyDelay, when set, is the time after a Node passes its health check
+	// during which it's rechecked, catching Nodes that become not ready again,
+	// before its update is considered successful.
+	VerifyDelay time.Duration
+	// WorkloadSettleTimeout is the longest time given, after a Node is
+	// uncordoned, for its drained workloads to be scheduled and ready before
+	// the rollout moves on, defaulting to one minute.
+	WorkloadSettleTimeout time.Duration
+	// VerifyBootID, when set, confirms that a Node rebooted into its update
+	// by comparing its boot ID from before and after the reboot. A Node with
+	// an unchanged boot ID has failed to reboot.
+	VerifyBootID bool
+	// HealthCheckTimeout is the longest time a Node is given to report itself
+	// ready after its update before its health check fails, defaulting to
+	// five minutes.
+	HealthCheckTimeout time.Duration
+	// HealthCheckInterval is the time between checks of the Node's readiness
+	// during its health check, defaulting to 10 seconds.
+	HealthCheckInterval time.Duration
+	// ReadinessTaints are the keys of taints, such as
+	// node.kubernetes.io/network-unavailable, that a Node must not have to
+	// pass its health check after updating.
+	ReadinessTaints []string
+	// ReadinessConditions are the conditions a Node must report to pass its
+	// health check after updating, each given as "Type" to require the
+	// condition be true or "Type=Status".
+	ReadinessConditions []string
+	// SkipHealthCheck, when set, skips waiting for a Node to be ready after
+	// its update for environments with their own health monitoring. The Node
+	// is still uncordoned.
+	SkipHealthCheck bool
+	// CheckDrainCapacity, when set, defers updating a Node until the other
+	// managed Nodes have the spare capacity to schedule the Pods its drain
+	// would evict.
+	CheckDrainCapacity bool
+	// MaxCordoned, when set, is the most managed Nodes permitted to be
+	// cordoned at once, counting Nodes left cordoned outside of an update
+	// such as while they recover. Further Nodes aren't cordoned, deferring
+	// their update, until the count falls below it.
+	MaxCordoned int
+	// SeparateAntiAffine, when set, keeps Nodes hosting anti-affine replicas
+	// of the same workload, such as the members of an HA pair, from updating
+	// at the same time.
+	SeparateAntiAffine bool
+	// MinRebootInterval, when set, is the least time permitted between the
+	// reboots of any two Nodes in the cluster.
+	MinRebootInterval time.Duration
+	// MaxUpdateStarts, when set, is the most Nodes permitted to begin
+	// updating within UpdateStartWindow across the cluster, however many may
+	// be updating at once.
+	MaxUpdateStarts int
+	// UpdateStartWindow is the rolling window MaxUpdateStarts applies to,
+	// defaulting to an hour.
+	UpdateStartWindow time.Duration
+	// ConcurrencyRampStep, when set, starts the rollout with a single Node
+	// updating at once and raises the number permitted by one after each
+	// ConcurrencyRampStep consecutive successful updates, up to BatchSize. A
+	// failed update halves the number permitted.
+	ConcurrencyRampStep int
+	// CanaryRollout, when set, updates the Nodes labeled with CanaryLabel
+	// ahead of the rest of the cluster. Other Nodes wait until every canary
+	// has updated, passed its health check, and soaked for CanarySoak. A
+	// canary failing its update blocks all further updates.
+	CanaryRollout bool
+	// CanaryLabel is the label that, when present on a Node, marks it as a
+	// canary, defaulting to marker.CanaryKey.
+	CanaryLabel string
+	// CanarySoak is the time, after the last canary completes its update,
+	// before other Nodes may begin updating.
+	CanarySoak time.Duration
+	// RollbackOnFailure, when set, directs a Node that fails its post-update
+	// health check, within HealthCheckTimeout, to roll back to the version it
+	// was running before the update. The Node stays cordoned until it's back
+	// on its prior version and ready.
+	RollbackOnFailure bool
+	// MaintenanceWindows, when set, are the comma separated daily windows,
+	// each given as "HH:MM-HH:MM", during which Nodes may begin updating.
+	// Nodes already updating are permitted to finish after a window closes.
+	// A window ending before it starts spans midnight.
+	MaintenanceWindows string
+	// MaintenanceTimezone is the name of the time zone the maintenance
+	// windows are observed in, defaulting to UTC.
+	MaintenanceTimezone string
+	// ReportEvents, when set, writes the Nodes' update lifecycle events to
+	// stdout as structured JSON records.
+	ReportEvents bool
+	// EventHistory, when set, is the number of recent update lifecycle events
+	// kept in memory and served alongside metrics.
+	EventHistory int
+	// StatusSink, when set, is periodically written the update status of the
+	// managed Nodes.
+	StatusSink StatusSink
+	// StatusSinkURL, when set and StatusSink is not, is a URL the managed
+	// Nodes' update status is periodically posted to as JSON.
+	StatusSinkURL string
+	// StatusInterval is the time between writes of the Nodes' update status
+	// to the status sink.
+	StatusInterval time.Duration
+	// NotifySink, when set, is notified as each Node's update begins,
+	// succeeds, or fails.
+	NotifySink notify.Sink
+	// NotifyURL, when set and NotifySink is not, is a webhook URL each
+	// Node's update notifications are posted to as JSON.
+	NotifyURL string
+	// SNSTopicARN, when set and NotifySink is not, is an Amazon SNS topic
+	// each Node's update notifications are published to.
+	SNSTopicARN string
+	// CloudWatchNamespace, when set, is the CloudWatch namespace the update
+	// metrics are published to as custom metrics.
+	CloudWatchNamespace string
+	// CloudWatchRegion is the region the CloudWatch metrics are published
+	// in, found by the AWS SDK's default provider chain when unset.
+	CloudWatchRegion string
+	// LeaderElection, when set, runs the Controller only while it holds a
+	// Lease so that a single replica acts on the cluster at a time, with any
+	// others standing by to take over.
+	LeaderElection bool
+	// LeaseName is the name of the Lease held by the leading replica,
+	// defaulting to bottlerocket-update-operator-controller.
+	LeaseName string
+	// LeaseNamespace is the namespace of the Lease, defaulting to
+	// bottlerocket.
+	LeaseNamespace string
+	// LeaseDuration is the time standby replicas wait, after the Lease was
+	// last renewed, before taking it over, defaulting to 15 seconds.
+	LeaseDuration time.Duration
+	// LeaseRenewDeadline is the time the leading replica retries renewing
+	// the Lease before giving up leadership, defaulting to 10 seconds.
+	LeaseRenewDeadline time.Duration
+	// LeaseRetryPeriod is the time between attempts to acquire or renew the
+	// Lease, defaulting to 2 seconds.
+	LeaseRetryPeriod time.Duration
+}
+
+func (c *Config) intentCacheTTL() time.Duration {
+	if c.IntentCacheTTL <= 0 {
+		return intentcache.DefaultTTL
+	}
+	return c.IntentCacheTTL
+}
+
+func (c *Config) resyncPeriod() (time.Duration, error) {
+	switch {
+	case c.ResyncPeriod == 0:
+		return nodestream.DefaultResyncPeriod, nil
+	case c.ResyncPeriod < 0:
+		return 0, errors.Errorf("invalid resync period %s", c.ResyncPeriod)
+	case c.ResyncPeriod < c.intentCacheTTL():
+		return 0, errors.Errorf("resync period %s must be at least the intent cache TTL %s", c.ResyncPeriod, c.intentCacheTTL())
+	}
+	return c.ResyncPeriod, nil
+}
+
+func (c *Config) maintenanceSchedule(clk clock.Clock) (*maintenanceSchedule, error) {
+	return newMaintenanceSchedule(c.MaintenanceWindows, c.MaintenanceTimezone, clk)
+}
+
+func (c *Config) maintenanceTimezone() string {
+	if c.MaintenanceTimezone == "" {
+		return time.UTC.String()
+	}
+	return c.MaintenanceTimezone
+}
+
+func (c *Config) autoLabelInterfaceVersion() marker.PlatformVersion {
+	if c.AutoLabelInterfaceVersion == "" {
+		return defaultAutoLabelInterfaceVersion
+	}
+	return c.AutoLabelInterfaceVersion
+}
+
+func (c *Config) keepCordonedLabel() string {
+	if c.KeepCordonedLabel == "" {
+		return defaultKeepCordonedLabel
+	}
+	return c.KeepCordonedLabel
+}
+
+func (c *Config) canaryLabel() string {
+	if c.CanaryLabel == "" {
+		return defaultCanaryLabel
+	}
+	return c.CanaryLabel
+}
+
+func (c *Config) doNotDrainAnnotation() string {
+	if c.DoNotDrainAnnotation == "" {
+		return defaultDoNotDrainAnnotation
+	}
+	return c.DoNotDrainAnnotation
+}
+
+func (c *Config) drainGrace() (*drainGrace, error) {
+	if c.DrainGraceSelector == "" {
+		return nil, nil
+	}
+	selector, err := labels.Parse(c.DrainGraceSelector)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid drain grace selector")
+	}
+	if c.DrainGracePeriod < 0 {
+		return nil, errors.Errorf("invalid drain grace period %s", c.DrainGracePeriod)
+	}
+	return &drainGrace{
+		selector: selector,
+		seconds:  int(c.DrainGracePeriod / time.Second),
+	}, nil
+}
+
+// nodeSelector is the validated NodeSelector, empty when every managed Node
+// is selected.
+func (c *Config) nodeSelector() (string, error) {
+	selector, err := labels.Parse(c.NodeSelector)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid node selector")
+	}
+	return selector.String(), nil
+}
+
+func (c *Config) drainFailureAction() (DrainFailureAction, error) {
+	switch c.DrainFailureAction {
+	case "":
+		return DrainFailureProceed, nil
+	case DrainFailureProceed, DrainFailureSkip, DrainFailureHalt:
+		return c.DrainFailureAction, nil
+	}
+	return "", errors.Errorf("unknown drain failure action %q", c.DrainFailureAction)
+}
+
+func (c *Config) incompleteViewAction() (IncompleteViewAction, error) {
+	switch c.IncompleteViewAction {
+	case "":
+		return IncompleteViewProceed, nil
+	case IncompleteViewProceed, IncompleteViewDeny, IncompleteViewRetry:
+		return c.IncompleteViewAction, nil
+	}
+	return "", errors.Errorf("unknown incomplete view action %q", c.IncompleteViewAction)
+}
+
+func (c *Config) resumeRamp() (time.Duration, error) {
+	switch {
+	case c.ResumeRamp < 0:
+		return 0, errors.Errorf("invalid resume ramp %s", c.ResumeRamp)
+	case c.ResumeRamp > 0 && c.BatchSize <= 1 && c.BatchPercent == 0:
+		// A single Node updating at once leaves nothing to ramp up.
+		return 0, errors.Errorf("resume ramp %s requires a batch size above 1 or a batch percent", c.ResumeRamp)
+	}
+	return c.ResumeRamp, nil
+}
+
+func (c *Config) statusSink() StatusSink {
+	if c.StatusSink != nil {
+		return c.StatusSink
+	}
+	if c.StatusSinkURL != "" {
+		return &HTTPStatusSink{URL: c.StatusSinkURL}
+	}
+	return nil
+}
+
+func (c *Config) notifySinks() ([]notify.Sink, error) {
+	if c.NotifySink != nil {
+		return []notify.Sink{c.NotifySink}, nil
+	}
+	var sinks []notify.Sink
+	if c.NotifyURL != "" {
+		sinks = append(sinks, &notify.Webhook{URL: c.NotifyURL})
+	}
+	if c.SNSTopicARN != "" {
+		topic, err := notify.NewSNS(c.SNSTopicARN)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, topic)
+	}
+	return sinks, nil
+}
+
+func (c *Config) statusInterval() time.Duration {
+	if c.StatusInterval <= 0 {
+		return defaultStatusInterval
+	}
+	return c.StatusInterval
+}
+
+func (c *Config) batchSize() (int, error) {
+	if c.BatchSize < 0 {
+		return 0, errors.Errorf("invalid batch size %d, must be at least 1", c.BatchSize)
+	}
+	if c.BatchSize == 0 {
+		return maxClusterActive, nil
+	}
+	return c.BatchSize, nil
+}
+
+func (c *Config) batchPercent() (int, error) {
+	if c.BatchPercent < 0 || c.BatchPercent > 100 {
+		return 0, errors.Errorf("invalid batch percent %d, must be between 0 and 100", c.BatchPercent)
+	}
+	if c.BatchPercent == 0 {
+		return 0, nil
+	}
+	if c.BatchSize != 0 {
+		return 0, errors.Errorf("batch size %d and batch percent %d are mutually exclusive, set only one", c.BatchSize, c.BatchPercent)
+	}
+	if c.BatchQuorum != 0 {
+		// The batch gate tracks batches of a fixed size.
+		return 0, errors.New("batch quorum requires a batch size, not a batch percent")
+	}
+	return c.BatchPercent, nil
+}
+
+func (c *Config) batchQuorum() (float64, error) {
+	if c.BatchQuorum < 0 || c.BatchQuorum > 1 {
+		return 0, errors.Errorf("invalid batch quorum %v, must be between 0 and 1", c.BatchQuorum)
+	}
+	return c.BatchQuorum, nil
+}
+
+func (c *Config) healthCheckTimeout() time.Duration {
+	if c.HealthCheckTimeout <= 0 {
+		return defaultHealthCheckTimeout
+	}
+	return c.HealthCheckTimeout
+}
+
+func (c *Config) healthCheckInterval() time.Duration {
+	if c.HealthCheckInterval <= 0 {
+		return defaultHealthCheckInterval
+	}
+	return c.HealthCheckInterval
+}
+
+func (c *Config) updateStartWindow() time.Duration {
+	if c.UpdateStartWindow <= 0 {
+		return defaultUpdateStartWindow
+	}
+	return c.UpdateStartWindow
+}
+
+func (c *Config) maxUpdateStarts() (int, error) {
+	if c.MaxUpdateStarts < 0 {
+		return 0, errors.Errorf("invalid max update starts %d, must not be negative", c.MaxUpdateStarts)
+	}
+	return c.MaxUpdateStarts, nil
+}
+
+func (c *Config) concurrencyRampStep() (int, error) {
+	if c.ConcurrencyRampStep < 0 {
+		return 0, errors.Errorf("invalid concurrency ramp step %d, must not be negative", c.ConcurrencyRampStep)
+	}
+	return c.ConcurrencyRampStep, nil
+}
+
+func (c *Config) maxCordoned() (int, error) {
+	if c.MaxCordoned < 0 {
+		return 0, errors.Errorf("invalid max cordoned %d, must not be negative", c.MaxCordoned)
+	}
+	return c.MaxCordoned, nil
+}
+
+func (c *Config) minRebootInterval() (time.Duration, error) {
+	if c.MinRebootInterval < 0 {
+		return 0, errors.Errorf("invalid min reboot interval %s, must not be negative", c.MinRebootInterval)
+	}
+	return c.MinRebootInterval, nil
+}
+
+func (c *Config) eventHistory() (int, error) {
+	if c.EventHistory < 0 {
+		return 0, errors.Errorf("invalid event history %d, must not be negative", c.EventHistory)
+	}
+	return c.EventHistory, nil
+}
+
+func (c *Config) readinessGates() (*readinessGates, error) {
+	return newReadinessGates(c.ReadinessTaints, c.ReadinessConditions)
+}
+
+func (c *Config) workloadSettleTimeout() (time.Duration, error) {
+	if c.WorkloadSettleTimeout < 0 {
+		return 0, errors.Errorf("invalid workload settle timeout %s", c.WorkloadSettleTimeout)
+	}
+	if c.WorkloadSettleTimeout == 0 {
+		return defaultWorkloadSettleTimeout, nil
+	}
+	return c.WorkloadSettleTimeout, nil
+}
+
+func (c *Config) leaseName() string {
+	if c.LeaseName == "" {
+		return defaultLeaseName
+	}
+	return c.LeaseName
+}
+
+func (c *Config) leaseNamespace() string {
+	if c.LeaseNamespace == "" {
+		return defaultLeaseNamespace
+	}
+	return c.LeaseNamespace
+}
+
+func (c *Config) leaseDuration() time.Duration {
+	if c.LeaseDuration <= 0 {
+		return defaultLeaseDuration
+	}
+	return c.LeaseDuration
+}
+
+func (c *Config) leaseRenewDeadline() time.Duration {
+	if c.LeaseRenewDeadline <= 0 {
+		return defaultLeaseRenewDeadline
+	}
+	return c.LeaseRenewDeadline
+}
+
+func (c *Config) leaseRetryPeriod() time.Duration {
+	if c.LeaseRetryPeriod <= 0 {
+		return defaultLeaseRetryPeriod
+	}
+	return c.LeaseRetryPeriod
+}
+
+func (c *Config) holdLabel() string {
+	if c.HoldLabel == "" {
+		return marker.HoldKey
+	}
+	return c.HoldLabel
+}
+
+// effectiveConfig is the configuration in effect, after defaults are applied,
+// as reported to operators.
+type effectiveConfig struct {
+	OrderByLaunchTime         bool     `json:"orderByLaunchTime"`
+	IntentCacheTTL            string   `json:"intentCacheTTL"`
+	ResyncPeriod              string   `json:"resyncPeriod"`
+	AutoLabelSelector         string   `json:"autoLabelSelector"`
+	AutoLabelInterfaceVersion string   `json:"autoLabelInterfaceVersion"`
+	ManagedSelector           string   `json:"managedSelector"`
+	NodeSelector              string   `json:"nodeSelector"`
+	LabelUnmarked             bool     `json:"labelUnmarked"`
+	MetricsAddr               string   `json:"metricsAddr"`
+	HealthAddr                string   `json:"healthAddr"`
+	TracingEndpoint           string   `json:"tracingEndpoint"`
+	KeepCordonedLabel         string   `json:"keepCordonedLabel"`
+	DrainGraceSelector        string   `json:"drainGraceSelector"`
+	DrainGracePeriod          string   `json:"drainGracePeriod"`
+	DoNotDrainAnnotation      string   `json:"doNotDrainAnnotation"`
+	DrainFailureAction        string   `json:"drainFailureAction"`
+	IncompleteViewAction      string   `json:"incompleteViewAction"`
+	CheckDrainCapacity        bool     `json:"checkDrainCapacity"`
+	MaxCordoned               int      `json:"maxCordoned"`
+	VerifyDelay               string   `json:"verifyDelay"`
+	WorkloadSettleTimeout     string   `json:"workloadSettleTimeout"`
+	VerifyBootID              bool     `json:"verifyBootID"`
+	HealthCheckTimeout        string   `json:"healthCheckTimeout"`
+	HealthCheckInterval       string   `json:"healthCheckInterval"`
+	ReadinessTaints           []string `json:"readinessTaints"`
+	ReadinessConditions       []string `json:"readinessConditions"`
+	SkipHealthCheck           bool     `json:"skipHealthCheck"`
+	ResumeRamp                string   `json:"resumeRamp"`
+	HoldLabel                 string   `json:"holdLabel"`
+	UnknownIntentGrace        string   `json:"unknownIntentGrace"`
+	BusyTimeout               string   `json:"busyTimeout"`
+	StartupSettle             string   `json:"startupSettle"`
+	BatchSize                 int      `json:"batchSize"`
+	BatchPercent              int      `json:"batchPercent"`
+	BatchQuorum               float64  `json:"batchQuorum"`
+	MinRebootInterval         string   `json:"minRebootInterval"`
+	MaxUpdateStarts           int      `json:"maxUpdateStarts"`
+	UpdateStartWindow         string   `json:"updateStartWindow"`
+	SeparateAntiAffine        bool     `json:"separateAntiAffine"`
+	ConcurrencyRampStep       int      `json:"concurrencyRampStep"`
+	CanaryRollout             bool     `json:"canaryRollout"`
+	CanaryLabel               string   `json:"canaryLabel"`
+	CanarySoak                string   `json:"canarySoak"`
+	RollbackOnFailure         bool     `json:"rollbackOnFailure"`
+	MaintenanceWindows        string   `json:"maintenanceWindows"`
+	MaintenanceTimezone       string   `json:"maintenanceTimezone"`
+	ReportEvents              bool     `json:"reportEvents"`
+	EventHistory              int      `json:"eventHistory"`
+	StatusSinkURL             string   `json:"statusSinkURL"`
+	StatusInterval            string   `json:"statusInterval"`
+	NotifyURL                 string   `json:"notifyURL"`
+	SNSTopicARN               string   `json:"snsTopicARN"`
+	CloudWatchNamespace       string   `json:"cloudWatchNamespace"`
+	CloudWatchRegion          string   `json:"cloudWatchRegion"`
+	LeaderElection            bool     `json:"leaderElection"`
+	LeaseName                 string   `json:"leaseName"`
+	LeaseNamespace            string   `json:"leaseNamespace"`
+	LeaseDuration             string   `json:"leaseDuration"`
+	LeaseRenewDeadline        string   `json:"leaseRenewDeadline"`
+	LeaseRetryPeriod          string   `json:"leaseRetryPeriod"`
+}
+
+// effective resolves the configuration in effect with any secrets redacted.
+func (c *Config) effective() (*effectiveConfig, error) {
+	action, err := c.drainFailureAction()
+	if err != nil {
+		return nil, err
+	}
+	quorum, err := c.batchQuorum()
+	if err != nil {
+		return nil, err
+	}
+	size, err := c.batchSize()
+	if err != nil {
+		return nil, err
+	}
+	percent, err := c.batchPercent()
+	if err != nil {
+		return nil, err
+	}
+	incomplete, err := c.incompleteViewAction()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.resumeRamp(); err != nil {
+		return nil, err
+	}
+	if _, err := c.maxUpdateStarts(); err != nil {
+		return nil, err
+	}
+	if _, err := c.concurrencyRampStep(); err != nil {
+		return nil, err
+	}
+	if _, err := c.maxCordoned(); err != nil {
+		return nil, err
+	}
+	if _, err := c.minRebootInterval(); err != nil {
+		return nil, err
+	}
+	if _, err := c.eventHistory(); err != nil {
+		return nil, err
+	}
+	schedule, err := c.maintenanceSchedule(clock.RealClock{})
+	if err != nil {
+		return nil, err
+	}
+	workloadTimeout, err := c.workloadSettleTimeout()
+	if err != nil {
+		return nil, err
+	}
+	gates, err := c.readinessGates()
+	if err != nil {
+		return nil, err
+	}
+	nodeSelector, err := c.nodeSelector()
+	if err != nil {
+		return nil, err
+	}
+	resync, err := c.resyncPeriod()
+	if err != nil {
+		return nil, err
+	}
+	return &effectiveConfig{
+		OrderByLaunchTime:         c.OrderByLaunchTime,
+		IntentCacheTTL:            c.intentCacheTTL().String(),
+		ResyncPeriod:              resync.String(),
+		AutoLabelSelector:         c.AutoLabelSelector,
+		AutoLabelInterfaceVersion: string(c.autoLabelInterfaceVersion()),
+		ManagedSelector:           c.ManagedSelector,
+		NodeSelector:              nodeSelector,
+		LabelUnmarked:             c.LabelUnmarked,
+		MetricsAddr:               c.MetricsAddr,
+		HealthAddr:                c.HealthAddr,
+		TracingEndpoint:           redactURL(c.TracingEndpoint),
+		KeepCordonedLabel:         c.keepCordonedLabel(),
+		DrainGraceSelector:        c.DrainGraceSelector,
+		DrainGracePeriod:          c.DrainGracePeriod.String(),
+		DoNotDrainAnnotation:      c.doNotDrainAnnotation(),
+		DrainFailureAction:        action,
+		IncompleteViewAction:      incomplete,
+		CheckDrainCapacity:        c.CheckDrainCapacity,
+		MaxCordoned:               c.MaxCordoned,
+		VerifyDelay:               c.VerifyDelay.String(),
+		WorkloadSettleTimeout:     workloadTimeout.String(),
+		VerifyBootID:              c.VerifyBootID,
+		HealthCheckTimeout:        c.healthCheckTimeout().String(),
+		HealthCheckInterval:       c.healthCheckInterval().String(),
+		ReadinessTaints:           gates.Taints(),
+		ReadinessConditions:       gates.Conditions(),
+		SkipHealthCheck:           c.SkipHealthCheck,
+		ResumeRamp:                c.ResumeRamp.String(),
+		HoldLabel:                 c.holdLabel(),
+		UnknownIntentGrace:        c.UnknownIntentGrace.String(),
+		BusyTimeout:               c.BusyTimeout.String(),
+		StartupSettle:             c.StartupSettle.String(),
+		BatchSize:                 size,
+		BatchPercent:              percent,
+		BatchQuorum:               quorum,
+		MinRebootInterval:         c.MinRebootInterval.String(),
+		MaxUpdateStarts:           c.MaxUpdateStarts,
+		UpdateStartWindow:         c.updateStartWindow().String(),
+		SeparateAntiAffine:        c.SeparateAntiAffine,
+		ConcurrencyRampStep:       c.ConcurrencyRampStep,
+		CanaryRollout:             c.CanaryRollout,
+		CanaryLabel:               c.canaryLabel(),
+		CanarySoak:                c.CanarySoak.String(),
+		RollbackOnFailure:         c.RollbackOnFailure,
+		MaintenanceWindows:        schedule.String(),
+		MaintenanceTimezone:       c.maintenanceTimezone(),
+		ReportEvents:              c.ReportEvents,
+		EventHistory:              c.EventHistory,
+		StatusSinkURL:             redactURL(c.StatusSinkURL),
+		StatusInterval:            c.statusInterval().String(),
+		NotifyURL:                 redactURL(c.NotifyURL),
+		SNSTopicARN:               c.SNSTopicARN,
+		CloudWatchNamespace:       c.CloudWatchNamespace,
+		CloudWatchRegion:          c.CloudWatchRegion,
+		LeaderElection:            c.LeaderElection,
+		LeaseName:                 c.leaseName(),
+		LeaseNamespace:            c.leaseNamespace(),
+		LeaseDuration:             c.leaseDuration().String(),
+		LeaseRenewDeadline:        c.leaseRenewDeadline().String(),
+		LeaseRetryPeriod:          c.leaseRetryPeriod().String(),
+	}, nil
+}
+
+// redactURL removes the credentials that may be embedded in the URL's
+// userinfo or query.
+func redactURL(rawurl string) string {
+	if rawurl == "" {
+		return ""
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		// The URL can't be inspected, so none of it is shown.
+		return "redacted"
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+		}
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "redacted")
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// String formats the effective configuration as JSON for logging.
+func (ec *effectiveConfig) String() string {
+	data, err := json.Marshal(ec)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// ServeHTTP responds with the effective configuration as JSON, or an error
+// when it's unable to be encoded.
+func (ec *effectiveConfig) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	body, err := json.Marshal(ec)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "unable to encode configuration").Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
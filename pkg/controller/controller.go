@@ -3,33 +3,118 @@ package controller
 import (
 	"context"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/health"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/workgroup"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes"
 )
 
 // Controller coordinates updates within a Cluster run by the Update Operator
 // Agent on Bottlerocket Nodes.
 type Controller struct {
-	log     logging.Logger
-	kube    kubernetes.Interface
-	manager *actionManager
+	log      logging.Logger
+	kube     kubernetes.Interface
+	manager  *actionManager
+	labeler  *autoLabeler
+	unmarked *unmarkedDetector
+	metrics  *metrics.Server
+	status   *statusReporter
+	// election runs the Controller only while it leads, when configured.
+	election *leaderElection
+	// health tracks the Controller's informers and event loop, served by the
+	// healthServer when configured.
+	health       *health.Status
+	healthServer *metrics.Server
 }
 
 // New creates a Controller instance.
-func New(log logging.Logger, kube kubernetes.Interface, nodeName string) (*Controller, error) {
-	return &Controller{
+func New(log logging.Logger, kube kubernetes.Interface, nodeName string, config Config) (*Controller, error) {
+	manager, err := newManager(log.WithField("worker", "manager"), kube, nodeName, config)
+	if err != nil {
+		return nil, err
+	}
+	effective, err := config.effective()
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("config", effective).Info("effective configuration")
+	c := &Controller{
 		log:     log,
 		kube:    kube,
-		manager: newManager(log.WithField("worker", "manager"), kube, nodeName),
-	}, nil
+		manager: manager,
+	}
+	if config.MetricsAddr != "" {
+		c.metrics = metrics.NewServer(log.WithField("worker", "metrics"), config.MetricsAddr)
+		c.metrics.Handle(ConfigPath, effective)
+		c.metrics.Handle(ActivePath, &activeHandler{manager: manager})
+		if manager.history != nil {
+			c.metrics.Handle(EventsPath, manager.history)
+		}
+	}
+	if config.HealthAddr != "" {
+		c.health = health.NewStatus(clock.RealClock{})
+		c.healthServer = metrics.NewHandlerServer(log.WithField("worker", "health"), config.HealthAddr, "health")
+		c.healthServer.Handle(health.LivePath, c.health.LiveHandler())
+		c.healthServer.Handle(health.ReadyPath, c.health.ReadyHandler())
+		manager.health = c.health
+	}
+	if sink := config.statusSink(); sink != nil {
+		c.status = &statusReporter{
+			log:      log.WithField("worker", "status"),
+			sink:     sink,
+			interval: config.statusInterval(),
+			clock:    clock.RealClock{},
+		}
+	}
+	if config.AutoLabelSelector != "" {
+		labeler, err := newAutoLabeler(log.WithField("worker", "labeler"), config, &k8sMarkerPoster{kube.CoreV1().Nodes()})
+		if err != nil {
+			return nil, err
+		}
+		c.labeler = labeler
+	}
+	if config.ManagedSelector != "" {
+		unmarked, err := newUnmarkedDetector(log.WithField("worker", "unmarked"), config, &k8sMarkerPoster{kube.CoreV1().Nodes()})
+		if err != nil {
+			return nil, err
+		}
+		c.unmarked = unmarked
+	} else if config.LabelUnmarked {
+		return nil, errors.New("labeling unmarked nodes requires a managed selector")
+	}
+	if config.LeaderElection {
+		election, err := newLeaderElection(log.WithField("worker", "election"), kube, electionIdentity(nodeName), config)
+		if err != nil {
+			return nil, err
+		}
+		c.election = election
+	}
+	return c, nil
 }
 
-// Run executes the event loop for the Controller until signaled to exit.
+// Run executes the event loop for the Controller until signaled to exit. With
+// leader election, the event loop is only run while the Controller leads.
+// Health is served throughout, a standby Controller is live and ready.
 func (c *Controller) Run(ctx context.Context) error {
+	if c.healthServer != nil {
+		serving, stop := context.WithCancel(ctx)
+		defer stop()
+		go c.healthServer.Run(serving)
+	}
+	if c.election != nil {
+		return c.election.Run(ctx, c.run)
+	}
+	return c.run(ctx)
+}
+
+func (c *Controller) run(ctx context.Context) error {
 	worker, cancel := context.WithCancel(ctx)
 	defer cancel()
+	defer c.manager.events.Shutdown()
 
 	c.log.Debug("starting workers")
 
@@ -37,15 +122,60 @@ func (c *Controller) Run(ctx context.Context) error {
 
 	// The nodestream will provide us with resource events that are scoped to
 	// Nodes we "should" care about - those are labeled with markers.
-	ns := nodestream.New(c.log.WithField("worker", "informer"), c.kube, nodestream.Config{}, c.manager)
+	ns := nodestream.New(c.log.WithField("worker", "informer"), c.kube, c.manager.streamConfig(), c.manager)
 	// Couple the informer's reflector in the manager for accessing the cached
 	// cluster state.
 	c.manager.SetStoreProvider(ns.GetInformer())
+	c.health.Synced("informer", ns.GetInformer().HasSynced)
+
+	group.Work(c.health.Work("informer", 0, ns.Run))
+	group.Work(c.health.Work("manager", managerStale, c.manager.Run))
+	group.Work(c.manager.tracer.Run)
+	group.Work(c.manager.notifier.Run)
+	group.Work(c.manager.cloudWatch.Run)
+
+	if c.manager.antiAffinity != nil {
+		pods := newPodStream(c.kube)
+		c.manager.antiAffinity.pods = pods
+		group.Work(pods.Run)
+	}
+
+	if c.status != nil {
+		c.status.storer = ns.GetInformer()
+		group.Work(c.status.Run)
+	}
+
+	if c.metrics != nil {
+		group.Work(c.metrics.Run)
+
+		// Count all of the cluster's Nodes to compare with those managed.
+		counter := &nodeCounter{}
+		cs := nodestream.New(c.log.WithField("worker", "counter-informer"), c.kube, counter.streamConfig(), counter)
+		counter.storer = cs.GetInformer()
+		group.Work(cs.Run)
+	}
+
+	if c.labeler != nil {
+		// Unmanaged Nodes are streamed separately so that the manager only
+		// ever sees the Nodes it is responsible for.
+		ls := nodestream.New(c.log.WithField("worker", "labeler-informer"), c.kube, c.labeler.streamConfig(), c.labeler)
+		group.Work(ls.Run)
+	}
 
-	group.Work(ns.Run)
-	group.Work(c.manager.Run)
+	if c.unmarked != nil {
+		us := nodestream.New(c.log.WithField("worker", "unmarked-informer"), c.kube, c.unmarked.streamConfig(), c.unmarked)
+		c.unmarked.storer = us.GetInformer()
+		group.Work(us.Run)
+	}
 
 	c.log.Debug("running control loop")
 	<-ctx.Done()
+	// The workers must have stopped acting on Nodes before returning, with
+	// leader election the lease is released as soon as this returns.
+	cancel()
+	if err := group.Wait(); err != nil {
+		c.log.WithError(err).Warn("worker stopped with error")
+	}
+	c.log.Debug("workers stopped")
 	return nil
 }
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+)
+
+const (
+	defaultLeaseName          = "bottlerocket-update-operator-controller"
+	defaultLeaseNamespace     = "bottlerocket"
+	defaultLeaseDuration      = 15 * time.Second
+	defaultLeaseRenewDeadline = 10 * time.Second
+	defaultLeaseRetryPeriod   = 2 * time.Second
+)
+
+// errLostLeadership is returned when the Controller stops after another
+// replica took over its lease.
+var errLostLeadership = errors.New("lost leadership")
+
+// leaderElection runs the Controller only while it holds the lease, so that
+// a single replica acts on the cluster's Nodes at a time. Standby replicas
+// wait to acquire the lease when the leader stops renewing it.
+type leaderElection struct {
+	log      logging.Logger
+	identity string
+	config   leaderelection.LeaderElectionConfig
+}
+
+func newLeaderElection(log logging.Logger, kube kubernetes.Interface, identity string, config Config) (*leaderElection, error) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: v1meta.ObjectMeta{
+			Name:      config.leaseName(),
+			Namespace: config.leaseNamespace(),
+		},
+		Client:     kube.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	election := &leaderElection{
+		log:      log.WithField("lease", lock.Describe()),
+		identity: identity,
+	}
+	election.config = leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		Name:          lock.Describe(),
+		LeaseDuration: config.leaseDuration(),
+		RenewDeadline: config.leaseRenewDeadline(),
+		RetryPeriod:   config.leaseRetryPeriod(),
+		// The lease is given up when stopped so that a standby replica
+		// takes over without waiting for the lease to expire.
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			// Replaced when run.
+			OnStartedLeading: func(context.Context) {},
+			OnStoppedLeading: func() {},
+			OnNewLeader:      election.observeLeader,
+		},
+	}
+	// Validate the configuration up front.
+	if _, err := leaderelection.NewLeaderElector(election.config); err != nil {
+		return nil, errors.WithMessage(err, "invalid leader election configuration")
+	}
+	return election, nil
+}
+
+// electionIdentity identifies the replica holding the lease by its hostname,
+// the Pod's name, falling back to the Node's name.
+func electionIdentity(nodeName string) string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return nodeName
+	}
+	return hostname
+}
+
+func (e *leaderElection) observeLeader(identity string) {
+	if identity == e.identity {
+		return
+	}
+	e.log.WithField("leader", identity).Info("standing by while another replica leads")
+}
+
+// Run runs the given function while holding the lease, until the context is
+// done or the lease is lost. The function must not return until its work has
+// stopped, the lease is released once it returns. Losing the lease returns
+// errLostLeadership, the replica should then exit to stand by anew.
+func (e *leaderElection) Run(ctx context.Context, run func(context.Context) error) error {
+	electCtx, stopElection := context.WithCancel(ctx)
+	defer stopElection()
+
+	leading := make(chan context.Context, 1)
+	config := e.config
+	config.Callbacks.OnStartedLeading = func(leaderCtx context.Context) {
+		leading <- leaderCtx
+	}
+	config.Callbacks.OnStoppedLeading = func() {
+		e.log.Info("stopped leading")
+	}
+	elector, err := leaderelection.NewLeaderElector(config)
+	if err != nil {
+		return errors.WithMessage(err, "invalid leader election configuration")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		elector.Run(electCtx)
+	}()
+	// The lease is released, when held, before returning.
+	defer func() {
+		stopElection()
+		<-stopped
+	}()
+
+	e.log.Info("waiting to acquire lease")
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-stopped:
+		return errLostLeadership
+	case leaderCtx := <-leading:
+		e.log.Info("acquired lease, leading")
+		err := run(leaderCtx)
+		if leaderCtx.Err() != nil && ctx.Err() == nil {
+			e.log.Error("lost lease, stopping")
+			return errLostLeadership
+		}
+		return err
+	}
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"gotest.tools/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testElectionConfig elects quickly for tests, while leaving time between
+// renewals to stop in.
+var testElectionConfig = Config{
+	LeaseDuration:      3 * time.Second,
+	LeaseRenewDeadline: 2 * time.Second,
+	LeaseRetryPeriod:   time.Second,
+}
+
+func testElection(t *testing.T, client *fake.Clientset, identity string) *leaderElection {
+	election, err := newLeaderElection(testoutput.Logger(t, logging.New("election")), client, identity, testElectionConfig)
+	assert.NilError(t, err)
+	return election
+}
+
+// runElection runs the election in the background, returning the channel
+// its result is sent on and a channel closed once it leads.
+func runElection(ctx context.Context, election *leaderElection) (<-chan error, <-chan struct{}) {
+	result := make(chan error, 1)
+	leading := make(chan struct{})
+	go func() {
+		result <- election.Run(ctx, func(ctx context.Context) error {
+			close(leading)
+			<-ctx.Done()
+			return nil
+		})
+	}()
+	return result, leading
+}
+
+func getLease(t *testing.T, client *fake.Clientset) *coordinationv1.Lease {
+	lease, err := client.CoordinationV1().Leases(defaultLeaseNamespace).Get(defaultLeaseName, v1meta.GetOptions{})
+	assert.NilError(t, err)
+	return lease
+}
+
+func holder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func TestLeaderElection(t *testing.T) {
+	t.Run("leads", func(t *testing.T) {
+		client := fake.NewSimpleClientset()
+		ctx, cancel := context.WithCancel(context.Background())
+		result, leading := runElection(ctx, testElection(t, client, "replica-a"))
+
+		select {
+		case <-leading:
+		case <-time.After(5 * time.Second):
+			t.Fatal("replica should lead an unheld lease")
+		}
+		assert.Equal(t, holder(getLease(t, client)), "replica-a")
+
+		// Stop between renewals, a renewal still underway when stopped may
+		// otherwise write over the released lease.
+		time.Sleep(testElectionConfig.LeaseRetryPeriod / 2)
+		cancel()
+		assert.NilError(t, <-result)
+		assert.Equal(t, holder(getLease(t, client)), "", "lease should be released when stopped")
+	})
+
+	t.Run("standby", func(t *testing.T) {
+		identity := "replica-b"
+		seconds := int32(60)
+		now := v1meta.NewMicroTime(time.Now())
+		client := fake.NewSimpleClientset(&coordinationv1.Lease{
+			ObjectMeta: v1meta.ObjectMeta{Name: defaultLeaseName, Namespace: defaultLeaseNamespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		result, leading := runElection(ctx, testElection(t, client, "replica-a"))
+
+		select {
+		case <-leading:
+			t.Fatal("replica should stand by while the lease is held")
+		case <-time.After(500 * time.Millisecond):
+		}
+		cancel()
+		assert.NilError(t, <-result)
+		assert.Equal(t, holder(getLease(t, client)), "replica-b")
+	})
+
+	t.Run("lost", func(t *testing.T) {
+		client := fake.NewSimpleClientset()
+		result, leading := runElection(context.Background(), testElection(t, client, "replica-a"))
+		<-leading
+
+		// Another replica takes over the lease.
+		lease := getLease(t, client)
+		identity := "replica-b"
+		lease.Spec.HolderIdentity = &identity
+		_, err := client.CoordinationV1().Leases(defaultLeaseNamespace).Update(lease)
+		assert.NilError(t, err)
+
+		select {
+		case err := <-result:
+			assert.Equal(t, err, errLostLeadership)
+		case <-time.After(5 * time.Second):
+			t.Fatal("replica should stop after losing its lease")
+		}
+	})
+}
+
+func TestLeaderElectionInvalid(t *testing.T) {
+	config := Config{LeaseDuration: 5 * time.Second, LeaseRenewDeadline: 10 * time.Second}
+	_, err := newLeaderElection(testoutput.Logger(t, logging.New("election")), fake.NewSimpleClientset(), "replica-a", config)
+	assert.ErrorContains(t, err, "invalid leader election configuration")
+}
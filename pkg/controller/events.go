@@ -0,0 +1,92 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source of the Events recorded by the controller.
+const eventComponent = "bottlerocket-update-operator"
+
+// Reasons of the Events recorded against Nodes as they're updated.
+const (
+	eventCordoning         = "Cordoning"
+	eventCordoned          = "Cordoned"
+	eventCordonFailed      = "CordonFailed"
+	eventDraining          = "Draining"
+	eventDrained           = "Drained"
+	eventDrainFailed       = "DrainFailed"
+	eventDrainBlocked      = "DrainBlocked"
+	eventForceDraining     = "ForceDraining"
+	eventUncordoned        = "Uncordoned"
+	eventUncordonFailed    = "UncordonFailed"
+	eventHealthCheckPassed = "HealthCheckPassed"
+	eventHealthCheckFailed = "HealthCheckFailed"
+	eventUpdateSucceeded   = "UpdateSucceeded"
+	eventUpdateFailed      = "UpdateFailed"
+	eventCanaryPassed      = "CanaryPassed"
+	eventCanaryFailed      = "CanaryFailed"
+	eventRollingBack       = "RollingBack"
+	eventRolledBack        = "RolledBack"
+	eventBusyTimeout       = "BusyTimeout"
+)
+
+// nodeRecorder records Kubernetes Events against Nodes as they step through
+// their update, surfacing the controller's actions in `kubectl describe node`
+// and to event-based alerting. A nil nodeRecorder records nothing.
+type nodeRecorder struct {
+	// sink is the broadcaster's recording of Events to the cluster.
+	sink     watch.Interface
+	recorder record.EventRecorder
+}
+
+// newNodeRecorder starts recording Events to the cluster, it's stopped with
+// Shutdown.
+func newNodeRecorder(kube kubernetes.Interface) *nodeRecorder {
+	if kube == nil {
+		return nil
+	}
+	broadcaster := record.NewBroadcaster()
+	sink := broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: kube.CoreV1().Events("")})
+	return &nodeRecorder{
+		sink:     sink,
+		recorder: broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent}),
+	}
+}
+
+// Normal records an informational Event against the Node.
+func (r *nodeRecorder) Normal(nodeName string, reason string, messageFmt string, args ...interface{}) {
+	r.record(nodeName, v1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// Warning records an Event against the Node for something gone wrong.
+func (r *nodeRecorder) Warning(nodeName string, reason string, messageFmt string, args ...interface{}) {
+	r.record(nodeName, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+func (r *nodeRecorder) record(nodeName string, eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	// Nodes' Events are referenced by their name, as the kubelet does, so
+	// that they're found when describing the Node.
+	ref := &v1.ObjectReference{
+		Kind: "Node",
+		Name: nodeName,
+		UID:  types.UID(nodeName),
+	}
+	r.recorder.Eventf(ref, eventType, reason, messageFmt, args...)
+}
+
+// Shutdown stops recording Events.
+func (r *nodeRecorder) Shutdown() {
+	if r == nil || r.sink == nil {
+		return
+	}
+	r.sink.Stop()
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+// testRecorder records the manager's Events for inspection.
+func testRecorder(m *actionManager) *record.FakeRecorder {
+	fake := record.NewFakeRecorder(100)
+	m.events = &nodeRecorder{recorder: fake}
+	return fake
+}
+
+// recordedEvents takes the Events recorded so far.
+func recordedEvents(fake *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-fake.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRecordEvents(t *testing.T) {
+	t.Run("update", func(t *testing.T) {
+		m, hooks := testManager(t)
+		fake := testRecorder(m)
+		hooks.NodeManager.DrainFn = trackDrainFn(new(bool), 2)
+
+		assert.NilError(t, m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a")))))
+		assert.DeepEqual(t, recordedEvents(fake), []string{
+			"Normal Cordoning Cordoning node for update",
+			"Normal Cordoned Cordoned node for update",
+			"Normal Draining Draining node for update",
+			"Normal Drained Drained node, evicting 2 pods",
+		})
+
+		assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+		assert.DeepEqual(t, recordedEvents(fake), []string{
+			"Normal HealthCheckPassed Node passed its health check after updating",
+			"Normal Uncordoned Uncordoned node after update",
+			"Normal UpdateSucceeded Node updated successfully",
+		})
+	})
+
+	t.Run("drain-failed", func(t *testing.T) {
+		m, hooks := testManager(t)
+		fake := testRecorder(m)
+		m.drainFailure = DrainFailureSkip
+		hooks.NodeManager.DrainFn = func(_ string) (int, error) {
+			return 1, errors.New("eviction failed")
+		}
+
+		assert.Check(t, m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a")))) != nil)
+		assert.DeepEqual(t, recordedEvents(fake), []string{
+			"Normal Cordoning Cordoning node for update",
+			"Normal Cordoned Cordoned node for update",
+			"Normal Draining Draining node for update",
+			"Warning DrainFailed Unable to drain node: eviction failed",
+			"Normal Uncordoned Uncordoned node, skipping its update after failing to drain",
+		})
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		m, hooks := testManager(t)
+		fake := testRecorder(m)
+		hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+			return false, nil
+		}
+
+		assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+		events := recordedEvents(fake)
+		assert.Equal(t, events[0], "Warning HealthCheckFailed Node failed its health check after updating: node not ready after 5m0s, last observed ready condition False")
+	})
+}
+
+func TestRecordEventsNil(t *testing.T) {
+	var r *nodeRecorder
+	r.Normal("node-a", eventCordoned, "Cordoned node for update")
+	r.Warning("node-a", eventDrainFailed, "Unable to drain node: %v", errors.New("eviction failed"))
+	r.Shutdown()
+}
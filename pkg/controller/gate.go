@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// rolloutGate pauses the cluster's rollout and, once resumed, ramps the
+// number of Nodes permitted to update concurrently back up to the maximum
+// rather than letting every waiting Node start at once.
+type rolloutGate struct {
+	mu sync.Mutex
+	// pausedBy is the Node that caused the rollout to pause, if paused.
+	pausedBy string
+	paused   bool
+	resumed  time.Time
+	// ramp is the duration, after resuming, over which the permitted
+	// concurrency increases to its maximum.
+	ramp  time.Duration
+	clock clock.Clock
+}
+
+func newRolloutGate(ramp time.Duration, clk clock.Clock) *rolloutGate {
+	return &rolloutGate{ramp: ramp, clock: clk}
+}
+
+// Pause halts the rollout on account of the named Node.
+func (g *rolloutGate) Pause(nodeName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = true
+	g.pausedBy = nodeName
+}
+
+// Resume lifts the pause, beginning the ramp up of concurrency.
+func (g *rolloutGate) Resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return
+	}
+	g.paused = false
+	g.pausedBy = ""
+	g.resumed = g.clock.Now()
+}
+
+// Paused reports whether the rollout is paused and the Node that paused it.
+func (g *rolloutGate) Paused() (bool, string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused, g.pausedBy
+}
+
+// Limit returns the number of Nodes permitted to be updating at once given the
+// maximum. None are permitted while paused, and after resuming the limit
+// increases linearly from 1 to the maximum over the ramp.
+func (g *rolloutGate) Limit(max int) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return 0
+	}
+	if g.ramp <= 0 || g.resumed.IsZero() || max <= 1 {
+		return max
+	}
+	elapsed := g.clock.Since(g.resumed)
+	if elapsed >= g.ramp {
+		return max
+	}
+	return 1 + int(float64(max-1)*float64(elapsed)/float64(g.ramp))
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testGate(ramp time.Duration) (*rolloutGate, *testClock) {
+	clk := newTestClock()
+	return newRolloutGate(ramp, clk), clk
+}
+
+func TestRolloutGateRamp(t *testing.T) {
+	gate, clk := testGate(4 * time.Minute)
+	const max = 5
+
+	// Nothing has paused the rollout yet.
+	assert.Equal(t, gate.Limit(max), max)
+
+	gate.Pause("node-a")
+	assert.Equal(t, gate.Limit(max), 0)
+
+	gate.Resume()
+	expected := []int{1, 2, 3, 4, 5, 5}
+	for i, limit := range expected {
+		assert.Equal(t, gate.Limit(max), limit, "after %d minutes", i)
+		clk.Step(time.Minute)
+	}
+}
+
+func TestRolloutGateNoRamp(t *testing.T) {
+	gate, _ := testGate(0)
+	gate.Pause("node-a")
+	gate.Resume()
+	assert.Equal(t, gate.Limit(5), 5)
+}
+
+func TestPolicyRampsAfterResume(t *testing.T) {
+	gate, clk := testGate(4 * time.Minute)
+	policy := defaultPolicy{
+		log:       testoutput.Logger(t, logging.New("policy-check")),
+		maxActive: 5,
+		gate:      gate,
+	}
+	starting := func(active int) bool {
+		permit, _, err := policy.Check(&PolicyCheck{
+			Intent:        intents.Stabilized(intents.WithUpdateAvailable()).SetBeginUpdate(),
+			ClusterActive: active,
+			ClusterCount:  10,
+		})
+		assert.NilError(t, err)
+		return permit
+	}
+
+	gate.Pause("node-a")
+	assert.Check(t, !starting(0), "paused rollout should not start updates")
+
+	gate.Resume()
+	assert.Check(t, starting(0))
+	assert.Check(t, !starting(1), "concurrency should start low after resuming")
+
+	clk.Step(2 * time.Minute)
+	assert.Check(t, starting(2))
+	assert.Check(t, !starting(3))
+
+	clk.Step(2 * time.Minute)
+	assert.Check(t, starting(4))
+	assert.Check(t, !starting(5))
+}
+
+func TestManagerResumesWhenUncordoned(t *testing.T) {
+	m, _ := testManager(t)
+	m.gate.Pause("node-a")
+
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "node-a"}}
+	node.Spec.Unschedulable = true
+	m.checkResume(node, false)
+	paused, _ := m.gate.Paused()
+	assert.Check(t, paused, "still cordoned node keeps rollout paused")
+
+	other := &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "node-b"}}
+	m.checkResume(other, false)
+	paused, _ = m.gate.Paused()
+	assert.Check(t, paused, "other nodes don't resume rollout")
+
+	node.Spec.Unschedulable = false
+	m.checkResume(node, false)
+	paused, _ = m.gate.Paused()
+	assert.Check(t, !paused)
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// readinessGates are the conditions a Node must satisfy, in addition to being
+// ready, before its update is considered successful. For example, a Node may
+// be ready while its network plugin is still initializing. A nil
+// readinessGates is always satisfied.
+type readinessGates struct {
+	// taints are the keys of taints the Node must not have.
+	taints []string
+	// conditions are the conditions the Node must report with their status.
+	conditions []conditionGate
+}
+
+// conditionGate is a Node condition and the status it must have.
+type conditionGate struct {
+	Type   v1.NodeConditionType
+	Status v1.ConditionStatus
+}
+
+func (g conditionGate) String() string {
+	return string(g.Type) + "=" + string(g.Status)
+}
+
+// newReadinessGates parses the gates from the taint keys and the conditions,
+// each given as "Type" to require the condition be true or "Type=Status".
+func newReadinessGates(taints []string, conditions []string) (*readinessGates, error) {
+	if len(taints) == 0 && len(conditions) == 0 {
+		return nil, nil
+	}
+	gates := &readinessGates{taints: taints}
+	for _, cond := range conditions {
+		gate, err := parseConditionGate(cond)
+		if err != nil {
+			return nil, err
+		}
+		gates.conditions = append(gates.conditions, gate)
+	}
+	return gates, nil
+}
+
+func parseConditionGate(cond string) (conditionGate, error) {
+	gate := conditionGate{Status: v1.ConditionTrue}
+	parts := strings.SplitN(cond, "=", 2)
+	gate.Type = v1.NodeConditionType(strings.TrimSpace(parts[0]))
+	if gate.Type == "" {
+		return gate, errors.Errorf("invalid readiness condition %q, missing condition type", cond)
+	}
+	if len(parts) == 2 {
+		switch status := v1.ConditionStatus(strings.TrimSpace(parts[1])); status {
+		case v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown:
+			gate.Status = status
+		default:
+			return gate, errors.Errorf("invalid readiness condition %q, status must be True, False, or Unknown", cond)
+		}
+	}
+	return gate, nil
+}
+
+// Unsatisfied describes the gates the Node doesn't yet satisfy. Conditions the
+// Node doesn't report are taken to be unknown.
+func (g *readinessGates) Unsatisfied(node *v1.Node) []string {
+	if g == nil {
+		return nil
+	}
+	var unsatisfied []string
+	for _, key := range g.taints {
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == key {
+				unsatisfied = append(unsatisfied, "taint "+key)
+				break
+			}
+		}
+	}
+	for _, gate := range g.conditions {
+		status := v1.ConditionUnknown
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == gate.Type {
+				status = cond.Status
+				break
+			}
+		}
+		if status != gate.Status {
+			unsatisfied = append(unsatisfied, "condition "+gate.String())
+		}
+	}
+	return unsatisfied
+}
+
+// Taints lists the taint keys the Node must not have.
+func (g *readinessGates) Taints() []string {
+	if g == nil {
+		return []string{}
+	}
+	return append([]string{}, g.taints...)
+}
+
+// Conditions lists the conditions the Node must have, as "Type=Status".
+func (g *readinessGates) Conditions() []string {
+	conditions := []string{}
+	if g == nil {
+		return conditions
+	}
+	for _, gate := range g.conditions {
+		conditions = append(conditions, gate.String())
+	}
+	return conditions
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+)
+
+func TestReadinessGatesParse(t *testing.T) {
+	gates, err := newReadinessGates(nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, gates == nil, "no gates should be configured")
+	assert.DeepEqual(t, gates.Taints(), []string{})
+	assert.DeepEqual(t, gates.Conditions(), []string{})
+
+	gates, err = newReadinessGates(
+		[]string{"node.kubernetes.io/network-unavailable"},
+		[]string{"NetworkReady", "DiskPressure=False", " CSIReady = Unknown "},
+	)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, gates.Taints(), []string{"node.kubernetes.io/network-unavailable"})
+	assert.DeepEqual(t, gates.Conditions(), []string{"NetworkReady=True", "DiskPressure=False", "CSIReady=Unknown"})
+}
+
+func TestReadinessGatesInvalid(t *testing.T) {
+	_, err := newReadinessGates(nil, []string{"NetworkReady=Maybe"})
+	assert.ErrorContains(t, err, `invalid readiness condition "NetworkReady=Maybe"`)
+
+	_, err = newReadinessGates(nil, []string{"=True"})
+	assert.ErrorContains(t, err, "missing condition type")
+}
+
+func TestReadinessGatesUnsatisfied(t *testing.T) {
+	gates, err := newReadinessGates(
+		[]string{"node.kubernetes.io/network-unavailable"},
+		[]string{"NetworkReady", "DiskPressure=False"},
+	)
+	assert.NilError(t, err)
+
+	node := &v1.Node{}
+	node.Spec.Taints = []v1.Taint{{Key: "node.kubernetes.io/network-unavailable", Effect: v1.TaintEffectNoSchedule}}
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse}}
+	// The unreported NetworkReady condition is taken to be unknown.
+	assert.DeepEqual(t, gates.Unsatisfied(node), []string{
+		"taint node.kubernetes.io/network-unavailable",
+		"condition NetworkReady=True",
+	})
+
+	node.Spec.Taints = nil
+	node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{Type: "NetworkReady", Status: v1.ConditionTrue})
+	assert.Check(t, len(gates.Unsatisfied(node)) == 0)
+
+	var none *readinessGates
+	assert.Check(t, len(none.Unsatisfied(&v1.Node{})) == 0)
+}
+
+func TestReadyWithGates(t *testing.T) {
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "node-a"}}
+	node.Spec.Taints = []v1.Taint{{Key: "node.kubernetes.io/network-unavailable", Effect: v1.TaintEffectNoSchedule}}
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	client := fake.NewSimpleClientset(node)
+
+	gates, err := newReadinessGates([]string{"node.kubernetes.io/network-unavailable"}, nil)
+	assert.NilError(t, err)
+	nm := newK8sNodeManager(testoutput.Logger(t, logging.New("node-manager")), client, nil, "", gates)
+
+	readiness, err := nm.Ready("node-a")
+	assert.NilError(t, err)
+	assert.Check(t, !readiness.Ready(), "node should wait on its taint")
+	assert.Equal(t, readiness.String(), "ready condition True, waiting on taint node.kubernetes.io/network-unavailable")
+
+	// Without gates, the ready condition alone is checked.
+	nm = newK8sNodeManager(testoutput.Logger(t, logging.New("node-manager")), client, nil, "", nil)
+	readiness, err = nm.Ready("node-a")
+	assert.NilError(t, err)
+	assert.Check(t, readiness.Ready())
+}
@@ -2,53 +2,208 @@ package controller
 
 import (
 	"context"
-	"fmt"
-	"math/rand"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/cloudwatch"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/health"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 	intentcache "github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent/cache"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/logfields"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/tracing"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
 const (
-	// maxQueuedIntents controls the number of queued Intents that are waiting
-	// to be handled.
-	maxQueuedIntents   = 100
-	maxQueuedInputs    = maxQueuedIntents * (1 / 4)
-	queueSkipThreshold = maxQueuedIntents / 2
+	// incompleteRetryDelay is the time after which an intent denied for an
+	// incomplete view of the cluster is checked again, when configured to
+	// retry.
+	incompleteRetryDelay = 30 * time.Second
+	// maintenanceResyncPeriod is the time between resynchronizations of the
+	// managed Nodes when updates are limited to maintenance windows.
+	maintenanceResyncPeriod = time.Minute
+	// errorBackoffInitial and errorBackoffMax bound the delay before a Node
+	// whose action errored again is retried.
+	errorBackoffInitial = 30 * time.Second
+	errorBackoffMax     = 10 * time.Minute
+	// skippedRetryDelay is the time a Node whose update was skipped, after it
+	// failed to drain, is held back before it may begin updating again.
+	skippedRetryDelay = time.Hour
+	// managerStale is how long the manager may take to handle an intent
+	// before the process isn't ready, longer than a Node's drain and
+	// post-update checks are expected to take.
+	managerStale = 30 * time.Minute
+	// managerIdleInterval is the time between the idle manager's reports that
+	// it's waiting on the queue.
+	managerIdleInterval = time.Minute
+
+	// The reasons updates fail, as counted by metrics.
+	failedDrain  = "drain"
+	failedReboot = "reboot"
+	failedHealth = "health"
 )
 
 var _ nodestream.Handler = (*actionManager)(nil)
 
-var randDropIntFunc func(int) int = rand.Intn
+var (
+	errRolloutHalted        = errors.New("rollout halted")
+	errInsufficientCapacity = errors.New("insufficient capacity to drain node")
+	errCordonLimit          = errors.New("too many nodes cordoned")
+)
 
 // actionManager handles node changes according to policy and runs a node update
 // flow to completion as allowed by policy.
 type actionManager struct {
+	// ctx is the context the manager is run with, stopping waits that would
+	// otherwise hold up shutdown.
+	ctx       context.Context
 	log       logging.Logger
 	kube      kubernetes.Interface
 	policy    Policy
-	inputs    chan *intent.Intent
+	queue     *intentQueue
 	storer    storer
 	poster    poster
+	markers   markerPoster
 	nodem     nodeManager
 	lastCache intentcache.LastCache
+	// evicted tracks the number of Pods evicted by each Node's most recent
+	// drain.
+	evicted map[string]int
+	// cordoned tracks when each Node was cordoned by the controller.
+	cordoned map[string]time.Time
+	// intended tracks the action each Node was last directed to take.
+	intended map[string]intendedAction
+	stuck    *stuckTracker
+	unknown  *unknownTracker
+	busy     *busyTracker
+	notReady *notReadyTracker
+	errored  *errorBackoff
+	skipped  *skipTracker
+	clock    clock.Clock
+	// keepCordonedLabel is the Node label that skips uncordoning the Node
+	// after its update.
+	keepCordonedLabel string
+	// drainFailure is the action taken when a Node fails to drain.
+	drainFailure DrainFailureAction
+	// incompleteView is the action taken when a policy check is made with an
+	// incomplete view of the cluster.
+	incompleteView IncompleteViewAction
+	// gate pauses the rollout, no further disruptive actions are taken while
+	// it is paused.
+	gate *rolloutGate
+	// batch holds back updates until a quorum of the previous batch is
+	// healthy, when configured.
+	batch *batchGate
+	// reboots spaces the reboots of Nodes across the cluster, when
+	// configured.
+	reboots *rebootSpacer
+	// starts limits how many Nodes may begin updating within a rolling
+	// window, when configured.
+	starts *startLimiter
+	// antiAffinity keeps Nodes hosting anti-affine replicas from updating
+	// together, when configured.
+	antiAffinity *antiAffinityGuard
+	// settle is the window after starting during which Node events are
+	// coalesced by settler before they're handled.
+	settle  time.Duration
+	settler *addSettler
+	// holdLabel is the Node label that holds the Node at its current step.
+	holdLabel string
+	// healthCheckTimeout is the longest time a Node is given to report itself
+	// ready after its update, checked every healthCheckInterval.
+	healthCheckTimeout  time.Duration
+	healthCheckInterval time.Duration
+	// verifyDelay is the time after a Node passes its health check during
+	// which it's rechecked before its update is considered successful.
+	verifyDelay time.Duration
+	// workloadTimeout is the longest time given for drained workloads to be
+	// rescheduled once their Node is uncordoned.
+	workloadTimeout time.Duration
+	// verifyBootID confirms that Nodes rebooted into their update using the
+	// boot IDs recorded before they rebooted.
+	verifyBootID bool
+	bootIDs      map[string]string
+	// skipHealthCheck skips checking Nodes after their update, leaving their
+	// health to external monitoring.
+	skipHealthCheck bool
+	// checkCapacity defers draining a Node until the cluster has the spare
+	// capacity to schedule its Pods.
+	checkCapacity bool
+	// maxCordoned is the most Nodes permitted to be cordoned at once, when
+	// set.
+	maxCordoned int
+	// reporter writes the Nodes' lifecycle events as structured records, when
+	// configured.
+	reporter *report.Reporter
+	// ramp adapts the number of Nodes permitted to update at once to the
+	// outcome of their updates, when configured.
+	ramp *concurrencyRamp
+	// history keeps the Nodes' recent lifecycle events to be served, when
+	// configured.
+	history *report.Ring
+	// events records the Nodes' update lifecycle as Kubernetes Events.
+	events *nodeRecorder
+	// windows limits the times that Nodes may begin updating, when
+	// configured.
+	windows *maintenanceSchedule
+	// nodeSelector limits the managed Nodes acted on to those matching, when
+	// configured.
+	nodeSelector string
+	// resyncPeriod is the time between resynchronizations of the managed
+	// Nodes.
+	resyncPeriod time.Duration
+	// canary updates the canary Nodes ahead of the rest of the cluster, when
+	// configured.
+	canary *canaryGate
+	// rollbacks are the Nodes directed to roll back after failing their
+	// health check, when configured.
+	rollbacks *rollbackTracker
+	// notifier notifies the Nodes' updates beginning and ending, when
+	// configured. updating are the versions of the Nodes it notified began
+	// updating.
+	notifier *notify.Notifier
+	updating map[string]updateVersions
+	// tracer exports the spans of the Nodes' updates, traced by traces, when
+	// configured.
+	tracer *tracing.Tracer
+	traces *intentTraces
+	// cloudWatch publishes the update metrics to CloudWatch, when configured.
+	cloudWatch *cloudwatch.Publisher
+	// health tracks the manager's loop, when set.
+	health *health.Status
+	// processing is set while the manager is handling an intent, rather than
+	// waiting on the queue.
+	processing int32
+}
+
+// intendedAction is an action a Node was directed to take and when.
+type intendedAction struct {
+	action marker.NodeAction
+	since  time.Time
 }
 
 // poster is the implementation of the intent poster that publishes the provided
+// intent. The annotations, if any, are published in the same write as the
 // intent.
 type poster interface {
-	Post(*intent.Intent) error
+	Post(*intent.Intent, marker.Annotations) error
 }
 
 // nodeManager is the implementation that interfaces the interactions with nodes
@@ -56,122 +211,347 @@ type poster interface {
 type nodeManager interface {
 	Cordon(string) error
 	Uncordon(string) error
-	Drain(string) error
+	// Drain evicts the Node's Pods, returning the number of Pods evicted.
+	Drain(string) (int, error)
+	// Ready reports the Node's readiness.
+	Ready(string) (nodeReadiness, error)
+	// BootID reports the Node's boot ID.
+	BootID(string) (string, error)
+	// Capacity reports whether the other Nodes have the spare capacity to
+	// schedule the Pods evicted by draining the Node, and any resources that
+	// fall short.
+	Capacity(string, []*v1.Node) (bool, v1.ResourceList, error)
+	// DrainBlockers reports the PodDisruptionBudgets that would block the
+	// Node's drain.
+	DrainBlockers(string) ([]budgetBlock, error)
+	// PendingWorkloads reports the number of Pods yet to be scheduled and
+	// ready after the Node is uncordoned.
+	PendingWorkloads(string) (int, error)
 }
 
 type storer interface {
 	GetStore() cache.Store
 }
 
-func newManager(log logging.Logger, kube kubernetes.Interface, nodeName string) *actionManager {
+func newManager(log logging.Logger, kube kubernetes.Interface, nodeName string, config Config) (*actionManager, error) {
 	var nodeclient corev1.NodeInterface
 	if kube != nil {
 		nodeclient = kube.CoreV1().Nodes()
 	}
+	grace, err := config.drainGrace()
+	if err != nil {
+		return nil, err
+	}
+	drainFailure, err := config.drainFailureAction()
+	if err != nil {
+		return nil, err
+	}
+	incompleteView, err := config.incompleteViewAction()
+	if err != nil {
+		return nil, err
+	}
+	quorum, err := config.batchQuorum()
+	if err != nil {
+		return nil, err
+	}
+	maxActive, err := config.batchSize()
+	if err != nil {
+		return nil, err
+	}
+	maxActivePercent, err := config.batchPercent()
+	if err != nil {
+		return nil, err
+	}
+	if maxActivePercent > 0 {
+		log.WithField("max-active-percent", maxActivePercent).Info("percent of nodes permitted to update at once")
+	} else {
+		log.WithField("max-active", maxActive).Info("nodes permitted to update at once")
+	}
+	workloadTimeout, err := config.workloadSettleTimeout()
+	if err != nil {
+		return nil, err
+	}
+	nodeSelector, err := config.nodeSelector()
+	if err != nil {
+		return nil, err
+	}
+	if nodeSelector != "" {
+		log.WithField("selector", nodeSelector).Info("managing only nodes matching the node selector")
+	}
+	resync, err := config.resyncPeriod()
+	if err != nil {
+		return nil, err
+	}
+	gates, err := config.readinessGates()
+	if err != nil {
+		return nil, err
+	}
+	resumeRamp, err := config.resumeRamp()
+	if err != nil {
+		return nil, err
+	}
+	maxUpdateStarts, err := config.maxUpdateStarts()
+	if err != nil {
+		return nil, err
+	}
+	concurrencyRampStep, err := config.concurrencyRampStep()
+	if err != nil {
+		return nil, err
+	}
+	maxCordoned, err := config.maxCordoned()
+	if err != nil {
+		return nil, err
+	}
+	minRebootInterval, err := config.minRebootInterval()
+	if err != nil {
+		return nil, err
+	}
+	eventHistory, err := config.eventHistory()
+	if err != nil {
+		return nil, err
+	}
+	batch := newBatchGate(maxActive, quorum)
+	clk := clock.RealClock{}
+	gate := newRolloutGate(resumeRamp, clk)
+	reboots := newRebootSpacer(minRebootInterval, clk)
+	starts := newStartLimiter(maxUpdateStarts, config.updateStartWindow(), clk)
+	ramp := newConcurrencyRamp(concurrencyRampStep, maxActive)
+	windows, err := config.maintenanceSchedule(clk)
+	if err != nil {
+		return nil, err
+	}
+	if windows != nil {
+		log.WithFields(logrus.Fields{
+			"windows":  windows.String(),
+			"timezone": windows.location.String(),
+		}).Info("nodes permitted to begin updating only during maintenance windows")
+	}
+	var canary *canaryGate
+	if config.CanaryRollout {
+		canary = newCanaryGate(config.canaryLabel(), config.CanarySoak, clk)
+		log.WithFields(logrus.Fields{
+			"label": config.canaryLabel(),
+			"soak":  config.CanarySoak.String(),
+		}).Info("canary nodes update ahead of the rest of the cluster")
+	}
+	if config.RollbackOnFailure {
+		log.WithField("deadline", config.healthCheckTimeout().String()).Info("nodes failing their health check are rolled back")
+	}
+	var antiAffinity *antiAffinityGuard
+	if config.SeparateAntiAffine {
+		antiAffinity = &antiAffinityGuard{}
+	}
+	var reporter *report.Reporter
+	var history *report.Ring
+	if config.ReportEvents || eventHistory > 0 {
+		var w io.Writer
+		if config.ReportEvents {
+			w = os.Stdout
+		}
+		reporter = report.New(w, "controller")
+	}
+	if eventHistory > 0 {
+		history = report.NewRing(eventHistory)
+		reporter.Keep(history)
+	}
+	sinks, err := config.notifySinks()
+	if err != nil {
+		return nil, err
+	}
+	tracer, err := tracing.NewTracer(log.WithField(logging.SubComponentField, "tracing"), "controller", config.TracingEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	publisher, err := cloudwatch.New(log.WithField(logging.SubComponentField, "cloudwatch"), config.CloudWatchNamespace, config.CloudWatchRegion)
+	if err != nil {
+		return nil, err
+	}
+	events := newNodeRecorder(kube)
+	nodem := newK8sNodeManager(log.WithField(logging.SubComponentField, "node-manager"), kube, grace, config.doNotDrainAnnotation(), gates)
+	nodem.events = events
 
-	return &actionManager{
-		log:       log,
-		kube:      kube,
-		policy:    &defaultPolicy{log: log.WithField(logging.SubComponentField, "policy-check")},
-		inputs:    make(chan *intent.Intent, maxQueuedInputs),
+	am := &actionManager{
+		ctx:  context.Background(),
+		log:  log,
+		kube: kube,
+		policy: &defaultPolicy{
+			log:               log.WithField(logging.SubComponentField, "policy-check"),
+			orderByLaunchTime: config.OrderByLaunchTime,
+			maxActive:         maxActive,
+			maxActivePercent:  maxActivePercent,
+			gate:              gate,
+			batch:             batch,
+			reboots:           reboots,
+			starts:            starts,
+			antiAffinity:      antiAffinity,
+			ramp:              ramp,
+			windows:           windows,
+			canary:            canary,
+		},
+		queue:     newIntentQueue(newQueueRateLimiter()),
 		poster:    &k8sPoster{log, nodeclient},
-		nodem:     &k8sNodeManager{kube},
-		lastCache: intentcache.NewLastCache(),
+		markers:   &k8sMarkerPoster{nodeclient},
+		nodem:     nodem,
+		lastCache: intentcache.NewLastCacheTTL(config.intentCacheTTL()),
+		evicted:   map[string]int{},
+		cordoned:  map[string]time.Time{},
+		intended:  map[string]intendedAction{},
+		stuck:     newStuckTracker(),
+		unknown:   newUnknownTracker(config.UnknownIntentGrace, clk),
+		busy:      newBusyTracker(config.BusyTimeout, clk),
+		notReady:  newNotReadyTracker(),
+		errored:   newErrorBackoff(errorBackoffInitial, errorBackoffMax, clk),
+		skipped:   newSkipTracker(skippedRetryDelay, clk),
+		clock:     clk,
+
+		keepCordonedLabel:   config.keepCordonedLabel(),
+		drainFailure:        drainFailure,
+		incompleteView:      incompleteView,
+		gate:                gate,
+		batch:               batch,
+		reboots:             reboots,
+		starts:              starts,
+		antiAffinity:        antiAffinity,
+		settle:              config.StartupSettle,
+		settler:             newAddSettler(config.StartupSettle > 0),
+		holdLabel:           config.holdLabel(),
+		checkCapacity:       config.CheckDrainCapacity,
+		verifyDelay:         config.VerifyDelay,
+		healthCheckTimeout:  config.healthCheckTimeout(),
+		healthCheckInterval: config.healthCheckInterval(),
+		workloadTimeout:     workloadTimeout,
+		verifyBootID:        config.VerifyBootID,
+		bootIDs:             map[string]string{},
+		skipHealthCheck:     config.SkipHealthCheck,
+		maxCordoned:         maxCordoned,
+		reporter:            reporter,
+		history:             history,
+		events:              events,
+		ramp:                ramp,
+		windows:             windows,
+		nodeSelector:        nodeSelector,
+		resyncPeriod:        resync,
+		canary:              canary,
+		rollbacks:           newRollbackTracker(config.RollbackOnFailure),
+		notifier:            notify.New(log.WithField(logging.SubComponentField, "notifier"), "controller", sinks...),
+		updating:            map[string]updateVersions{},
+		tracer:              tracer,
+		traces:              newIntentTraces(tracer),
+		cloudWatch:          publisher,
 	}
+	publisher.SetFunc(cloudwatch.NodesUpdating, am.countUpdating)
+	return am, nil
+}
+
+// streamConfig is the nodestream configuration needed to observe the managed
+// Nodes, limited to those matching the node selector. With maintenance
+// windows, the Nodes are resynchronized often enough that those waiting begin
+// updating soon after a window opens.
+func (am *actionManager) streamConfig() nodestream.Config {
+	config := nodestream.Config{
+		LabelSelectorExtra: am.nodeSelector,
+		ResyncPeriod:       am.resyncPeriod,
+	}
+	if am.windows != nil && (config.ResyncPeriod == 0 || config.ResyncPeriod > maintenanceResyncPeriod) {
+		config.ResyncPeriod = maintenanceResyncPeriod
+	}
+	return config
 }
 
 func (am *actionManager) Run(ctx context.Context) error {
 	am.log.Debug("starting")
 	defer am.log.Debug("finished")
+	am.ctx = ctx
 
-	queuedIntents := make(chan *intent.Intent, maxQueuedIntents)
+	go func() {
+		<-ctx.Done()
+		am.queue.ShutDown()
+	}()
+	if am.settle > 0 {
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-am.clock.After(am.settle):
+				am.releaseSettled()
+			}
+		}()
+	}
 
-	// TODO: split out accepted intent handler - it should handle its
-	// prioritization as needed to ensure that active nodes' events reach it.
+	if am.health != nil {
+		go am.reportIdle(ctx)
+	}
 
 	for {
-		// Handle active intents
-		select {
-		case <-ctx.Done():
+		nodeName, in, ok := am.queue.Get()
+		if !ok {
 			return nil
+		}
+		atomic.StoreInt32(&am.processing, 1)
+		err := am.process(nodeName, in)
+		atomic.StoreInt32(&am.processing, 0)
+		if err != nil {
+			am.health.Failed("manager", err)
+		} else {
+			am.health.Succeeded("manager")
+		}
+		am.queue.Done(nodeName, err)
+	}
+}
 
-		case qin, ok := <-queuedIntents:
-			log := am.log.WithFields(logfields.Intent(qin))
-			log.Debug("checking with policy")
-			// TODO: make policy checking and consideration richer
-			pview, err := am.makePolicyCheck(qin)
-			if err != nil {
-				log.WithError(err).Error("policy unenforceable")
-				continue
-			}
-			proceed, err := am.policy.Check(pview)
-			if err != nil {
-				log.WithError(err).Error("policy check errored")
-				continue
-			}
-			if !proceed {
-				log.Debug("policy denied intent")
-				continue
-			}
-			if !ok {
-				break
-			}
-			log.Debug("handling permitted intent")
-			am.takeAction(qin)
-
-		case input, ok := <-am.inputs:
-			if !ok {
-				am.log.Error("input channel closed")
-				break
-			}
-
-			queued := len(queuedIntents)
-			log := am.log.WithFields(logfields.Intent(input)).
-				WithFields(logrus.Fields{
-					"queue":        "process",
-					"queue-length": fmt.Sprintf("%d", queued),
-				})
-			if queued < queueSkipThreshold {
-				queuedIntents <- input
-				continue
-			}
-			// Start dropping if its not possible to queue at all.
-			if queued+1 > maxQueuedIntents {
-				log.Warn("queue full, dropping intent this try")
-				continue
-			}
-
-			// TODO: handle backpressure better with rescheduling instead of drops
-
-			// Queue is getting full, let's be more selective about events that
-			// are propagated.
-
-			if isClusterActive(input) {
-				log.Info("queue active intent")
-				queuedIntents <- input
-				continue
-			}
-
-			if isLowPriority(input) {
-				n := randDropIntFunc(10)
-				willDrop := n%2 == 0
-				if willDrop {
-					// Intent is picked up again when cached Intent expires &
-					// Informer syncs OR if the Intent is changed (from update
-					// or otherwise by Node). This provides indirect
-					// backpressure by delaying the next time the Intent will be
-					// handled.
-					log.Warn("queue backlog high, randomly dropping intent")
-					continue
-				}
+// reportIdle reports the manager's loop as succeeding while it's waiting on
+// the queue, an idle manager isn't stale.
+func (am *actionManager) reportIdle(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-am.clock.After(managerIdleInterval):
+			if atomic.LoadInt32(&am.processing) == 0 {
+				am.health.Succeeded("manager")
 			}
-			log.Debug("queue intent")
-			queuedIntents <- input
+		}
+	}
+}
 
+// process takes the action for the Node's Intent when permitted by policy.
+// A Node retried, after its action errored or once its wait to be retried
+// elapsed, is given no Intent, its current Intent is handled instead.
+func (am *actionManager) process(nodeName string, in *intent.Intent) error {
+	if am.ctx.Err() != nil {
+		return nil
+	}
+	if in == nil {
+		node, ok := am.storedNode(nodeName)
+		if !ok {
+			return nil
+		}
+		if in = am.actionable(node); in == nil {
+			return nil
 		}
+		am.log.WithFields(logfields.Intent(in)).Info("retrying node")
+		am.traces.Queued(node, in)
 	}
+	log := am.log.WithFields(logfields.Intent(in))
+	span := am.traces.Handling(nodeName)
+	proceed, retryAfter := am.checkPolicy(in)
+	if retryAfter > 0 {
+		am.traces.Requeue(nodeName)
+		am.queue.AddAfter(in, retryAfter)
+		return nil
+	}
+	if !proceed {
+		span.SetAttribute("policy.permitted", "false")
+		am.traces.Done(nodeName, nil)
+		return nil
+	}
+	log.Debug("handling permitted intent")
+	err := am.takeAction(in)
+	am.traces.Done(nodeName, err)
+	return err
 }
 
+// isLowPriority matches intents of Nodes with nothing to do, which are rate
+// limited when queued.
 func isLowPriority(in *intent.Intent) bool {
 	stabilizing := in.Wanted == marker.NodeActionStabilize
 	unknown := in.Wanted == marker.NodeActionUnknown || in.Wanted == ""
@@ -179,6 +559,17 @@ func isLowPriority(in *intent.Intent) bool {
 	return (stabilizing && !hasUpdate) || unknown
 }
 
+// progressesUpdate matches intents that move a Node through its update.
+func progressesUpdate(in *intent.Intent) bool {
+	switch in.Wanted {
+	case marker.NodeActionPrepareUpdate,
+		marker.NodeActionPerformUpdate,
+		marker.NodeActionRebootUpdate:
+		return true
+	}
+	return false
+}
+
 func (am *actionManager) takeAction(pin *intent.Intent) error {
 	log := am.log.WithFields(logfields.Intent(pin))
 	successCheckRun := successfulUpdate(pin)
@@ -186,46 +577,511 @@ func (am *actionManager) takeAction(pin *intent.Intent) error {
 		log.Debug("handling successful update")
 	}
 
+	if paused, pausedBy := am.gate.Paused(); paused && !successCheckRun && progressesUpdate(pin) {
+		log.WithField("paused-by", pausedBy).Warn("rollout is paused, not progressing update")
+		return errRolloutHalted
+	}
+
 	if pin.Intrusive() && !successCheckRun {
+		if am.checkCapacity {
+			if err := am.checkDrainCapacity(pin.NodeName); err != nil {
+				log.WithError(err).Warn("not draining node, deferring update")
+				return err
+			}
+		}
+		if err := am.checkCordonLimit(pin.NodeName); err != nil {
+			log.WithError(err).Warn("not cordoning node, deferring update")
+			return err
+		}
+		if err := am.checkDrainBlockers(pin); err != nil {
+			log.WithError(err).Warn("not draining node, deferring update")
+			return err
+		}
+		am.events.Normal(pin.NodeName, eventCordoning, "Cordoning node for update")
+		span := am.traces.Span(pin.NodeName).Child("controller.cordon")
 		err := am.nodem.Cordon(pin.NodeName)
+		span.End(err)
 		if err != nil {
 			log.WithError(err).Error("could not cordon")
+			am.events.Warning(pin.NodeName, eventCordonFailed, "Unable to cordon node: %v", err)
 			return err
 		}
-		err = am.nodem.Drain(pin.NodeName)
+		am.events.Normal(pin.NodeName, eventCordoned, "Cordoned node for update")
+		if _, ok := am.cordoned[pin.NodeName]; !ok {
+			am.cordoned[pin.NodeName] = am.clock.Now()
+		}
+		am.events.Normal(pin.NodeName, eventDraining, "Draining node for update")
+		span = am.traces.Span(pin.NodeName).Child("controller.drain")
+		evicted, err := am.nodem.Drain(pin.NodeName)
+		span.SetAttribute("drain.evicted", strconv.Itoa(evicted))
+		span.End(err)
+		am.evicted[pin.NodeName] = evicted
 		if err != nil {
 			log.WithError(err).Error("could not drain")
-			// TODO: make workload check/ignore configurable
-			log.Warn("proceeding anyway")
+			metrics.DrainFailures.WithLabelValues(pin.NodeName).Inc()
+			am.events.Warning(pin.NodeName, eventDrainFailed, "Unable to drain node: %v", err)
+			if err := am.handleDrainFailure(pin, err); err != nil {
+				return err
+			}
+		} else {
+			am.events.Normal(pin.NodeName, eventDrained, "Drained node, evicting %d pods", evicted)
 		}
+		am.recordBootID(pin.NodeName)
 	}
 
 	// Handle successful node reconnection.
+	updated := pin
+	var rebootErr error
+	var rollingBack bool
 	if successCheckRun {
 		// Reset the state to begin its stabilization.
 		pin = pin.Reset()
 
-		err := am.checkNode(pin.NodeName)
+		rebootErr = am.verifyRebooted(pin.NodeName)
+		if rebootErr != nil {
+			log.WithError(rebootErr).Error("node failed to reboot into update")
+		}
+		var err error
+		if am.skipHealthCheck {
+			log.Debug("health check disabled, relying on external monitoring")
+		} else {
+			err = am.checkNode(pin.NodeName)
+			if am.ctx.Err() != nil {
+				// The check was cut short as the manager stopped, it's
+				// neither passed nor failed and the Node is checked again
+				// once it's next handled.
+				return err
+			}
+			if err != nil {
+				am.events.Warning(pin.NodeName, eventHealthCheckFailed, "Node failed its health check after updating: %v", err)
+			} else {
+				am.events.Normal(pin.NodeName, eventHealthCheckPassed, "Node passed its health check after updating")
+			}
+		}
 		if err != nil {
 			log.WithError(err).Error("unable to perform success-check")
 			// TODO: make success checks configurable
 			log.Warn("proceeding anyway")
+		} else if rebootErr == nil && am.batch.Healthy(pin.NodeName) {
+			log.WithField("quorum", am.batch.quorum).Info("quorum of batch is healthy, releasing next batch")
 		}
-		err = am.nodem.Uncordon(pin.NodeName)
+		if err == nil && rebootErr == nil {
+			am.errored.Forget(pin.NodeName)
+			if limit, raised := am.ramp.Succeeded(); raised {
+				log.WithField("allowed-active", limit).Info("raised concurrency after consecutive successful updates")
+			}
+			if am.canary.Passed(pin.NodeName) {
+				log.WithField("soak", am.canary.soak.String()).Info("canary updated successfully")
+				am.events.Normal(pin.NodeName, eventCanaryPassed, "Canary node updated successfully, soaking before updating other nodes")
+			}
+		} else if rebootErr != nil {
+			am.updateFailed(log, failedReboot)
+			am.canaryFailed(log, pin.NodeName, rebootErr)
+		} else {
+			am.updateFailed(log, failedHealth)
+			am.canaryFailed(log, pin.NodeName, err)
+			rollingBack = am.rollback(log, pin.NodeName, err)
+		}
+		if rollingBack {
+			log.Info("leaving node cordoned while it rolls back")
+			delete(am.evicted, pin.NodeName)
+			delete(am.cordoned, pin.NodeName)
+		} else if am.keepCordoned(pin.NodeName) {
+			log.WithField("label", am.keepCordonedLabel).Info("node labeled to remain cordoned, not uncordoning")
+			delete(am.evicted, pin.NodeName)
+			delete(am.cordoned, pin.NodeName)
+		} else {
+			span := am.traces.Span(pin.NodeName).Child("controller.uncordon")
+			err = am.nodem.Uncordon(pin.NodeName)
+			span.End(err)
+			if err != nil {
+				log.WithError(err).Error("could not uncordon")
+				am.events.Warning(pin.NodeName, eventUncordonFailed, "Unable to uncordon node: %v", err)
+				// TODO: make policy consider failed success handle scenarios,
+				// otherwise we could make a starved cluster.
+				log.Warn("workload will not return")
+				return err
+			}
+			am.observeUncordon(pin.NodeName)
+			am.events.Normal(pin.NodeName, eventUncordoned, "Uncordoned node after update")
+
+			// Give evicted workloads a chance to be rescheduled before moving on,
+			// there's nothing to wait on for a Node that had nothing to evict.
+			// Nodes without a tracked drain are given the full wait.
+			evicted, tracked := am.evicted[pin.NodeName]
+			delete(am.evicted, pin.NodeName)
+			if tracked && evicted == 0 {
+				log.Debug("no pods were evicted, skipping wait for workloads")
+			} else {
+				am.waitWorkloads(pin.NodeName)
+			}
+		}
+
+		if am.stuck.Recovered(pin.NodeName) {
+			log.Info("previously stuck node recovered")
+		}
+	}
+
+	err := am.poster.Post(pin, am.traces.Propagate(pin.NodeName, am.updateTimes(pin, successCheckRun)))
+	if err != nil {
+		log.WithError(err).Error("unable to post intent")
+		return err
+	}
+	am.observeIntent(pin)
+	if pin.Intrusive() {
+		am.reboots.Rebooted()
+	}
+	if beginsUpdate(pin) {
+		am.starts.Started(pin.NodeName)
+		am.reporter.Report(report.Begin, pin, nil)
+		am.notifyUpdate(report.Begin, pin.NodeName, nil)
+	}
+	if successCheckRun && rebootErr != nil {
+		am.reporter.Report(report.Failure, updated, rebootErr)
+		am.notifyUpdate(report.Failure, pin.NodeName, rebootErr)
+		am.events.Warning(pin.NodeName, eventUpdateFailed, "Node failed to reboot into its update: %v", rebootErr)
+	} else if successCheckRun {
+		metrics.UpdatesCompleted.Inc()
+		am.cloudWatch.Add(cloudwatch.UpdatesSucceeded, 1)
+		am.publishUpdateDuration(pin.NodeName)
+		am.reporter.Report(report.Success, updated, nil)
+		am.notifyUpdate(report.Success, pin.NodeName, nil)
+		am.events.Normal(pin.NodeName, eventUpdateSucceeded, "Node updated successfully")
+		if err := am.postLastUpdated(pin.NodeName); err != nil {
+			log.WithError(err).Warn("unable to record last update")
+		}
+	}
+	return nil
+}
+
+// updateFailed counts a failed update and backs off the concurrency ramp.
+func (am *actionManager) updateFailed(log logging.Logger, reason string) {
+	metrics.UpdateFailures.WithLabelValues(reason).Inc()
+	am.cloudWatch.Add(cloudwatch.UpdatesFailed, 1)
+	if limit, lowered := am.ramp.Failed(); lowered {
+		log.WithField("allowed-active", limit).Warn("lowered concurrency after failed update")
+	}
+}
+
+// canaryFailed blocks further updates when the Node that failed its update is
+// a canary.
+func (am *actionManager) canaryFailed(log logging.Logger, nodeName string, err error) {
+	if !am.canary.Failed(nodeName, err) {
+		return
+	}
+	log.WithError(err).Error("canary failed its update, blocking all further updates")
+	am.events.Warning(nodeName, eventCanaryFailed, "Canary node failed its update, blocking all further updates: %v", err)
+}
+
+// rollback directs the Node that failed its health check to roll back to the
+// version it ran before the update, when configured. The return indicates
+// whether the Node is rolling back.
+func (am *actionManager) rollback(log logging.Logger, nodeName string, err error) bool {
+	if !am.rollbacks.Enabled() {
+		return false
+	}
+	node, ok := am.storedNode(nodeName)
+	if !ok {
+		log.Warn("unable to find node to roll back")
+		return false
+	}
+	version := bootedVersion(node)
+	if version == "" {
+		log.Warn("node has not reported its version, unable to roll back")
+		return false
+	}
+	postErr := am.markers.PostMarkers(nodeName, marker.Annotations{marker.RollbackKey: version})
+	if postErr != nil {
+		log.WithError(postErr).Error("unable to direct node to roll back")
+		return false
+	}
+	am.rollbacks.RollingBack(nodeName, version)
+	log.WithError(err).WithField("version", version).Error("rolling back node after failed health check")
+	am.events.Warning(nodeName, eventRollingBack, "Rolling back node from %s after failing its health check: %v", version, err)
+	return true
+}
+
+// checkRolledBack uncordons the Node once it has rolled back and is ready.
+func (am *actionManager) checkRolledBack(node *v1.Node) {
+	version, ok := am.rollbacks.RolledBack(node)
+	if !ok {
+		return
+	}
+	log := am.log.WithFields(logrus.Fields{
+		"node":    node.GetName(),
+		"version": version,
+	})
+	log.Info("node rolled back")
+	err := am.nodem.Uncordon(node.GetName())
+	if err != nil {
+		log.WithError(err).Error("could not uncordon rolled back node")
+		am.events.Warning(node.GetName(), eventUncordonFailed, "Unable to uncordon node: %v", err)
+		return
+	}
+	am.events.Normal(node.GetName(), eventRolledBack, "Node rolled back from %s and was uncordoned", version)
+}
+
+// beginsUpdate matches intents that direct a Node to begin its update.
+func beginsUpdate(in *intent.Intent) bool {
+	return in.Wanted == marker.NodeActionPrepareUpdate && in.Active != marker.NodeActionPrepareUpdate
+}
+
+// updateTimes are the annotations recording the times the Node began and
+// finished its update, to be posted with the intent. Each update begins with
+// its completion cleared.
+func (am *actionManager) updateTimes(pin *intent.Intent, completed bool) marker.Annotations {
+	now := am.clock.Now().UTC().Format(time.RFC3339)
+	switch {
+	case beginsUpdate(pin):
+		return marker.Annotations{
+			marker.UpdateStartedAtKey:   now,
+			marker.UpdateCompletedAtKey: "",
+		}
+	case completed:
+		return marker.Annotations{marker.UpdateCompletedAtKey: now}
+	}
+	return nil
+}
+
+// postLastUpdated records the time the Node completed its update.
+func (am *actionManager) postLastUpdated(nodeName string) error {
+	return am.markers.PostMarkers(nodeName, marker.Annotations{
+		marker.LastUpdatedKey: am.clock.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// observeLastUpdated exposes the time the Node last completed an update, as
+// recorded on the Node, as a metric.
+func (am *actionManager) observeLastUpdated(node *v1.Node) {
+	value, ok := node.GetAnnotations()[marker.LastUpdatedKey]
+	if !ok || value == "" {
+		return
+	}
+	updated, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		am.log.WithError(err).WithField("node", node.GetName()).Debug("ignoring invalid last updated time")
+		return
+	}
+	metrics.LastUpdated.WithLabelValues(node.GetName()).Set(float64(updated.Unix()))
+}
+
+// handleDrainFailure takes the configured action for a Node that failed to
+// drain. A nil return permits the Node's update to continue.
+func (am *actionManager) handleDrainFailure(pin *intent.Intent, drainErr error) error {
+	log := am.log.WithFields(logfields.Intent(pin)).WithField("drain-failure", am.drainFailure)
+	switch am.drainFailure {
+	case DrainFailureHalt:
+		am.updateFailed(log, failedDrain)
+		am.gate.Pause(pin.NodeName)
+		log.Error("halting rollout, node left cordoned for investigation")
+		am.reporter.Report(report.Failure, pin, drainErr)
+		am.notifyUpdate(report.Failure, pin.NodeName, drainErr)
+		return errors.WithMessage(drainErr, "rollout halted")
+	case DrainFailureSkip:
+		am.updateFailed(log, failedDrain)
+		log.WithField("retry-after", am.skipped.period).Warn("skipping update of node")
+		am.skipped.Skip(pin.NodeName)
+		am.reporter.Report(report.Failure, pin, drainErr)
+		am.notifyUpdate(report.Failure, pin.NodeName, drainErr)
+		delete(am.evicted, pin.NodeName)
+		err := am.nodem.Uncordon(pin.NodeName)
 		if err != nil {
 			log.WithError(err).Error("could not uncordon")
-			// TODO: make policy consider failed success handle scenarios,
-			// otherwise we could make a starved cluster.
-			log.Warn("workload will not return")
+			am.events.Warning(pin.NodeName, eventUncordonFailed, "Unable to uncordon node: %v", err)
 			return err
 		}
+		am.observeUncordon(pin.NodeName)
+		am.events.Normal(pin.NodeName, eventUncordoned, "Uncordoned node, skipping its update after failing to drain")
+		err = am.poster.Post(pin.Reset(), nil)
+		if err != nil {
+			log.WithError(err).Error("unable to post intent")
+			return err
+		}
+		return errors.WithMessage(drainErr, "skipped update")
+	default:
+		log.Warn("proceeding anyway")
+		return nil
 	}
+}
 
-	err := am.poster.Post(pin)
+// checkDrainCapacity checks that the other managed Nodes have the capacity to
+// schedule the Pods evicted by draining the Node.
+func (am *actionManager) checkDrainCapacity(nodeName string) error {
+	if am.storer == nil {
+		return errors.New("manager has no store to access, needed for capacity check")
+	}
+	var others []*v1.Node
+	for _, res := range am.storer.GetStore().List() {
+		node, ok := res.(*v1.Node)
+		if !ok || node.GetName() == nodeName {
+			continue
+		}
+		others = append(others, node)
+	}
+	ok, short, err := am.nodem.Capacity(nodeName, others)
 	if err != nil {
-		log.WithError(err).Error("unable to post intent")
+		return errors.WithMessage(err, "unable to check capacity")
 	}
-	return err
+	if !ok {
+		return errors.WithMessagef(errInsufficientCapacity, "resources short by %s", formatResources(short))
+	}
+	return nil
+}
+
+// checkDrainBlockers checks that no PodDisruptionBudget would block the
+// Node's drain, which would otherwise leave the Node cordoned until the drain
+// fails. The blocking budgets and their Pods are reported so that they may be
+// fixed. The Node isn't held back when the budgets can't be checked, its drain
+// respects them regardless.
+func (am *actionManager) checkDrainBlockers(pin *intent.Intent) error {
+	log := am.log.WithFields(logfields.Intent(pin))
+	blocks, err := am.nodem.DrainBlockers(pin.NodeName)
+	if err != nil {
+		log.WithError(err).Warn("unable to check pod disruption budgets")
+		return nil
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	for _, block := range blocks {
+		log.WithFields(logrus.Fields{
+			"budget": block.Budget,
+			"pods":   strings.Join(block.Pods, ","),
+		}).Warn("pod disruption budget permits no disruptions, blocking drain")
+	}
+	metrics.DrainsBlocked.WithLabelValues(pin.NodeName).Inc()
+	blockedErr := &drainBlockedError{blocks: blocks}
+	am.reporter.Report(report.DrainBlocked, pin, blockedErr)
+	am.events.Warning(pin.NodeName, eventDrainBlocked, "Not draining node: %v", blockedErr)
+	return blockedErr
+}
+
+// checkCordonLimit checks that cordoning the Node keeps the number of cordoned
+// Nodes within the configured limit. Nodes already cordoned are always
+// permitted.
+func (am *actionManager) checkCordonLimit(nodeName string) error {
+	if am.maxCordoned <= 0 {
+		return nil
+	}
+	cordoned := am.cordonedNodes()
+	if _, ok := cordoned[nodeName]; ok {
+		return nil
+	}
+	if len(cordoned) >= am.maxCordoned {
+		return errors.WithMessagef(errCordonLimit, "%d of %d permitted", len(cordoned), am.maxCordoned)
+	}
+	return nil
+}
+
+// cordonedNodes are the managed Nodes that are cordoned, either by the
+// controller or as seen in the store. Nodes labeled to remain cordoned after
+// their update aren't counted, they're no longer taking part in the rollout.
+func (am *actionManager) cordonedNodes() map[string]struct{} {
+	cordoned := make(map[string]struct{}, len(am.cordoned))
+	for name := range am.cordoned {
+		cordoned[name] = struct{}{}
+	}
+	if am.storer == nil {
+		return cordoned
+	}
+	for _, res := range am.storer.GetStore().List() {
+		node, ok := res.(*v1.Node)
+		if !ok || !node.Spec.Unschedulable {
+			continue
+		}
+		if _, keep := node.Labels[am.keepCordonedLabel]; keep {
+			continue
+		}
+		cordoned[node.GetName()] = struct{}{}
+	}
+	return cordoned
+}
+
+// observeUncordon records the time the Node spent cordoned by the controller.
+func (am *actionManager) observeUncordon(nodeName string) {
+	cordoned, ok := am.cordoned[nodeName]
+	if !ok {
+		return
+	}
+	delete(am.cordoned, nodeName)
+	metrics.CordonDuration.WithLabelValues(nodeName).Observe(am.clock.Since(cordoned).Seconds())
+}
+
+// observeIntent records the time the Node spent directed to take its previous
+// action once it's directed to take another.
+func (am *actionManager) observeIntent(in *intent.Intent) {
+	prev, ok := am.intended[in.NodeName]
+	if ok && prev.action == in.Wanted {
+		return
+	}
+	if ok {
+		metrics.IntentDuration.WithLabelValues(string(prev.action)).Observe(am.clock.Since(prev.since).Seconds())
+	}
+	am.intended[in.NodeName] = intendedAction{action: in.Wanted, since: am.clock.Now()}
+}
+
+// keepCordoned indicates whether the Node is labeled to remain cordoned after
+// its update.
+func (am *actionManager) keepCordoned(nodeName string) bool {
+	node, ok := am.storedNode(nodeName)
+	if !ok {
+		return false
+	}
+	_, keep := node.Labels[am.keepCordonedLabel]
+	return keep
+}
+
+// storedNode is the Node as last observed.
+func (am *actionManager) storedNode(nodeName string) (*v1.Node, bool) {
+	if am.storer == nil {
+		return nil, false
+	}
+	obj, exists, err := am.storer.GetStore().GetByKey(nodeName)
+	if err != nil || !exists {
+		return nil, false
+	}
+	node, ok := obj.(*v1.Node)
+	return node, ok
+}
+
+// checkPolicy checks whether the policy permits the intent, the intent is to
+// be checked again once the returned delay elapses when one is given.
+func (am *actionManager) checkPolicy(in *intent.Intent) (proceed bool, retryAfter time.Duration) {
+	log := am.log.WithFields(logfields.Intent(in))
+	log.Debug("checking with policy")
+	// TODO: make policy checking and consideration richer
+	pview, err := am.makePolicyCheck(in)
+	if err != nil {
+		log.WithError(err).Error("policy unenforceable")
+		return false, 0
+	}
+	metrics.NodesUpdating.Set(float64(pview.ClusterActive))
+	if pview.Incomplete() {
+		log := log.WithFields(logrus.Fields{
+			"skipped":  pview.Skipped,
+			"unlisted": pview.Unlisted,
+			"action":   am.incompleteView,
+		})
+		switch am.incompleteView {
+		case IncompleteViewDeny:
+			log.Warn("cluster view is incomplete, denying intent")
+			return false, 0
+		case IncompleteViewRetry:
+			log.WithField("delay", incompleteRetryDelay).Warn("cluster view is incomplete, checking intent again later")
+			return false, incompleteRetryDelay
+		default:
+			log.Warn("cluster view is incomplete, checking policy with the nodes in view")
+		}
+	}
+	proceed, retryAfter, err = am.policy.Check(pview)
+	if err != nil {
+		log.WithError(err).Error("policy check errored")
+		return false, 0
+	}
+	if !proceed {
+		log.WithField("retry-after", retryAfter).Debug("policy denied intent")
+	}
+	return proceed, retryAfter
 }
 
 // makePolicyCheck collects cluster information as a PolicyCheck for which to be
@@ -245,7 +1101,7 @@ func (am *actionManager) handle(node intent.Input) {
 	log := am.log.WithField("node", node.GetName())
 	log.Debug("handling event")
 
-	in := am.intentFor(node)
+	in := am.actionable(node)
 	if in == nil {
 		return // no actionable intent signaled
 	}
@@ -257,21 +1113,29 @@ func (am *actionManager) handle(node intent.Input) {
 			Debug("retrieved cached queued intent to dedupe")
 	}
 	if intent.Equivalent(lastQueued, in) {
+		metrics.ControllerDuplicateIntents.Inc()
 		log.Debug("not queuing duplicate intent")
 		return
 	}
 
 	record := in.Clone()
-	select {
-	case am.inputs <- in:
-		log.Debug("queue intent")
-		am.lastCache.Record(record)
-	default:
-		log.WithFields(logrus.Fields{
-			"queue":        "input",
-			"queue-length": len(am.inputs),
-		}).Warn("unable to queue intent (back pressure)")
+	am.traces.Queued(node, in)
+	am.queue.Add(in)
+	log.WithField("queue-length", am.queue.Len()).Debug("queue intent")
+	am.lastCache.Record(record)
+}
+
+// actionable is the Node's Intent to act on, nil when there's nothing to do
+// or the Node is held.
+func (am *actionManager) actionable(node intent.Input) *intent.Intent {
+	if _, held := node.GetLabels()[am.holdLabel]; held {
+		am.log.WithFields(logrus.Fields{
+			"node":  node.GetName(),
+			"label": am.holdLabel,
+		}).Info("node is held, not advancing")
+		return nil
 	}
+	return am.intentFor(node)
 }
 
 // intentFor interprets the intention given the Node's annotations.
@@ -280,14 +1144,46 @@ func (am *actionManager) intentFor(node intent.Input) *intent.Intent {
 	log := am.log.WithFields(logfields.Intent(in))
 
 	if in.Stuck() {
+		am.stuck.Stuck(in.NodeName)
+		am.reporter.Report(report.Stuck, in, nil)
 		reset := in.Reset()
 		log.WithField("intent-reset", reset.DisplayString()).Debug("node intent indicates stuck")
 		log.Warn("stabilizing stuck node")
 		return reset
 	}
-	// TODO: add per-node bucketed backoff for error handling and retries.
+	if in.State != marker.NodeStateBusy {
+		am.busy.Idle(in.NodeName)
+	} else if since, expired := am.busy.Expired(in.NodeName, in.Active, node.GetAnnotations()[marker.ActionStartedKey]); expired {
+		am.busy.Idle(in.NodeName)
+		am.stuck.Stuck(in.NodeName)
+		am.reporter.Report(report.Stuck, in, nil)
+		reset := in.Reset()
+		log.WithFields(logrus.Fields{
+			"intent-reset": reset.DisplayString(),
+			"busy-since":   since.UTC().Format(time.RFC3339),
+			"timeout":      am.busy.timeout,
+		}).Error("node busy without progress past timeout, resetting to stabilize")
+		am.events.Warning(in.NodeName, eventBusyTimeout, "Node was busy with %s without progress since %s, resetting to stabilize", in.Active, since.UTC().Format(time.RFC3339))
+		return reset
+	}
+	if !unknownIntent(in) {
+		am.unknown.Forget(in.NodeName)
+	} else if am.unknown.Expired(in.NodeName) {
+		am.unknown.Forget(in.NodeName)
+		reset := in.Reset()
+		log.WithFields(logrus.Fields{
+			"intent-reset": reset.DisplayString(),
+			"grace":        am.unknown.grace,
+		}).Warn("node intent remained unknown past grace period, resetting")
+		return reset
+	}
 	if in.Errored() {
 		log.Debug("intent errored")
+		if wait := am.errored.Wait(in.NodeName); wait > 0 {
+			log.WithField("backoff", wait).Debug("action errored on node, waiting to retry")
+			am.queue.RetryAfter(in.NodeName, wait)
+			return nil
+		}
 		log.Warn("action errored on node, resetting to stabilize")
 		in = in.Reset()
 		return in.Projected()
@@ -307,7 +1203,26 @@ func (am *actionManager) intentFor(node intent.Input) *intent.Intent {
 		return in
 	}
 
+	if stabilized(in) && am.stuck.Recovered(in.NodeName) {
+		log.Info("previously stuck node recovered")
+	}
+
 	if in.HasUpdateAvailable() && in.Waiting() && !in.Errored() {
+		// Updating a Node that's already unhealthy could worsen an outage.
+		if nodeNotReady(node) {
+			if am.notReady.Hold(in.NodeName) {
+				log.Warn("node is not ready, not starting update until it recovers")
+			}
+			return nil
+		}
+		if am.notReady.Release(in.NodeName) {
+			log.Info("node is ready, starting update")
+		}
+		if wait := am.skipped.Remaining(in.NodeName); wait > 0 {
+			log.WithField("wait", wait).Debug("node's update was skipped, waiting to retry")
+			am.queue.RetryAfter(in.NodeName, wait)
+			return nil
+		}
 		log.Debug("intent starts update")
 		return in.SetBeginUpdate()
 	}
@@ -323,15 +1238,67 @@ func successfulUpdate(in *intent.Intent) bool {
 
 // OnAdd is a Handler implementation for nodestream
 func (am *actionManager) OnAdd(node *v1.Node) {
+	am.checkResume(node, false)
+	am.observeLastUpdated(node)
+	am.canary.Observe(node)
+	am.starts.Observe(node)
+	if am.settler.Hold(node) {
+		return
+	}
 	am.handle(node)
 }
 
 // OnDelete is a Handler implementation for nodestream
 func (am *actionManager) OnDelete(node *v1.Node) {
+	am.checkResume(node, true)
+	am.settler.Forget(node.GetName())
 	am.handle(node)
+	am.stuck.Forget(node.GetName())
+	am.unknown.Forget(node.GetName())
+	am.busy.Forget(node.GetName())
+	am.errored.Forget(node.GetName())
+	am.skipped.Forget(node.GetName())
+	am.batch.Forget(node.GetName())
+	am.canary.Forget(node.GetName())
+	am.rollbacks.Forget(node.GetName())
+	am.notReady.Release(node.GetName())
+	metrics.LastUpdated.DeleteLabelValues(node.GetName())
+	metrics.DrainFailures.DeleteLabelValues(node.GetName())
+	metrics.DrainsBlocked.DeleteLabelValues(node.GetName())
+	metrics.CordonDuration.DeleteLabelValues(node.GetName())
+	metrics.StuckRecovered.DeleteLabelValues(node.GetName())
 }
 
 // OnUpdate is a Handler implementation for nodestream
 func (am *actionManager) OnUpdate(_ *v1.Node, node *v1.Node) {
+	am.checkResume(node, false)
+	am.observeLastUpdated(node)
+	am.canary.Observe(node)
+	am.checkRolledBack(node)
+	if am.settler.Hold(node) {
+		return
+	}
 	am.handle(node)
 }
+
+// releaseSettled handles the Nodes coalesced while starting up.
+func (am *actionManager) releaseSettled() {
+	nodes := am.settler.Release()
+	am.log.WithField("nodes", len(nodes)).Info("startup settled, handling nodes")
+	for _, node := range nodes {
+		am.handle(node)
+	}
+}
+
+// checkResume resumes a paused rollout once the Node that paused it is no
+// longer held: an operator uncordons it, after investigating, or removes it.
+func (am *actionManager) checkResume(node *v1.Node, deleted bool) {
+	paused, pausedBy := am.gate.Paused()
+	if !paused || pausedBy != node.GetName() {
+		return
+	}
+	if deleted || !node.Spec.Unschedulable {
+		am.gate.Resume()
+		am.log.WithField("node", node.GetName()).Info("resuming rollout")
+	}
+}
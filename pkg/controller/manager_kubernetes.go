@@ -1,20 +1,124 @@
 package controller
 
 import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/k8sutil"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/kubectl/pkg/drain"
 )
 
+const (
+	// workloadPollInterval is the time between checks for drained workloads
+	// to be rescheduled once their Node is uncordoned.
+	workloadPollInterval = 5 * time.Second
+	// podDeletePollInterval is the time between checks for the Pods deleted by
+	// a forced drain to be gone.
+	podDeletePollInterval = time.Second
+	// defaultPodDeleteTimeout is the time, beyond their grace period, that
+	// Pods deleted by a forced drain are given to be gone.
+	defaultPodDeleteTimeout = time.Minute
+)
+
 type k8sNodeManager struct {
-	kube kubernetes.Interface
+	log   logging.Logger
+	kube  kubernetes.Interface
+	out   io.Writer
+	err   io.Writer
+	grace *drainGrace
+	// doNotDrain is the annotation of Pods left in place when their Node is
+	// drained, when it's set to true.
+	doNotDrain string
+	// gates are the conditions, in addition to being ready, a Node must
+	// satisfy to be considered ready.
+	gates *readinessGates
+	// evict evicts the Pods, respecting their PodDisruptionBudgets.
+	evict func(*drain.Helper, []v1.Pod) error
+	// events records the Events of forced drains.
+	events *nodeRecorder
+	// deleteTimeout is the time, beyond their grace period, that Pods deleted
+	// by a forced drain are given to be gone.
+	deleteTimeout time.Duration
+}
+
+func newK8sNodeManager(log logging.Logger, kube kubernetes.Interface, grace *drainGrace, doNotDrain string, gates *readinessGates) *k8sNodeManager {
+	return &k8sNodeManager{
+		log:           log,
+		kube:          kube,
+		out:           log.Writer(),
+		err:           log.WriterLevel(logrus.WarnLevel),
+		grace:         grace,
+		doNotDrain:    doNotDrain,
+		gates:         gates,
+		evict:         (*drain.Helper).DeleteOrEvictPods,
+		deleteTimeout: defaultPodDeleteTimeout,
+	}
+}
+
+// drainGrace overrides the termination grace period of Pods matching its
+// selector when they're drained.
+type drainGrace struct {
+	selector labels.Selector
+	seconds  int
+}
+
+// split separates the Pods using their own grace period from the Pods that
+// are given the overriding grace period.
+func (g *drainGrace) split(pods []v1.Pod) (defaulted []v1.Pod, overridden []v1.Pod) {
+	if g == nil {
+		return pods, nil
+	}
+	for _, pod := range pods {
+		if g.selector.Matches(labels.Set(pod.Labels)) {
+			overridden = append(overridden, pod)
+		} else {
+			defaulted = append(defaulted, pod)
+		}
+	}
+	return defaulted, overridden
+}
+
+// doNotDrainFilter reports whether the Pod is to be drained, like the drainer's
+// own filters. Pods with the do-not-drain annotation set to true are left in
+// place.
+func (k *k8sNodeManager) doNotDrainFilter(pod v1.Pod) bool {
+	keep, err := strconv.ParseBool(pod.Annotations[k.doNotDrain])
+	return err != nil || !keep
+}
+
+// podsForDeletion lists the Node's Pods to be drained, as selected by the
+// drainer's filters and then doNotDrainFilter, and the Pods kept in place by
+// it. The vendored drain.Helper takes no additional filters, so the filter is
+// applied to the Pods the drainer lists.
+func (k *k8sNodeManager) podsForDeletion(drainer *drain.Helper, nodeName string) (pods []v1.Pod, kept []v1.Pod, warnings string, err error) {
+	list, errs := drainer.GetPodsForDeletion(nodeName)
+	if errs != nil {
+		return nil, nil, "", utilerrors.NewAggregate(errs)
+	}
+	for _, pod := range list.Pods() {
+		if k.doNotDrainFilter(pod) {
+			pods = append(pods, pod)
+		} else {
+			kept = append(kept, pod)
+		}
+	}
+	return pods, kept, list.Warnings(), nil
 }
 
 func (k *k8sNodeManager) forNode(nodeName string) (*v1.Node, *drain.Helper, error) {
@@ -23,7 +127,16 @@ func (k *k8sNodeManager) forNode(nodeName string) (*v1.Node, *drain.Helper, erro
 	if err != nil {
 		return nil, nil, errors.WithMessage(err, "unable to retrieve node from api")
 	}
-	drainer = &drain.Helper{Client: k.kube}
+	drainer = &drain.Helper{
+		Client: k.kube,
+		// DaemonSet Pods, such as the Agent, remain in place as they would be
+		// immediately rescheduled to the Node.
+		IgnoreAllDaemonSets: true,
+		// Pods are given their own termination grace period.
+		GracePeriodSeconds: -1,
+		Out:                k.out,
+		ErrOut:             k.err,
+	}
 	return node, drainer, err
 }
 
@@ -43,15 +156,446 @@ func (k *k8sNodeManager) Cordon(nodeName string) error {
 	return k.setCordon(nodeName, true)
 }
 
-func (k *k8sNodeManager) Drain(nodeName string) error {
+// Drain evicts the Node's Pods, returning the number of Pods evicted. The Pods
+// of Nodes annotated to force their drain are deleted instead, the annotation
+// is removed once they're deleted so that the escalation applies to a single
+// drain.
+func (k *k8sNodeManager) Drain(nodeName string) (int, error) {
+	node, drainer, err := k.forNode(nodeName)
+	if err != nil {
+		return 0, errors.WithMessage(err, "unable to operate")
+	}
+	_, forced := node.Annotations[marker.ForceDrainKey]
+	if forced {
+		// Unreplicated Pods and Pods with local data would otherwise fail
+		// the drain too.
+		drainer.Force = true
+		drainer.DeleteLocalData = true
+	}
+	pods, kept, warnings, err := k.podsForDeletion(drainer, nodeName)
+	if err != nil {
+		return 0, err
+	}
+	if warnings != "" {
+		k.log.WithField("node", nodeName).Warnf("drain: %s", warnings)
+	}
+	for _, pod := range kept {
+		k.log.WithFields(logrus.Fields{
+			"node":       nodeName,
+			"pod":        pod.Namespace + "/" + pod.Name,
+			"annotation": k.doNotDrain,
+		}).Info("pod annotated to not be drained, leaving in place")
+	}
+	if len(pods) == 0 {
+		if forced {
+			return 0, k.clearForceDrain(nodeName)
+		}
+		return 0, nil
+	}
+	if forced {
+		k.log.WithFields(logrus.Fields{
+			"node":       nodeName,
+			"pods":       len(pods),
+			"annotation": marker.ForceDrainKey,
+		}).Warn("FORCE DRAINING NODE: deleting pods without eviction, pod disruption budgets are not respected")
+		k.events.Warning(nodeName, eventForceDraining, "Force draining node, deleting %d pods without respecting their disruption budgets", len(pods))
+		if err := k.deletePods(pods); err != nil {
+			return len(pods), err
+		}
+		return len(pods), k.clearForceDrain(nodeName)
+	}
+	defaulted, overridden := k.grace.split(pods)
+	if len(defaulted) != 0 {
+		err = k.evict(drainer, defaulted)
+		if err != nil {
+			return len(pods), k.explainEvictFailure(pods, err)
+		}
+	}
+	if len(overridden) != 0 {
+		graceDrainer := *drainer
+		graceDrainer.GracePeriodSeconds = k.grace.seconds
+		k.log.WithFields(logrus.Fields{
+			"node":  nodeName,
+			"pods":  len(overridden),
+			"grace": k.grace.seconds,
+		}).Debug("draining pods with overridden grace period")
+		err = k.evict(&graceDrainer, overridden)
+		if err != nil {
+			return len(pods), k.explainEvictFailure(pods, err)
+		}
+	}
+	return len(pods), nil
+}
+
+// explainEvictFailure replaces a failed eviction's error with the
+// PodDisruptionBudgets that blocked it, when there are any to blame.
+func (k *k8sNodeManager) explainEvictFailure(pods []v1.Pod, evictErr error) error {
+	blocks, err := k.budgetBlocks(pods)
+	if err != nil || len(blocks) == 0 {
+		return evictErr
+	}
+	return &drainBlockedError{blocks: blocks}
+}
+
+// DrainBlockers reports the PodDisruptionBudgets that would block draining
+// the Node. Forced drains delete Pods without regard to their budgets, so
+// they're never blocked.
+func (k *k8sNodeManager) DrainBlockers(nodeName string) ([]budgetBlock, error) {
+	node, drainer, err := k.forNode(nodeName)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to operate")
+	}
+	if _, forced := node.Annotations[marker.ForceDrainKey]; forced {
+		return nil, nil
+	}
+	pods, _, _, err := k.podsForDeletion(drainer, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return k.budgetBlocks(pods)
+}
+
+func (k *k8sNodeManager) budgetBlocks(pods []v1.Pod) ([]budgetBlock, error) {
+	if len(pods) == 0 {
+		return nil, nil
+	}
+	budgets, err := k.kube.PolicyV1beta1().PodDisruptionBudgets(v1meta.NamespaceAll).List(v1meta.ListOptions{})
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to list pod disruption budgets")
+	}
+	return blockingBudgets(pods, budgets.Items), nil
+}
+
+// clearForceDrain removes the Node's annotation forcing its drain, later
+// drains respect the Pods' disruption budgets again.
+func (k *k8sNodeManager) clearForceDrain(nodeName string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := k.kube.CoreV1().Nodes().Get(nodeName, v1meta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := node.Annotations[marker.ForceDrainKey]; !ok {
+			return nil
+		}
+		delete(node.Annotations, marker.ForceDrainKey)
+		_, err = k.kube.CoreV1().Nodes().Update(node)
+		return err
+	})
+	return errors.WithMessage(err, "unable to remove force drain annotation")
+}
+
+// deletePods deletes the Pods directly, without the Eviction API, so that
+// their PodDisruptionBudgets can't block them from being removed. Pods are
+// given the same grace period they'd be given when evicted and are waited on
+// until they're gone.
+func (k *k8sNodeManager) deletePods(pods []v1.Pod) error {
+	defaulted, overridden := k.grace.split(pods)
+	var errs []error
+	remove := func(pod v1.Pod, opts *v1meta.DeleteOptions) {
+		err := k.kube.CoreV1().Pods(pod.Namespace).Delete(pod.Name, opts)
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.WithMessagef(err, "unable to delete pod %s/%s", pod.Namespace, pod.Name))
+		}
+	}
+	for _, pod := range defaulted {
+		remove(pod, &v1meta.DeleteOptions{})
+	}
+	for _, pod := range overridden {
+		seconds := int64(k.grace.seconds)
+		remove(pod, &v1meta.DeleteOptions{GracePeriodSeconds: &seconds})
+	}
+	if len(errs) != 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+	return k.waitDeleted(pods, k.longestGrace(defaulted, overridden)+k.deleteTimeout)
+}
+
+// longestGrace is the longest termination grace period given to the deleted
+// Pods.
+func (k *k8sNodeManager) longestGrace(defaulted, overridden []v1.Pod) time.Duration {
+	var longest int64
+	for _, pod := range defaulted {
+		seconds := int64(v1.DefaultTerminationGracePeriodSeconds)
+		if pod.Spec.TerminationGracePeriodSeconds != nil {
+			seconds = *pod.Spec.TerminationGracePeriodSeconds
+		}
+		if seconds > longest {
+			longest = seconds
+		}
+	}
+	if len(overridden) != 0 && int64(k.grace.seconds) > longest {
+		longest = int64(k.grace.seconds)
+	}
+	return time.Duration(longest) * time.Second
+}
+
+// waitDeleted waits until the deleted Pods are gone, giving up after the
+// timeout. Pods replaced by another of the same name are gone.
+func (k *k8sNodeManager) waitDeleted(pods []v1.Pod, timeout time.Duration) error {
+	remaining := pods
+	err := wait.PollImmediate(podDeletePollInterval, timeout, func() (bool, error) {
+		var pending []v1.Pod
+		for _, pod := range remaining {
+			current, err := k.kube.CoreV1().Pods(pod.Namespace).Get(pod.Name, v1meta.GetOptions{})
+			if apierrors.IsNotFound(err) || (err == nil && current.UID != pod.UID) {
+				continue
+			}
+			if err != nil {
+				k.log.WithError(err).WithField("pod", pod.Namespace+"/"+pod.Name).Warn("unable to check deleted pod")
+			}
+			pending = append(pending, pod)
+		}
+		remaining = pending
+		return len(remaining) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf("%d deleted pods not gone after %s", len(remaining), timeout)
+	}
+	return err
+}
+
+// Capacity reports whether the other Nodes have the spare capacity to
+// schedule the Pods that draining the named Node would evict.
+func (k *k8sNodeManager) Capacity(nodeName string, others []*v1.Node) (bool, v1.ResourceList, error) {
 	_, drainer, err := k.forNode(nodeName)
 	if err != nil {
-		return errors.WithMessage(err, "unable to operate")
+		return false, nil, errors.WithMessage(err, "unable to operate")
+	}
+	pods, _, _, err := k.podsForDeletion(drainer, nodeName)
+	if err != nil {
+		return false, nil, err
 	}
-	return drain.RunNodeDrain(drainer, nodeName)
+	scheduled, err := k.kube.CoreV1().Pods(v1meta.NamespaceAll).List(v1meta.ListOptions{})
+	if err != nil {
+		return false, nil, errors.WithMessage(err, "unable to list pods")
+	}
+	ok, short := sufficientCapacity(pods, others, scheduled.Items)
+	return ok, short, nil
+}
+
+// Ready reports the Node's NodeReady condition and any readiness gates it
+// doesn't yet satisfy.
+func (k *k8sNodeManager) Ready(nodeName string) (nodeReadiness, error) {
+	node, err := k.kube.CoreV1().Nodes().Get(nodeName, v1meta.GetOptions{})
+	if err != nil {
+		return nodeReadiness{}, errors.WithMessage(err, "unable to retrieve node from api")
+	}
+	readiness := readinessOf(node)
+	readiness.Unsatisfied = k.gates.Unsatisfied(node)
+	return readiness, nil
+}
+
+// BootID reports the Node's boot ID, which changes each time it boots.
+func (k *k8sNodeManager) BootID(nodeName string) (string, error) {
+	node, err := k.kube.CoreV1().Nodes().Get(nodeName, v1meta.GetOptions{})
+	if err != nil {
+		return "", errors.WithMessage(err, "unable to retrieve node from api")
+	}
+	return node.Status.NodeInfo.BootID, nil
+}
+
+// PendingWorkloads reports the number of Pods yet to settle after the Node is
+// uncordoned: the Node's Pods that aren't ready and any Pods waiting to be
+// scheduled.
+func (k *k8sNodeManager) PendingWorkloads(nodeName string) (int, error) {
+	pods, err := k.kube.CoreV1().Pods(v1meta.NamespaceAll).List(v1meta.ListOptions{})
+	if err != nil {
+		return 0, errors.WithMessage(err, "unable to list pods")
+	}
+	return pendingWorkloads(nodeName, pods.Items), nil
+}
+
+// pendingWorkloads counts the Pods scheduled to the Node that aren't ready and
+// the Pods that are yet to be scheduled, as the drained Node's workloads may
+// be either. Terminal and terminating Pods are never counted.
+func pendingWorkloads(nodeName string, pods []v1.Pod) int {
+	pending := 0
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		switch pod.Spec.NodeName {
+		case "":
+			if pod.Status.Phase == v1.PodPending {
+				pending++
+			}
+		case nodeName:
+			if !podTerminated(pod) && !podReady(pod) {
+				pending++
+			}
+		}
+	}
+	return pending
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeReadiness is the state of a Node's NodeReady condition, its status is
+// empty when the Node hasn't reported the condition.
+type nodeReadiness struct {
+	Status  v1.ConditionStatus
+	Reason  string
+	Message string
+	// Unsatisfied describes the readiness gates the Node doesn't yet
+	// satisfy.
+	Unsatisfied []string
+}
+
+// Ready reports whether the condition is true and the readiness gates are
+// satisfied.
+func (r nodeReadiness) Ready() bool {
+	return r.Status == v1.ConditionTrue && len(r.Unsatisfied) == 0
+}
+
+func (r nodeReadiness) String() string {
+	if r.Status == "" {
+		return "no ready condition reported"
+	}
+	desc := "ready condition " + string(r.Status)
+	if r.Reason != "" {
+		desc += ", reason " + r.Reason
+	}
+	if r.Message != "" {
+		desc += ": " + r.Message
+	}
+	if len(r.Unsatisfied) != 0 {
+		desc += ", waiting on " + strings.Join(r.Unsatisfied, ", ")
+	}
+	return desc
+}
+
+func readinessOf(node *v1.Node) nodeReadiness {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return nodeReadiness{Status: cond.Status, Reason: cond.Reason, Message: cond.Message}
+		}
+	}
+	return nodeReadiness{}
 }
 
+func nodeReady(node *v1.Node) bool {
+	return readinessOf(node).Ready()
+}
+
+// checkNode waits for the Node to report itself as ready and then, when
+// configured, verifies that it remains ready.
 func (am *actionManager) checkNode(nodeName string) error {
+	if err := am.waitReady(nodeName); err != nil {
+		return err
+	}
+	return am.verifyReady(nodeName)
+}
+
+// waitReady waits for the Node to report itself as ready, checking every
+// health check interval until the health check timeout.
+func (am *actionManager) waitReady(nodeName string) error {
+	log := am.log.WithField("node", nodeName)
+	var elapsed time.Duration
+	var last string
+	for {
+		if err := am.ctx.Err(); err != nil {
+			return errors.Wrap(err, "stopped waiting for node to be ready")
+		}
+		readiness, err := am.nodem.Ready(nodeName)
+		if err != nil {
+			log.WithError(err).Warn("unable to check node readiness")
+			last = "unable to check readiness: " + err.Error()
+		} else if readiness.Ready() {
+			return nil
+		} else {
+			last = readiness.String()
+		}
+		if elapsed >= am.healthCheckTimeout {
+			return errors.Errorf("node not ready after %s, last observed %s", elapsed, last)
+		}
+		wait := am.healthCheckInterval
+		if remaining := am.healthCheckTimeout - elapsed; remaining < wait {
+			wait = remaining
+		}
+		if err := am.sleep(wait); err != nil {
+			return errors.Wrap(err, "stopped waiting for node to be ready")
+		}
+		elapsed += wait
+	}
+}
+
+// verifyReady rechecks the ready Node throughout the verification delay to
+// catch Nodes that flap back to not ready shortly after updating.
+func (am *actionManager) verifyReady(nodeName string) error {
+	log := am.log.WithField("node", nodeName)
+	var elapsed time.Duration
+	for elapsed < am.verifyDelay {
+		if err := am.ctx.Err(); err != nil {
+			return errors.Wrap(err, "stopped verifying node readiness")
+		}
+		wait := am.healthCheckInterval
+		if remaining := am.verifyDelay - elapsed; remaining < wait {
+			wait = remaining
+		}
+		if err := am.sleep(wait); err != nil {
+			return errors.Wrap(err, "stopped verifying node readiness")
+		}
+		elapsed += wait
+
+		readiness, err := am.nodem.Ready(nodeName)
+		if err != nil {
+			log.WithError(err).Warn("unable to check node readiness")
+			continue
+		}
+		if !readiness.Ready() {
+			return errors.Errorf("node became not ready %s after passing its health check, %s", elapsed, readiness)
+		}
+	}
+	return nil
+}
+
+// recordBootID records the Node's boot ID before it reboots into its update,
+// when configured to verify the reboot.
+func (am *actionManager) recordBootID(nodeName string) {
+	if !am.verifyBootID {
+		return
+	}
+	log := am.log.WithField("node", nodeName)
+	bootID, err := am.nodem.BootID(nodeName)
+	if err != nil {
+		log.WithError(err).Warn("unable to record boot id, reboot will not be verified")
+		delete(am.bootIDs, nodeName)
+		return
+	}
+	am.bootIDs[nodeName] = bootID
+}
+
+// verifyRebooted confirms that the Node's boot ID changed since it was
+// recorded, an unchanged boot ID means that the Node did not reboot into its
+// update. Nodes without a recorded boot ID, such as those rebooted before the
+// controller started, aren't verified.
+func (am *actionManager) verifyRebooted(nodeName string) error {
+	before, ok := am.bootIDs[nodeName]
+	delete(am.bootIDs, nodeName)
+	if !am.verifyBootID || !ok || before == "" {
+		return nil
+	}
+	log := am.log.WithField("node", nodeName)
+	after, err := am.nodem.BootID(nodeName)
+	if err != nil {
+		log.WithError(err).Warn("unable to check boot id, reboot will not be verified")
+		return nil
+	}
+	if after == before {
+		return errors.Errorf("node did not reboot, boot id %q is unchanged", after)
+	}
+	log.WithFields(logrus.Fields{
+		"before": before,
+		"after":  after,
+	}).Debug("verified node rebooted")
 	return nil
 }
 
@@ -60,9 +604,9 @@ type k8sPoster struct {
 	nodeclient corev1.NodeInterface
 }
 
-func (k *k8sPoster) Post(i *intent.Intent) error {
+func (k *k8sPoster) Post(i *intent.Intent, annos marker.Annotations) error {
 	nodeName := i.GetName()
-	err := k8sutil.PostMetadata(k.nodeclient, nodeName, i)
+	err := k8sutil.PostMetadata(k.nodeclient, nodeName, annotatedIntent{i, annos})
 	if err != nil {
 		return err
 	}
@@ -72,3 +616,69 @@ func (k *k8sPoster) Post(i *intent.Intent) error {
 	}).Debugf("posted intent")
 	return nil
 }
+
+// annotatedIntent is an Intent posted along with additional annotations.
+type annotatedIntent struct {
+	*intent.Intent
+	annos marker.Annotations
+}
+
+// GetAnnotations returns the Intent's annotations with the additional
+// annotations.
+func (a annotatedIntent) GetAnnotations() map[string]string {
+	annos := a.Intent.GetAnnotations()
+	for k, v := range a.annos {
+		annos[k] = v
+	}
+	return annos
+}
+
+// waitWorkloads waits for the workloads drained from the Node to be scheduled
+// and ready, giving up after the workload settle timeout or once the
+// controller is stopped.
+func (am *actionManager) waitWorkloads(nodeName string) {
+	log := am.log.WithField("node", nodeName)
+	var elapsed time.Duration
+	for {
+		pending, err := am.nodem.PendingWorkloads(nodeName)
+		if err != nil {
+			log.WithError(err).Warn("unable to check pending workloads")
+		} else if pending == 0 {
+			log.WithField("elapsed", elapsed).Info("workloads scheduled")
+			return
+		} else {
+			log.WithFields(logrus.Fields{
+				"pending": pending,
+				"elapsed": elapsed,
+			}).Info("waiting for workloads to be scheduled")
+		}
+		if elapsed >= am.workloadTimeout {
+			log.WithField("timeout", am.workloadTimeout).Warn("workloads not settled before timeout, continuing")
+			return
+		}
+		if am.ctx.Err() != nil {
+			log.Debug("stopped waiting for workloads")
+			return
+		}
+		wait := workloadPollInterval
+		if remaining := am.workloadTimeout - elapsed; remaining < wait {
+			wait = remaining
+		}
+		if am.sleep(wait) != nil {
+			log.Debug("stopped waiting for workloads")
+			return
+		}
+		elapsed += wait
+	}
+}
+
+// sleep waits for the duration to pass, returning early with an error once the
+// manager is stopped.
+func (am *actionManager) sleep(d time.Duration) error {
+	select {
+	case <-am.ctx.Done():
+		return am.ctx.Err()
+	case <-am.clock.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/kubectl/pkg/drain"
+)
+
+func testPod(name string, labels map[string]string) v1.Pod {
+	return v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func podNames(pods []v1.Pod) []string {
+	names := []string{}
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func TestDrainGrace(t *testing.T) {
+	config := Config{
+		DrainGraceSelector: "app in (postgres,mysql)",
+		DrainGracePeriod:   5 * time.Minute,
+	}
+	grace, err := config.drainGrace()
+	assert.NilError(t, err)
+	assert.Equal(t, grace.seconds, 300)
+
+	pods := []v1.Pod{
+		testPod("web", map[string]string{"app": "web"}),
+		testPod("postgres", map[string]string{"app": "postgres"}),
+		testPod("unlabeled", nil),
+		testPod("mysql", map[string]string{"app": "mysql", "tier": "db"}),
+	}
+	defaulted, overridden := grace.split(pods)
+	assert.DeepEqual(t, podNames(defaulted), []string{"web", "unlabeled"})
+	assert.DeepEqual(t, podNames(overridden), []string{"postgres", "mysql"})
+}
+
+func TestDrainGraceUnset(t *testing.T) {
+	grace, err := (&Config{}).drainGrace()
+	assert.NilError(t, err)
+	assert.Assert(t, grace == nil)
+
+	pods := []v1.Pod{testPod("web", map[string]string{"app": "web"})}
+	defaulted, overridden := grace.split(pods)
+	assert.Equal(t, len(defaulted), 1)
+	assert.Equal(t, len(overridden), 0)
+}
+
+func TestDrainGraceInvalid(t *testing.T) {
+	for _, config := range []Config{
+		{DrainGraceSelector: "app in (", DrainGracePeriod: time.Minute},
+		{DrainGraceSelector: "app=db", DrainGracePeriod: -time.Minute},
+	} {
+		_, err := config.drainGrace()
+		assert.Check(t, err != nil)
+	}
+}
+
+// testDrainCluster creates a Node, annotated as given, with replicated Pods
+// scheduled on it.
+func testDrainCluster(t *testing.T, annotations map[string]string) (*k8sNodeManager, *fake.Clientset) {
+	controller := true
+	owner := []v1meta.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web", Controller: &controller}}
+	objects := []runtime.Object{
+		&v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "node-a", Annotations: annotations}},
+	}
+	for _, name := range []string{"web-1", "web-2"} {
+		objects = append(objects, &v1.Pod{
+			ObjectMeta: v1meta.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "web"}, OwnerReferences: owner},
+			Spec:       v1.PodSpec{NodeName: "node-a"},
+		})
+	}
+	client := fake.NewSimpleClientset(objects...)
+	nm := newK8sNodeManager(testoutput.Logger(t, logging.New("node-manager")), client, nil, marker.DoNotDrainKey, nil)
+	// Evictions are blocked by the Pods' disruption budget.
+	nm.evict = func(_ *drain.Helper, pods []v1.Pod) error {
+		return errors.Errorf("cannot evict %d pods as it would violate the pods' disruption budget", len(pods))
+	}
+	return nm, client
+}
+
+func deletedPods(client *fake.Clientset) []string {
+	deleted := []string{}
+	for _, action := range client.Actions() {
+		if del, ok := action.(k8stesting.DeleteAction); ok && action.Matches("delete", "pods") {
+			deleted = append(deleted, del.GetName())
+		}
+	}
+	return deleted
+}
+
+func TestDrainPodDisruptionBudget(t *testing.T) {
+	nm, client := testDrainCluster(t, nil)
+	evicted, err := nm.Drain("node-a")
+	assert.Check(t, err != nil, "drain should be blocked by the disruption budget")
+	assert.Equal(t, evicted, 2)
+	assert.DeepEqual(t, deletedPods(client), []string{})
+
+	// The blocking budget is named once it's found.
+	budget := testBudget("default", "web", 0, map[string]string{"app": "web"})
+	_, err = client.PolicyV1beta1().PodDisruptionBudgets("default").Create(&budget)
+	assert.NilError(t, err)
+	_, err = nm.Drain("node-a")
+	assert.Error(t, err, "drain blocked by pod disruption budgets: default/web (pods default/web-1, default/web-2)")
+}
+
+func TestDrainBlockers(t *testing.T) {
+	budget := testBudget("default", "web", 0, map[string]string{"app": "web"})
+
+	nm, client := testDrainCluster(t, nil)
+	blocks, err := nm.DrainBlockers("node-a")
+	assert.NilError(t, err)
+	assert.Equal(t, len(blocks), 0)
+
+	_, err = client.PolicyV1beta1().PodDisruptionBudgets("default").Create(&budget)
+	assert.NilError(t, err)
+	blocks, err = nm.DrainBlockers("node-a")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, blocks, []budgetBlock{
+		{Budget: "default/web", Pods: []string{"default/web-1", "default/web-2"}},
+	})
+
+	// Forced drains aren't blocked.
+	nm, client = testDrainCluster(t, map[string]string{marker.ForceDrainKey: ""})
+	_, err = client.PolicyV1beta1().PodDisruptionBudgets("default").Create(&budget)
+	assert.NilError(t, err)
+	blocks, err = nm.DrainBlockers("node-a")
+	assert.NilError(t, err)
+	assert.Equal(t, len(blocks), 0)
+}
+
+func TestForceDrain(t *testing.T) {
+	nm, client := testDrainCluster(t, map[string]string{marker.ForceDrainKey: ""})
+	fake := record.NewFakeRecorder(10)
+	nm.events = &nodeRecorder{recorder: fake}
+	evicted, err := nm.Drain("node-a")
+	assert.NilError(t, err, "forced drain should bypass the disruption budget")
+	assert.Equal(t, evicted, 2)
+	assert.DeepEqual(t, deletedPods(client), []string{"web-1", "web-2"})
+	assert.DeepEqual(t, recordedEvents(fake), []string{
+		"Warning ForceDraining Force draining node, deleting 2 pods without respecting their disruption budgets",
+	})
+
+	pods, err := client.CoreV1().Pods("default").List(v1meta.ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(pods.Items), 0)
+
+	// The escalation applies to the one drain.
+	node, err := client.CoreV1().Nodes().Get("node-a", v1meta.GetOptions{})
+	assert.NilError(t, err)
+	_, forced := node.Annotations[marker.ForceDrainKey]
+	assert.Check(t, !forced, "force drain annotation should be removed")
+}
+
+func TestForceDrainWaitsForDeletion(t *testing.T) {
+	nm, client := testDrainCluster(t, map[string]string{marker.ForceDrainKey: ""})
+	nm.events = &nodeRecorder{recorder: record.NewFakeRecorder(10)}
+	nm.deleteTimeout = 10 * time.Millisecond
+	for _, name := range []string{"web-1", "web-2"} {
+		pod, err := client.CoreV1().Pods("default").Get(name, v1meta.GetOptions{})
+		assert.NilError(t, err)
+		var grace int64
+		pod.Spec.TerminationGracePeriodSeconds = &grace
+		_, err = client.CoreV1().Pods("default").Update(pod)
+		assert.NilError(t, err)
+	}
+	// The Pods linger after they're deleted, as though they're yet to
+	// terminate.
+	client.PrependReactor("delete", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	_, err := nm.Drain("node-a")
+	assert.ErrorContains(t, err, "2 deleted pods not gone")
+	node, err := client.CoreV1().Nodes().Get("node-a", v1meta.GetOptions{})
+	assert.NilError(t, err)
+	_, forced := node.Annotations[marker.ForceDrainKey]
+	assert.Check(t, forced, "force drain annotation should remain until the pods are gone")
+}
+
+// annotateDoNotDrain annotates the named Pod to be left in place when drained.
+func annotateDoNotDrain(t *testing.T, client *fake.Clientset, name string) {
+	pod, err := client.CoreV1().Pods("default").Get(name, v1meta.GetOptions{})
+	assert.NilError(t, err)
+	pod.Annotations = map[string]string{marker.DoNotDrainKey: "true"}
+	_, err = client.CoreV1().Pods("default").Update(pod)
+	assert.NilError(t, err)
+}
+
+func TestDrainDoNotDrain(t *testing.T) {
+	t.Run("evicted", func(t *testing.T) {
+		nm, client := testDrainCluster(t, nil)
+		annotateDoNotDrain(t, client, "web-2")
+		var evicted []string
+		nm.evict = func(_ *drain.Helper, pods []v1.Pod) error {
+			evicted = append(evicted, podNames(pods)...)
+			return nil
+		}
+		count, err := nm.Drain("node-a")
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+		assert.DeepEqual(t, evicted, []string{"web-1"})
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		nm, client := testDrainCluster(t, map[string]string{marker.ForceDrainKey: ""})
+		annotateDoNotDrain(t, client, "web-2")
+		count, err := nm.Drain("node-a")
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+		assert.DeepEqual(t, deletedPods(client), []string{"web-1"})
+	})
+
+	t.Run("false", func(t *testing.T) {
+		nm, client := testDrainCluster(t, nil)
+		pod, err := client.CoreV1().Pods("default").Get("web-2", v1meta.GetOptions{})
+		assert.NilError(t, err)
+		pod.Annotations = map[string]string{marker.DoNotDrainKey: "false"}
+		_, err = client.CoreV1().Pods("default").Update(pod)
+		assert.NilError(t, err)
+		var evicted []string
+		nm.evict = func(_ *drain.Helper, pods []v1.Pod) error {
+			evicted = append(evicted, podNames(pods)...)
+			return nil
+		}
+		count, err := nm.Drain("node-a")
+		assert.NilError(t, err)
+		assert.Equal(t, count, 2)
+		assert.DeepEqual(t, evicted, []string{"web-1", "web-2"})
+	})
+
+	t.Run("all", func(t *testing.T) {
+		nm, client := testDrainCluster(t, nil)
+		annotateDoNotDrain(t, client, "web-1")
+		annotateDoNotDrain(t, client, "web-2")
+		count, err := nm.Drain("node-a")
+		assert.NilError(t, err, "nothing should be left to evict")
+		assert.Equal(t, count, 0)
+
+		// Pods left in place aren't blocked by their budget.
+		budget := testBudget("default", "web", 0, map[string]string{"app": "web"})
+		_, err = client.PolicyV1beta1().PodDisruptionBudgets("default").Create(&budget)
+		assert.NilError(t, err)
+		blocks, err := nm.DrainBlockers("node-a")
+		assert.NilError(t, err)
+		assert.Equal(t, len(blocks), 0)
+	})
+}
+
+func TestPostWithAnnotations(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        "node-a",
+		Annotations: map[string]string{marker.UpdateCompletedAtKey: "2020-07-09T00:00:00Z"},
+	}})
+	poster := &k8sPoster{testoutput.Logger(t, logging.New("poster")), client.CoreV1().Nodes()}
+
+	in := intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()
+	assert.NilError(t, poster.Post(in, marker.Annotations{
+		marker.UpdateStartedAtKey:   "2020-07-10T00:00:00Z",
+		marker.UpdateCompletedAtKey: "",
+	}))
+
+	var updates int
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	assert.Equal(t, updates, 1, "intent and annotations should be posted together")
+	node, err := client.CoreV1().Nodes().Get("node-a", v1meta.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, node.Annotations[marker.NodeActionWanted], marker.NodeActionPrepareUpdate)
+	assert.Equal(t, node.Annotations[marker.UpdateStartedAtKey], "2020-07-10T00:00:00Z")
+	assert.Equal(t, node.Annotations[marker.UpdateCompletedAtKey], "")
+}
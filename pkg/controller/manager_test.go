@@ -1,34 +1,67 @@
 package controller
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/health"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
 )
 
 type testingPoster struct {
-	calledIntents []intent.Intent
-	fn            func(i *intent.Intent) error
+	calledIntents     []intent.Intent
+	calledAnnotations []marker.Annotations
+	calledMarkers     []marker.Container
+	markedNodes       []string
+	fn                func(i *intent.Intent) error
 }
 
-func (p *testingPoster) Post(i *intent.Intent) error {
+func (p *testingPoster) Post(i *intent.Intent, annos marker.Annotations) error {
 	p.calledIntents = append(p.calledIntents, *i)
+	p.calledAnnotations = append(p.calledAnnotations, annos)
 	if p.fn != nil {
 		return p.fn(i)
 	}
 	return nil
 }
 
+func (p *testingPoster) PostMarkers(nodeName string, markers marker.Container) error {
+	p.calledMarkers = append(p.calledMarkers, markers)
+	p.markedNodes = append(p.markedNodes, nodeName)
+	return nil
+}
+
 type testingNodeManager struct {
 	CordonFn   func(string) error
 	UncordonFn func(string) error
-	DrainFn    func(string) error
+	DrainFn    func(string) (int, error)
+	ReadyFn    func(string) (bool, error)
+	// ReadinessFn, when set, reports the Node's full readiness in place of
+	// ReadyFn.
+	ReadinessFn func(string) (nodeReadiness, error)
+	CapacityFn  func(string, []*v1.Node) (bool, v1.ResourceList, error)
+	BootIDFn    func(string) (string, error)
+	PendingFn   func(string) (int, error)
+	BlockersFn  func(string) ([]budgetBlock, error)
 }
 
 func trackFn(v *bool) func(string) error {
@@ -38,6 +71,13 @@ func trackFn(v *bool) func(string) error {
 	}
 }
 
+func trackDrainFn(v *bool, evicted int) func(string) (int, error) {
+	return func(_ string) (int, error) {
+		*v = true
+		return evicted, nil
+	}
+}
+
 func (nm *testingNodeManager) Cordon(n string) error {
 	if nm.CordonFn != nil {
 		return nm.CordonFn(n)
@@ -52,27 +92,97 @@ func (nm *testingNodeManager) Uncordon(n string) error {
 	return nil
 }
 
-func (nm *testingNodeManager) Drain(n string) error {
+func (nm *testingNodeManager) Drain(n string) (int, error) {
 	if nm.DrainFn != nil {
 		return nm.DrainFn(n)
 	}
-	return nil
+	return 0, nil
+}
+
+func (nm *testingNodeManager) Ready(n string) (nodeReadiness, error) {
+	if nm.ReadinessFn != nil {
+		return nm.ReadinessFn(n)
+	}
+	if nm.ReadyFn != nil {
+		ready, err := nm.ReadyFn(n)
+		if !ready {
+			return nodeReadiness{Status: v1.ConditionFalse}, err
+		}
+		return nodeReadiness{Status: v1.ConditionTrue}, err
+	}
+	return nodeReadiness{Status: v1.ConditionTrue}, nil
+}
+
+func (nm *testingNodeManager) BootID(n string) (string, error) {
+	if nm.BootIDFn != nil {
+		return nm.BootIDFn(n)
+	}
+	return "", nil
+}
+
+func (nm *testingNodeManager) Capacity(n string, others []*v1.Node) (bool, v1.ResourceList, error) {
+	if nm.CapacityFn != nil {
+		return nm.CapacityFn(n, others)
+	}
+	return true, nil, nil
+}
+
+func (nm *testingNodeManager) DrainBlockers(n string) ([]budgetBlock, error) {
+	if nm.BlockersFn != nil {
+		return nm.BlockersFn(n)
+	}
+	return nil, nil
+}
+
+func (nm *testingNodeManager) PendingWorkloads(n string) (int, error) {
+	if nm.PendingFn != nil {
+		return nm.PendingFn(n)
+	}
+	return 0, nil
 }
 
 type testManagerHooks struct {
 	Poster      *testingPoster
 	NodeManager *testingNodeManager
+	Clock       *testClock
+}
+
+// testClock is a fake clock that records the durations slept.
+type testClock struct {
+	*clock.FakeClock
+	Slept []time.Duration
+}
+
+func newTestClock() *testClock {
+	return &testClock{FakeClock: clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC))}
+}
+
+// After records the wait as slept and passes it at once, the manager's waits
+// are run inline with its handling.
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.Slept = append(c.Slept, d)
+	ch := c.FakeClock.After(d)
+	c.FakeClock.Step(d)
+	return ch
 }
 
 func testManager(t *testing.T) (*actionManager, *testManagerHooks) {
-	m := newManager(testoutput.Logger(t, logging.New("manager")), nil, "test-node")
+	m, err := newManager(testoutput.Logger(t, logging.New("manager")), nil, "test-node", Config{})
+	assert.NilError(t, err)
 
 	hooks := &testManagerHooks{
 		Poster:      &testingPoster{},
 		NodeManager: &testingNodeManager{},
+		Clock:       newTestClock(),
 	}
 	m.poster = hooks.Poster
+	m.markers = hooks.Poster
 	m.nodem = hooks.NodeManager
+	m.clock = hooks.Clock
+	m.gate.clock = hooks.Clock
+	m.unknown.clock = hooks.Clock
+	m.errored.clock = hooks.Clock
+	m.skipped.clock = hooks.Clock
 	return m, hooks
 }
 
@@ -106,6 +216,27 @@ func TestManagerIntentForSimple(t *testing.T) {
 	}
 }
 
+func TestManagerIntentForNotReady(t *testing.T) {
+	m, _ := testManager(t)
+	node := testNode(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()), time.Now())
+	withReady := func(status v1.ConditionStatus) *v1.Node {
+		ready := node.DeepCopy()
+		ready.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}
+		return ready
+	}
+
+	assert.Check(t, m.intentFor(withReady(v1.ConditionFalse)) == nil, "not ready node should be skipped")
+	assert.Check(t, m.intentFor(withReady(v1.ConditionUnknown)) == nil, "unreachable node should be skipped")
+
+	recovered := m.intentFor(withReady(v1.ConditionTrue))
+	assert.Assert(t, recovered != nil, "recovered node should be eligible")
+	assert.Equal(t, recovered.Wanted, marker.NodeActionPrepareUpdate)
+	assert.Check(t, !m.notReady.Release("node-a"), "recovered node should no longer be held")
+
+	// Nodes without the condition aren't held back.
+	assert.Check(t, m.intentFor(node) != nil)
+}
+
 func TestManagerHandleCacheFilter(t *testing.T) {
 	m, _ := testManager(t)
 	nodes := []string{"node-a", "node-b"}
@@ -120,14 +251,30 @@ func TestManagerHandleCacheFilter(t *testing.T) {
 		intents.UpdateSuccess(intents.WithNodeName(nodes[0])),
 		intents.UpdateSuccess(intents.WithNodeName(nodes[1])),
 	}
-	m.inputs = make(chan *intent.Intent, len(eventInputs))
-	defer close(m.inputs)
+	before := testutil.ToFloat64(metrics.ControllerDuplicateIntents)
 
 	for _, eventInput := range eventInputs {
 		m.handle(eventInput)
 	}
 
-	assert.Equal(t, len(m.inputs), 4)
+	assert.Equal(t, testutil.ToFloat64(metrics.ControllerDuplicateIntents), before+2)
+	// Each Node is queued once, with its latest Intent.
+	assert.Equal(t, m.queue.Len(), 2)
+	for _, node := range nodes {
+		assert.Equal(t, m.queue.latest[node].Active, intents.UpdateSuccess().Active)
+	}
+}
+
+func TestManagerHandleDuplicateMetric(t *testing.T) {
+	m, _ := testManager(t)
+	before := testutil.ToFloat64(metrics.ControllerDuplicateIntents)
+
+	m.handle(intents.UpdatePrepared(intents.WithNodeName("node-a")))
+	assert.Equal(t, testutil.ToFloat64(metrics.ControllerDuplicateIntents), before)
+	m.handle(intents.UpdatePrepared(intents.WithNodeName("node-a")))
+	m.handle(intents.UpdatePrepared(intents.WithNodeName("node-a")))
+	assert.Equal(t, testutil.ToFloat64(metrics.ControllerDuplicateIntents), before+2)
+	assert.Equal(t, m.queue.Len(), 1)
 }
 
 func TestManagerIntentForTargeted(t *testing.T) {
@@ -209,7 +356,7 @@ func TestTakeAction(t *testing.T) {
 			cordoned   = false
 			drained    = false
 		)
-		hooks.NodeManager.DrainFn = trackFn(&drained)
+		hooks.NodeManager.DrainFn = trackDrainFn(&drained, 1)
 		hooks.NodeManager.CordonFn = trackFn(&cordoned)
 		hooks.NodeManager.UncordonFn = trackFn(&uncordoned)
 		in := intents.UpdatePerformed()
@@ -230,7 +377,7 @@ func TestTakeAction(t *testing.T) {
 			cordoned   = false
 			drained    = false
 		)
-		hooks.NodeManager.DrainFn = trackFn(&drained)
+		hooks.NodeManager.DrainFn = trackDrainFn(&drained, 1)
 		hooks.NodeManager.CordonFn = trackFn(&cordoned)
 		hooks.NodeManager.UncordonFn = trackFn(&uncordoned)
 		in := intents.Unknown()
@@ -243,6 +390,250 @@ func TestTakeAction(t *testing.T) {
 		assert.Check(t, drained == false)
 		assert.Check(t, uncordoned == false)
 	})
+
+	t.Run("success-after-drain", func(t *testing.T) {
+		for _, evicted := range []int{0, 3} {
+			t.Run(fmt.Sprintf("evicted(%d)", evicted), func(t *testing.T) {
+				m, hooks := testManager(t)
+				drained := false
+				hooks.NodeManager.DrainFn = trackDrainFn(&drained, evicted)
+				checks := 0
+				hooks.NodeManager.PendingFn = func(_ string) (int, error) {
+					checks++
+					return 2 - checks, nil
+				}
+				pin := m.intentFor(intents.UpdatePerformed(intents.WithNodeName(intents.NodeName)))
+				assert.NilError(t, m.takeAction(pin))
+				assert.Check(t, drained)
+
+				err := m.takeAction(intents.UpdateSuccess(intents.WithNodeName(intents.NodeName)))
+				assert.NilError(t, err)
+				if evicted == 0 {
+					// Nothing was evicted, so there's nothing to wait on.
+					assert.Equal(t, checks, 0)
+					assert.Check(t, len(hooks.Clock.Slept) == 0)
+				} else {
+					assert.Equal(t, checks, 2)
+					assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{workloadPollInterval})
+				}
+			})
+		}
+	})
+
+	t.Run("success-waits-for-ready", func(t *testing.T) {
+		m, hooks := testManager(t)
+		checks := 0
+		hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+			checks++
+			return checks == 3, nil
+		}
+		err := m.takeAction(intents.UpdateSuccess())
+		assert.NilError(t, err)
+		assert.Equal(t, checks, 3)
+		assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{
+			defaultHealthCheckInterval, defaultHealthCheckInterval,
+		})
+	})
+
+	t.Run("health-check-timeout", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.healthCheckTimeout = 50 * time.Second
+		m.healthCheckInterval = 20 * time.Second
+		hooks.NodeManager.ReadinessFn = func(_ string) (nodeReadiness, error) {
+			return nodeReadiness{
+				Status:  v1.ConditionFalse,
+				Reason:  "KubeletNotReady",
+				Message: "container runtime network not ready",
+			}, nil
+		}
+		err := m.waitReady("node-a")
+		assert.Error(t, err, "node not ready after 50s, last observed ready condition False, reason KubeletNotReady: container runtime network not ready")
+		assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{
+			20 * time.Second, 20 * time.Second, 10 * time.Second,
+		})
+
+		hooks.NodeManager.ReadinessFn = func(_ string) (nodeReadiness, error) {
+			return nodeReadiness{}, errors.New("apiserver unavailable")
+		}
+		err = m.waitReady("node-a")
+		assert.ErrorContains(t, err, "last observed unable to check readiness: apiserver unavailable")
+	})
+}
+
+func TestManagerHealth(t *testing.T) {
+	m, _ := testManager(t)
+	clk := clock.NewFakeClock(time.Now())
+	m.clock = clk
+	m.health = health.NewStatus(clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.health.Work("manager", managerStale, m.Run)(ctx) }()
+	defer func() {
+		cancel()
+		assert.NilError(t, <-done)
+	}()
+
+	waitIdle := func() {
+		for attempt := 0; !clk.HasWaiters(); attempt++ {
+			assert.Assert(t, attempt < 100, "manager should report while idle")
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A manager stuck handling an intent goes stale.
+	waitIdle()
+	atomic.StoreInt32(&m.processing, 1)
+	clk.Step(managerStale + managerIdleInterval)
+	assert.ErrorContains(t, m.health.Ready(), "manager has not succeeded")
+
+	// An idle manager is kept from going stale.
+	atomic.StoreInt32(&m.processing, 0)
+	waitIdle()
+	clk.Step(managerIdleInterval)
+	for attempt := 0; m.health.Ready() != nil; attempt++ {
+		assert.Assert(t, attempt < 100, "idle manager should be ready")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCheckNodeStopped(t *testing.T) {
+	m, hooks := testManager(t)
+	m.verifyDelay = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	m.ctx = ctx
+	checks := 0
+	hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+		checks++
+		if checks == 2 {
+			cancel()
+		}
+		return true, nil
+	}
+	err := m.checkNode("node-a")
+	assert.ErrorContains(t, err, "stopped verifying node readiness")
+	assert.Equal(t, checks, 2)
+	assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{defaultHealthCheckInterval})
+
+	// Waiting for a Node that isn't ready stops too.
+	m.ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+		return false, nil
+	}
+	err = m.waitReady("node-a")
+	assert.ErrorContains(t, err, "stopped waiting for node to be ready")
+}
+
+func TestNodeReadiness(t *testing.T) {
+	node := &v1.Node{}
+	assert.Equal(t, readinessOf(node).String(), "no ready condition reported")
+	assert.Check(t, !nodeReady(node))
+
+	node.Status.Conditions = []v1.NodeCondition{
+		{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+		{Type: v1.NodeReady, Status: v1.ConditionUnknown, Reason: "NodeStatusUnknown", Message: "Kubelet stopped posting node status."},
+	}
+	assert.Equal(t, readinessOf(node).String(), "ready condition Unknown, reason NodeStatusUnknown: Kubelet stopped posting node status.")
+	assert.Check(t, !nodeReady(node))
+
+	node.Status.Conditions[1] = v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue}
+	assert.Check(t, nodeReady(node))
+}
+
+func TestWaitWorkloads(t *testing.T) {
+	t.Run("settles", func(t *testing.T) {
+		m, hooks := testManager(t)
+		checks := 0
+		hooks.NodeManager.PendingFn = func(_ string) (int, error) {
+			checks++
+			if checks == 2 {
+				return 0, errors.New("apiserver unavailable")
+			}
+			return 3 - checks, nil
+		}
+		m.waitWorkloads("node-a")
+		assert.Equal(t, checks, 3)
+		assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{workloadPollInterval, workloadPollInterval})
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.workloadTimeout = 12 * time.Second
+		hooks.NodeManager.PendingFn = func(_ string) (int, error) {
+			return 1, nil
+		}
+		m.waitWorkloads("node-a")
+		assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{
+			workloadPollInterval, workloadPollInterval, 2 * time.Second,
+		})
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		m, hooks := testManager(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		m.ctx = ctx
+		checks := 0
+		hooks.NodeManager.PendingFn = func(_ string) (int, error) {
+			checks++
+			if checks == 2 {
+				cancel()
+			}
+			return 1, nil
+		}
+		m.waitWorkloads("node-a")
+		assert.Equal(t, checks, 2)
+		assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{workloadPollInterval})
+	})
+}
+
+func TestVerifyReady(t *testing.T) {
+	readiness := func(results ...bool) func(string) (bool, error) {
+		return func(_ string) (bool, error) {
+			ready := results[0]
+			if len(results) > 1 {
+				results = results[1:]
+			}
+			return ready, nil
+		}
+	}
+
+	t.Run("stays-ready", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.verifyDelay = 25 * time.Second
+		hooks.NodeManager.ReadyFn = readiness(true)
+		assert.NilError(t, m.checkNode("node-a"))
+		assert.DeepEqual(t, hooks.Clock.Slept, []time.Duration{
+			defaultHealthCheckInterval, defaultHealthCheckInterval, 5 * time.Second,
+		})
+	})
+
+	t.Run("flaps", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.verifyDelay = time.Minute
+		m.batch = newBatchGate(1, 1)
+		m.batch.Join("node-a")
+		// The Node passes its health check, then flaps back to not ready.
+		hooks.NodeManager.ReadyFn = readiness(true, true, false, true)
+		err := m.checkNode("node-a")
+		assert.ErrorContains(t, err, "not ready 20s after passing")
+
+		// The flapping Node isn't counted as healthy.
+		hooks.NodeManager.ReadyFn = readiness(true, false)
+		assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+		assert.Check(t, !m.batch.Open("node-b"), "flapping node should not release the next batch")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m, hooks := testManager(t)
+		checks := 0
+		hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+			checks++
+			return true, nil
+		}
+		assert.NilError(t, m.checkNode("node-a"))
+		assert.Equal(t, checks, 1)
+		assert.Equal(t, len(hooks.Clock.Slept), 0)
+	})
 }
 
 func TestMakePolicyCheck(t *testing.T) {
@@ -293,3 +684,484 @@ func TestSuccessfulUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestKeepCordoned(t *testing.T) {
+	m, hooks := testManager(t)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	m.SetStoreProvider(&testStorer{store})
+
+	kept := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:   "kept",
+		Labels: map[string]string{marker.KeepCordonedKey: ""},
+	}}
+	normal := &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "normal"}}
+	assert.NilError(t, store.Add(kept))
+	assert.NilError(t, store.Add(normal))
+
+	uncordoned := map[string]bool{}
+	hooks.NodeManager.UncordonFn = func(n string) error {
+		uncordoned[n] = true
+		return nil
+	}
+
+	for _, name := range []string{"kept", "normal"} {
+		err := m.takeAction(intents.UpdateSuccess(intents.WithNodeName(name)))
+		assert.NilError(t, err)
+	}
+	assert.Check(t, !uncordoned["kept"])
+	assert.Check(t, uncordoned["normal"])
+	// The kept Node's update is still completed.
+	assert.Equal(t, len(hooks.Poster.calledIntents), 2)
+}
+
+func TestCordonLimit(t *testing.T) {
+	m, hooks := testManager(t)
+	m.maxCordoned = 2
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	m.SetStoreProvider(&testStorer{store})
+
+	// A Node left cordoned while it recovers counts toward the limit, a Node
+	// kept cordoned after its update doesn't.
+	recovering := &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "recovering"}}
+	recovering.Spec.Unschedulable = true
+	kept := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:   "kept",
+		Labels: map[string]string{marker.KeepCordonedKey: ""},
+	}}
+	kept.Spec.Unschedulable = true
+	assert.NilError(t, store.Add(recovering))
+	assert.NilError(t, store.Add(kept))
+
+	var cordoned []string
+	hooks.NodeManager.CordonFn = func(n string) error {
+		cordoned = append(cordoned, n)
+		return nil
+	}
+	performed := func(nodeName string) *intent.Intent {
+		return m.intentFor(intents.UpdatePerformed(intents.WithNodeName(nodeName)))
+	}
+
+	assert.NilError(t, m.takeAction(performed("node-a")))
+	assert.DeepEqual(t, cordoned, []string{"node-a"})
+
+	err := m.takeAction(performed("node-b"))
+	assert.Equal(t, errors.Cause(err), errCordonLimit)
+	assert.DeepEqual(t, cordoned, []string{"node-a"})
+	assert.Equal(t, len(hooks.Poster.calledIntents), 1, "update should be deferred")
+
+	// Nodes already cordoned are within the limit.
+	assert.NilError(t, m.takeAction(performed("node-a")))
+	assert.DeepEqual(t, cordoned, []string{"node-a", "node-a"})
+
+	// The deferred Node is cordoned once another is uncordoned.
+	recovering.Spec.Unschedulable = false
+	assert.NilError(t, store.Update(recovering))
+	assert.NilError(t, m.takeAction(performed("node-b")))
+	assert.DeepEqual(t, cordoned, []string{"node-a", "node-a", "node-b"})
+}
+
+type testStorer struct {
+	store cache.Store
+}
+
+func (s *testStorer) GetStore() cache.Store {
+	return s.store
+}
+
+func TestDrainFailure(t *testing.T) {
+	drainFailure := func(_ string) (int, error) {
+		return 1, errors.New("drain failed")
+	}
+	performed := func(nodeName string) *intent.Intent {
+		return intents.UpdatePerformed(intents.WithNodeName(nodeName))
+	}
+
+	t.Run("proceed", func(t *testing.T) {
+		m, hooks := testManager(t)
+		hooks.NodeManager.DrainFn = drainFailure
+
+		err := m.takeAction(m.intentFor(performed("node-a")))
+		assert.NilError(t, err)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 1)
+		assert.Check(t, hooks.Poster.calledIntents[0].Intrusive())
+	})
+
+	t.Run("halt", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.drainFailure = DrainFailureHalt
+		hooks.NodeManager.DrainFn = drainFailure
+		uncordoned := false
+		hooks.NodeManager.UncordonFn = trackFn(&uncordoned)
+
+		err := m.takeAction(m.intentFor(performed("node-a")))
+		assert.Check(t, err != nil)
+		assert.Check(t, !uncordoned, "halted node should stay cordoned")
+		assert.Equal(t, len(hooks.Poster.calledIntents), 0)
+
+		// The rollout is paused for other Nodes.
+		cordoned := false
+		hooks.NodeManager.CordonFn = trackFn(&cordoned)
+		err = m.takeAction(m.intentFor(performed("node-b")))
+		assert.Equal(t, err, errRolloutHalted)
+		assert.Check(t, !cordoned)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 0)
+
+		// Nodes completing their update are still returned to service.
+		err = m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-c")))
+		assert.NilError(t, err)
+		assert.Check(t, uncordoned)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.drainFailure = DrainFailureSkip
+		hooks.NodeManager.DrainFn = drainFailure
+		uncordoned := false
+		hooks.NodeManager.UncordonFn = trackFn(&uncordoned)
+
+		err := m.takeAction(m.intentFor(performed("node-a")))
+		assert.Check(t, err != nil)
+		assert.Check(t, uncordoned)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 1)
+		assert.Check(t, !hooks.Poster.calledIntents[0].Intrusive(), "skipped node should be reset")
+
+		// The skipped Node isn't started on its update again until it's
+		// been held back for a while.
+		waiting := intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable())
+		assert.Check(t, m.intentFor(waiting) == nil, "skipped node should be held back")
+		hooks.Clock.Step(skippedRetryDelay)
+		assert.Check(t, m.intentFor(waiting) != nil, "skipped node should be retried")
+
+		// The rollout continues with other Nodes.
+		cordoned := false
+		hooks.NodeManager.CordonFn = trackFn(&cordoned)
+		hooks.NodeManager.DrainFn = nil
+		err = m.takeAction(m.intentFor(performed("node-b")))
+		assert.NilError(t, err)
+		assert.Check(t, cordoned)
+		assert.Equal(t, len(hooks.Poster.calledIntents), 2)
+	})
+}
+
+func TestDrainFailureActionConfig(t *testing.T) {
+	action, err := (&Config{}).drainFailureAction()
+	assert.NilError(t, err)
+	assert.Equal(t, action, DrainFailureProceed)
+
+	_, err = (&Config{DrainFailureAction: "explode"}).drainFailureAction()
+	assert.Check(t, err != nil)
+}
+
+func TestManagerHold(t *testing.T) {
+	m, _ := testManager(t)
+
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name:        "held",
+		Labels:      map[string]string{marker.HoldKey: ""},
+		Annotations: intents.UpdatePrepared(intents.WithNodeName("held")).GetAnnotations(),
+	}}
+	assert.Assert(t, m.intentFor(node) != nil, "node should otherwise advance")
+
+	m.handle(node)
+	assert.Equal(t, m.queue.Len(), 0, "held node should not advance")
+
+	delete(node.Labels, marker.HoldKey)
+	m.handle(node)
+	assert.Equal(t, m.queue.Len(), 1, "node should advance once released")
+}
+
+func TestCordonDuration(t *testing.T) {
+	// cordonDuration returns the count and sum of the Node's observed cordon
+	// durations.
+	cordonDuration := func(nodeName string) (uint64, float64) {
+		families, err := metrics.Registry.Gather()
+		assert.NilError(t, err)
+		for _, family := range families {
+			if family.GetName() != "brupop_controller_cordon_duration_seconds" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				for _, label := range m.GetLabel() {
+					if label.GetName() == metrics.NodeLabel && label.GetValue() == nodeName {
+						return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+					}
+				}
+			}
+		}
+		return 0, 0
+	}
+
+	m, hooks := testManager(t)
+	nodeName := "cordon-duration"
+	pin := m.intentFor(intents.UpdatePerformed(intents.WithNodeName(nodeName)))
+	assert.NilError(t, m.takeAction(pin))
+	count, _ := cordonDuration(nodeName)
+	assert.Equal(t, count, uint64(0))
+
+	hooks.Clock.Step(10 * time.Minute)
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName(nodeName))))
+	count, sum := cordonDuration(nodeName)
+	assert.Equal(t, count, uint64(1))
+	assert.Equal(t, sum, (10 * time.Minute).Seconds())
+}
+
+func TestUpdateMetrics(t *testing.T) {
+	failures := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.UpdateFailures.WithLabelValues(reason))
+	}
+
+	t.Run("completed", func(t *testing.T) {
+		m, _ := testManager(t)
+		before := testutil.ToFloat64(metrics.UpdatesCompleted)
+		assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("metrics-completed"))))
+		assert.Equal(t, testutil.ToFloat64(metrics.UpdatesCompleted), before+1)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		m, hooks := testManager(t)
+		hooks.NodeManager.ReadyFn = func(string) (bool, error) {
+			return false, errors.New("node unreachable")
+		}
+		before := failures(failedHealth)
+		assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("metrics-unhealthy"))))
+		assert.Equal(t, failures(failedHealth), before+1)
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.drainFailure = DrainFailureSkip
+		hooks.NodeManager.DrainFn = func(string) (int, error) {
+			return 0, errors.New("eviction blocked")
+		}
+		nodeName := "metrics-drain"
+		before := failures(failedDrain)
+		pin := m.intentFor(intents.UpdatePerformed(intents.WithNodeName(nodeName)))
+		assert.Check(t, m.takeAction(pin) != nil)
+		assert.Equal(t, failures(failedDrain), before+1)
+		assert.Equal(t, testutil.ToFloat64(metrics.DrainFailures.WithLabelValues(nodeName)), float64(1))
+	})
+}
+
+func TestIntentDuration(t *testing.T) {
+	// intentDuration returns the count and sum of the observed durations of
+	// the action.
+	intentDuration := func(action marker.NodeAction) (uint64, float64) {
+		families, err := metrics.Registry.Gather()
+		assert.NilError(t, err)
+		for _, family := range families {
+			if family.GetName() != "brupop_controller_intent_duration_seconds" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				for _, label := range m.GetLabel() {
+					if label.GetName() == metrics.IntentLabel && label.GetValue() == string(action) {
+						return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+					}
+				}
+			}
+		}
+		return 0, 0
+	}
+
+	m, hooks := testManager(t)
+	nodeName := "intent-duration"
+	countBefore, sumBefore := intentDuration(marker.NodeActionPrepareUpdate)
+
+	pin := m.intentFor(intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()))
+	assert.Equal(t, pin.Wanted, marker.NodeActionPrepareUpdate)
+	assert.NilError(t, m.takeAction(pin))
+	// Posting the same action again doesn't restart its time.
+	hooks.Clock.Step(2 * time.Minute)
+	assert.NilError(t, m.takeAction(pin))
+	count, _ := intentDuration(marker.NodeActionPrepareUpdate)
+	assert.Equal(t, count, countBefore)
+
+	hooks.Clock.Step(3 * time.Minute)
+	pin = m.intentFor(intents.UpdatePrepared(intents.WithNodeName(nodeName)))
+	assert.Equal(t, pin.Wanted, marker.NodeActionPerformUpdate)
+	assert.NilError(t, m.takeAction(pin))
+	count, sum := intentDuration(marker.NodeActionPrepareUpdate)
+	assert.Equal(t, count, countBefore+1)
+	assert.Equal(t, sum-sumBefore, (5 * time.Minute).Seconds())
+}
+
+func TestLastUpdated(t *testing.T) {
+	m, hooks := testManager(t)
+	nodeName := "last-updated"
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName(nodeName))))
+
+	assert.Equal(t, len(hooks.Poster.calledMarkers), 1)
+	updated := hooks.Poster.calledMarkers[0].GetAnnotations()[marker.LastUpdatedKey]
+	assert.Equal(t, updated, hooks.Clock.Now().UTC().Format(time.RFC3339))
+
+	// Updates that don't complete aren't recorded.
+	assert.NilError(t, m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName(nodeName)))))
+	assert.Equal(t, len(hooks.Poster.calledMarkers), 1)
+
+	// The recorded time is exposed once observed on the Node.
+	node := testNode(intents.Stabilized(intents.WithNodeName(nodeName)), time.Now())
+	node.Annotations[marker.LastUpdatedKey] = updated
+	m.observeLastUpdated(node)
+	assert.Equal(t, testutil.ToFloat64(metrics.LastUpdated.WithLabelValues(nodeName)), float64(hooks.Clock.Now().Unix()))
+
+	statuses := nodeStatuses([]interface{}{node}, time.Now())
+	assert.Equal(t, statuses[0].LastUpdated, updated)
+}
+
+func TestUpdateTimes(t *testing.T) {
+	m, hooks := testManager(t)
+	nodeName := "update-times"
+	started := hooks.Clock.Now().UTC().Format(time.RFC3339)
+
+	begin := intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()).SetBeginUpdate()
+	assert.NilError(t, m.takeAction(begin))
+	assert.DeepEqual(t, hooks.Poster.calledAnnotations[0], marker.Annotations{
+		marker.UpdateStartedAtKey:   started,
+		marker.UpdateCompletedAtKey: "",
+	})
+
+	// Intermediate steps aren't stamped.
+	assert.NilError(t, m.takeAction(m.intentFor(intents.UpdatePrepared(intents.WithNodeName(nodeName)))))
+	assert.Check(t, hooks.Poster.calledAnnotations[1] == nil)
+
+	hooks.Clock.Step(10 * time.Minute)
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName(nodeName))))
+	assert.DeepEqual(t, hooks.Poster.calledAnnotations[2], marker.Annotations{
+		marker.UpdateCompletedAtKey: hooks.Clock.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func TestManagerReport(t *testing.T) {
+	m, hooks := testManager(t)
+	var buf bytes.Buffer
+	m.reporter = report.New(&buf, "controller")
+	m.drainFailure = DrainFailureSkip
+
+	assert.NilError(t, m.takeAction(m.intentFor(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()))))
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	m.intentFor(intents.Unknown(intents.WithNodeName("node-b")))
+	hooks.NodeManager.DrainFn = func(string) (int, error) {
+		return 1, errors.New("drain failed")
+	}
+	assert.Check(t, m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-c")))) != nil)
+
+	var records []report.Record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec report.Record
+		assert.NilError(t, dec.Decode(&rec))
+		assert.Equal(t, rec.Component, "controller")
+		records = append(records, rec)
+	}
+	assert.Equal(t, len(records), 4)
+
+	assert.Equal(t, records[0].Event, report.Begin)
+	assert.Equal(t, records[0].Node, "node-a")
+	assert.Equal(t, records[0].Wanted, marker.NodeActionPrepareUpdate)
+
+	// The success is reported with the update's final step rather than the
+	// reset that follows it.
+	assert.Equal(t, records[1].Event, report.Success)
+	assert.Equal(t, records[1].Node, "node-a")
+	assert.Equal(t, records[1].Active, marker.NodeActionRebootUpdate)
+
+	assert.Equal(t, records[2].Event, report.Stuck)
+	assert.Equal(t, records[2].Node, "node-b")
+
+	assert.Equal(t, records[3].Event, report.Failure)
+	assert.Equal(t, records[3].Node, "node-c")
+	assert.Equal(t, records[3].Error, "drain failed")
+}
+
+func TestManagerHistory(t *testing.T) {
+	m, _ := testManager(t)
+	m.history = report.NewRing(2)
+	m.reporter = report.New(nil, "controller")
+	m.reporter.Keep(m.history)
+
+	assert.NilError(t, m.takeAction(m.intentFor(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()))))
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	m.intentFor(intents.Unknown(intents.WithNodeName("node-b")))
+
+	// Only the most recent events are kept.
+	recs := m.history.Records()
+	assert.Equal(t, len(recs), 2)
+	assert.Equal(t, recs[0].Event, report.Success)
+	assert.Equal(t, recs[0].Node, "node-a")
+	assert.Equal(t, recs[1].Event, report.Stuck)
+	assert.Equal(t, recs[1].Node, "node-b")
+}
+
+func TestVerifyBootID(t *testing.T) {
+	cases := []struct {
+		name    string
+		after   string
+		success bool
+	}{
+		{name: "changed", after: "boot-2", success: true},
+		{name: "unchanged", after: "boot-1", success: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, hooks := testManager(t)
+			m.verifyBootID = true
+			m.history = report.NewRing(4)
+			m.reporter = report.New(nil, "controller")
+			m.reporter.Keep(m.history)
+			bootID := "boot-1"
+			hooks.NodeManager.BootIDFn = func(string) (string, error) {
+				return bootID, nil
+			}
+
+			assert.NilError(t, m.takeAction(intents.PendingRebootUpdate(intents.WithNodeName("node-a"))))
+			assert.Equal(t, m.bootIDs["node-a"], "boot-1")
+
+			bootID = tc.after
+			assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+			_, recorded := m.bootIDs["node-a"]
+			assert.Check(t, !recorded, "boot id should only be verified once")
+
+			recs := m.history.Records()
+			assert.Equal(t, len(recs), 1)
+			if tc.success {
+				assert.Equal(t, recs[0].Event, report.Success)
+				assert.Equal(t, len(hooks.Poster.calledMarkers), 1, "successful update should be recorded")
+			} else {
+				assert.Equal(t, recs[0].Event, report.Failure)
+				assert.Check(t, strings.Contains(recs[0].Error, "did not reboot"))
+				assert.Equal(t, len(hooks.Poster.calledMarkers), 0, "failed reboot should not be recorded as updated")
+			}
+			// Either way, the Node is reset to stabilize.
+			last := hooks.Poster.calledIntents[len(hooks.Poster.calledIntents)-1]
+			assert.Equal(t, last.Wanted, marker.NodeActionStabilize)
+		})
+	}
+}
+
+func TestVerifyBootIDDisabled(t *testing.T) {
+	m, hooks := testManager(t)
+	hooks.NodeManager.BootIDFn = func(string) (string, error) {
+		t.Fatal("boot id should not be checked when disabled")
+		return "", nil
+	}
+	assert.NilError(t, m.takeAction(intents.PendingRebootUpdate(intents.WithNodeName("node-a"))))
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+}
+
+func TestSkipHealthCheck(t *testing.T) {
+	m, hooks := testManager(t)
+	m.skipHealthCheck = true
+	hooks.NodeManager.ReadyFn = func(string) (bool, error) {
+		t.Fatal("health check should be skipped")
+		return false, nil
+	}
+	var uncordoned []string
+	hooks.NodeManager.UncordonFn = func(n string) error {
+		uncordoned = append(uncordoned, n)
+		return nil
+	}
+
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	assert.DeepEqual(t, uncordoned, []string{"node-a"})
+	assert.Equal(t, len(hooks.Poster.calledMarkers), 1, "update should be recorded as successful")
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ nodestream.Handler = (*nodeCounter)(nil)
+
+// nodeCounter publishes the number of Nodes in the cluster and how many of
+// them are managed by the operator. It must be given a store of all of the
+// cluster's Nodes.
+type nodeCounter struct {
+	storer storer
+}
+
+// streamConfig is the nodestream configuration needed to count all Nodes.
+func (nc *nodeCounter) streamConfig() nodestream.Config {
+	return nodestream.Config{Unmanaged: true}
+}
+
+func (nc *nodeCounter) OnAdd(_ *v1.Node) {
+	nc.publish()
+}
+
+func (nc *nodeCounter) OnUpdate(_ *v1.Node, _ *v1.Node) {
+	nc.publish()
+}
+
+func (nc *nodeCounter) OnDelete(_ *v1.Node) {
+	nc.publish()
+}
+
+func (nc *nodeCounter) publish() {
+	if nc.storer == nil {
+		return
+	}
+	total, managed := countNodes(nc.storer.GetStore().List())
+	metrics.NodesTotal.Set(float64(total))
+	metrics.NodesManaged.Set(float64(managed))
+	metrics.NodesUnmanaged.Set(float64(total - managed))
+}
+
+// countNodes counts the Nodes, and those labeled for management, among the
+// listed resources.
+func countNodes(resources []interface{}) (total int, managed int) {
+	for _, res := range resources {
+		node, ok := res.(*v1.Node)
+		if !ok {
+			continue
+		}
+		total++
+		if _, ok := node.Labels[marker.NodeSelectorLabel]; ok {
+			managed++
+		}
+	}
+	return total, managed
+}
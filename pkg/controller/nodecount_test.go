@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNodeCounter(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	managed := map[string]string{marker.NodeSelectorLabel: "2.0.0"}
+	for _, node := range []*v1.Node{
+		{ObjectMeta: v1meta.ObjectMeta{Name: "managed-a", Labels: managed}},
+		{ObjectMeta: v1meta.ObjectMeta{Name: "managed-b", Labels: managed}},
+		{ObjectMeta: v1meta.ObjectMeta{Name: "labeled", Labels: map[string]string{"os": "linux"}}},
+		{ObjectMeta: v1meta.ObjectMeta{Name: "unlabeled"}},
+		{ObjectMeta: v1meta.ObjectMeta{Name: "managed-c", Labels: managed}},
+	} {
+		assert.NilError(t, store.Add(node))
+	}
+
+	counter := &nodeCounter{storer: &testStorer{store}}
+	counter.publish()
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesTotal), float64(5))
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesManaged), float64(3))
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesUnmanaged), float64(2))
+
+	assert.NilError(t, store.Delete(&v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "managed-a"}}))
+	counter.OnDelete(nil)
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesTotal), float64(4))
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesManaged), float64(2))
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesUnmanaged), float64(2))
+}
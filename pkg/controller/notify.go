@@ -0,0 +1,61 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+)
+
+// updateVersions are the versions a Node is updating from and to.
+type updateVersions struct {
+	from string
+	to   string
+}
+
+// notifyUpdate notifies the Node's update beginning, succeeding, or failing,
+// with the versions it's updating from and to. The versions are taken from the
+// Node as its update begins, the version it ran is unknown once it rebooted.
+func (am *actionManager) notifyUpdate(event report.Event, nodeName string, err error) {
+	if am.notifier == nil {
+		return
+	}
+	node, ok := am.storedNode(nodeName)
+	if !ok {
+		node = &v1.Node{}
+	}
+	versions := am.updating[nodeName]
+	switch event {
+	case report.Begin:
+		versions = updateVersions{from: bootedVersion(node), to: updateVersion(node)}
+		am.updating[nodeName] = versions
+	case report.Success:
+		if booted := bootedVersion(node); booted != "" {
+			versions.to = booted
+		}
+		delete(am.updating, nodeName)
+	default:
+		delete(am.updating, nodeName)
+	}
+	note := notify.Notification{
+		Event:       event,
+		Node:        nodeName,
+		FromVersion: versions.from,
+		ToVersion:   versions.to,
+	}
+	if err != nil {
+		note.Error = err.Error()
+	}
+	am.notifier.Notify(note)
+}
+
+// updateVersion is the version the Node is updating to, as directed or chosen
+// by its Agent.
+func updateVersion(node *v1.Node) string {
+	annos := node.GetAnnotations()
+	if target := annos[marker.TargetVersionKey]; target != "" {
+		return target
+	}
+	return annos[marker.ChosenUpdateVersionKey]
+}
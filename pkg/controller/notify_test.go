@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/notify"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+)
+
+type testNotifySink struct {
+	sent chan notify.Notification
+}
+
+func (s *testNotifySink) Send(_ context.Context, note notify.Notification) error {
+	s.sent <- note
+	return nil
+}
+
+func TestManagerNotify(t *testing.T) {
+	m, hooks := testManager(t)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	m.SetStoreProvider(&testStorer{store})
+	node := testVersionedNode("node-a", "1.0.0", true)
+	node.Annotations[marker.ChosenUpdateVersionKey] = "1.1.0"
+	assert.NilError(t, store.Add(node))
+
+	sink := &testNotifySink{sent: make(chan notify.Notification, 1)}
+	m.notifier = notify.New(testoutput.Logger(t, logging.New("notifier")), "controller", sink)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.notifier.Run(ctx)
+
+	assert.NilError(t, m.takeAction(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()))
+	note := <-sink.sent
+	assert.Equal(t, note.Event, report.Begin)
+	assert.Equal(t, note.Node, "node-a")
+	assert.Equal(t, note.FromVersion, "1.0.0")
+	assert.Equal(t, note.ToVersion, "1.1.0")
+
+	// Intermediate steps aren't notified.
+	assert.NilError(t, m.takeAction(m.intentFor(intents.UpdatePrepared(intents.WithNodeName("node-a")))))
+
+	assert.NilError(t, store.Update(testVersionedNode("node-a", "1.1.0", true)))
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	note = <-sink.sent
+	assert.Equal(t, note.Event, report.Success)
+	assert.Equal(t, note.FromVersion, "1.0.0", "version updated from should be remembered")
+	assert.Equal(t, note.ToVersion, "1.1.0")
+	assert.Equal(t, len(m.updating), 0)
+
+	m.drainFailure = DrainFailureSkip
+	hooks.NodeManager.DrainFn = func(_ string) (int, error) {
+		return 0, errors.New("drain failed")
+	}
+	m.takeAction(m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-b"))))
+	note = <-sink.sent
+	assert.Equal(t, note.Event, report.Failure)
+	assert.Equal(t, note.Node, "node-b")
+	assert.Equal(t, note.Error, "drain failed")
+}
@@ -2,6 +2,8 @@ package controller
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/logfields"
@@ -19,14 +21,38 @@ const (
 
 type Policy interface {
 	// Check determines if the policy permits continuing with an intended
-	// action.
-	Check(*PolicyCheck) (bool, error)
+	// action. A denial that lifts with time returns the time remaining until
+	// the intent may be checked again.
+	Check(*PolicyCheck) (bool, time.Duration, error)
 }
 
 type PolicyCheck struct {
 	Intent        *intent.Intent
 	ClusterActive int
 	ClusterCount  int
+	// Active are the names of the Nodes considered active.
+	Active []string
+	// Candidates are the Nodes waiting to begin an update, ordered by their
+	// launch time from oldest to newest.
+	Candidates []UpdateCandidate
+	// Skipped is the number of listed resources that weren't Nodes and were
+	// left out of the check.
+	Skipped int
+	// Unlisted indicates the intended Node wasn't listed, as happens when the
+	// store is only partly populated.
+	Unlisted bool
+}
+
+// Incomplete reports whether the check was made with an incomplete view of
+// the cluster.
+func (ck *PolicyCheck) Incomplete() bool {
+	return ck.Skipped > 0 || ck.Unlisted
+}
+
+// UpdateCandidate is a Node that is waiting to begin an update.
+type UpdateCandidate struct {
+	NodeName string
+	Launched time.Time
 }
 
 func newPolicyCheck(in *intent.Intent, resources cache.Store) (*PolicyCheck, error) {
@@ -35,15 +61,30 @@ func newPolicyCheck(in *intent.Intent, resources cache.Store) (*PolicyCheck, err
 	ress := resources.List()
 	clusterCount := len(ress)
 	clusterActive := 0
+	skipped := 0
+	listed := false
+	var active []string
+	var candidates []UpdateCandidate
 	for _, res := range ress {
 		node, ok := res.(*v1.Node)
 		if !ok {
 			clusterCount--
+			skipped++
 			continue
 		}
+		if node.GetName() == in.GetName() {
+			listed = true
+		}
 		cin := intent.Given(node)
+		if isUpdateCandidate(cin) {
+			candidates = append(candidates, UpdateCandidate{
+				NodeName: node.GetName(),
+				Launched: node.CreationTimestamp.Time,
+			})
+		}
 		if isClusterActive(cin) {
 			clusterActive++
+			active = append(active, node.GetName())
 			if logging.Debuggable {
 				logging.New("policy-check").WithFields(logfields.Intent(cin)).
 					WithField("cluster-active", fmt.Sprintf("%d", clusterActive)).
@@ -64,10 +105,23 @@ func newPolicyCheck(in *intent.Intent, resources cache.Store) (*PolicyCheck, err
 		return nil, errors.Errorf("%d resources listed of inappropriate type", len(ress))
 	}
 
+	// Order the candidates oldest first, falling back to their names to keep
+	// the order stable for Nodes launched at the same time.
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Launched.Equal(candidates[j].Launched) {
+			return candidates[i].NodeName < candidates[j].NodeName
+		}
+		return candidates[i].Launched.Before(candidates[j].Launched)
+	})
+
 	return &PolicyCheck{
 		Intent:        in,
 		ClusterActive: clusterActive,
 		ClusterCount:  clusterCount,
+		Active:        active,
+		Candidates:    candidates,
+		Skipped:       skipped,
+		Unlisted:      !listed,
 	}, nil
 }
 
@@ -77,11 +131,65 @@ func isClusterActive(i *intent.Intent) bool {
 	return !stabilizing && !i.Stuck()
 }
 
+// isUpdateCandidate matches intents of Nodes that are waiting to begin an
+// update.
+func isUpdateCandidate(i *intent.Intent) bool {
+	return !isClusterActive(i) && i.Realized() && i.Waiting() && i.HasUpdateAvailable()
+}
+
 type defaultPolicy struct {
 	log logging.Logger
+	// orderByLaunchTime permits beginning updates on the longest running
+	// candidates first.
+	orderByLaunchTime bool
+	// maxActive is the most Nodes permitted to be updating at once, defaulting
+	// to maxClusterActive.
+	maxActive int
+	// maxActivePercent, when set, is the percentage of the cluster's Nodes
+	// permitted to be updating at once and is used in place of maxActive.
+	maxActivePercent int
+	// gate, when set, limits the Nodes permitted to be updating while the
+	// rollout is paused or ramping up after resuming.
+	gate *rolloutGate
+	// batch, when set, holds back updates until a quorum of the previous
+	// batch of Nodes is healthy.
+	batch *batchGate
+	// antiAffinity, when set, keeps Nodes hosting anti-affine replicas of the
+	// same workload from updating at the same time.
+	antiAffinity *antiAffinityGuard
+	// reboots, when set, spaces the reboots of Nodes across the cluster.
+	reboots *rebootSpacer
+	// starts, when set, limits how many Nodes may begin updating within a
+	// rolling window across the cluster.
+	starts *startLimiter
+	// ramp, when set, adapts the number of Nodes permitted to be updating at
+	// once to the rollout's successes and failures.
+	ramp *concurrencyRamp
+	// windows, when set, limits the times that Nodes may begin updating.
+	windows *maintenanceSchedule
+	// canary, when set, holds back Nodes from updating until the canaries
+	// have updated and soaked.
+	canary *canaryGate
 }
 
-func (p *defaultPolicy) Check(ck *PolicyCheck) (bool, error) {
+// allowedActive is the number of Nodes currently permitted to be updating at
+// once in a cluster of the given size.
+func (p *defaultPolicy) allowedActive(clusterCount int) int {
+	max := p.maxActive
+	if p.maxActivePercent > 0 {
+		max = (clusterCount*p.maxActivePercent + 99) / 100
+	}
+	if max <= 0 {
+		max = maxClusterActive
+	}
+	max = p.ramp.Limit(max)
+	if p.gate == nil {
+		return max
+	}
+	return p.gate.Limit(max)
+}
+
+func (p *defaultPolicy) Check(ck *PolicyCheck) (bool, time.Duration, error) {
 	log := p.log.WithFields(logfields.Intent(ck.Intent)).
 		WithFields(logrus.Fields{
 			"cluster-active": fmt.Sprintf("%d", ck.ClusterActive),
@@ -93,30 +201,106 @@ func (p *defaultPolicy) Check(ck *PolicyCheck) (bool, error) {
 	// at time of the projection to the next state. So, we have to check when
 	// the update process is starting up.
 	startingUpdate := ck.Intent.Active == marker.NodeActionStabilize
+	if ck.Intent.Intrusive() {
+		if wait := p.reboots.Wait(); wait > 0 {
+			log.WithField("wait", wait).Debug("deny intent, too soon after the last reboot")
+			return false, wait, nil
+		}
+	}
 	if !startingUpdate {
 		if ck.Intent.InProgress() {
 			if logging.Debuggable {
 				log.Debug("permit already in progress")
 			}
-			return true, nil
+			return true, 0, nil
 		}
 
 		if ck.Intent.Terminal() {
 			if logging.Debuggable {
 				log.Debug("permit terminal intent")
 			}
-			return true, nil
+			return true, 0, nil
+		}
+	}
+
+	// Nodes mid-update are permitted to finish, above, once the window
+	// closes. Everything else waits for the next window to open.
+	if wait := p.windows.Wait(); wait > 0 {
+		log.WithField("wait", wait).Debug("deny intent, outside of the maintenance windows")
+		return false, wait, nil
+	}
+
+	beginning := startingUpdate && progressesUpdate(ck.Intent)
+	if beginning {
+		if open, reason := p.canary.Open(ck.Intent.GetName()); !open {
+			log.WithField("reason", reason).Debug("deny intent, held back by the canary rollout")
+			return false, p.canary.Soaking(), nil
+		}
+	}
+
+	if beginning {
+		if wait, first := p.starts.Limited(); wait > 0 {
+			log := log.WithField("wait", wait)
+			if first {
+				log.Info("deny intent, update start rate limit reached")
+			} else {
+				log.Debug("deny intent, update start rate limit reached")
+			}
+			return false, wait, nil
 		}
 	}
 
+	if beginning && !p.batch.Open(ck.Intent.GetName()) {
+		log.Debug("deny intent, waiting on a quorum of the current batch to be healthy")
+		return false, 0, nil
+	}
+
+	if beginning {
+		if conflict, ok := p.antiAffinity.Conflict(ck.Intent.GetName(), ck.Active); ok {
+			log.WithField("conflict", conflict).Debug("deny intent, node hosting anti-affine replicas is updating")
+			return false, 0, nil
+		}
+	}
+
+	allowedActive := p.allowedActive(ck.ClusterCount)
+	if p.orderByLaunchTime && !launchOrdered(ck, allowedActive-ck.ClusterActive, p.canaryHeld) {
+		log.Debug("deny intent, longer running nodes are waiting to update")
+		return false, 0, nil
+	}
+
 	// If there are no other active nodes in the cluster, then go ahead with the
 	// intended action.
-	if ck.ClusterActive < maxClusterActive {
-		log.WithField("allowed-active", fmt.Sprintf("%d", maxClusterActive)).Debugf("permit according to active threshold")
-
-		return true, nil
+	if ck.ClusterActive < allowedActive {
+		log.WithField("allowed-active", fmt.Sprintf("%d", allowedActive)).Debugf("permit according to active threshold")
+		if beginning {
+			p.batch.Join(ck.Intent.GetName())
+		}
+		return true, 0, nil
 	}
 
 	log.Debug("deny intent")
-	return false, nil
+	return false, 0, nil
+}
+
+// canaryHeld reports whether the Node is held back by the canary rollout.
+func (p *defaultPolicy) canaryHeld(nodeName string) bool {
+	open, _ := p.canary.Open(nodeName)
+	return !open
+}
+
+// launchOrdered reports whether the intended Node is among the oldest
+// candidates that may fill the available slots. Nodes that aren't candidates
+// are not subject to ordering, and candidates that are held back don't take
+// up a slot.
+func launchOrdered(ck *PolicyCheck, slots int, held func(nodeName string) bool) bool {
+	i := 0
+	for _, candidate := range ck.Candidates {
+		if candidate.NodeName == ck.Intent.GetName() {
+			return i < slots
+		}
+		if !held(candidate.NodeName) {
+			i++
+		}
+	}
+	return true
 }
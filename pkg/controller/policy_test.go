@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
@@ -11,6 +12,9 @@ import (
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
 
 	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestPolicyCheck(t *testing.T) {
@@ -98,7 +102,7 @@ func TestPolicyCheck(t *testing.T) {
 					log: testoutput.Logger(t, logging.New("policy-check")),
 				}
 
-				permit, err := policy.Check(check)
+				permit, _, err := policy.Check(check)
 				assert.Equal(t, tc.ShouldPermit, permit)
 				if tc.ShouldError {
 					assert.Error(t, err, "")
@@ -138,3 +142,139 @@ func TestIsClusterActiveIntents(t *testing.T) {
 		})
 	}
 }
+
+// testNode creates a Node resource carrying the intent's markers.
+func testNode(in *intent.Intent, launched time.Time) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: v1meta.ObjectMeta{
+			Name:              in.GetName(),
+			CreationTimestamp: v1meta.NewTime(launched),
+			Annotations:       in.GetAnnotations(),
+			Labels:            in.GetLabels(),
+		},
+	}
+}
+
+func TestPolicyCheckLaunchOrder(t *testing.T) {
+	now := time.Now()
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, node := range []*v1.Node{
+		testNode(intents.Stabilized(intents.WithNodeName("newest"), intents.WithUpdateAvailable()), now),
+		testNode(intents.Stabilized(intents.WithNodeName("oldest"), intents.WithUpdateAvailable()), now.Add(-2*time.Hour)),
+		testNode(intents.Stabilized(intents.WithNodeName("older"), intents.WithUpdateAvailable()), now.Add(-time.Hour)),
+		testNode(intents.Stabilized(intents.WithNodeName("ancient"), intents.WithUpdateAvailable(marker.NodeUpdateUnavailable)), now.Add(-3*time.Hour)),
+	} {
+		assert.NilError(t, store.Add(node))
+	}
+
+	cases := []struct {
+		nodeName     string
+		ordered      bool
+		shouldPermit bool
+	}{
+		{nodeName: "oldest", ordered: true, shouldPermit: true},
+		{nodeName: "older", ordered: true, shouldPermit: false},
+		{nodeName: "newest", ordered: true, shouldPermit: false},
+		{nodeName: "newest", ordered: false, shouldPermit: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s(ordered=%t)", tc.nodeName, tc.ordered), func(t *testing.T) {
+			in := intents.Stabilized(intents.WithNodeName(tc.nodeName), intents.WithUpdateAvailable()).SetBeginUpdate()
+			check, err := newPolicyCheck(in, store)
+			assert.NilError(t, err)
+			assert.Equal(t, len(check.Candidates), 3)
+			assert.Equal(t, check.Candidates[0].NodeName, "oldest")
+			assert.Equal(t, check.Candidates[1].NodeName, "older")
+			assert.Equal(t, check.Candidates[2].NodeName, "newest")
+
+			policy := defaultPolicy{
+				log:               testoutput.Logger(t, logging.New("policy-check")),
+				orderByLaunchTime: tc.ordered,
+			}
+			permit, _, err := policy.Check(check)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.shouldPermit, permit)
+		})
+	}
+}
+
+func TestPolicyCheckLaunchOrderCanary(t *testing.T) {
+	now := time.Now()
+	canaryNode := testCanary(intents.Stabilized(intents.WithNodeName("canary"), intents.WithUpdateAvailable()))
+	canaryNode.CreationTimestamp = v1meta.NewTime(now)
+	nodes := []*v1.Node{
+		canaryNode,
+		testNode(intents.Stabilized(intents.WithNodeName("oldest"), intents.WithUpdateAvailable()), now.Add(-2*time.Hour)),
+		testNode(intents.Stabilized(intents.WithNodeName("older"), intents.WithUpdateAvailable()), now.Add(-time.Hour)),
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	canary := newCanaryGate(marker.CanaryKey, 0, newTestClock())
+	for _, node := range nodes {
+		assert.NilError(t, store.Add(node))
+		canary.Observe(node)
+	}
+	policy := defaultPolicy{
+		log:               testoutput.Logger(t, logging.New("policy-check")),
+		orderByLaunchTime: true,
+		canary:            canary,
+	}
+
+	// The canary, though the newest, is first to update: the older Nodes it
+	// holds back don't take its place in the launch order.
+	for nodeName, shouldPermit := range map[string]bool{"canary": true, "oldest": false, "older": false} {
+		in := intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()).SetBeginUpdate()
+		check, err := newPolicyCheck(in, store)
+		assert.NilError(t, err)
+		permit, _, err := policy.Check(check)
+		assert.NilError(t, err)
+		assert.Equal(t, permit, shouldPermit, "%s", nodeName)
+	}
+}
+
+func TestCheckPolicyIncompleteView(t *testing.T) {
+	now := time.Now()
+	begin := intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()
+
+	// The store is partly populated: the intended Node isn't listed yet and a
+	// resource that isn't a Node was listed.
+	partial := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	assert.NilError(t, partial.Add(testNode(intents.Stabilized(intents.WithNodeName("node-b")), now)))
+	assert.NilError(t, partial.Add(&v1.Pod{ObjectMeta: v1meta.ObjectMeta{Name: "not-a-node", Namespace: "default"}}))
+
+	complete := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	assert.NilError(t, complete.Add(testNode(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()), now)))
+	assert.NilError(t, complete.Add(testNode(intents.Stabilized(intents.WithNodeName("node-b")), now)))
+
+	check, err := newPolicyCheck(begin, partial)
+	assert.NilError(t, err)
+	assert.Check(t, check.Incomplete())
+	assert.Equal(t, check.Skipped, 1)
+	assert.Check(t, check.Unlisted)
+	assert.Equal(t, check.ClusterCount, 1)
+
+	cases := []struct {
+		action  IncompleteViewAction
+		store   cache.Store
+		proceed bool
+		retry   time.Duration
+	}{
+		{action: IncompleteViewProceed, store: partial, proceed: true},
+		{action: IncompleteViewDeny, store: partial},
+		{action: IncompleteViewRetry, store: partial, retry: incompleteRetryDelay},
+		// Complete views are checked as usual.
+		{action: IncompleteViewDeny, store: complete, proceed: true},
+		{action: IncompleteViewRetry, store: complete, proceed: true},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s(complete=%t)", tc.action, tc.store == complete), func(t *testing.T) {
+			m, _ := testManager(t)
+			m.incompleteView = tc.action
+			m.SetStoreProvider(&testStorer{tc.store})
+
+			proceed, retry := m.checkPolicy(begin)
+			assert.Equal(t, proceed, tc.proceed)
+			assert.Equal(t, retry, tc.retry)
+		})
+	}
+}
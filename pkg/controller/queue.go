@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+)
+
+const (
+	// queueBaseDelay and queueMaxDelay bound the per Node delay of rate
+	// limited Intents, doubling with each Intent until one is handled without
+	// error.
+	queueBaseDelay = time.Second
+	queueMaxDelay  = 5 * time.Minute
+	// queueRate and queueBurst limit the rate at which rate limited Intents
+	// are handled across all Nodes.
+	queueRate  = 10
+	queueBurst = 100
+)
+
+// intentQueue queues the Nodes' Intents to be handled, keyed by Node so that
+// a Node waiting to be handled is queued once with its latest Intent. Nodes
+// that are updating are always handled ahead of the others, so that idle
+// Nodes never hold up an update in progress. Low priority Intents are rate
+// limited, and Nodes whose action errored are retried with exponential
+// backoff.
+type intentQueue struct {
+	// delayed holds the rate limited and retried Nodes until they're ready to
+	// be handled.
+	delayed workqueue.RateLimitingInterface
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// active and idle are the Nodes ready to be handled, in order. Nodes on
+	// active are updating and are handled before any on idle.
+	active []string
+	idle   []string
+	// ready are the Nodes on active or idle, set when on active.
+	ready map[string]bool
+	// levels are the delayed Nodes, set when they're to be handled as active.
+	levels map[string]bool
+	// latest are the Intents to handle for the queued Nodes.
+	latest map[string]*intent.Intent
+	// retrying are the Nodes queued to retry their errored action.
+	retrying map[string]struct{}
+	// handling are the Nodes being handled, set when handled as active.
+	handling map[string]bool
+	shutdown bool
+}
+
+// newQueueRateLimiter limits the rate limited Intents per Node, with backoff,
+// and across all Nodes.
+func newQueueRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(queueBaseDelay, queueMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(queueRate), queueBurst)},
+	)
+}
+
+func newIntentQueue(limiter workqueue.RateLimiter) *intentQueue {
+	q := &intentQueue{
+		delayed:  workqueue.NewNamedRateLimitingQueue(limiter, "intents"),
+		ready:    map[string]bool{},
+		levels:   map[string]bool{},
+		latest:   map[string]*intent.Intent{},
+		retrying: map[string]struct{}{},
+		handling: map[string]bool{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+	go q.release()
+	return q
+}
+
+// release readies the delayed Nodes as their delay elapses.
+func (q *intentQueue) release() {
+	for {
+		item, shutdown := q.delayed.Get()
+		if shutdown {
+			return
+		}
+		nodeName := item.(string)
+		q.mu.Lock()
+		active := q.levels[nodeName]
+		delete(q.levels, nodeName)
+		q.push(nodeName, active)
+		q.mu.Unlock()
+		q.delayed.Done(item)
+	}
+}
+
+// push readies the Node, moving it ahead of the idle Nodes when it's readied
+// as active. The lock must be held.
+func (q *intentQueue) push(nodeName string, active bool) {
+	wasActive, ok := q.ready[nodeName]
+	switch {
+	case !ok && active:
+		q.active = append(q.active, nodeName)
+	case !ok:
+		q.idle = append(q.idle, nodeName)
+	case active && !wasActive:
+		q.idle = removeName(q.idle, nodeName)
+		q.active = append(q.active, nodeName)
+	default:
+		return
+	}
+	q.ready[nodeName] = active
+	q.cond.Signal()
+}
+
+// delay readies the Node once the delay elapses, or its rate limit permits
+// when no delay is given.
+func (q *intentQueue) delay(nodeName string, active bool, delay time.Duration) {
+	q.mu.Lock()
+	q.levels[nodeName] = q.levels[nodeName] || active
+	q.mu.Unlock()
+	if delay > 0 {
+		q.delayed.AddAfter(nodeName, delay)
+		return
+	}
+	q.delayed.AddRateLimited(nodeName)
+}
+
+// Add queues the Intent, replacing any Intent still waiting for its Node.
+func (q *intentQueue) Add(in *intent.Intent) {
+	if isLowPriority(in) {
+		q.mu.Lock()
+		q.latest[in.NodeName] = in
+		q.mu.Unlock()
+		q.delay(in.NodeName, false, 0)
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.latest[in.NodeName] = in
+	q.push(in.NodeName, isClusterActive(in))
+}
+
+// AddAfter queues the Intent to be handled again after the delay, unless
+// another Intent for its Node is queued first.
+func (q *intentQueue) AddAfter(in *intent.Intent, delay time.Duration) {
+	q.mu.Lock()
+	if _, ok := q.latest[in.NodeName]; !ok {
+		q.latest[in.NodeName] = in
+	}
+	q.mu.Unlock()
+	q.delay(in.NodeName, isClusterActive(in), delay)
+}
+
+// RetryAfter queues the Node to be handled again with its current Intent after
+// the delay, as for a Node whose Intent may only be acted on once the delay
+// elapses.
+func (q *intentQueue) RetryAfter(nodeName string, delay time.Duration) {
+	q.mu.Lock()
+	q.retrying[nodeName] = struct{}{}
+	q.mu.Unlock()
+	q.delay(nodeName, false, delay)
+}
+
+// Get waits for the next Node to handle, taking the Nodes that are updating
+// first. The Node's Intent is returned, or nil when the Node is being retried
+// and its current Intent is to be handled. Done must be called for the Node
+// once it's handled. Get returns false once the queue is shut down.
+func (q *intentQueue) Get() (string, *intent.Intent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for !q.shutdown && len(q.active) == 0 && len(q.idle) == 0 {
+			q.cond.Wait()
+		}
+		if q.shutdown {
+			return "", nil, false
+		}
+		var nodeName string
+		if len(q.active) > 0 {
+			nodeName, q.active = q.active[0], q.active[1:]
+		} else {
+			nodeName, q.idle = q.idle[0], q.idle[1:]
+		}
+		active := q.ready[nodeName]
+		delete(q.ready, nodeName)
+		in, queued := q.latest[nodeName]
+		_, retrying := q.retrying[nodeName]
+		delete(q.latest, nodeName)
+		delete(q.retrying, nodeName)
+		// Otherwise the Node's Intent was already handled when it was
+		// queued again.
+		if queued || retrying {
+			q.handling[nodeName] = active
+			return nodeName, in, true
+		}
+	}
+}
+
+// Done marks the Node as handled. A Node handled without error has its
+// backoff reset, otherwise it's retried once its backoff elapses.
+func (q *intentQueue) Done(nodeName string, err error) {
+	q.mu.Lock()
+	active, ok := q.handling[nodeName]
+	delete(q.handling, nodeName)
+	if ok && err != nil {
+		q.retrying[nodeName] = struct{}{}
+	}
+	q.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err == nil {
+		q.delayed.Forget(nodeName)
+		return
+	}
+	q.delay(nodeName, active, 0)
+}
+
+// Len is the number of Nodes with an Intent waiting to be handled.
+func (q *intentQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.latest)
+}
+
+// ShutDown stops the queue, Get returns false from then on.
+func (q *intentQueue) ShutDown() {
+	q.mu.Lock()
+	q.shutdown = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.delayed.ShutDown()
+}
+
+func removeName(names []string, name string) []string {
+	for i, n := range names {
+		if n == name {
+			return append(names[:i], names[i+1:]...)
+		}
+	}
+	return names
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+)
+
+func TestIntentQueuePriority(t *testing.T) {
+	q := newIntentQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour))
+	defer q.ShutDown()
+
+	idle := intents.Stabilized(intents.WithNodeName("node-a"))
+	assert.Assert(t, isLowPriority(idle))
+	q.Add(idle)
+	q.Add(intents.UpdatePrepared(intents.WithNodeName("node-b")))
+	// The Node's latest Intent replaces the one waiting.
+	q.Add(intents.UpdatePerformed(intents.WithNodeName("node-b")))
+	assert.Equal(t, q.Len(), 2)
+
+	nodeName, in, ok := q.Get()
+	assert.Check(t, ok)
+	assert.Equal(t, nodeName, "node-b", "updating node should be handled ahead of the rate limited node")
+	assert.Equal(t, in.Wanted, intents.UpdatePerformed().Wanted)
+	q.Done(nodeName, nil)
+	assert.Equal(t, q.Len(), 1, "idle node should still be waiting")
+}
+
+func TestIntentQueueActiveFirst(t *testing.T) {
+	q := newIntentQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour))
+	defer q.ShutDown()
+
+	candidate := func(nodeName string) *intent.Intent {
+		return intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable())
+	}
+	q.Add(candidate("node-a"))
+	q.Add(candidate("node-b"))
+	q.Add(intents.UpdatePerformed(intents.WithNodeName("node-c")))
+	// A waiting Node that begins updating moves ahead of the idle Nodes.
+	q.Add(intents.PendingPrepareUpdate(intents.WithNodeName("node-b")))
+	q.Add(candidate("node-d"))
+
+	var order []string
+	for q.Len() > 0 {
+		nodeName, _, ok := q.Get()
+		assert.Assert(t, ok)
+		order = append(order, nodeName)
+		q.Done(nodeName, nil)
+	}
+	assert.DeepEqual(t, order, []string{"node-c", "node-b", "node-a", "node-d"})
+}
+
+func TestIntentQueueRetry(t *testing.T) {
+	q := newIntentQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond))
+	defer q.ShutDown()
+
+	q.Add(intents.UpdatePrepared(intents.WithNodeName("node-a")))
+	nodeName, _, _ := q.Get()
+	q.Done(nodeName, errors.New("drain blocked"))
+	assert.Equal(t, q.delayed.NumRequeues("node-a"), 1)
+
+	// The Node is retried with its current Intent.
+	nodeName, in, ok := q.Get()
+	assert.Check(t, ok)
+	assert.Equal(t, nodeName, "node-a")
+	assert.Check(t, in == nil)
+	q.Done(nodeName, nil)
+	assert.Equal(t, q.delayed.NumRequeues("node-a"), 0, "backoff should be reset once handled")
+}
+
+func TestIntentQueueAddAfter(t *testing.T) {
+	q := newIntentQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour))
+	defer q.ShutDown()
+
+	latest := intents.UpdatePerformed(intents.WithNodeName("node-a"))
+	q.Add(latest)
+	q.AddAfter(intents.UpdatePrepared(intents.WithNodeName("node-a")), time.Hour)
+	nodeName, in, _ := q.Get()
+	assert.Equal(t, nodeName, "node-a")
+	assert.Equal(t, in, latest, "later intent should not be replaced by the delayed one")
+	q.Done(nodeName, nil)
+}
+
+func TestIntentQueueRetryAfter(t *testing.T) {
+	q := newIntentQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour))
+	defer q.ShutDown()
+
+	// The Node is handled with its current Intent once the delay elapses.
+	q.RetryAfter("node-a", time.Millisecond)
+	nodeName, in, ok := q.Get()
+	assert.Check(t, ok)
+	assert.Equal(t, nodeName, "node-a")
+	assert.Check(t, in == nil)
+	q.Done(nodeName, nil)
+}
+
+func TestIntentQueueShutDown(t *testing.T) {
+	q := newIntentQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Hour, time.Hour))
+	q.ShutDown()
+	_, _, ok := q.Get()
+	assert.Check(t, !ok)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import "sync"
+
+// concurrencyRamp adapts the number of Nodes permitted to update at once to
+// the rollout's track record. It starts at 1 and is raised by one for every
+// run of consecutive successful updates, up to the maximum, and is halved by a
+// failed update.
+type concurrencyRamp struct {
+	mu sync.Mutex
+	// step is the number of consecutive successful updates needed to raise
+	// the limit.
+	step      int
+	max       int
+	limit     int
+	successes int
+}
+
+func newConcurrencyRamp(step int, max int) *concurrencyRamp {
+	if step <= 0 {
+		return nil
+	}
+	if max < 1 {
+		max = 1
+	}
+	return &concurrencyRamp{step: step, max: max, limit: 1}
+}
+
+// Limit returns the number of Nodes currently permitted to be updating at
+// once, no more than the given maximum. The maximum is kept as the ceiling for
+// raising the limit as it may change with the size of the cluster.
+func (r *concurrencyRamp) Limit(max int) int {
+	if r == nil {
+		return max
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.max = max
+	if r.limit < max {
+		return r.limit
+	}
+	return max
+}
+
+// Succeeded records a successful update, returning the limit and whether it
+// was raised.
+func (r *concurrencyRamp) Succeeded() (int, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.successes++
+	if r.successes < r.step || r.limit >= r.max {
+		return r.limit, false
+	}
+	r.successes = 0
+	r.limit++
+	return r.limit, true
+}
+
+// Failed records a failed update, returning the limit and whether it was
+// lowered.
+func (r *concurrencyRamp) Failed() (int, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.successes = 0
+	if r.limit <= 1 {
+		return r.limit, false
+	}
+	r.limit /= 2
+	return r.limit, true
+}
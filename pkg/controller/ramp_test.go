@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+)
+
+func TestConcurrencyRamp(t *testing.T) {
+	r := newConcurrencyRamp(2, 4)
+	assert.Equal(t, r.Limit(4), 1, "ramp should start with a single node")
+
+	expected := []int{1, 2, 2, 3, 3, 4, 4, 4}
+	for i, limit := range expected {
+		r.Succeeded()
+		assert.Equal(t, r.Limit(4), limit, "after %d successes", i+1)
+	}
+	assert.Equal(t, r.Limit(2), 2, "limit should not exceed the maximum given")
+
+	limit, lowered := r.Failed()
+	assert.Check(t, lowered)
+	assert.Equal(t, limit, 2)
+	r.Failed()
+	assert.Equal(t, r.Limit(4), 1)
+	_, lowered = r.Failed()
+	assert.Check(t, !lowered, "limit should not fall below a single node")
+
+	// A failure restarts the run of successes needed to raise the limit.
+	r.Succeeded()
+	r.Failed()
+	r.Succeeded()
+	assert.Equal(t, r.Limit(4), 1)
+	r.Succeeded()
+	assert.Equal(t, r.Limit(4), 2)
+}
+
+func TestConcurrencyRampDisabled(t *testing.T) {
+	r := newConcurrencyRamp(0, 4)
+	assert.Assert(t, r == nil)
+	assert.Equal(t, r.Limit(4), 4)
+	r.Succeeded()
+	r.Failed()
+	assert.Equal(t, r.Limit(4), 4)
+}
+
+func TestManagerConcurrencyRamp(t *testing.T) {
+	m, hooks := testManager(t)
+	m.ramp = newConcurrencyRamp(1, 3)
+	policy := m.policy.(*defaultPolicy)
+	policy.maxActive = 3
+	policy.ramp = m.ramp
+
+	permitted := func(active int) bool {
+		in := intents.Stabilized(intents.WithNodeName("node-z"), intents.WithUpdateAvailable()).SetBeginUpdate()
+		permit, _, err := m.policy.Check(&PolicyCheck{Intent: in, ClusterActive: active, ClusterCount: 10})
+		assert.NilError(t, err)
+		return permit
+	}
+
+	assert.Check(t, permitted(0))
+	assert.Check(t, !permitted(1), "only a single node should update at first")
+
+	// Successful updates ramp up the concurrency.
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	assert.Check(t, permitted(1))
+	assert.Check(t, !permitted(2))
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-b"))))
+	assert.Check(t, permitted(2))
+
+	// A failed update backs it off.
+	hooks.NodeManager.ReadyFn = func(string) (bool, error) {
+		return false, errors.New("node unreachable")
+	}
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-c"))))
+	assert.Check(t, permitted(0))
+	assert.Check(t, !permitted(1))
+}
+
+func TestConcurrencyRampMaxFollowsLimit(t *testing.T) {
+	r := newConcurrencyRamp(1, 1)
+	// The cluster grew, raising the ceiling.
+	assert.Equal(t, r.Limit(3), 1)
+	limit, raised := r.Succeeded()
+	assert.Check(t, raised)
+	assert.Equal(t, limit, 2)
+	limit, raised = r.Succeeded()
+	assert.Check(t, raised)
+	assert.Equal(t, limit, 3)
+	_, raised = r.Succeeded()
+	assert.Check(t, !raised)
+	// The cluster shrank, holding the limit at the new ceiling.
+	assert.Equal(t, r.Limit(2), 2)
+	_, raised = r.Succeeded()
+	assert.Check(t, !raised)
+}
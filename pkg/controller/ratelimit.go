@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+)
+
+// startLimiter limits how many Nodes may begin updating within a rolling
+// window across the cluster, regardless of how many may be updating at once.
+// A nil startLimiter permits every start.
+type startLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	clock  clock.Clock
+	// starts are the updates begun within the window, oldest first.
+	starts []updateStart
+	// reported is the end of the wait last reported by Limited.
+	reported time.Time
+}
+
+// updateStart is a Node beginning its update.
+type updateStart struct {
+	nodeName string
+	at       time.Time
+}
+
+func newStartLimiter(limit int, window time.Duration, clk clock.Clock) *startLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &startLimiter{limit: limit, window: window, clock: clk}
+}
+
+// Limited returns the time remaining before another Node may begin updating.
+// The return indicates whether the wait wasn't reported before, so that each
+// wait is logged prominently once.
+func (l *startLimiter) Limited() (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune()
+	if len(l.starts) < l.limit {
+		return 0, false
+	}
+	until := l.starts[len(l.starts)-l.limit].at.Add(l.window)
+	first := !until.Equal(l.reported)
+	l.reported = until
+	return until.Sub(l.clock.Now()), first
+}
+
+// Started notes that the Node began updating now.
+func (l *startLimiter) Started(nodeName string) {
+	if l == nil {
+		return
+	}
+	l.add(nodeName, l.clock.Now())
+}
+
+// Observe counts the update the Node recorded beginning, as posted with
+// marker.UpdateStartedAtKey, when it began within the window. This carries
+// the window across restarts of the controller.
+func (l *startLimiter) Observe(node *v1.Node) {
+	if l == nil {
+		return
+	}
+	at, err := time.Parse(time.RFC3339, node.GetAnnotations()[marker.UpdateStartedAtKey])
+	if err != nil {
+		return
+	}
+	l.add(node.GetName(), at)
+}
+
+func (l *startLimiter) add(nodeName string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// The recorded start is posted to the second.
+	at = at.Truncate(time.Second)
+	for _, start := range l.starts {
+		if start.nodeName == nodeName && start.at.Equal(at) {
+			return
+		}
+	}
+	l.starts = append(l.starts, updateStart{nodeName: nodeName, at: at})
+	sort.SliceStable(l.starts, func(i, j int) bool {
+		return l.starts[i].at.Before(l.starts[j].at)
+	})
+	l.prune()
+}
+
+// prune drops the starts that have left the window.
+func (l *startLimiter) prune() {
+	cutoff := l.clock.Now().Add(-l.window)
+	kept := l.starts[:0]
+	for _, start := range l.starts {
+		if start.at.After(cutoff) {
+			kept = append(kept, start)
+		}
+	}
+	l.starts = kept
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+)
+
+func TestStartLimiter(t *testing.T) {
+	clk := newTestClock()
+	starts := newStartLimiter(2, time.Hour, clk)
+
+	starts.Started("node-a")
+	clk.Step(10 * time.Minute)
+	wait, _ := starts.Limited()
+	assert.Equal(t, wait, time.Duration(0))
+
+	starts.Started("node-b")
+	wait, first := starts.Limited()
+	assert.Equal(t, wait, 50*time.Minute, "should wait for the oldest start to leave the window")
+	assert.Check(t, first)
+	_, first = starts.Limited()
+	assert.Check(t, !first, "same wait should only be reported once")
+
+	clk.Step(50 * time.Minute)
+	wait, _ = starts.Limited()
+	assert.Equal(t, wait, time.Duration(0))
+	starts.Started("node-c")
+	wait, first = starts.Limited()
+	assert.Equal(t, wait, 10*time.Minute)
+	assert.Check(t, first, "a later wait should be reported")
+}
+
+func TestStartLimiterObserve(t *testing.T) {
+	clk := newTestClock()
+	starts := newStartLimiter(2, time.Hour, clk)
+	startedNode := func(name string, ago time.Duration) *v1.Node {
+		return &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				marker.UpdateStartedAtKey: clk.Now().Add(-ago).Format(time.RFC3339),
+			},
+		}}
+	}
+
+	starts.Observe(startedNode("node-a", 2*time.Hour))
+	starts.Observe(startedNode("node-b", 30*time.Minute))
+	starts.Observe(&v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "node-c"}})
+	wait, _ := starts.Limited()
+	assert.Equal(t, wait, time.Duration(0), "only starts within the window should count")
+
+	// Nodes are observed again when the controller regains its lease.
+	starts.Observe(startedNode("node-b", 30*time.Minute))
+	wait, _ = starts.Limited()
+	assert.Equal(t, wait, time.Duration(0), "a start should only be counted once")
+
+	starts.Observe(startedNode("node-c", 20*time.Minute))
+	wait, _ = starts.Limited()
+	assert.Equal(t, wait, 30*time.Minute)
+}
+
+func TestStartLimiterDisabled(t *testing.T) {
+	starts := newStartLimiter(0, time.Hour, newTestClock())
+	assert.Check(t, starts == nil)
+	starts.Started("node-a")
+	starts.Observe(&v1.Node{})
+	wait, _ := starts.Limited()
+	assert.Equal(t, wait, time.Duration(0))
+}
+
+func TestPolicyStartLimit(t *testing.T) {
+	clk := newTestClock()
+	starts := newStartLimiter(1, time.Hour, clk)
+	policy := defaultPolicy{
+		log:       testoutput.Logger(t, logging.New("policy-check")),
+		maxActive: 3,
+		starts:    starts,
+	}
+	permitted := func(in *intent.Intent) bool {
+		permit, _, err := policy.Check(&PolicyCheck{Intent: in, ClusterActive: 1, ClusterCount: 10})
+		assert.NilError(t, err)
+		return permit
+	}
+	beginning := func(nodeName string) *intent.Intent {
+		return intents.Stabilized(intents.WithNodeName(nodeName), intents.WithUpdateAvailable()).SetBeginUpdate()
+	}
+
+	assert.Check(t, permitted(beginning("node-a")))
+	starts.Started("node-a")
+	assert.Check(t, !permitted(beginning("node-b")), "start should wait on the rate limit")
+	_, wait, err := policy.Check(&PolicyCheck{Intent: beginning("node-b"), ClusterActive: 1, ClusterCount: 10})
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Hour, "start should be checked again once the oldest start leaves the window")
+	assert.Check(t, permitted(intents.PreparingUpdate(intents.WithNodeName("node-a"))), "in progress update should be permitted")
+	assert.Check(t, permitted(intents.UpdateSuccess(intents.WithNodeName("node-a"))), "terminal intent should be permitted")
+
+	clk.Step(time.Hour)
+	assert.Check(t, permitted(beginning("node-b")), "start should be permitted once the window frees up")
+}
+
+func TestManagerRecordsUpdateStarts(t *testing.T) {
+	m, hooks := testManager(t)
+	m.starts = newStartLimiter(1, time.Hour, hooks.Clock)
+
+	assert.NilError(t, m.takeAction(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()))
+	wait, _ := m.starts.Limited()
+	assert.Equal(t, wait, time.Hour)
+}
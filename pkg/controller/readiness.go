@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	v1 "k8s.io/api/core/v1"
+)
+
+// notReadyTracker remembers the Nodes held back from starting an update
+// because they're not ready, so that their holding and recovery are each
+// reported once.
+type notReadyTracker struct {
+	mu    sync.Mutex
+	nodes map[string]struct{}
+}
+
+func newNotReadyTracker() *notReadyTracker {
+	return &notReadyTracker{nodes: map[string]struct{}{}}
+}
+
+// Hold notes the Node is held back, the return indicates whether it wasn't
+// already.
+func (t *notReadyTracker) Hold(nodeName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.nodes[nodeName]; ok {
+		return false
+	}
+	t.nodes[nodeName] = struct{}{}
+	return true
+}
+
+// Release notes the Node is no longer held back, the return indicates
+// whether it was.
+func (t *notReadyTracker) Release(nodeName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.nodes[nodeName]; !ok {
+		return false
+	}
+	delete(t.nodes, nodeName)
+	return true
+}
+
+// nodeNotReady reports whether the Node's NodeReady condition reports that
+// it's not ready. Inputs that aren't Nodes, or that lack the condition, are
+// not considered to be not ready.
+func nodeNotReady(input intent.Input) bool {
+	node, ok := input.(*v1.Node)
+	if !ok {
+		return false
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status != v1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// stuckTracker remembers Nodes that were detected as stuck until they recover
+// to a healthy terminal state.
+type stuckTracker struct {
+	mu    sync.Mutex
+	nodes map[string]struct{}
+}
+
+func newStuckTracker() *stuckTracker {
+	return &stuckTracker{nodes: map[string]struct{}{}}
+}
+
+// Stuck notes that the Node was detected as stuck.
+func (t *stuckTracker) Stuck(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nodes[nodeName] = struct{}{}
+}
+
+// Recovered notes that the Node reached a healthy terminal state, counting
+// its recovery if it was previously stuck. The return indicates whether the
+// Node was stuck.
+func (t *stuckTracker) Recovered(nodeName string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.nodes[nodeName]; !ok {
+		return false
+	}
+	delete(t.nodes, nodeName)
+	metrics.StuckRecovered.WithLabelValues(nodeName).Inc()
+	return true
+}
+
+// Forget drops any record of the Node.
+func (t *stuckTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.nodes, nodeName)
+}
+
+// stabilized indicates the Node has settled into its stabilized state without
+// error.
+func stabilized(in *intent.Intent) bool {
+	return in.Wanted == marker.NodeActionStabilize &&
+		in.Active == marker.NodeActionStabilize &&
+		in.Realized() && in.Waiting() && !in.Stuck()
+}
+
+// unknownTracker times how long Nodes have reported an unknown intent so that
+// Nodes persistently in that state may be recovered.
+type unknownTracker struct {
+	mu    sync.Mutex
+	grace time.Duration
+	clock clock.Clock
+	since map[string]time.Time
+}
+
+// newUnknownTracker creates a tracker expiring unknown intents after grace, a
+// zero grace never expires them.
+func newUnknownTracker(grace time.Duration, clk clock.Clock) *unknownTracker {
+	return &unknownTracker{
+		grace: grace,
+		clock: clk,
+		since: map[string]time.Time{},
+	}
+}
+
+// Expired notes that the Node's intent is unknown and reports whether it has
+// been for longer than the grace period.
+func (t *unknownTracker) Expired(nodeName string) bool {
+	if t.grace <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	since, ok := t.since[nodeName]
+	if !ok {
+		t.since[nodeName] = t.clock.Now()
+		return false
+	}
+	return t.clock.Since(since) > t.grace
+}
+
+// Forget drops any record of the Node.
+func (t *unknownTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.since, nodeName)
+}
+
+// busyTracker times how long Nodes have been busy with the same action so
+// that Nodes whose Agent stopped partway through an action, leaving them busy
+// indefinitely, may be recovered.
+type busyTracker struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	clock   clock.Clock
+	busy    map[string]busyAction
+	// observed are the Nodes seen since the controller started.
+	observed map[string]struct{}
+}
+
+// busyAction is the action a Node is busy with and since when.
+type busyAction struct {
+	action marker.NodeAction
+	since  time.Time
+}
+
+// newBusyTracker creates a tracker expiring Nodes busy with the same action
+// for longer than timeout, a zero timeout never expires them.
+func newBusyTracker(timeout time.Duration, clk clock.Clock) *busyTracker {
+	return &busyTracker{
+		timeout:  timeout,
+		clock:    clk,
+		busy:     map[string]busyAction{},
+		observed: map[string]struct{}{},
+	}
+}
+
+// Expired notes that the Node is busy with the action and reports since when
+// and whether it has been for longer than the timeout. The time the Agent
+// recorded starting the action, if any, is used for Nodes already busy when
+// the controller started, otherwise the Node is timed from when it's first
+// seen busy with the action.
+func (t *busyTracker) Expired(nodeName string, action marker.NodeAction, started string) (time.Time, bool) {
+	if t.timeout <= 0 {
+		return time.Time{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	busy, ok := t.busy[nodeName]
+	if !ok || busy.action != action {
+		busy = busyAction{action: action, since: t.clock.Now()}
+		if _, seen := t.observed[nodeName]; !seen {
+			if at, err := time.Parse(time.RFC3339, started); err == nil && at.Before(busy.since) {
+				busy.since = at
+			}
+		}
+		t.busy[nodeName] = busy
+	}
+	t.observed[nodeName] = struct{}{}
+	return busy.since, t.clock.Since(busy.since) > t.timeout
+}
+
+// Idle notes that the Node isn't busy.
+func (t *busyTracker) Idle(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.busy, nodeName)
+	t.observed[nodeName] = struct{}{}
+}
+
+// Forget drops any record of the Node.
+func (t *busyTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.busy, nodeName)
+	delete(t.observed, nodeName)
+}
+
+// errorBackoff delays retrying Nodes whose actions errored, doubling the delay
+// with each consecutive error up to a limit, so that a consistently failing
+// Node isn't retried in a hot loop.
+type errorBackoff struct {
+	mu      sync.Mutex
+	initial time.Duration
+	max     time.Duration
+	clock   clock.Clock
+	nodes   map[string]*nodeBackoff
+}
+
+type nodeBackoff struct {
+	// delay is the current delay, zero until the Node errors after a retry.
+	delay time.Duration
+	until time.Time
+	// retried is set once the Node was permitted a retry, so that its next
+	// error extends the delay.
+	retried bool
+}
+
+func newErrorBackoff(initial, max time.Duration, clk clock.Clock) *errorBackoff {
+	return &errorBackoff{
+		initial: initial,
+		max:     max,
+		clock:   clk,
+		nodes:   map[string]*nodeBackoff{},
+	}
+}
+
+// Wait notes that the Node's action errored and returns the time remaining
+// before it may be retried. A Node is retried at once after its first error.
+func (b *errorBackoff) Wait(nodeName string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	nb, ok := b.nodes[nodeName]
+	if !ok {
+		b.nodes[nodeName] = &nodeBackoff{retried: true}
+		return 0
+	}
+	if nb.retried {
+		switch {
+		case nb.delay == 0:
+			nb.delay = b.initial
+		case nb.delay < b.max:
+			nb.delay *= 2
+		}
+		if nb.delay > b.max {
+			nb.delay = b.max
+		}
+		nb.until = now.Add(nb.delay)
+		nb.retried = false
+		return nb.delay
+	}
+	if remaining := nb.until.Sub(now); remaining > 0 {
+		return remaining
+	}
+	nb.retried = true
+	return 0
+}
+
+// Forget drops any record of the Node, as once it successfully updates.
+func (b *errorBackoff) Forget(nodeName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.nodes, nodeName)
+}
+
+// unknownIntent matches intents with missing, unknown, or unrecognized
+// markers, as left on a Node with corrupted markers.
+func unknownIntent(in *intent.Intent) bool {
+	unknownState := in.State == "" || in.State == marker.NodeStateUnknown
+	return unknownState || !knownAction(in.Wanted) || !knownAction(in.Active)
+}
+
+func knownAction(action marker.NodeAction) bool {
+	switch action {
+	case marker.NodeActionStabilize,
+		marker.NodeActionReset,
+		marker.NodeActionPrepareUpdate,
+		marker.NodeActionPerformUpdate,
+		marker.NodeActionRebootUpdate:
+		return true
+	}
+	return false
+}
+
+// skipTracker holds back Nodes whose update was skipped, so that a Node that
+// fails to drain isn't immediately started on the same update again.
+type skipTracker struct {
+	mu     sync.Mutex
+	period time.Duration
+	clock  clock.Clock
+	until  map[string]time.Time
+}
+
+func newSkipTracker(period time.Duration, clk clock.Clock) *skipTracker {
+	return &skipTracker{
+		period: period,
+		clock:  clk,
+		until:  map[string]time.Time{},
+	}
+}
+
+// Skip notes that the Node's update was skipped, holding it back for the
+// period.
+func (t *skipTracker) Skip(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.until[nodeName] = t.clock.Now().Add(t.period)
+}
+
+// Remaining returns the time left before the skipped Node may begin updating
+// again, zero once it may.
+func (t *skipTracker) Remaining(nodeName string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until, ok := t.until[nodeName]
+	if !ok {
+		return 0
+	}
+	remaining := until.Sub(t.clock.Now())
+	if remaining <= 0 {
+		delete(t.until, nodeName)
+		return 0
+	}
+	return remaining
+}
+
+// Forget drops any record of the Node.
+func (t *skipTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.until, nodeName)
+}
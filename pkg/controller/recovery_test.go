@@ -0,0 +1,215 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+)
+
+func TestStuckRecovered(t *testing.T) {
+	recoveries := func(nodeName string) float64 {
+		return testutil.ToFloat64(metrics.StuckRecovered.WithLabelValues(nodeName))
+	}
+
+	t.Run("stabilized", func(t *testing.T) {
+		m, _ := testManager(t)
+		nodeName := "stuck-stabilized"
+
+		stuck := intents.Unknown(intents.WithNodeName(nodeName))
+		assert.Assert(t, stuck.Stuck())
+		assert.Assert(t, m.intentFor(stuck) != nil)
+		assert.Equal(t, recoveries(nodeName), float64(0))
+
+		m.intentFor(intents.Stabilized(intents.WithNodeName(nodeName)))
+		assert.Equal(t, recoveries(nodeName), float64(1))
+
+		// Staying healthy isn't another recovery.
+		m.intentFor(intents.Stabilized(intents.WithNodeName(nodeName)))
+		assert.Equal(t, recoveries(nodeName), float64(1))
+	})
+
+	t.Run("updated", func(t *testing.T) {
+		m, _ := testManager(t)
+		nodeName := "stuck-updated"
+
+		m.intentFor(intents.Unknown(intents.WithNodeName(nodeName)))
+		err := m.takeAction(intents.UpdateSuccess(intents.WithNodeName(nodeName)))
+		assert.NilError(t, err)
+		assert.Equal(t, recoveries(nodeName), float64(1))
+	})
+
+	t.Run("never-stuck", func(t *testing.T) {
+		m, _ := testManager(t)
+		nodeName := "never-stuck"
+
+		m.intentFor(intents.Stabilized(intents.WithNodeName(nodeName)))
+		err := m.takeAction(intents.UpdateSuccess(intents.WithNodeName(nodeName)))
+		assert.NilError(t, err)
+		assert.Equal(t, recoveries(nodeName), float64(0))
+	})
+}
+
+func TestUnknownIntentRecovery(t *testing.T) {
+	corrupted := func(nodeName string) *intent.Intent {
+		return &intent.Intent{
+			NodeName:        nodeName,
+			Wanted:          "corrupted",
+			Active:          "corrupted",
+			State:           "corrupted",
+			UpdateAvailable: marker.NodeUpdateUnknown,
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		m, _ := testManager(t)
+		in := corrupted("unknown-disabled")
+		assert.Assert(t, !in.Stuck())
+		assert.Assert(t, m.intentFor(in) == nil)
+		assert.Assert(t, m.intentFor(in) == nil)
+	})
+
+	t.Run("reset-after-grace", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.unknown = newUnknownTracker(time.Minute, hooks.Clock)
+		in := corrupted("unknown-reset")
+
+		assert.Assert(t, m.intentFor(in) == nil)
+		hooks.Clock.Step(30 * time.Second)
+		assert.Assert(t, m.intentFor(in) == nil)
+
+		hooks.Clock.Step(time.Minute)
+		reset := m.intentFor(in)
+		assert.Assert(t, reset != nil)
+		assert.Equal(t, reset.Wanted, marker.NodeActionStabilize)
+		assert.Equal(t, reset.NodeName, in.NodeName)
+
+		// The grace period starts over once the Node is reset.
+		assert.Assert(t, m.intentFor(in) == nil)
+	})
+
+	t.Run("known-forgotten", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.unknown = newUnknownTracker(time.Minute, hooks.Clock)
+		nodeName := "unknown-known"
+
+		assert.Assert(t, m.intentFor(corrupted(nodeName)) == nil)
+		hooks.Clock.Step(30 * time.Second)
+		m.intentFor(intents.Stabilized(intents.WithNodeName(nodeName)))
+
+		// The earlier unknown intent no longer counts toward the grace period.
+		hooks.Clock.Step(45 * time.Second)
+		assert.Assert(t, m.intentFor(corrupted(nodeName)) == nil)
+	})
+}
+
+func TestBusyTimeout(t *testing.T) {
+	busy := func(nodeName string) *intent.Intent {
+		return intents.BusyRebootUpdate(intents.WithNodeName(nodeName))
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		m, hooks := testManager(t)
+		assert.Assert(t, m.intentFor(busy("busy-disabled")) == nil)
+		hooks.Clock.Step(24 * time.Hour)
+		assert.Assert(t, m.intentFor(busy("busy-disabled")) == nil)
+	})
+
+	t.Run("reset-after-timeout", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.busy = newBusyTracker(10*time.Minute, hooks.Clock)
+		fake := testRecorder(m)
+		in := busy("busy-reset")
+
+		assert.Assert(t, m.intentFor(in) == nil)
+		hooks.Clock.Step(10 * time.Minute)
+		assert.Assert(t, m.intentFor(in) == nil)
+
+		hooks.Clock.Step(time.Second)
+		reset := m.intentFor(in)
+		assert.Assert(t, reset != nil)
+		assert.Equal(t, reset.Wanted, marker.NodeActionStabilize)
+		assert.Equal(t, reset.NodeName, in.NodeName)
+		assert.DeepEqual(t, recordedEvents(fake), []string{
+			"Warning BusyTimeout Node was busy with reboot-update without progress since 2020-07-10T00:00:00Z, resetting to stabilize",
+		})
+
+		// The timeout starts over once the Node is reset.
+		assert.Assert(t, m.intentFor(in) == nil)
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.busy = newBusyTracker(10*time.Minute, hooks.Clock)
+		nodeName := "busy-progress"
+
+		preparing := &intent.Intent{
+			NodeName:        nodeName,
+			Wanted:          marker.NodeActionPrepareUpdate,
+			Active:          marker.NodeActionPrepareUpdate,
+			State:           marker.NodeStateBusy,
+			UpdateAvailable: marker.NodeUpdateAvailable,
+		}
+		assert.Assert(t, m.intentFor(preparing) == nil)
+		hooks.Clock.Step(8 * time.Minute)
+		// Busy with another action, the Node has made progress.
+		assert.Assert(t, m.intentFor(busy(nodeName)) == nil)
+		hooks.Clock.Step(8 * time.Minute)
+		assert.Assert(t, m.intentFor(busy(nodeName)) == nil)
+	})
+
+	t.Run("action-started", func(t *testing.T) {
+		m, hooks := testManager(t)
+		m.busy = newBusyTracker(10*time.Minute, hooks.Clock)
+		node := testNode(busy("busy-started"), time.Time{})
+		node.Annotations[marker.ActionStartedKey] = hooks.Clock.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+		// The Node was busy before the controller started.
+		reset := m.intentFor(node)
+		assert.Assert(t, reset != nil)
+		assert.Equal(t, reset.Wanted, marker.NodeActionStabilize)
+
+		// Once observed, the Node is timed from when it's seen busy as the
+		// recorded start may predate the Node's current action.
+		assert.Assert(t, m.intentFor(node) == nil)
+	})
+}
+
+func TestErrorBackoff(t *testing.T) {
+	m, hooks := testManager(t)
+	m.errored = newErrorBackoff(30*time.Second, 2*time.Minute, hooks.Clock)
+	nodeName := "errored"
+	errored := func() *intent.Intent {
+		return m.intentFor(intents.UpdateError(intents.WithNodeName(nodeName)))
+	}
+
+	// The first error is retried at once.
+	retry := errored()
+	assert.Assert(t, retry != nil)
+	assert.Equal(t, retry.Wanted, marker.NodeActionStabilize)
+
+	// Each consecutive error doubles the delay, up to the limit. The Node is
+	// queued to be retried once the delay elapses.
+	for _, delay := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute} {
+		assert.Assert(t, errored() == nil, "retry should be delayed by %s", delay)
+		_, retrying := m.queue.retrying[nodeName]
+		assert.Check(t, retrying, "node should be queued to retry after %s", delay)
+		hooks.Clock.Step(delay - time.Second)
+		assert.Assert(t, errored() == nil, "retry should be delayed by %s", delay)
+		hooks.Clock.Step(time.Second)
+		assert.Assert(t, errored() != nil, "retry should be permitted after %s", delay)
+	}
+
+	// A successful update starts the backoff over.
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName(nodeName))))
+	assert.Assert(t, errored() != nil)
+	assert.Equal(t, m.errored.Wait(nodeName), 30*time.Second)
+
+	// Other Nodes are unaffected.
+	assert.Assert(t, m.intentFor(intents.UpdateError(intents.WithNodeName("other"))) != nil)
+}
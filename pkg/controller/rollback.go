@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+)
+
+// rollbackTracker remembers the Nodes directed to roll back after failing
+// their health check, and the versions they're rolling back from, until
+// they're back on their prior version. A nil rollbackTracker tracks nothing.
+type rollbackTracker struct {
+	mu    sync.Mutex
+	nodes map[string]string
+}
+
+func newRollbackTracker(enabled bool) *rollbackTracker {
+	if !enabled {
+		return nil
+	}
+	return &rollbackTracker{nodes: map[string]string{}}
+}
+
+// Enabled reports whether Nodes are rolled back.
+func (t *rollbackTracker) Enabled() bool {
+	return t != nil
+}
+
+// RollingBack notes the Node was directed to roll back from the version.
+func (t *rollbackTracker) RollingBack(nodeName string, version string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nodes[nodeName] = version
+}
+
+// RolledBack reports whether the Node, directed to roll back, has booted into
+// another version and is ready, returning the version it rolled back from.
+// The Node is forgotten once it's rolled back.
+func (t *rollbackTracker) RolledBack(node *v1.Node) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	version, ok := t.nodes[node.GetName()]
+	if !ok {
+		return "", false
+	}
+	booted := bootedVersion(node)
+	if booted == "" || booted == version || !nodeReady(node) {
+		return "", false
+	}
+	delete(t.nodes, node.GetName())
+	return version, true
+}
+
+// Forget drops any record of the Node.
+func (t *rollbackTracker) Forget(nodeName string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.nodes, nodeName)
+}
+
+// bootedVersion is the version of the partition the Node boots from, as last
+// posted by its Agent. Before the Agent reposts its partitions after
+// rebooting into an update, that's the staging partition holding the update.
+func bootedVersion(node *v1.Node) string {
+	annos := node.GetAnnotations()
+	if annos[marker.NextToBootKey] == marker.NodePartitionStaging {
+		return annos[marker.StagingPartitionKey]
+	}
+	return annos[marker.ActivePartitionKey]
+}
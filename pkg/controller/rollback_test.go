@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+)
+
+// testVersionedNode is a Node reporting the version it boots from and its
+// readiness.
+func testVersionedNode(name string, version string, ready bool) *v1.Node {
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{
+		Name: name,
+		Annotations: map[string]string{
+			marker.ActivePartitionKey: version,
+			marker.NextToBootKey:      marker.NodePartitionActive,
+		},
+	}}
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}
+	return node
+}
+
+func TestBootedVersion(t *testing.T) {
+	node := testVersionedNode("node-a", "1.0.0", true)
+	assert.Equal(t, bootedVersion(node), "1.0.0")
+
+	// The update is booted before the Agent reposts its partitions.
+	node.Annotations[marker.StagingPartitionKey] = "1.1.0"
+	node.Annotations[marker.NextToBootKey] = marker.NodePartitionStaging
+	assert.Equal(t, bootedVersion(node), "1.1.0")
+
+	assert.Equal(t, bootedVersion(&v1.Node{}), "")
+}
+
+func TestRollbackTracker(t *testing.T) {
+	rollbacks := newRollbackTracker(true)
+	assert.Check(t, rollbacks.Enabled())
+	rollbacks.RollingBack("node-a", "1.1.0")
+
+	_, ok := rollbacks.RolledBack(testVersionedNode("node-a", "1.1.0", true))
+	assert.Check(t, !ok, "node has yet to roll back")
+	_, ok = rollbacks.RolledBack(testVersionedNode("node-a", "1.0.0", false))
+	assert.Check(t, !ok, "node has yet to become ready")
+	_, ok = rollbacks.RolledBack(testVersionedNode("node-b", "1.0.0", true))
+	assert.Check(t, !ok, "node was not rolling back")
+
+	version, ok := rollbacks.RolledBack(testVersionedNode("node-a", "1.0.0", true))
+	assert.Check(t, ok)
+	assert.Equal(t, version, "1.1.0")
+	_, ok = rollbacks.RolledBack(testVersionedNode("node-a", "1.0.0", true))
+	assert.Check(t, !ok, "rolled back node should be forgotten")
+}
+
+func TestRollbackTrackerDisabled(t *testing.T) {
+	rollbacks := newRollbackTracker(false)
+	assert.Check(t, !rollbacks.Enabled())
+	rollbacks.RollingBack("node-a", "1.1.0")
+	_, ok := rollbacks.RolledBack(testVersionedNode("node-a", "1.0.0", true))
+	assert.Check(t, !ok)
+	rollbacks.Forget("node-a")
+}
+
+func TestManagerRollbackOnFailure(t *testing.T) {
+	m, hooks := testManager(t)
+	fake := testRecorder(m)
+	m.rollbacks = newRollbackTracker(true)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	m.SetStoreProvider(&testStorer{store})
+	assert.NilError(t, store.Add(testVersionedNode("node-a", "1.1.0", false)))
+
+	hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+		return false, nil
+	}
+	var uncordoned []string
+	hooks.NodeManager.UncordonFn = func(n string) error {
+		uncordoned = append(uncordoned, n)
+		return nil
+	}
+
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	assert.Check(t, len(uncordoned) == 0, "node should stay cordoned while it rolls back")
+	var directed bool
+	for _, posted := range hooks.Poster.calledMarkers {
+		if posted.GetAnnotations()[marker.RollbackKey] == "1.1.0" {
+			directed = true
+		}
+	}
+	assert.Check(t, directed, "node should be directed to roll back")
+	events := recordedEvents(fake)
+	assert.Equal(t, events[1], "Warning RollingBack Rolling back node from 1.1.0 after failing its health check: node not ready after 5m0s, last observed ready condition False")
+
+	// Uncordoned once back on its prior version.
+	m.OnUpdate(nil, testVersionedNode("node-a", "1.0.0", true))
+	assert.DeepEqual(t, uncordoned, []string{"node-a"})
+	events = recordedEvents(fake)
+	assert.Equal(t, events[len(events)-1], "Normal RolledBack Node rolled back from 1.1.0 and was uncordoned")
+}
+
+func TestManagerRollbackDisabled(t *testing.T) {
+	m, hooks := testManager(t)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	m.SetStoreProvider(&testStorer{store})
+	assert.NilError(t, store.Add(testVersionedNode("node-a", "1.1.0", false)))
+	hooks.NodeManager.ReadyFn = func(_ string) (bool, error) {
+		return false, nil
+	}
+	var uncordoned []string
+	hooks.NodeManager.UncordonFn = func(n string) error {
+		uncordoned = append(uncordoned, n)
+		return nil
+	}
+
+	assert.NilError(t, m.takeAction(intents.UpdateSuccess(intents.WithNodeName("node-a"))))
+	assert.DeepEqual(t, uncordoned, []string{"node-a"})
+	for _, posted := range hooks.Poster.calledMarkers {
+		_, ok := posted.GetAnnotations()[marker.RollbackKey]
+		assert.Check(t, !ok, "node should not be directed to roll back")
+	}
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// addSettler coalesces the Node events received while the controller starts
+// up, when the informer adds every Node at once, so that they're handled
+// together once the cluster's state has settled.
+type addSettler struct {
+	mu       sync.Mutex
+	settling bool
+	// pending is the latest received state of each Node.
+	pending map[string]*v1.Node
+}
+
+func newAddSettler(settling bool) *addSettler {
+	return &addSettler{settling: settling, pending: map[string]*v1.Node{}}
+}
+
+// Hold records the Node's latest state if still settling, the return
+// indicates whether the Node was held.
+func (s *addSettler) Hold(node *v1.Node) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.settling {
+		return false
+	}
+	s.pending[node.GetName()] = node
+	return true
+}
+
+// Forget drops the Node's held state.
+func (s *addSettler) Forget(nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, nodeName)
+}
+
+// Release ends settling, returning the held Nodes ordered by name.
+func (s *addSettler) Release() []*v1.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settling = false
+	nodes := make([]*v1.Node, 0, len(s.pending))
+	for _, node := range s.pending {
+		nodes = append(nodes, node)
+	}
+	s.pending = map[string]*v1.Node{}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].GetName() < nodes[j].GetName()
+	})
+	return nodes
+}
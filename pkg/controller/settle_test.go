@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"gotest.tools/assert"
+)
+
+func TestStartupSettleCoalescesAdds(t *testing.T) {
+	m, _ := testManager(t)
+	m.settle = time.Minute
+	m.settler = newAddSettler(true)
+	launched := time.Now()
+
+	// Each of the Nodes would be handled as they're added if not settling.
+	m.OnAdd(testNode(intents.UpdateError(intents.WithNodeName("node-a")), launched))
+	m.OnAdd(testNode(intents.UpdateError(intents.WithNodeName("node-b")), launched))
+	m.OnUpdate(nil, testNode(intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()), launched))
+	m.OnAdd(testNode(intents.UpdateError(intents.WithNodeName("node-c")), launched))
+	m.OnDelete(testNode(intents.UpdateError(intents.WithNodeName("node-c")), launched))
+	assert.Equal(t, m.queue.Len(), 1, "only the deletion should be handled while settling")
+	assert.Check(t, m.queue.latest["node-c"] != nil)
+	delete(m.queue.latest, "node-c")
+
+	m.releaseSettled()
+	assert.Equal(t, m.queue.Len(), 2)
+	// The latest state of each Node is handled.
+	assert.Equal(t, m.queue.latest["node-a"].Wanted, marker.NodeActionPrepareUpdate)
+	assert.Check(t, m.queue.latest["node-b"] != nil)
+
+	// Events are handled as they arrive once settled.
+	m.OnAdd(testNode(intents.UpdateError(intents.WithNodeName("node-d")), launched))
+	assert.Equal(t, m.queue.Len(), 3)
+}
+
+func TestStartupSettleDisabled(t *testing.T) {
+	m, _ := testManager(t)
+	m.OnAdd(testNode(intents.UpdateError(intents.WithNodeName("node-a")), time.Now()))
+	assert.Equal(t, m.queue.Len(), 1)
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// rebootSpacer enforces a minimum interval between the reboots of any two
+// Nodes in the cluster, regardless of how many may be updating at once.
+type rebootSpacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time
+	clock    clock.Clock
+}
+
+func newRebootSpacer(interval time.Duration, clk clock.Clock) *rebootSpacer {
+	if interval <= 0 {
+		return nil
+	}
+	return &rebootSpacer{interval: interval, clock: clk}
+}
+
+// Wait returns the time remaining before another Node may be rebooted.
+func (s *rebootSpacer) Wait() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last.IsZero() {
+		return 0
+	}
+	remaining := s.interval - s.clock.Since(s.last)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Rebooted notes that a Node was told to reboot.
+func (s *rebootSpacer) Rebooted() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = s.clock.Now()
+}
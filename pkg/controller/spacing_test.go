@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"gotest.tools/assert"
+)
+
+func TestRebootSpacing(t *testing.T) {
+	m, hooks := testManager(t)
+	m.reboots = newRebootSpacer(2*time.Minute, hooks.Clock)
+	m.policy.(*defaultPolicy).reboots = m.reboots
+
+	permitted := func(nodeName string) bool {
+		in := m.intentFor(intents.UpdatePerformed(intents.WithNodeName(nodeName)))
+		assert.Assert(t, in.Intrusive())
+		permit, _, err := m.policy.Check(&PolicyCheck{Intent: in, ClusterActive: 2, ClusterCount: 3})
+		assert.NilError(t, err)
+		if permit {
+			assert.NilError(t, m.takeAction(in))
+		}
+		return permit
+	}
+
+	assert.Check(t, permitted("node-a"), "first reboot should be permitted")
+	assert.Check(t, !permitted("node-b"), "reboot should wait on the minimum interval")
+	_, wait, err := m.policy.Check(&PolicyCheck{Intent: m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-b")))})
+	assert.NilError(t, err)
+	assert.Equal(t, wait, 2*time.Minute, "reboot should be checked again once the interval passes")
+	hooks.Clock.Step(time.Minute)
+	assert.Check(t, !permitted("node-b"))
+	hooks.Clock.Step(time.Minute)
+	assert.Check(t, permitted("node-b"), "reboot should be permitted after the minimum interval")
+	assert.Check(t, !permitted("node-c"), "interval should be measured from the latest reboot")
+	assert.Equal(t, len(hooks.Poster.calledIntents), 2)
+}
+
+func TestRebootSpacerDisabled(t *testing.T) {
+	s := newRebootSpacer(0, newTestClock())
+	assert.Check(t, s == nil)
+	s.Rebooted()
+	assert.Equal(t, s.Wait(), time.Duration(0))
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	defaultStatusInterval = time.Minute
+	// statusWriteTimeout bounds each write to a StatusSink so that a slow
+	// sink cannot hold up subsequent reports.
+	statusWriteTimeout = 10 * time.Second
+)
+
+// NodeStatus is a snapshot of a Node's update status.
+type NodeStatus struct {
+	NodeName string `json:"node"`
+	// Version is the version of the Node's active partition.
+	Version string `json:"version"`
+	// StagedVersion is the version of the Node's staging partition, if any.
+	StagedVersion   string            `json:"stagedVersion,omitempty"`
+	Wanted          marker.NodeAction `json:"wanted"`
+	Active          marker.NodeAction `json:"active"`
+	State           marker.NodeState  `json:"state"`
+	UpdateAvailable marker.NodeUpdate `json:"updateAvailable"`
+	// LastUpdated is the time the Node last completed an update, if known.
+	LastUpdated string `json:"lastUpdated,omitempty"`
+	// Launched is the time the Node joined the cluster.
+	Launched time.Time `json:"launched"`
+	// Observed is the time the snapshot was taken.
+	Observed time.Time `json:"observed"`
+}
+
+// StatusSink receives periodic snapshots of the managed Nodes' update status,
+// for example to publish them to an external datastore or dashboard.
+type StatusSink interface {
+	Write(ctx context.Context, statuses []NodeStatus) error
+}
+
+// statusReporter periodically writes the status of the managed Nodes to a
+// StatusSink. Reporting is best-effort, failed writes are logged and dropped.
+type statusReporter struct {
+	log      logging.Logger
+	sink     StatusSink
+	storer   storer
+	interval time.Duration
+	clock    clock.Clock
+}
+
+// Run reports the Nodes' status every interval until the context is done.
+func (r *statusReporter) Run(ctx context.Context) error {
+	for {
+		timer := r.clock.NewTimer(r.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+			r.report(ctx)
+		}
+	}
+}
+
+func (r *statusReporter) report(ctx context.Context) {
+	if r.storer == nil {
+		return
+	}
+	statuses := nodeStatuses(r.storer.GetStore().List(), r.clock.Now())
+	ctx, cancel := context.WithTimeout(ctx, statusWriteTimeout)
+	defer cancel()
+	err := r.sink.Write(ctx, statuses)
+	if err != nil {
+		r.log.WithError(err).Warn("unable to write node status")
+		return
+	}
+	r.log.WithField("nodes", len(statuses)).Debug("wrote node status")
+}
+
+// nodeStatuses snapshots the status of the listed Nodes, ordered by name.
+func nodeStatuses(resources []interface{}, observed time.Time) []NodeStatus {
+	statuses := []NodeStatus{}
+	for _, res := range resources {
+		node, ok := res.(*v1.Node)
+		if !ok {
+			continue
+		}
+		in := intent.Given(node)
+		statuses = append(statuses, NodeStatus{
+			NodeName:        node.Name,
+			Version:         node.Annotations[marker.ActivePartitionKey],
+			StagedVersion:   node.Annotations[marker.StagingPartitionKey],
+			Wanted:          in.Wanted,
+			Active:          in.Active,
+			State:           in.State,
+			UpdateAvailable: in.UpdateAvailable,
+			LastUpdated:     node.Annotations[marker.LastUpdatedKey],
+			Launched:        node.CreationTimestamp.Time,
+			Observed:        observed,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeName < statuses[j].NodeName
+	})
+	return statuses
+}
+
+// HTTPStatusSink posts the Nodes' status as JSON to a URL, for example:
+// {"nodes": [{"node": "ip-10-0-0-1", "version": "1.0.0", ...}]}
+type HTTPStatusSink struct {
+	URL string
+	// Client is used to make the request, the default client is used when
+	// nil.
+	Client *http.Client
+}
+
+type httpStatusPayload struct {
+	Nodes []NodeStatus `json:"nodes"`
+}
+
+// Write posts the statuses to the sink's URL.
+func (h *HTTPStatusSink) Write(ctx context.Context, statuses []NodeStatus) error {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(httpStatusPayload{Nodes: statuses})
+	if err != nil {
+		return errors.Wrap(err, "unable to encode status")
+	}
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create status request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "status request error")
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.Errorf("bad http response, status code: %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"k8s.io/client-go/tools/cache"
+)
+
+type testStatusSink struct {
+	written chan []NodeStatus
+	err     error
+}
+
+func (s *testStatusSink) Write(_ context.Context, statuses []NodeStatus) error {
+	s.written <- statuses
+	return s.err
+}
+
+func testStatusStore(t *testing.T, launched time.Time) cache.Store {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	updating := testNode(intents.PendingUpdate(intents.WithNodeName("node-b")), launched)
+	updating.Annotations[marker.ActivePartitionKey] = "1.0.0"
+	updating.Annotations[marker.StagingPartitionKey] = "1.1.0"
+	assert.NilError(t, store.Add(updating))
+	assert.NilError(t, store.Add(testNode(intents.Stabilized(intents.WithNodeName("node-a")), launched)))
+	return store
+}
+
+func TestStatusReporter(t *testing.T) {
+	launched := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	clk := newTestClock()
+	sink := &testStatusSink{
+		written: make(chan []NodeStatus, 1),
+		// Failures are dropped, reporting continues regardless.
+		err: errors.New("sink unavailable"),
+	}
+	r := &statusReporter{
+		log:      testoutput.Logger(t, logging.New("status")),
+		sink:     sink,
+		storer:   &testStorer{testStatusStore(t, launched)},
+		interval: time.Minute,
+		clock:    clk,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	for i := 0; i < 2; i++ {
+		for !clk.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+		clk.Step(time.Minute)
+		statuses := <-sink.written
+		assert.Equal(t, len(statuses), 2)
+		assert.Equal(t, statuses[0].NodeName, "node-a")
+		assert.Equal(t, statuses[0].State, marker.NodeStateReady)
+		assert.Equal(t, statuses[1].NodeName, "node-b")
+		assert.Equal(t, statuses[1].Version, "1.0.0")
+		assert.Equal(t, statuses[1].StagedVersion, "1.1.0")
+		assert.Equal(t, statuses[1].Wanted, marker.NodeActionPerformUpdate)
+		assert.Equal(t, statuses[1].Launched, launched)
+		assert.Equal(t, statuses[1].Observed, clk.Now())
+	}
+
+	cancel()
+	assert.NilError(t, <-done)
+}
+
+func TestHTTPStatusSink(t *testing.T) {
+	var received httpStatusPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, r.Method == http.MethodPost)
+		assert.Check(t, r.Header.Get("Content-Type") == "application/json")
+		assert.Check(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	statuses := nodeStatuses(testStatusStore(t, time.Now()).List(), time.Now())
+	sink := &HTTPStatusSink{URL: server.URL}
+	assert.NilError(t, sink.Write(context.Background(), statuses))
+	assert.Equal(t, len(received.Nodes), 2)
+	assert.Equal(t, received.Nodes[1].NodeName, "node-b")
+	assert.Equal(t, received.Nodes[1].Version, "1.0.0")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	sink = &HTTPStatusSink{URL: failing.URL}
+	assert.Check(t, sink.Write(context.Background(), statuses) != nil)
+}
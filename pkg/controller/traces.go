@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/tracing"
+)
+
+// intentTraces tracks the spans of the Nodes' Intents from when they're queued
+// until they're handled. Each update is traced from the Intent that begins it,
+// the Intents after join its trace through the traceparent posted to the Node
+// with marker.TraceParentKey. A nil intentTraces traces nothing.
+type intentTraces struct {
+	tracer *tracing.Tracer
+
+	mu sync.Mutex
+	// queued are the spans of the Intents waiting to be handled.
+	queued map[string]*tracing.Span
+	// handling are the spans of the Intents being handled.
+	handling map[string]*tracing.Span
+}
+
+func newIntentTraces(tracer *tracing.Tracer) *intentTraces {
+	if tracer == nil {
+		return nil
+	}
+	return &intentTraces{
+		tracer:   tracer,
+		queued:   map[string]*tracing.Span{},
+		handling: map[string]*tracing.Span{},
+	}
+}
+
+// Queued starts the span of the Intent queued for the Node, ending the span of
+// any Intent it supersedes.
+func (t *intentTraces) Queued(node intent.Input, in *intent.Intent) {
+	if t == nil {
+		return
+	}
+	var parent tracing.SpanContext
+	if !beginsUpdate(in) {
+		parent = tracing.ParseTraceparent(node.GetAnnotations()[marker.TraceParentKey])
+	}
+	span := t.tracer.Start("controller.intent", parent)
+	span.SetAttribute("k8s.node.name", in.NodeName)
+	span.SetAttribute("intent.wanted", in.Wanted)
+	span.SetAttribute("intent.active", in.Active)
+	span.SetAttribute("intent.state", in.State)
+
+	t.mu.Lock()
+	superseded := t.queued[in.NodeName]
+	t.queued[in.NodeName] = span
+	t.mu.Unlock()
+	if superseded != nil {
+		superseded.SetAttribute("intent.superseded", "true")
+		superseded.End(nil)
+	}
+}
+
+// Handling takes the span of the Node's queued Intent as it's handled.
+func (t *intentTraces) Handling(nodeName string) *tracing.Span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := t.queued[nodeName]
+	delete(t.queued, nodeName)
+	t.handling[nodeName] = span
+	return span
+}
+
+// Span is the span of the Intent being handled for the Node.
+func (t *intentTraces) Span(nodeName string) *tracing.Span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.handling[nodeName]
+}
+
+// Requeue returns the span of the Intent being handled to the queue, as when
+// it's to be checked again later, unless another Intent was queued since.
+func (t *intentTraces) Requeue(nodeName string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	span := t.handling[nodeName]
+	delete(t.handling, nodeName)
+	_, superseded := t.queued[nodeName]
+	if !superseded {
+		t.queued[nodeName] = span
+	}
+	t.mu.Unlock()
+	if superseded {
+		span.End(nil)
+	}
+}
+
+// Done ends the span of the Intent handled for the Node with the outcome of
+// its action.
+func (t *intentTraces) Done(nodeName string, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	span := t.handling[nodeName]
+	delete(t.handling, nodeName)
+	t.mu.Unlock()
+	span.End(err)
+}
+
+// Propagate adds the traceparent of the Intent being handled for the Node to
+// the annotations posted with it, so that the Agent's spans and the Intents
+// that follow join its trace.
+func (t *intentTraces) Propagate(nodeName string, annotations marker.Annotations) marker.Annotations {
+	traceparent := t.Span(nodeName).Context().Traceparent()
+	if traceparent == "" {
+		return annotations
+	}
+	if annotations == nil {
+		annotations = marker.Annotations{}
+	}
+	annotations[marker.TraceParentKey] = traceparent
+	return annotations
+}
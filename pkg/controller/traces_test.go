@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	v1meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/tracing"
+)
+
+// exportedSpan is the part of an exported span checked by the tests.
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+}
+
+// testTracer creates a Tracer that exports to a collector, returning the
+// spans the collector received once flushed. The collector is closed with the
+// returned func.
+func testTracer(t *testing.T) (*tracing.Tracer, func() []exportedSpan, func()) {
+	var spans []exportedSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		assert.Check(t, json.NewDecoder(r.Body).Decode(&req))
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	tracer, err := tracing.NewTracer(testoutput.Logger(t, logging.New("tracing")), "controller", collector.URL+"/v1/traces")
+	assert.NilError(t, err)
+	return tracer, func() []exportedSpan {
+		assert.NilError(t, tracer.Flush(context.Background()))
+		return spans
+	}, collector.Close
+}
+
+func TestManagerTraces(t *testing.T) {
+	m, hooks := testManager(t)
+	tracer, exported, done := testTracer(t)
+	defer done()
+	m.traces = newIntentTraces(tracer)
+	handle := func(node intent.Input, in *intent.Intent) {
+		m.traces.Queued(node, in)
+		m.traces.Handling(in.NodeName)
+		err := m.takeAction(in)
+		m.traces.Done(in.NodeName, err)
+		assert.NilError(t, err)
+	}
+
+	begin := intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()
+	handle(begin, begin)
+	posted := hooks.Poster.calledAnnotations[0]
+	assert.Check(t, posted[marker.UpdateStartedAtKey] != "", "trace should be posted alongside the other annotations")
+	began := tracing.ParseTraceparent(posted[marker.TraceParentKey])
+	assert.Assert(t, began.Valid())
+
+	// The Node's next Intent joins the update's trace.
+	node := &v1.Node{ObjectMeta: v1meta.ObjectMeta{Name: "node-a", Annotations: posted}}
+	handle(node, m.intentFor(intents.UpdatePerformed(intents.WithNodeName("node-a"))))
+	rebooting := tracing.ParseTraceparent(hooks.Poster.calledAnnotations[1][marker.TraceParentKey])
+	assert.Equal(t, rebooting.TraceID, began.TraceID)
+	assert.Check(t, rebooting.SpanID != began.SpanID)
+
+	spans := exported()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+		assert.Check(t, span.TraceID == spans[0].TraceID, "spans should be in one trace")
+	}
+	assert.DeepEqual(t, names, []string{"controller.intent", "controller.cordon", "controller.drain", "controller.intent"})
+	assert.Equal(t, spans[0].ParentSpanID, "", "update should begin its trace")
+	assert.Equal(t, spans[1].ParentSpanID, spans[3].SpanID)
+	assert.Equal(t, spans[3].ParentSpanID, spans[0].SpanID)
+}
+
+func TestIntentTracesSuperseded(t *testing.T) {
+	tracer, exported, done := testTracer(t)
+	defer done()
+	traces := newIntentTraces(tracer)
+	first := intents.UpdatePrepared(intents.WithNodeName("node-a"))
+	traces.Queued(first, first)
+	second := intents.UpdatePerformed(intents.WithNodeName("node-a"))
+	traces.Queued(second, second)
+	assert.Equal(t, len(exported()), 1, "superseded span should be ended")
+
+	handling := traces.Handling("node-a")
+	traces.Requeue("node-a")
+	assert.Equal(t, traces.Handling("node-a"), handling, "requeued span should be handled again")
+	traces.Done("node-a", nil)
+	assert.Equal(t, len(exported()), 2)
+}
+
+func TestIntentTracesDisabled(t *testing.T) {
+	traces := newIntentTraces(nil)
+	assert.Check(t, traces == nil)
+	in := intents.UpdatePrepared(intents.WithNodeName("node-a"))
+	traces.Queued(in, in)
+	assert.Check(t, traces.Handling("node-a") == nil)
+	traces.Done("node-a", nil)
+	assert.Check(t, traces.Propagate("node-a", nil) == nil, "nothing should be posted without tracing")
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var _ nodestream.Handler = (*unmarkedDetector)(nil)
+
+// unmarkedDetector reports Nodes that match its selector, and so are expected
+// to be managed, but are missing the operator's marker label and don't take
+// part in updates. It may also label them for management.
+type unmarkedDetector struct {
+	log      logging.Logger
+	selector labels.Selector
+	// label, when set, labels the unmarked Nodes for management.
+	label   bool
+	version marker.PlatformVersion
+	poster  markerPoster
+	storer  storer
+	// reported are the unmarked Nodes already reported, to report each Node
+	// once.
+	reported map[string]bool
+}
+
+func newUnmarkedDetector(log logging.Logger, config Config, poster markerPoster) (*unmarkedDetector, error) {
+	selector, err := labels.Parse(config.ManagedSelector)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid managed selector")
+	}
+	if selector.Empty() {
+		return nil, errors.New("managed selector must not select all nodes")
+	}
+	return &unmarkedDetector{
+		log:      log,
+		selector: selector,
+		label:    config.LabelUnmarked,
+		version:  config.autoLabelInterfaceVersion(),
+		poster:   poster,
+		reported: map[string]bool{},
+	}, nil
+}
+
+// streamConfig is the nodestream configuration needed to observe the Nodes
+// expected to be managed.
+func (ud *unmarkedDetector) streamConfig() nodestream.Config {
+	return nodestream.Config{
+		Unmanaged:          true,
+		LabelSelectorExtra: ud.selector.String(),
+	}
+}
+
+// unmarked reports whether the Node is expected to be managed and is missing
+// the marker label.
+func (ud *unmarkedDetector) unmarked(node *v1.Node) bool {
+	if _, ok := node.Labels[marker.NodeSelectorLabel]; ok {
+		return false
+	}
+	return ud.selector.Matches(labels.Set(node.Labels))
+}
+
+func (ud *unmarkedDetector) OnAdd(node *v1.Node) {
+	ud.check(node)
+	ud.publish()
+}
+
+func (ud *unmarkedDetector) OnUpdate(_ *v1.Node, node *v1.Node) {
+	ud.check(node)
+	ud.publish()
+}
+
+func (ud *unmarkedDetector) OnDelete(node *v1.Node) {
+	if node != nil {
+		delete(ud.reported, node.GetName())
+	}
+	ud.publish()
+}
+
+func (ud *unmarkedDetector) check(node *v1.Node) {
+	name := node.GetName()
+	if !ud.unmarked(node) {
+		delete(ud.reported, name)
+		return
+	}
+	log := ud.log.WithField("node", name)
+	if !ud.label {
+		if !ud.reported[name] {
+			log.Warn("node matches managed selector but is missing the management label")
+			ud.reported[name] = true
+		}
+		return
+	}
+	err := ud.poster.PostMarkers(name, marker.Labels{
+		marker.NodeSelectorLabel: ud.version,
+	})
+	if err != nil {
+		log.WithError(err).Error("unable to label unmarked node for management")
+		return
+	}
+	log.Info("labeled unmarked node for management")
+}
+
+func (ud *unmarkedDetector) publish() {
+	if ud.storer == nil {
+		return
+	}
+	count := 0
+	for _, res := range ud.storer.GetStore().List() {
+		node, ok := res.(*v1.Node)
+		if ok && ud.unmarked(node) {
+			count++
+		}
+	}
+	metrics.NodesUnmarked.Set(float64(count))
+}
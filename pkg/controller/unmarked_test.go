@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/marker"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func testUnmarkedStore(t *testing.T) cache.Store {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, node := range []*v1.Node{
+		testLabelNode("unmarked-a", map[string]string{"os": "bottlerocket"}),
+		testLabelNode("unmarked-b", map[string]string{"os": "bottlerocket"}),
+		testLabelNode("managed", map[string]string{
+			"os":                     "bottlerocket",
+			marker.NodeSelectorLabel: "2.0.0",
+		}),
+		testLabelNode("other", map[string]string{"os": "linux"}),
+	} {
+		assert.NilError(t, store.Add(node))
+	}
+	return store
+}
+
+func TestUnmarkedDetector(t *testing.T) {
+	store := testUnmarkedStore(t)
+	poster := &testingPoster{}
+	ud, err := newUnmarkedDetector(testoutput.Logger(t, logging.New("unmarked")), Config{
+		ManagedSelector: "os=bottlerocket",
+	}, poster)
+	assert.NilError(t, err)
+	ud.storer = &testStorer{store}
+
+	for _, res := range store.List() {
+		ud.OnAdd(res.(*v1.Node))
+	}
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesUnmarked), float64(2))
+	assert.DeepEqual(t, ud.reported, map[string]bool{"unmarked-a": true, "unmarked-b": true})
+	assert.Equal(t, len(poster.calledMarkers), 0, "nodes should only be reported")
+
+	// Nodes are no longer reported once labeled.
+	labeled := testLabelNode("unmarked-a", map[string]string{
+		"os":                     "bottlerocket",
+		marker.NodeSelectorLabel: "2.0.0",
+	})
+	assert.NilError(t, store.Update(labeled))
+	ud.OnUpdate(nil, labeled)
+	assert.Equal(t, testutil.ToFloat64(metrics.NodesUnmarked), float64(1))
+	assert.DeepEqual(t, ud.reported, map[string]bool{"unmarked-b": true})
+}
+
+func TestUnmarkedDetectorLabel(t *testing.T) {
+	poster := &testingPoster{}
+	ud, err := newUnmarkedDetector(testoutput.Logger(t, logging.New("unmarked")), Config{
+		ManagedSelector: "os=bottlerocket",
+		LabelUnmarked:   true,
+	}, poster)
+	assert.NilError(t, err)
+
+	for _, res := range testUnmarkedStore(t).List() {
+		ud.OnAdd(res.(*v1.Node))
+	}
+	sort.Strings(poster.markedNodes)
+	assert.DeepEqual(t, poster.markedNodes, []string{"unmarked-a", "unmarked-b"})
+	for _, markers := range poster.calledMarkers {
+		assert.DeepEqual(t, markers, marker.Labels{
+			marker.NodeSelectorLabel: defaultAutoLabelInterfaceVersion,
+		})
+	}
+}
+
+func TestUnmarkedDetectorInvalidSelector(t *testing.T) {
+	for _, selector := range []string{"os in (", " "} {
+		_, err := newUnmarkedDetector(testoutput.Logger(t, logging.New("unmarked")), Config{
+			ManagedSelector: selector,
+		}, &testingPoster{})
+		assert.Check(t, err != nil, "selector %q should be rejected", selector)
+	}
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// maintenanceWindow is a daily span of time during which updates may begin,
+// given as offsets from midnight. A window whose end is before its start
+// spans midnight.
+type maintenanceWindow struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// contains reports whether the time of day, as an offset from midnight, falls
+// within the window.
+func (w maintenanceWindow) contains(offset time.Duration) bool {
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+func (w maintenanceWindow) String() string {
+	return formatTimeOfDay(w.start) + "-" + formatTimeOfDay(w.end)
+}
+
+// maintenanceSchedule permits updates to begin only during its windows, as
+// observed in its time zone.
+type maintenanceSchedule struct {
+	windows  []maintenanceWindow
+	location *time.Location
+	clock    clock.Clock
+}
+
+// newMaintenanceSchedule parses the comma separated windows, each given as
+// "HH:MM-HH:MM", observed in the named time zone. There's no schedule, and
+// updates may begin at any time, when no windows are given.
+func newMaintenanceSchedule(spec string, timezone string, clk clock.Clock) (*maintenanceSchedule, error) {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var windows []maintenanceWindow
+	for _, field := range strings.Split(spec, ",") {
+		window, err := parseMaintenanceWindow(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return &maintenanceSchedule{windows: windows, location: location, clock: clk}, nil
+}
+
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid maintenance timezone %q", timezone)
+	}
+	return location, nil
+}
+
+func parseMaintenanceWindow(field string) (maintenanceWindow, error) {
+	parts := strings.Split(field, "-")
+	if len(parts) != 2 {
+		return maintenanceWindow{}, errors.Errorf("invalid maintenance window %q, must be HH:MM-HH:MM", field)
+	}
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return maintenanceWindow{}, errors.WithMessagef(err, "invalid maintenance window %q", field)
+	}
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return maintenanceWindow{}, errors.WithMessagef(err, "invalid maintenance window %q", field)
+	}
+	if start == end {
+		return maintenanceWindow{}, errors.Errorf("invalid maintenance window %q, must not be empty", field)
+	}
+	return maintenanceWindow{start: start, end: end}, nil
+}
+
+// parseTimeOfDay parses "HH:MM" as an offset from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, errors.Errorf("invalid time of day %q, must be HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatTimeOfDay(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
+
+// Open reports whether the current time falls within one of the windows.
+func (s *maintenanceSchedule) Open() bool {
+	if s == nil {
+		return true
+	}
+	return s.open(s.clock.Now())
+}
+
+func (s *maintenanceSchedule) open(now time.Time) bool {
+	local := now.In(s.location)
+	offset := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+	for _, window := range s.windows {
+		if window.contains(offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait returns the time remaining until one of the windows next opens, there's
+// no wait while one is open.
+func (s *maintenanceSchedule) Wait() time.Duration {
+	if s == nil {
+		return 0
+	}
+	now := s.clock.Now()
+	if s.open(now) {
+		return 0
+	}
+	local := now.In(s.location)
+	var wait time.Duration
+	for _, window := range s.windows {
+		opens := time.Date(local.Year(), local.Month(), local.Day(),
+			int(window.start/time.Hour), int(window.start%time.Hour/time.Minute), 0, 0, s.location)
+		if !opens.After(now) {
+			opens = opens.AddDate(0, 0, 1)
+		}
+		if until := opens.Sub(now); wait == 0 || until < wait {
+			wait = until
+		}
+	}
+	return wait
+}
+
+func (s *maintenanceSchedule) String() string {
+	if s == nil {
+		return ""
+	}
+	windows := make([]string, len(s.windows))
+	for i, window := range s.windows {
+		windows[i] = window.String()
+	}
+	return strings.Join(windows, ",")
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/nodestream"
+	"gotest.tools/assert"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestMaintenanceSchedule(t *testing.T) {
+	s, err := newMaintenanceSchedule("22:00-04:00, 12:00-13:30", "", newTestClock())
+	assert.NilError(t, err)
+	assert.Equal(t, s.String(), "22:00-04:00,12:00-13:30")
+	assert.Equal(t, s.location, time.UTC)
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2020, 7, 10, hour, min, 0, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		time time.Time
+		open bool
+	}{
+		{at(21, 59), false},
+		{at(22, 0), true},
+		{at(0, 0), true},
+		{at(3, 59), true},
+		{at(4, 0), false},
+		{at(11, 59), false},
+		{at(12, 0), true},
+		{at(13, 29), true},
+		{at(13, 30), false},
+	} {
+		assert.Equal(t, s.open(tc.time), tc.open, "at %s", tc.time.Format("15:04"))
+	}
+
+	// The windows are observed in the schedule's time zone.
+	s.location = time.FixedZone("UTC-8", -8*60*60)
+	assert.Check(t, !s.open(at(22, 0)), "22:00 UTC is 14:00 at UTC-8")
+	assert.Check(t, s.open(at(6, 0)), "06:00 UTC is 22:00 at UTC-8")
+}
+
+func TestMaintenanceScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"22:00",
+		"22:00-04:00-06:00",
+		"25:00-04:00",
+		"22:00-4pm",
+		"22:00-22:00",
+		"22:00-04:00,",
+	} {
+		_, err := newMaintenanceSchedule(spec, "", newTestClock())
+		assert.ErrorContains(t, err, "invalid maintenance window", "spec %q", spec)
+	}
+	_, err := newMaintenanceSchedule("22:00-04:00", "Nowhere/Special", newTestClock())
+	assert.ErrorContains(t, err, "invalid maintenance timezone")
+	_, err = newManager(testoutput.Logger(t, logging.New("manager")), nil, "test-node", Config{MaintenanceWindows: "22:00"})
+	assert.ErrorContains(t, err, "invalid maintenance window")
+}
+
+func TestMaintenanceScheduleDisabled(t *testing.T) {
+	s, err := newMaintenanceSchedule("", "", newTestClock())
+	assert.NilError(t, err)
+	assert.Check(t, s == nil)
+	assert.Check(t, s.Open())
+	assert.Equal(t, s.Wait(), time.Duration(0))
+	assert.Equal(t, s.String(), "")
+}
+
+func TestPolicyMaintenanceWindows(t *testing.T) {
+	m, hooks := testManager(t)
+	windows, err := newMaintenanceSchedule("01:00-03:00", "UTC", hooks.Clock)
+	assert.NilError(t, err)
+	m.windows = windows
+	m.policy.(*defaultPolicy).windows = windows
+
+	permitted := func(in *intent.Intent) bool {
+		permit, _, err := m.policy.Check(&PolicyCheck{Intent: in, ClusterActive: 0, ClusterCount: 3})
+		assert.NilError(t, err)
+		return permit
+	}
+	beginning := func() *intent.Intent {
+		return intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()
+	}
+
+	// The test clock starts at midnight, before the window opens.
+	assert.Check(t, !permitted(beginning()), "update should wait for the window to open")
+	_, wait, err := m.policy.Check(&PolicyCheck{Intent: beginning(), ClusterCount: 3})
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Hour, "update should be checked again once the window opens")
+	hooks.Clock.Step(time.Hour)
+	assert.Check(t, permitted(beginning()), "update should begin once the window opens")
+	hooks.Clock.Step(2 * time.Hour)
+	assert.Check(t, !permitted(beginning()), "update should wait for the next window")
+	assert.Equal(t, windows.Wait(), 22*time.Hour)
+	assert.Check(t, permitted(intents.PendingRebootUpdate(intents.WithNodeName("node-b"))),
+		"update in progress should be permitted to finish after the window closes")
+
+	assert.Equal(t, m.streamConfig().ResyncPeriod, maintenanceResyncPeriod)
+	m.windows = nil
+	assert.Equal(t, m.streamConfig().ResyncPeriod, nodestream.DefaultResyncPeriod)
+}
+
+func TestProcessOutsideMaintenanceWindows(t *testing.T) {
+	m, hooks := testManager(t)
+	windows, err := newMaintenanceSchedule("01:00-03:00", "UTC", hooks.Clock)
+	assert.NilError(t, err)
+	m.policy.(*defaultPolicy).windows = windows
+	in := intents.Stabilized(intents.WithNodeName("node-a"), intents.WithUpdateAvailable()).SetBeginUpdate()
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	assert.NilError(t, store.Add(testNode(in, hooks.Clock.Now())))
+	m.SetStoreProvider(&testStorer{store})
+
+	assert.NilError(t, m.process("node-a", in))
+	assert.Equal(t, len(hooks.Poster.calledIntents), 0)
+	assert.Equal(t, m.queue.Len(), 1, "denied intent should be checked again once the window opens")
+}
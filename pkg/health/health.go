@@ -0,0 +1,204 @@
+// Package health reports the liveness and readiness of the operator's
+// processes over HTTP, for Kubernetes to probe.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// LivePath is the HTTP path that liveness is served on.
+	LivePath = "/healthz"
+	// ReadyPath is the HTTP path that readiness is served on.
+	ReadyPath = "/readyz"
+)
+
+// Status tracks the health of a process's informers and worker loops. The
+// process is live while its loops are running and ready once its informers
+// have synced and its loops have recently succeeded. A nil Status tracks
+// nothing.
+type Status struct {
+	mu     sync.Mutex
+	clock  clock.Clock
+	synced map[string]func() bool
+	loops  map[string]*loop
+}
+
+// loop is the state of a worker loop.
+type loop struct {
+	// stale is how long the loop may go without succeeding before the process
+	// isn't ready, the loop's successes aren't considered when zero.
+	stale time.Duration
+	// succeeded is when the loop last succeeded, or started if it has yet to.
+	succeeded time.Time
+	failed    error
+	// stopped is set when the loop returned while the process was running.
+	stopped error
+}
+
+// NewStatus creates an empty Status.
+func NewStatus(clk clock.Clock) *Status {
+	return &Status{
+		clock:  clk,
+		synced: map[string]func() bool{},
+		loops:  map[string]*loop{},
+	}
+}
+
+// Synced adds the informer's sync status, such as its HasSynced, to the
+// process's readiness.
+func (s *Status) Synced(name string, hasSynced func() bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synced[name] = hasSynced
+}
+
+// Work wraps the worker loop to track that it's running. A loop returning
+// while its context is live leaves the process no longer live, a loop that
+// returns as it's canceled is forgotten. With a stale duration, the loop must
+// report its success within that duration for the process to be ready.
+func (s *Status) Work(name string, stale time.Duration, fn func(context.Context) error) func(context.Context) error {
+	if s == nil {
+		return fn
+	}
+	return func(ctx context.Context) error {
+		s.mu.Lock()
+		s.loops[name] = &loop{stale: stale, succeeded: s.clock.Now()}
+		s.mu.Unlock()
+
+		err := fn(ctx)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ctx.Err() != nil {
+			delete(s.loops, name)
+			return err
+		}
+		stopped := err
+		if stopped == nil {
+			stopped = errors.New("returned")
+		}
+		if l, ok := s.loops[name]; ok {
+			l.stopped = stopped
+		}
+		return err
+	}
+}
+
+// Succeeded notes that an iteration of the loop succeeded.
+func (s *Status) Succeeded(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.loops[name]; ok {
+		l.succeeded = s.clock.Now()
+		l.failed = nil
+	}
+}
+
+// Failed notes that an iteration of the loop failed.
+func (s *Status) Failed(name string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.loops[name]; ok {
+		l.failed = err
+	}
+}
+
+// Live reports why the process isn't live, if it isn't.
+func (s *Status) Live() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range s.loopNames() {
+		if stopped := s.loops[name].stopped; stopped != nil {
+			return errors.WithMessagef(stopped, "%s stopped", name)
+		}
+	}
+	return nil
+}
+
+// Ready reports why the process isn't ready, if it isn't.
+func (s *Status) Ready() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.synced))
+	for name := range s.synced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !s.synced[name]() {
+			return errors.Errorf("%s has not synced", name)
+		}
+	}
+	for _, name := range s.loopNames() {
+		l := s.loops[name]
+		if l.stopped != nil {
+			return errors.WithMessagef(l.stopped, "%s stopped", name)
+		}
+		since := s.clock.Since(l.succeeded)
+		if l.stale <= 0 || since <= l.stale {
+			continue
+		}
+		if l.failed != nil {
+			return errors.WithMessagef(l.failed, "%s has not succeeded in %s", name, since.Round(time.Second))
+		}
+		return errors.Errorf("%s has not succeeded in %s", name, since.Round(time.Second))
+	}
+	return nil
+}
+
+func (s *Status) loopNames() []string {
+	names := make([]string, 0, len(s.loops))
+	for name := range s.loops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LiveHandler serves the process's liveness.
+func (s *Status) LiveHandler() http.Handler {
+	return probeHandler(s.Live)
+}
+
+// ReadyHandler serves the process's readiness.
+func (s *Status) ReadyHandler() http.Handler {
+	return probeHandler(s.Ready)
+}
+
+// probeHandler responds OK when the check passes and Service Unavailable with
+// the reason when it doesn't.
+func probeHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
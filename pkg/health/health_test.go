@@ -0,0 +1,124 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// runLoop runs the loop wrapped by the Status until it's stopped, returning
+// once the loop has started.
+func runLoop(ctx context.Context, s *Status, name string, stale time.Duration) (stop func(error), done <-chan error) {
+	started := make(chan struct{})
+	stopped := make(chan error)
+	finished := make(chan error, 1)
+	work := s.Work(name, stale, func(ctx context.Context) error {
+		close(started)
+		select {
+		case err := <-stopped:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	})
+	go func() { finished <- work(ctx) }()
+	<-started
+	return func(err error) { stopped <- err }, finished
+}
+
+func TestStatusSynced(t *testing.T) {
+	s := NewStatus(clock.NewFakeClock(time.Now()))
+	synced := false
+	s.Synced("informer", func() bool { return synced })
+	assert.Error(t, s.Ready(), "informer has not synced")
+	assert.NilError(t, s.Live())
+
+	synced = true
+	assert.NilError(t, s.Ready())
+}
+
+func TestStatusLoopStopped(t *testing.T) {
+	s := NewStatus(clock.NewFakeClock(time.Now()))
+	stop, done := runLoop(context.Background(), s, "manager", 0)
+	assert.NilError(t, s.Live())
+	assert.NilError(t, s.Ready())
+
+	stop(errors.New("input channel closed"))
+	assert.Error(t, <-done, "input channel closed")
+	assert.Error(t, s.Live(), "manager stopped: input channel closed")
+	assert.Error(t, s.Ready(), "manager stopped: input channel closed")
+}
+
+func TestStatusLoopCanceled(t *testing.T) {
+	s := NewStatus(clock.NewFakeClock(time.Now()))
+	ctx, cancel := context.WithCancel(context.Background())
+	_, done := runLoop(ctx, s, "manager", 0)
+
+	// Loops stopped with the process are forgotten.
+	cancel()
+	assert.NilError(t, <-done)
+	assert.NilError(t, s.Live())
+	assert.NilError(t, s.Ready())
+}
+
+func TestStatusLoopStale(t *testing.T) {
+	clk := clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC))
+	s := NewStatus(clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runLoop(ctx, s, "update-checker", time.Hour)
+
+	// The loop is given the stale duration to first succeed.
+	clk.Step(time.Hour)
+	assert.NilError(t, s.Ready())
+	s.Failed("update-checker", errors.New("update API unavailable"))
+	clk.Step(time.Minute)
+	assert.Error(t, s.Ready(), "update-checker has not succeeded in 1h1m0s: update API unavailable")
+	assert.NilError(t, s.Live(), "failing loops are still running")
+
+	s.Succeeded("update-checker")
+	assert.NilError(t, s.Ready())
+}
+
+func TestStatusNil(t *testing.T) {
+	var s *Status
+	called := false
+	work := s.Work("manager", time.Minute, func(context.Context) error {
+		called = true
+		return nil
+	})
+	assert.NilError(t, work(context.Background()))
+	assert.Check(t, called)
+	s.Synced("informer", func() bool { return false })
+	s.Failed("manager", errors.New("failed"))
+	assert.NilError(t, s.Live())
+	assert.NilError(t, s.Ready())
+}
+
+func TestHandlers(t *testing.T) {
+	s := NewStatus(clock.NewFakeClock(time.Now()))
+	synced := false
+	s.Synced("informer", func() bool { return synced })
+
+	rec := httptest.NewRecorder()
+	s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	assert.Equal(t, rec.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, strings.TrimSpace(rec.Body.String()), "informer has not synced")
+
+	rec = httptest.NewRecorder()
+	s.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivePath, nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, strings.TrimSpace(rec.Body.String()), "ok")
+
+	synced = true
+	rec = httptest.NewRecorder()
+	s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+	assert.Equal(t, rec.Code, http.StatusOK)
+}
@@ -6,10 +6,13 @@ import (
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
 
 	"github.com/karlseguin/ccache"
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
 const (
-	cacheTimeout = time.Second * 15
+	// DefaultTTL is the duration for which cached Intents are retained by
+	// default.
+	DefaultTTL = time.Second * 15
 )
 
 // LastCache provides access to the last cached Intent that came from the same
@@ -17,17 +20,41 @@ const (
 type LastCache interface {
 	Last(*intent.Intent) *intent.Intent
 	Record(*intent.Intent)
+	Forget(*intent.Intent)
 }
 
 type lastCache struct {
 	cache *ccache.Cache
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// cachedIntent is a cached Intent and when it's no longer considered.
+type cachedIntent struct {
+	in      *intent.Intent
+	expires time.Time
 }
 
 // NewLastCache creates a general cache suitable for storing and retrieving the
 // last observed Intent given its source.
 func NewLastCache() LastCache {
+	return NewLastCacheTTL(DefaultTTL)
+}
+
+// NewLastCacheTTL creates a LastCache that retains each Intent for no longer
+// than the provided ttl. A non-positive ttl uses the DefaultTTL.
+func NewLastCacheTTL(ttl time.Duration) LastCache {
+	return newLastCache(ttl, clock.RealClock{})
+}
+
+func newLastCache(ttl time.Duration, clk clock.Clock) *lastCache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
 	return &lastCache{
 		cache: ccache.New(ccache.Configure().MaxSize(1000).ItemsToPrune(100)),
+		ttl:   ttl,
+		clock: clk,
 	}
 }
 
@@ -40,11 +67,13 @@ func (i *lastCache) Last(in *intent.Intent) *intent.Intent {
 	if val == nil {
 		return nil
 	}
-	if val.Expired() {
+	cached, ok := val.Value().(*cachedIntent)
+	if !ok {
 		return nil
 	}
-	lastCachedIntent, ok := val.Value().(*intent.Intent)
-	if !ok {
+	if !i.clock.Now().Before(cached.expires) {
+		// Evict the stale Intent so that it's no longer considered.
+		i.cache.Delete(in.GetName())
 		return nil
 	}
 
@@ -52,7 +81,7 @@ func (i *lastCache) Last(in *intent.Intent) *intent.Intent {
 	// val.Extend(cacheExtension)
 
 	// Copy to protect against misuse of cached in-memory Intent.
-	return lastCachedIntent.Clone()
+	return cached.in.Clone()
 }
 
 // Record caches the provided Intent as the most recent Intent handled for a
@@ -61,5 +90,14 @@ func (i *lastCache) Record(in *intent.Intent) {
 	if in == nil {
 		return
 	}
-	i.cache.Set(in.GetName(), in.Clone(), cacheTimeout)
+	i.cache.Set(in.GetName(), &cachedIntent{in: in.Clone(), expires: i.clock.Now().Add(i.ttl)}, i.ttl)
+}
+
+// Forget drops the Intent cached for the provided Intent's source, so that the
+// same Intent is handled again when it's next received.
+func (i *lastCache) Forget(in *intent.Intent) {
+	if in == nil {
+		return
+	}
+	i.cache.Delete(in.GetName())
 }
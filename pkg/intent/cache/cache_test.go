@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestLastCacheRecord(t *testing.T) {
+	c := NewLastCache()
+	in := intents.Stabilized()
+	assert.Check(t, c.Last(in) == nil)
+	c.Record(in)
+	assert.Check(t, intent.Equivalent(c.Last(in), in))
+}
+
+func TestLastCacheForget(t *testing.T) {
+	c := NewLastCache()
+	in := intents.Stabilized()
+	c.Record(in)
+	c.Forget(in)
+	assert.Check(t, c.Last(in) == nil, "forgotten intent should not be deduplicated")
+}
+
+func TestLastCacheTTLExpiry(t *testing.T) {
+	ttl := 10 * time.Second
+	clk := clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC))
+	c := newLastCache(ttl, clk)
+	in := intents.Stabilized()
+	c.Record(in)
+	clk.Step(ttl - time.Second)
+	assert.Check(t, intent.Equivalent(c.Last(in), in), "intent should be deduplicated before expiry")
+
+	clk.Step(time.Second)
+
+	// The expired Intent is released so that it may be handled again.
+	assert.Check(t, c.Last(in) == nil, "intent should not be deduplicated after expiry")
+	// And remains released, having been evicted.
+	assert.Check(t, c.Last(in) == nil)
+	// Until it's handled, and recorded, again.
+	c.Record(in)
+	assert.Check(t, intent.Equivalent(c.Last(in), in))
+}
+
+func TestLastCacheDefaultTTL(t *testing.T) {
+	c := NewLastCacheTTL(0).(*lastCache)
+	assert.Equal(t, c.ttl, DefaultTTL)
+	c = NewLastCacheTTL(-time.Second).(*lastCache)
+	assert.Equal(t, c.ttl, DefaultTTL)
+}
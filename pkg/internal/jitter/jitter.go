@@ -0,0 +1,29 @@
+// Package jitter randomly spreads out delays, so that hosts waiting at the same
+// time don't all act at once.
+package jitter
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	mu sync.Mutex
+	// random is seeded so that hosts started at the same time don't share the
+	// same sequence.
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Float64 returns a random fraction in [0, 1), it's safe for concurrent use.
+func Float64() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return random.Float64()
+}
+
+// Delay moves the delay earlier or later by up to the fraction of the delay,
+// by the random fraction in [0, 1) given. The average delay is unchanged.
+func Delay(delay time.Duration, fraction float64, random float64) time.Duration {
+	return delay + time.Duration(float64(delay)*fraction*(2*random-1))
+}
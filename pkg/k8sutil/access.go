@@ -0,0 +1,108 @@
+package k8sutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+// Access is a permission needed to operate.
+type Access struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Verb        string
+}
+
+func (a Access) String() string {
+	resource := a.Resource
+	if a.Subresource != "" {
+		resource += "/" + a.Subresource
+	}
+	if a.Group != "" {
+		resource += "." + a.Group
+	}
+	return a.Verb + " " + resource
+}
+
+// nodeAccess is needed to watch and post markers to Nodes.
+var nodeAccess = []Access{
+	{Resource: "nodes", Verb: "get"},
+	{Resource: "nodes", Verb: "list"},
+	{Resource: "nodes", Verb: "watch"},
+	{Resource: "nodes", Verb: "update"},
+	{Resource: "nodes", Verb: "patch"},
+}
+
+// AgentAccess is the access needed by the Agent, which additionally patches
+// its Node's status with the update's conditions.
+var AgentAccess = append(append([]Access(nil), nodeAccess...),
+	Access{Resource: "nodes", Subresource: "status", Verb: "patch"},
+)
+
+// ControllerAccess is the access needed by the Controller, which additionally
+// drains Nodes of their Pods.
+var ControllerAccess = append(append([]Access(nil), nodeAccess...),
+	Access{Resource: "pods", Verb: "get"},
+	Access{Resource: "pods", Verb: "list"},
+	Access{Resource: "pods", Verb: "watch"},
+	Access{Resource: "pods", Verb: "delete"},
+	Access{Resource: "pods", Subresource: "eviction", Verb: "create"},
+	Access{Group: "apps", Resource: "daemonsets", Verb: "get"},
+	Access{Group: "policy", Resource: "poddisruptionbudgets", Verb: "list"},
+	Access{Resource: "events", Verb: "create"},
+	Access{Resource: "events", Verb: "patch"},
+)
+
+// LeaderElectionAccess is the additional access needed by the Controller to
+// hold the Lease electing the leading replica.
+var LeaderElectionAccess = []Access{
+	{Group: "coordination.k8s.io", Resource: "leases", Verb: "get"},
+	{Group: "coordination.k8s.io", Resource: "leases", Verb: "create"},
+	{Group: "coordination.k8s.io", Resource: "leases", Verb: "update"},
+}
+
+// MissingAccessError lists the access that was found to be denied.
+type MissingAccessError struct {
+	Missing []Access
+}
+
+func (e *MissingAccessError) Error() string {
+	missing := make([]string, len(e.Missing))
+	for i, access := range e.Missing {
+		missing[i] = access.String()
+	}
+	return fmt.Sprintf("missing RBAC permissions, grant them to the service account's ClusterRole: %s",
+		strings.Join(missing, ", "))
+}
+
+// CheckAccess reviews whether the client's own credentials permit the access
+// needed, returning a MissingAccessError listing any that are denied.
+func CheckAccess(reviews authorizationclient.SelfSubjectAccessReviewInterface, needed []Access) error {
+	var missing []Access
+	for _, access := range needed {
+		review, err := reviews.Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:       access.Group,
+					Resource:    access.Resource,
+					Subresource: access.Subresource,
+					Verb:        access.Verb,
+				},
+			},
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "unable to review access to %s", access)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, access)
+		}
+	}
+	if len(missing) != 0 {
+		return &MissingAccessError{Missing: missing}
+	}
+	return nil
+}
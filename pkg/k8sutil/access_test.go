@@ -0,0 +1,53 @@
+package k8sutil
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeReviews responds to access reviews, denying the given access.
+func fakeReviews(denied ...Access) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = true
+		for _, d := range denied {
+			if d == (Access{Group: attrs.Group, Resource: attrs.Resource, Subresource: attrs.Subresource, Verb: attrs.Verb}) {
+				review.Status.Allowed = false
+			}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func TestCheckAccessAllowed(t *testing.T) {
+	client := fakeReviews()
+	err := CheckAccess(client.AuthorizationV1().SelfSubjectAccessReviews(), ControllerAccess)
+	assert.NilError(t, err)
+}
+
+func TestCheckAccessDenied(t *testing.T) {
+	eviction := Access{Resource: "pods", Subresource: "eviction", Verb: "create"}
+	patch := Access{Resource: "nodes", Verb: "patch"}
+	client := fakeReviews(eviction, patch)
+
+	err := CheckAccess(client.AuthorizationV1().SelfSubjectAccessReviews(), ControllerAccess)
+	missingErr, ok := err.(*MissingAccessError)
+	assert.Assert(t, ok, "expected missing access error, got: %v", err)
+	assert.DeepEqual(t, missingErr.Missing, []Access{patch, eviction})
+	assert.ErrorContains(t, err, "create pods/eviction")
+	assert.ErrorContains(t, err, "patch nodes")
+
+	// The Agent doesn't need to evict.
+	err = CheckAccess(client.AuthorizationV1().SelfSubjectAccessReviews(), AgentAccess)
+	missingErr, ok = err.(*MissingAccessError)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, missingErr.Missing, []Access{patch})
+}
@@ -21,15 +21,47 @@ type WriteContainer interface {
 func OverwriteFrom(from Container, into WriteContainer) {
 	fromA := from.GetAnnotations()
 	intoA := into.GetAnnotations()
+	if intoA == nil {
+		intoA = make(map[string]string, len(fromA))
+	}
 	for k := range fromA {
 		intoA[k] = fromA[k]
 	}
 	fromL := from.GetLabels()
 	intoL := into.GetLabels()
-	for k := range fromA {
+	if intoL == nil {
+		intoL = make(map[string]string, len(fromL))
+	}
+	for k := range fromL {
 		intoL[k] = fromL[k]
 	}
 
 	into.SetAnnotations(intoA)
 	into.SetLabels(intoL)
 }
+
+// Annotations is a Container of annotations only, it has no labels.
+type Annotations map[string]string
+
+// GetAnnotations returns the annotations.
+func (a Annotations) GetAnnotations() map[string]string {
+	return a
+}
+
+// GetLabels returns an empty set of labels.
+func (a Annotations) GetLabels() map[string]string {
+	return map[string]string{}
+}
+
+// Labels is a Container of labels only, it has no annotations.
+type Labels map[string]string
+
+// GetAnnotations returns an empty set of annotations.
+func (l Labels) GetAnnotations() map[string]string {
+	return map[string]string{}
+}
+
+// GetLabels returns the labels.
+func (l Labels) GetLabels() map[string]string {
+	return l
+}
@@ -32,4 +32,94 @@ const (
 	// NodeActionActive provides the acknowledged and acted-upon action that was
 	// wanted of a Node.
 	NodeActionActive Key = Prefix + "/action-active"
+
+	// ActivePartitionKey reports the version of the image in the Node's active
+	// partition.
+	ActivePartitionKey Key = Prefix + "/active-partition"
+	// StagingPartitionKey reports the version of the image in the Node's
+	// staging partition, if any.
+	StagingPartitionKey Key = Prefix + "/staging-partition"
+	// NextToBootKey reports which of the Node's partitions will be booted next.
+	NextToBootKey Key = Prefix + "/next-to-boot"
+
+	// ChosenUpdateVersionKey, ChosenUpdateArchKey, and ChosenUpdateVariantKey
+	// describe the image of the update the Node would update to, they're
+	// cleared when no update is available.
+	ChosenUpdateVersionKey Key = Prefix + "/chosen-update-version"
+	ChosenUpdateArchKey    Key = Prefix + "/chosen-update-arch"
+	ChosenUpdateVariantKey Key = Prefix + "/chosen-update-variant"
+
+	// UpToDateKey reports the version the Node is up to date with when it has
+	// no update available, it's cleared when an update is available.
+	UpToDateKey Key = Prefix + "/up-to-date"
+	// UpdateCheckedKey reports the time, in RFC 3339 format, the Node last
+	// checked for an available update.
+	UpdateCheckedKey Key = Prefix + "/update-checked"
+
+	// UpdateCheckFailingKey reports the error of the Node's update checks
+	// once they've failed repeatedly, it's cleared when a check succeeds.
+	UpdateCheckFailingKey Key = Prefix + "/update-check-failing"
+
+	// LastUpdatedKey reports the time, in RFC 3339 format, the Node last
+	// completed an update successfully.
+	LastUpdatedKey Key = Prefix + "/last-updated"
+
+	// UpdateStartedAtKey and UpdateCompletedAtKey report the times, in RFC
+	// 3339 format, the Node began and finished its most recent update. The
+	// completion is cleared as the next update begins.
+	UpdateStartedAtKey   Key = Prefix + "/update-started-at"
+	UpdateCompletedAtKey Key = Prefix + "/update-completed-at"
+
+	// ActionStartedKey reports the time, in RFC 3339 format, the Node's Agent
+	// last began realizing an action.
+	ActionStartedKey Key = Prefix + "/action-started"
+
+	// UnsupportedOSKey reports the Node's OS version when it's too old to be
+	// updated by the Agent, such Nodes need to be updated manually. It's
+	// cleared on supported Nodes.
+	UnsupportedOSKey Key = Prefix + "/unsupported-os"
+
+	// ErrorHistoryKey holds the Node's most recent errors, with their
+	// timestamps, as a JSON list.
+	ErrorHistoryKey Key = Prefix + "/error-history"
+
+	// HoldKey is a label that, when present, holds the Node at its current
+	// step. Neither the Controller nor the Agent advance a held Node's Intent
+	// until the label is removed.
+	HoldKey Key = Prefix + "/hold"
+
+	// ForceDrainKey is an annotation that, when present, escalates the Node's
+	// drain to delete its Pods rather than evict them, bypassing the
+	// PodDisruptionBudgets that would otherwise block the drain indefinitely.
+	ForceDrainKey Key = Prefix + "/force-drain"
+
+	// KeepCordonedKey is a label that, when present, keeps the Node cordoned
+	// after it has been updated. For example, Nodes being decommissioned should
+	// not be given workloads once they're updated.
+	KeepCordonedKey Key = Prefix + "/keep-cordoned"
+
+	// TargetVersionKey is an annotation that, when set, directs the Node to
+	// update to the given version rather than the newest available update.
+	// The update fails when the version isn't available to the Node.
+	TargetVersionKey Key = Prefix + "/target-version"
+
+	// DoNotDrainKey is an annotation that, when set to true on a Pod, leaves
+	// the Pod in place when its Node is drained for an update. The Pod is stopped
+	// by the Node's reboot instead.
+	DoNotDrainKey Key = Prefix + "/do-not-drain"
+
+	// RollbackKey is an annotation, set by the Controller to the version a Node
+	// failed its health check after updating to, that directs the Agent to
+	// roll the Node back to the image it ran before. The version isn't updated
+	// to again until the annotation is removed.
+	RollbackKey Key = Prefix + "/rollback"
+
+	// CanaryKey is a label that, when present, marks the Node as a canary. With
+	// a canary rollout, canaries are updated ahead of the rest of the cluster.
+	CanaryKey Key = Prefix + "/canary"
+
+	// TraceParentKey is an annotation, set by the Controller alongside the
+	// Intent it posts, that carries the W3C traceparent of the update's trace
+	// so that the Agent's spans join the Controller's.
+	TraceParentKey Key = Prefix + "/traceparent"
 )
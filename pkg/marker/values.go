@@ -94,3 +94,15 @@ const (
 	NodeUpdateUnavailable NodeUpdate = "false"
 	NodeUpdateUnknown     NodeUpdate = "unknown"
 )
+
+// NodePartition names one of the Node's partitions.
+type NodePartition = string
+
+const (
+	NodePartitionActive  NodePartition = "active"
+	NodePartitionStaging NodePartition = "staging"
+)
+
+// UpdateAvailableCondition is the type of the Node condition reporting
+// whether the Node has an update available, mirroring UpdateAvailableKey.
+const UpdateAvailableCondition = "BottlerocketUpdateAvailable"
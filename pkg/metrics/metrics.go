@@ -0,0 +1,218 @@
+// Package metrics provides the Prometheus metrics published by the Update
+// Operator.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "brupop"
+
+	// NodeLabel is the metric label identifying the Node a metric describes.
+	NodeLabel = "node"
+	// ActionLabel is the metric label identifying the Update API action, its
+	// method and path, a metric describes.
+	ActionLabel = "action"
+	// ReasonLabel is the metric label identifying why an update failed.
+	ReasonLabel = "reason"
+	// IntentLabel is the metric label identifying the action a Node was
+	// directed to take.
+	IntentLabel = "intent"
+)
+
+var (
+	// Registry holds the operator's metrics and is served by Handler.
+	Registry = prometheus.NewRegistry()
+
+	// StuckRecovered counts Nodes that were detected as stuck and subsequently
+	// reached a healthy terminal state.
+	StuckRecovered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "stuck_recovered_total",
+		Help:      "Number of times a node detected as stuck recovered to a healthy state.",
+	}, []string{NodeLabel})
+
+	// CordonDuration observes the time Nodes spent cordoned by the controller
+	// for their update.
+	CordonDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "cordon_duration_seconds",
+		Help:      "Time nodes spent cordoned for their update, from cordon to uncordon.",
+		// 1 minute to about 8.5 hours.
+		Buckets: prometheus.ExponentialBuckets(60, 2, 10),
+	}, []string{NodeLabel})
+
+	// LastUpdated is the time Nodes last completed an update.
+	LastUpdated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "last_updated_timestamp_seconds",
+		Help:      "Time, in seconds since the epoch, nodes last completed an update.",
+	}, []string{NodeLabel})
+
+	// NodesUpdating is the number of managed Nodes counted as active against
+	// the limit of Nodes updating at once, as of the latest policy check.
+	NodesUpdating = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "nodes_updating",
+		Help:      "Number of nodes currently updating.",
+	})
+	// UpdatesCompleted counts the Nodes' successfully completed updates.
+	UpdatesCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "updates_completed_total",
+		Help:      "Number of node updates completed successfully.",
+	})
+	// UpdateFailures counts the Nodes' failed updates by the reason they
+	// failed.
+	UpdateFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "update_failures_total",
+		Help:      "Number of node updates that failed, by reason.",
+	}, []string{ReasonLabel})
+	// DrainFailures counts the Nodes that failed to drain for their update.
+	DrainFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "drain_failures_total",
+		Help:      "Number of times nodes failed to drain for their update.",
+	}, []string{NodeLabel})
+	// DrainsBlocked counts the times Nodes were held back from draining by
+	// PodDisruptionBudgets that permit no disruptions.
+	DrainsBlocked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "drains_blocked_total",
+		Help:      "Number of times nodes were held back from draining by pod disruption budgets permitting no disruptions.",
+	}, []string{NodeLabel})
+	// CanariesFailed is the number of canary Nodes whose failed update is
+	// blocking the rollout.
+	CanariesFailed = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "canaries_failed",
+		Help:      "Number of canary nodes whose failed update is blocking further updates.",
+	})
+	// IntentDuration observes the time Nodes spent directed to take each
+	// action before being directed to take the next.
+	IntentDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "intent_duration_seconds",
+		Help:      "Time nodes spent directed to take an action before being directed to take the next.",
+		// 10 seconds to about 5.7 hours.
+		Buckets: prometheus.ExponentialBuckets(10, 2, 12),
+	}, []string{IntentLabel})
+
+	// NodesTotal is the number of Nodes in the cluster.
+	NodesTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "nodes",
+		Help:      "Number of nodes in the cluster.",
+	})
+	// NodesManaged is the number of Nodes labeled for management by the
+	// operator.
+	NodesManaged = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "nodes_managed",
+		Help:      "Number of nodes labeled for management by the operator.",
+	})
+	// NodesUnmanaged is the number of Nodes not managed by the operator.
+	NodesUnmanaged = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "nodes_unmanaged",
+		Help:      "Number of nodes not managed by the operator.",
+	})
+	// NodesUnmarked is the number of Nodes expected to be managed that are
+	// missing the operator's marker label.
+	NodesUnmarked = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "nodes_unmarked",
+		Help:      "Number of nodes matching the managed selector that aren't labeled for management.",
+	})
+
+	// UnsupportedOS is set when the host's OS version is too old to be updated
+	// by the agent.
+	UnsupportedOS = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "unsupported_os",
+		Help:      "Set to 1 when the host's OS version is too old to be updated by the agent.",
+	})
+
+	// UpdateCheckFailures is the number of consecutive update checks that
+	// failed to list the available updates.
+	UpdateCheckFailures = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "update_check_failures",
+		Help:      "Number of consecutive update checks that failed to list available updates.",
+	})
+
+	// ControllerDuplicateIntents counts the Intents the controller skipped as
+	// equivalent to ones it recently handled.
+	ControllerDuplicateIntents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "duplicate_intents_skipped_total",
+		Help:      "Number of node events skipped by the controller as duplicates of recently handled intents.",
+	})
+	// AgentDuplicateIntents counts the Intents the agent skipped as equivalent
+	// to ones it recently handled.
+	AgentDuplicateIntents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "duplicate_intents_skipped_total",
+		Help:      "Number of node events skipped by the agent as duplicates of recently handled intents.",
+	})
+
+	// UpdateAPIRetries counts the retries needed by Update API requests.
+	UpdateAPIRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "update_api_retries_total",
+		Help:      "Number of times Update API requests were retried.",
+	}, []string{ActionLabel})
+)
+
+func init() {
+	Registry.MustRegister(
+		StuckRecovered,
+		CordonDuration,
+		LastUpdated,
+		NodesUpdating,
+		UpdatesCompleted,
+		UpdateFailures,
+		DrainFailures,
+		DrainsBlocked,
+		CanariesFailed,
+		IntentDuration,
+		NodesTotal,
+		NodesManaged,
+		NodesUnmanaged,
+		NodesUnmarked,
+		UpdateAPIRetries,
+		UnsupportedOS,
+		UpdateCheckFailures,
+		ControllerDuplicateIntents,
+		AgentDuplicateIntents,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// Path is the HTTP path that metrics are served on.
+	Path = "/metrics"
+
+	shutdownTimeout = 5 * time.Second
+	// bindRetryInterval is the time between attempts to listen on an address
+	// that's unavailable.
+	bindRetryInterval = 30 * time.Second
+)
+
+// Server serves the operator's metrics over HTTP.
+type Server struct {
+	log   logging.Logger
+	addr  string
+	mux   *http.ServeMux
+	retry time.Duration
+	clock clock.Clock
+	// served names what's served in the Server's logs.
+	served string
+}
+
+// NewServer creates a Server that listens on the given address.
+func NewServer(log logging.Logger, addr string) *Server {
+	s := NewHandlerServer(log, addr, "metrics")
+	s.Handle(Path, Handler())
+	return s
+}
+
+// NewHandlerServer creates a Server that listens on the given address serving
+// only the handlers added to it, such as to serve them apart from the metrics.
+// The served name describes the handlers in the Server's logs.
+func NewHandlerServer(log logging.Logger, addr string, served string) *Server {
+	return &Server{log: log, addr: addr, mux: http.NewServeMux(), retry: bindRetryInterval, clock: clock.RealClock{}, served: served}
+}
+
+// Handle serves the handler on the path alongside the Server's other handlers.
+// Handlers must be added before the Server is run.
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// Run serves the handlers until the context is canceled. An unavailable address
+// doesn't stop the operator, the failure is logged and listening is retried
+// until it succeeds.
+func (s *Server) Run(ctx context.Context) error {
+	listener, ok := s.listen(ctx)
+	if !ok {
+		return nil
+	}
+	srv := &http.Server{Addr: s.addr, Handler: s.mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	s.log.WithField("addr", s.addr).Info("serving " + s.served)
+	err := srv.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// listen listens on the Server's address, retrying until it succeeds or the
+// context is canceled.
+func (s *Server) listen(ctx context.Context) (net.Listener, bool) {
+	log := s.log.WithField("addr", s.addr)
+	for {
+		listener, err := net.Listen("tcp", s.addr)
+		if err == nil {
+			return listener, true
+		}
+		log.WithError(err).WithField("retry", s.retry).Errorf("unable to listen for %s, operator continuing without them", s.served)
+		timer := s.clock.NewTimer(s.retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false
+		case <-timer.C():
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestServerBindRetry(t *testing.T) {
+	// Hold the address so that the Server is unable to listen on it.
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	addr := held.Addr().String()
+
+	s := NewServer(testoutput.Logger(t, logging.New("metrics")), addr)
+	clk := clock.NewFakeClock(time.Now())
+	s.clock = clk
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	// The Server keeps retrying rather than failing, leaving the rest of the
+	// operator running.
+	waitRetry(t, clk, done)
+	clk.Step(bindRetryInterval)
+	waitRetry(t, clk, done)
+
+	// Metrics are served once the address is released.
+	assert.NilError(t, held.Close())
+	clk.Step(bindRetryInterval)
+	var resp *http.Response
+	for attempt := 0; attempt < 100; attempt++ {
+		resp, err = http.Get("http://" + addr + Path)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+	cancel()
+	assert.NilError(t, <-done)
+}
+
+func TestServerBindCanceled(t *testing.T) {
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer held.Close()
+
+	s := NewServer(testoutput.Logger(t, logging.New("metrics")), held.Addr().String())
+	clk := clock.NewFakeClock(time.Now())
+	s.clock = clk
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	waitRetry(t, clk, done)
+	cancel()
+	select {
+	case err := <-done:
+		assert.NilError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop when canceled")
+	}
+}
+
+// waitRetry waits for the Server to be waiting to retry listening, failing if
+// it stops instead.
+func waitRetry(t *testing.T, clk *clock.FakeClock, done <-chan error) {
+	t.Helper()
+	for attempt := 0; !clk.HasWaiters(); attempt++ {
+		select {
+		case err := <-done:
+			t.Fatalf("server stopped while address unavailable: %v", err)
+		default:
+		}
+		if attempt == 100 {
+			t.Fatal("server is not waiting to retry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -8,7 +8,8 @@ import (
 )
 
 const (
-	defaultResyncPeriod = time.Minute * 10
+	// DefaultResyncPeriod is the ResyncPeriod used when none is provided.
+	DefaultResyncPeriod = time.Minute * 10
 )
 
 type Config struct {
@@ -16,7 +17,8 @@ type Config struct {
 	// provided name.
 	NodeName string
 	// ResyncPeriod is the time between complete resynchronization of the cached
-	// resource data.
+	// resource data, redelivering every Node to the handler as an update.
+	// Defaults to DefaultResyncPeriod.
 	ResyncPeriod time.Duration
 	// PlatformVersion, when specified, limits the nodestream to Nodes that are
 	// labeled with the provided PlatformVersion.
@@ -24,6 +26,9 @@ type Config struct {
 	// OperatorVersion, when specified, limits the nodestream to Nodes that are
 	// labeled with the provided OperatorVersion.
 	OperatorVersion marker.OperatorVersion
+	// Unmanaged, when set, streams Nodes regardless of whether they are
+	// labeled for management by the operator.
+	Unmanaged bool
 	// LabelSelectorExtra is a free-form selector appended to the calculated
 	// selector.
 	LabelSelectorExtra string
@@ -48,7 +53,9 @@ func (c *Config) selector() func(options *metav1.ListOptions) {
 		fieldSelector = "metadata.name=" + c.NodeName
 	}
 
-	labelSelector = marker.NodeSelectorLabel
+	if !c.Unmanaged {
+		labelSelector = marker.NodeSelectorLabel
+	}
 
 	if c.LabelSelectorExtra != "" {
 		if labelSelector != "" {
@@ -72,7 +79,7 @@ func (c *Config) selector() func(options *metav1.ListOptions) {
 
 func (c *Config) resyncPeriod() time.Duration {
 	if c.ResyncPeriod == 0 {
-		return defaultResyncPeriod
+		return DefaultResyncPeriod
 	}
 	return c.ResyncPeriod
 }
@@ -0,0 +1,194 @@
+// Package notify sends notifications of the Nodes' updates beginning and
+// ending, such as to a webhook, without holding up the updates themselves.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+)
+
+const (
+	// sendTimeout bounds each attempt to send a Notification.
+	sendTimeout = 10 * time.Second
+	// sendAttempts is the most attempts made to send a Notification, the
+	// first retry is made after retryDelay, doubled for each retry after.
+	sendAttempts = 5
+	retryDelay   = 2 * time.Second
+	// queueSize is the most Notifications waiting to be sent, those made
+	// while the queue is full are dropped.
+	queueSize = 100
+)
+
+// Notification describes a Node's update beginning, succeeding, or failing.
+type Notification struct {
+	// Time is when the event happened, in RFC 3339 format.
+	Time string `json:"time"`
+	// Event is report.Begin, report.Success, or report.Failure.
+	Event report.Event `json:"event"`
+	// Component is the notifying component, the agent or controller.
+	Component string `json:"component"`
+	// Node is the name of the updating Node.
+	Node string `json:"node"`
+	// FromVersion and ToVersion are the versions the Node is updating from
+	// and to, when known.
+	FromVersion string `json:"fromVersion,omitempty"`
+	ToVersion   string `json:"toVersion,omitempty"`
+	// Error describes the failure, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Sink receives the Notifications.
+type Sink interface {
+	Send(ctx context.Context, note Notification) error
+}
+
+// Webhook posts each Notification as JSON to a URL, for example:
+// {"time": "2020-07-10T00:00:00Z", "event": "begin", "node": "ip-10-0-0-1", ...}
+type Webhook struct {
+	URL string
+	// Client is used to make the request, the default client is used when
+	// nil.
+	Client *http.Client
+}
+
+// Send posts the Notification to the webhook's URL.
+func (w *Webhook) Send(ctx context.Context, note Notification) error {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(note)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode notification")
+	}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create notification request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "notification request error")
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.Errorf("bad http response, status code: %d", response.StatusCode)
+	}
+	return nil
+}
+
+// Notifier sends a component's Notifications to each of its Sinks in the
+// background, retrying failed sends with backoff, so that a slow or failing
+// Sink never holds up an update. Sending is best-effort, Notifications that
+// can't be sent are logged and dropped. A nil Notifier sends nothing.
+type Notifier struct {
+	log       logging.Logger
+	sinks     []Sink
+	component string
+	clock     clock.Clock
+	queue     chan Notification
+	// timeout, attempts, and retryDelay bound the sending of each
+	// Notification.
+	timeout    time.Duration
+	attempts   int
+	retryDelay time.Duration
+}
+
+// New creates a Notifier sending the component's Notifications to the Sinks,
+// once run. No Notifier is created without a Sink.
+func New(log logging.Logger, component string, sinks ...Sink) *Notifier {
+	var configured []Sink
+	for _, sink := range sinks {
+		if sink != nil {
+			configured = append(configured, sink)
+		}
+	}
+	if len(configured) == 0 {
+		return nil
+	}
+	return &Notifier{
+		log:        log,
+		sinks:      configured,
+		component:  component,
+		clock:      clock.RealClock{},
+		queue:      make(chan Notification, queueSize),
+		timeout:    sendTimeout,
+		attempts:   sendAttempts,
+		retryDelay: retryDelay,
+	}
+}
+
+// Notify queues the Notification of the event to be sent, stamped with the
+// current time and the Notifier's component.
+func (n *Notifier) Notify(note Notification) {
+	if n == nil {
+		return
+	}
+	note.Time = n.clock.Now().UTC().Format(time.RFC3339)
+	note.Component = n.component
+	select {
+	case n.queue <- note:
+	default:
+		n.log.WithField("node", note.Node).WithField("event", note.Event).Warn("too many notifications waiting to be sent, dropping notification")
+	}
+}
+
+// Run sends the queued Notifications, in order, until the context is canceled.
+func (n *Notifier) Run(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case note := <-n.queue:
+			n.send(ctx, note)
+		}
+	}
+}
+
+// send sends the Notification to each of the Sinks.
+func (n *Notifier) send(ctx context.Context, note Notification) {
+	for _, sink := range n.sinks {
+		n.sendTo(ctx, sink, note)
+	}
+}
+
+// sendTo sends the Notification to the Sink, retrying with backoff until it's
+// sent, its attempts are exhausted, or the context is canceled.
+func (n *Notifier) sendTo(ctx context.Context, sink Sink, note Notification) {
+	log := n.log.WithField("node", note.Node).WithField("event", note.Event).WithField("sink", fmt.Sprintf("%T", sink))
+	delay := n.retryDelay
+	for attempt := 1; ; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, n.timeout)
+		err := sink.Send(sendCtx, note)
+		cancel()
+		if err == nil {
+			log.Debug("sent notification")
+			return
+		}
+		log = log.WithError(err).WithField("attempt", attempt)
+		if attempt >= n.attempts {
+			log.Error("unable to send notification, dropping notification")
+			return
+		}
+		log.WithField("retry-delay", delay).Warn("unable to send notification, retrying")
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.clock.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+)
+
+// testSink records the Notifications sent, failing each send with the errors
+// given in turn.
+type testSink struct {
+	sent chan Notification
+	errs []error
+}
+
+func (s *testSink) Send(ctx context.Context, note Notification) error {
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	s.sent <- note
+	return nil
+}
+
+func testNotifier(t *testing.T, sink Sink) *Notifier {
+	n := New(testoutput.Logger(t, logging.New("notify")), "controller", sink)
+	n.retryDelay = time.Millisecond
+	return n
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan Notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, r.Method == http.MethodPost)
+		assert.Check(t, r.Header.Get("Content-Type") == "application/json")
+		var note Notification
+		assert.Check(t, json.NewDecoder(r.Body).Decode(&note))
+		received <- note
+	}))
+	defer server.Close()
+
+	note := Notification{Event: report.Begin, Node: "node-a", FromVersion: "1.0.0", ToVersion: "1.1.0"}
+	assert.NilError(t, (&Webhook{URL: server.URL}).Send(context.Background(), note))
+	assert.DeepEqual(t, <-received, note)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	assert.ErrorContains(t, (&Webhook{URL: failing.URL}).Send(context.Background(), note), "status code: 503")
+}
+
+func TestNotifierRetry(t *testing.T) {
+	sink := &testSink{sent: make(chan Notification, 1), errs: []error{errors.New("unavailable"), errors.New("unavailable")}}
+	n := testNotifier(t, sink)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Notify(Notification{Event: report.Failure, Node: "node-a", Error: "drain blocked"})
+	note := <-sink.sent
+	assert.Equal(t, note.Node, "node-a")
+	assert.Equal(t, note.Component, "controller")
+	assert.Check(t, note.Time != "")
+	assert.Equal(t, len(sink.errs), 0, "failed sends should be retried")
+}
+
+func TestNotifierDropped(t *testing.T) {
+	sink := &testSink{sent: make(chan Notification, 2), errs: []error{errors.New("unavailable"), errors.New("unavailable")}}
+	n := testNotifier(t, sink)
+	n.attempts = 2
+	n.send(context.Background(), Notification{Node: "node-a"})
+	assert.Equal(t, len(sink.sent), 0, "notification should be dropped once its attempts are exhausted")
+
+	// The queued Notifications are never blocked on.
+	for i := 0; i < queueSize+1; i++ {
+		n.Notify(Notification{Node: "node-b"})
+	}
+	assert.Equal(t, len(n.queue), queueSize)
+}
+
+func TestNotifierTimeout(t *testing.T) {
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer server.Close()
+	defer close(blocked)
+
+	n := testNotifier(t, &Webhook{URL: server.URL})
+	n.timeout = 10 * time.Millisecond
+	n.attempts = 1
+	done := make(chan struct{})
+	go func() {
+		n.send(context.Background(), Notification{Node: "node-a"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow webhook should time out")
+	}
+}
+
+func TestNotifierDisabled(t *testing.T) {
+	n := New(testoutput.Logger(t, logging.New("notify")), "agent", nil)
+	assert.Check(t, n == nil)
+	n.Notify(Notification{Node: "node-a"})
+	assert.NilError(t, n.Run(context.Background()))
+}
+
+func TestNotifierSinks(t *testing.T) {
+	failing := &testSink{sent: make(chan Notification, 1), errs: []error{errors.New("unavailable")}}
+	working := &testSink{sent: make(chan Notification, 1)}
+	n := New(testoutput.Logger(t, logging.New("notify")), "controller", nil, failing, working)
+	n.attempts = 1
+	assert.Equal(t, len(n.sinks), 2, "unconfigured sinks should be skipped")
+	n.send(context.Background(), Notification{Node: "node-a"})
+	assert.Equal(t, len(failing.sent), 0)
+	assert.Equal(t, (<-working.sent).Node, "node-a", "failing sink should not keep the others from being sent to")
+}
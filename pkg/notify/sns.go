@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/pkg/errors"
+)
+
+// maxSubjectLength is the longest subject SNS accepts.
+const maxSubjectLength = 100
+
+// SNS publishes each Notification as JSON to an Amazon SNS topic, for example
+// to fan the fleet's update activity out to email, chat, or queues.
+type SNS struct {
+	TopicARN string
+	client   snsiface.SNSAPI
+}
+
+// NewSNS creates an SNS Sink publishing to the topic, in the topic's region.
+// The credentials are found by the AWS SDK's default provider chain.
+func NewSNS(topicARN string) (*SNS, error) {
+	parsed, err := arn.Parse(topicARN)
+	if err != nil || parsed.Service != sns.ServiceName {
+		return nil, errors.Errorf("invalid SNS topic ARN %q", topicARN)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AWS session")
+	}
+	client := sns.New(sess, aws.NewConfig().WithRegion(parsed.Region))
+	return &SNS{TopicARN: topicARN, client: client}, nil
+}
+
+// Send publishes the Notification to the topic, with its event as the "event"
+// message attribute for subscription filter policies.
+func (s *SNS) Send(ctx context.Context, note Notification) error {
+	body, err := json.Marshal(note)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode notification")
+	}
+	_, err = s.client.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Subject:  aws.String(subject(note)),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"event": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(string(note.Event)),
+			},
+		},
+	})
+	return errors.Wrap(err, "unable to publish notification")
+}
+
+// subject summarizes the Notification for subscriptions, such as email, that
+// show a subject.
+func subject(note Notification) string {
+	subject := fmt.Sprintf("Bottlerocket update %s: %s", note.Event, note.Node)
+	if len(subject) > maxSubjectLength {
+		subject = subject[:maxSubjectLength]
+	}
+	return subject
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/report"
+)
+
+type testSNSClient struct {
+	snsiface.SNSAPI
+	published []*sns.PublishInput
+	err       error
+}
+
+func (c *testSNSClient) PublishWithContext(_ aws.Context, input *sns.PublishInput, _ ...request.Option) (*sns.PublishOutput, error) {
+	c.published = append(c.published, input)
+	return &sns.PublishOutput{}, c.err
+}
+
+func TestSNSSend(t *testing.T) {
+	client := &testSNSClient{}
+	topic := "arn:aws:sns:us-west-2:123456789012:updates"
+	s := &SNS{TopicARN: topic, client: client}
+
+	note := Notification{Event: report.Success, Component: "controller", Node: "ip-10-0-0-1", FromVersion: "1.0.0", ToVersion: "1.1.0"}
+	assert.NilError(t, s.Send(context.Background(), note))
+	assert.Equal(t, len(client.published), 1)
+	published := client.published[0]
+	assert.Equal(t, aws.StringValue(published.TopicArn), topic)
+	assert.Equal(t, aws.StringValue(published.Subject), "Bottlerocket update success: ip-10-0-0-1")
+	assert.Equal(t, aws.StringValue(published.MessageAttributes["event"].StringValue), "success")
+	var message Notification
+	assert.NilError(t, json.Unmarshal([]byte(aws.StringValue(published.Message)), &message))
+	assert.DeepEqual(t, message, note)
+
+	client.err = errors.New("throttled")
+	assert.ErrorContains(t, s.Send(context.Background(), note), "unable to publish notification")
+}
+
+func TestSNSSubjectLength(t *testing.T) {
+	long := subject(Notification{Event: report.Failure, Node: strings.Repeat("n", 200)})
+	assert.Equal(t, len(long), maxSubjectLength)
+}
+
+func TestNewSNS(t *testing.T) {
+	for _, invalid := range []string{"", "updates", "arn:aws:sqs:us-west-2:123456789012:updates"} {
+		_, err := NewSNS(invalid)
+		assert.ErrorContains(t, err, "invalid SNS topic ARN", invalid)
+	}
+	// The topic's region is used over the one configured.
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+	os.Setenv("AWS_REGION", "us-east-1")
+	s, err := NewSNS("arn:aws:sns:us-west-2:123456789012:updates")
+	assert.NilError(t, err)
+	assert.Equal(t, s.TopicARN, "arn:aws:sns:us-west-2:123456789012:updates")
+	assert.Equal(t, aws.StringValue(s.client.(*sns.SNS).Config.Region), "us-west-2")
+}
@@ -1,22 +1,31 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/jitter"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
 )
 
 const (
+	// bottlerocketAPISock is the default path of the update API's socket.
 	bottlerocketAPISock = "/run/api.sock"
 	// The minimum required host Bottlerocket OS version is v0.4.1 because that's when the Update API
 	// was first added. https://github.com/bottlerocket-os/bottlerocket/releases/tag/v0.4.1
+	// It's the default minimum and the lowest that may be configured.
 	minimumRequiredOSVer = "0.4.1"
 )
 
@@ -29,6 +38,8 @@ const (
 	stateReady     updateState = "Ready"
 )
 
+var _ platform.ImageUpdate = (*updateImage)(nil)
+
 type updateImage struct {
 	Arch    string `json:"arch"`
 	Version string `json:"version"`
@@ -39,11 +50,36 @@ func (ui *updateImage) Identifier() interface{} {
 	return ui.Version
 }
 
+func (ui *updateImage) TargetVersion() string {
+	return ui.Version
+}
+
+func (ui *updateImage) TargetArch() string {
+	return ui.Arch
+}
+
+func (ui *updateImage) TargetVariant() string {
+	return ui.Variant
+}
+
 type stagedImage struct {
 	Image      updateImage `json:"image"`
 	NextToBoot bool        `json:"next_to_boot"`
 }
 
+// partition converts the staged image into its platform representation.
+func (si *stagedImage) partition() *platform.Partition {
+	if si == nil {
+		return nil
+	}
+	return &platform.Partition{
+		Version:    si.Image.Version,
+		Variant:    si.Image.Variant,
+		Arch:       si.Image.Arch,
+		NextToBoot: si.NextToBoot,
+	}
+}
+
 type updateCommand string
 
 const (
@@ -77,61 +113,256 @@ type updateStatus struct {
 	MostRecentCommand *commandResult `json:"most_recent_command"`
 }
 
+// validate checks that the update status is internally consistent for its
+// reported state so that callers may rely on the fields the state implies.
+func (us *updateStatus) validate() error {
+	if us.ActivePartition == nil {
+		return errors.Errorf("update state %q has no active partition", us.UpdateState)
+	}
+	switch us.UpdateState {
+	case stateAvailable, stateStaged, stateReady:
+		if us.ChosenUpdate == nil {
+			return errors.Errorf("update state %q has no chosen update", us.UpdateState)
+		}
+	}
+	switch us.UpdateState {
+	case stateStaged, stateReady:
+		if us.StagingPartition == nil {
+			return errors.Errorf("update state %q has no staging partition", us.UpdateState)
+		}
+	}
+	return nil
+}
+
+// idle reports whether the update API is free to begin an update: it isn't
+// running a command and hasn't activated an update.
+func (us *updateStatus) idle() bool {
+	if us.MostRecentCommand != nil && us.MostRecentCommand.CmdStatus == Unknown {
+		// The command's status is unknown until it completes.
+		return false
+	}
+	switch us.UpdateState {
+	case stateIdle, stateAvailable, stateStaged:
+		return true
+	}
+	return false
+}
+
+const (
+	defaultTimeout       = 10 * time.Second
+	defaultMaxAttempts   = 5
+	defaultRetryDelay    = 2 * time.Second
+	defaultRetryDeadline = time.Minute
+	// retryJitter is the fraction of each retry delay that it's randomly
+	// moved earlier or later by, spreading the retries of hosts busy at the
+	// same time.
+	retryJitter = 0.2
+)
+
+// ClientConfig configures the requests made to the update API. Unset fields
+// use their defaults.
+type ClientConfig struct {
+	// Timeout bounds each request, defaulting to 10 seconds.
+	Timeout time.Duration
+	// MaxAttempts is the most times a request is attempted while the API is
+	// busy or unreachable, defaulting to 5.
+	MaxAttempts int
+	// RetryDelay is the time waited before first retrying a request, doubled
+	// for each retry after, defaulting to 2 seconds.
+	RetryDelay time.Duration
+	// RetryDeadline bounds the time spent retrying a request, defaulting to 1
+	// minute.
+	RetryDeadline time.Duration
+	// MinimumOSVersion is the lowest host OS version supported, defaulting to
+	// the version the Update API was added in, 0.4.1.
+	MinimumOSVersion string
+	// SocketPath is the path of the update API's unix socket, defaulting to
+	// /run/api.sock.
+	SocketPath string
+}
+
+func (c ClientConfig) validate() error {
+	if c.Timeout < 0 {
+		return errors.Errorf("invalid update API timeout %s, must not be negative", c.Timeout)
+	}
+	if c.MaxAttempts < 0 {
+		return errors.Errorf("invalid update API max attempts %d, must not be negative", c.MaxAttempts)
+	}
+	if c.RetryDelay < 0 {
+		return errors.Errorf("invalid update API retry delay %s, must not be negative", c.RetryDelay)
+	}
+	if c.RetryDeadline < 0 {
+		return errors.Errorf("invalid update API retry deadline %s, must not be negative", c.RetryDeadline)
+	}
+	if c.MinimumOSVersion != "" {
+		min, err := semver.NewVersion(c.MinimumOSVersion)
+		if err != nil {
+			return errors.Wrapf(err, "invalid minimum OS version %q", c.MinimumOSVersion)
+		}
+		if min.LessThan(semver.MustParse(minimumRequiredOSVer)) {
+			return errors.Errorf("invalid minimum OS version %q, must be at least %s", c.MinimumOSVersion, minimumRequiredOSVer)
+		}
+	}
+	return nil
+}
+
 type apiClient struct {
 	log        logging.Logger
 	httpClient *http.Client
+	// maxAttempts is the most times a request is attempted while the API is
+	// busy or unreachable, the default is used when it's unset.
+	maxAttempts int
+	// retryDelay is the time waited before first retrying a request, it's
+	// doubled for each retry after.
+	retryDelay time.Duration
+	// retryDeadline bounds the time spent retrying a request, the default is
+	// used when it's unset.
+	retryDeadline time.Duration
+	// minimumOSVersion is the lowest host OS version supported.
+	minimumOSVersion string
+	// clock times the retries.
+	clock clock.Clock
 }
 
-func newAPIClient() *apiClient {
+func newAPIClient(config ClientConfig, clk clock.Clock) (*apiClient, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	timeout := config.Timeout
+	if timeout == 0 {
+		// The Bottlerocket API should always immediately return a response
+		// regardless of the request, the timeout guards against waiting
+		// forever if it doesn't.
+		timeout = defaultTimeout
+	}
+	maxAttempts := config.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	retryDelay := config.RetryDelay
+	if retryDelay == 0 {
+		retryDelay = defaultRetryDelay
+	}
+	retryDeadline := config.RetryDeadline
+	if retryDeadline == 0 {
+		retryDeadline = defaultRetryDeadline
+	}
+	minimumOSVersion := config.MinimumOSVersion
+	if minimumOSVersion == "" {
+		minimumOSVersion = minimumRequiredOSVer
+	}
+	socketPath := config.SocketPath
+	if socketPath == "" {
+		socketPath = bottlerocketAPISock
+	}
 	return &apiClient{log: logging.New("update-api"), httpClient: &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
 				dialer := net.Dialer{}
-				return dialer.DialContext(ctx, "unix", bottlerocketAPISock)
+				return dialer.DialContext(ctx, "unix", socketPath)
 			},
 		},
-		// By default, Timeout is set to 0 which would mean no timeout.
-		// Set a 10 second timeout for all requests so we don't wait forever if the API fails to return a response.
-		// The Bottlerocket API should always immediately return a response regardless of the request.
-		// The 10 second value picked here is arbitrary and should be changed if it proves insufficient.
-		Timeout: 10 * time.Second,
+		Timeout: timeout,
 	},
-	}
+		maxAttempts:      maxAttempts,
+		retryDelay:       retryDelay,
+		retryDeadline:    retryDeadline,
+		minimumOSVersion: minimumOSVersion,
+		clock:            clk,
+	}, nil
 }
 
+// randJitterFunc returns a random fraction in [0, 1) used to jitter the
+// retries, it's safe for concurrent requests.
+var randJitterFunc = jitter.Float64
+
+// backoffDelay is the time waited before the given retry, starting from the
+// initial delay and doubling for each retry after, randomly moved earlier or
+// later by up to retryJitter of the delay.
+func backoffDelay(initial time.Duration, retry int) time.Duration {
+	delay := initial << uint(retry-1)
+	return jitter.Delay(delay, retryJitter, randJitterFunc())
+}
+
+// do makes the request, retrying with exponential backoff while the API is
+// busy or unreachable until the attempts are exhausted or the retry deadline
+// would be passed. Other error responses fail without being retried. The
+// request's context cancels the request and its retries.
 func (c *apiClient) do(req *http.Request) (*http.Response, error) {
-	var response *http.Response
-	const maxAttempts = 5
-	attempts := 0
-	// Retry up to 5 times in case the Update API is busy; Waiting 10 seconds between each attempt.
-	for ; attempts < maxAttempts; attempts++ {
-		var err error
-		response, err = c.httpClient.Do(req)
+	maxAttempts := c.maxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	retryDelay := c.retryDelay
+	if retryDelay <= 0 {
+		retryDelay = defaultRetryDelay
+	}
+	retryDeadline := c.retryDeadline
+	if retryDeadline <= 0 {
+		retryDeadline = defaultRetryDeadline
+	}
+	deadline := c.clock.Now().Add(retryDeadline)
+	retries := 0
+	action := req.Method + " " + req.URL.Path
+	// Record the retries needed by the request, however it turns out.
+	defer func() {
+		if retries > 0 {
+			metrics.UpdateAPIRetries.WithLabelValues(action).Add(float64(retries))
+		}
+		c.log.WithField("action", action).WithField("retries", retries).Debug("update API request completed")
+	}()
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(retryDelay, attempt)
+			if c.clock.Now().Add(delay).After(deadline) {
+				break
+			}
+			c.log.WithError(lastErr).Infof("retrying update API request in %s ...", delay)
+			select {
+			case <-c.clock.After(delay):
+			case <-req.Context().Done():
+				return nil, errors.Wrap(req.Context().Err(), "update API request canceled")
+			}
+			retries++
+			// The request's body was consumed by the attempt, it's sent
+			// again from the start.
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, errors.Wrapf(err, "update API request error")
+				}
+				req.Body = body
+			}
+		}
+		response, err := c.httpClient.Do(req)
 		if err != nil {
-			return nil, errors.Wrapf(err, "update API request error")
+			if ctxErr := req.Context().Err(); ctxErr != nil {
+				return nil, errors.Wrap(ctxErr, "update API request canceled")
+			}
+			// Network errors are transient, the API may not be listening
+			// yet.
+			lastErr = errors.Wrapf(err, "update API request error")
+			continue
 		}
 		if response.StatusCode >= 200 && response.StatusCode < 300 {
-			// Response OK
-			break
-		} else if response.StatusCode == 423 {
-			if attempts < maxAttempts-1 {
-				c.log.Info("API server busy, retrying in 10 seconds ...")
-				// Retry after ten seconds if we get a 423 Locked response (update API busy)
-				time.Sleep(10 * time.Second)
-				continue
-			}
+			return response, nil
+		}
+		if response.StatusCode == http.StatusLocked {
+			// The update API is busy with another request.
+			response.Body.Close()
+			lastErr = errors.New("update API busy")
+			continue
 		}
 		// API response was a non-transient error, bail out.
 		return response, errors.Errorf("bad http response, status code: %d", response.StatusCode)
 	}
-	if attempts == 5 {
-		return nil, errors.New("update API unavailable: retries exhausted")
-	}
-	return response, nil
+	return nil, errors.WithMessage(lastErr, "update API unavailable: retries exhausted")
 }
 
-func (c *apiClient) Get(path string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, "http://unix"+path, nil)
+func (c *apiClient) Get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://unix"+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -139,8 +370,8 @@ func (c *apiClient) Get(path string) (*http.Response, error) {
 	return c.do(req)
 }
 
-func (c *apiClient) Post(path string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodPost, "http://unix"+path, http.NoBody)
+func (c *apiClient) Post(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://unix"+path, http.NoBody)
 	if err != nil {
 		return nil, err
 	}
@@ -148,9 +379,19 @@ func (c *apiClient) Post(path string) (*http.Response, error) {
 	return c.do(req)
 }
 
+func (c *apiClient) Patch(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, "http://unix"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.log.WithField("path", path).WithField("method", http.MethodPatch).Debugf("update API request")
+	return c.do(req)
+}
+
 // GetUpdateStatus returns the update status from the update API
-func (c *apiClient) GetUpdateStatus() (*updateStatus, error) {
-	response, err := c.Get("/updates/status")
+func (c *apiClient) GetUpdateStatus(ctx context.Context) (*updateStatus, error) {
+	response, err := c.Get(ctx, "/updates/status")
 	if err != nil {
 		return nil, err
 	}
@@ -164,11 +405,15 @@ func (c *apiClient) GetUpdateStatus() (*updateStatus, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = updateStatus.validate()
+	if err != nil {
+		return nil, errors.WithMessage(err, "inconsistent update status")
+	}
 	return &updateStatus, nil
 }
 
-func (c *apiClient) GetMostRecentCommand() (*commandResult, error) {
-	updateStatus, err := c.GetUpdateStatus()
+func (c *apiClient) GetMostRecentCommand(ctx context.Context) (*commandResult, error) {
+	updateStatus, err := c.GetUpdateStatus(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -179,8 +424,8 @@ type osInfo struct {
 	VersionID string `json:"version_id"`
 }
 
-func (c *apiClient) GetOSInfo() (*osInfo, error) {
-	response, err := c.Get("/os")
+func (c *apiClient) GetOSInfo(ctx context.Context) (*osInfo, error) {
+	response, err := c.Get(ctx, "/os")
 	if err != nil {
 		return nil, err
 	}
@@ -197,22 +442,79 @@ func (c *apiClient) GetOSInfo() (*osInfo, error) {
 	return &osInfo, nil
 }
 
-func (c *apiClient) RefreshUpdates() error {
-	_, err := c.Post("/actions/refresh-updates")
+func (c *apiClient) RefreshUpdates(ctx context.Context) error {
+	_, err := c.Post(ctx, "/actions/refresh-updates")
 	return err
 }
 
-func (c *apiClient) PrepareUpdate() error {
-	_, err := c.Post("/actions/prepare-update")
+// versionLockLatest is the version lock that leaves the API to choose the
+// latest update, the host's default.
+const versionLockLatest = "latest"
+
+// GetVersionLock returns the version the host is locked to updating to.
+func (c *apiClient) GetVersionLock(ctx context.Context) (string, error) {
+	response, err := c.Get(ctx, "/settings")
+	if err != nil {
+		return "", errors.WithMessage(err, "unable to get version lock")
+	}
+
+	var settings struct {
+		Updates struct {
+			VersionLock string `json:"version-lock"`
+		} `json:"updates"`
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	err = json.Unmarshal(body, &settings)
+	if err != nil {
+		return "", err
+	}
+	if settings.Updates.VersionLock == "" {
+		return versionLockLatest, nil
+	}
+	return settings.Updates.VersionLock, nil
+}
+
+// SetVersionLock locks the host to updating to the given version, the API
+// chooses the version once updates are next refreshed. Locking to
+// versionLockLatest lifts the lock.
+func (c *apiClient) SetVersionLock(ctx context.Context, version string) error {
+	lock := version
+	if lock != versionLockLatest {
+		// The version lock is given with a "v" prefix, such as "v1.0.5".
+		lock = "v" + strings.TrimPrefix(version, "v")
+	}
+	settings := map[string]interface{}{
+		"updates": map[string]string{
+			"version-lock": lock,
+		},
+	}
+	body, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Patch(ctx, "/settings", body); err != nil {
+		return errors.WithMessage(err, "unable to set version lock")
+	}
+	if _, err := c.Post(ctx, "/tx/commit_and_apply"); err != nil {
+		return errors.WithMessage(err, "unable to commit version lock")
+	}
+	return nil
+}
+
+func (c *apiClient) PrepareUpdate(ctx context.Context) error {
+	_, err := c.Post(ctx, "/actions/prepare-update")
 	return err
 }
 
-func (c *apiClient) ActivateUpdate() error {
-	_, err := c.Post("/actions/activate-update")
+func (c *apiClient) ActivateUpdate(ctx context.Context) error {
+	_, err := c.Post(ctx, "/actions/activate-update")
 	return err
 }
 
-func (c *apiClient) Reboot() error {
-	_, err := c.Post("/actions/reboot")
+func (c *apiClient) Reboot(ctx context.Context) error {
+	_, err := c.Post(ctx, "/actions/reboot")
 	return err
 }
@@ -1,10 +1,34 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/Masterminds/semver"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/metrics"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform/noop"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Update status responses, as returned by the update API, for each of the
+// update states.
+const (
+	statusIdleJSON      = `{"update_state":"Idle","available_updates":["0.4.0","0.3.4","0.3.3","0.3.2","0.3.1","0.3.0"],"chosen_update":null,"active_partition":{"image":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"next_to_boot":true},"staging_partition":null,"most_recent_command":{"cmd_type":"refresh","cmd_status":"Success","timestamp":"2020-07-08T21:32:35.802253160Z","exit_status":0,"stderr":""}}`
+	statusAvailableJSON = `{"update_state":"Available","available_updates":["0.4.0","0.3.4","0.3.3","0.3.2","0.3.1","0.3.0"],"chosen_update":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"active_partition":{"image":{"arch":"x86_64","version":"0.3.2","variant":"aws-k8s-1.15"},"next_to_boot":true},"staging_partition":null,"most_recent_command":{"cmd_type":"refresh","cmd_status":"Success","timestamp":"2020-06-18T17:57:43.141433622Z","exit_status":0,"stderr":""}}`
+	statusStagedJSON    = `{"update_state":"Staged","available_updates":["0.4.0","0.3.4","0.3.3","0.3.2","0.3.1","0.3.0"],"chosen_update":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"active_partition":{"image":{"arch":"x86_64","version":"0.3.4","variant":"aws-k8s-1.15"},"next_to_boot":true},"staging_partition":{"image":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"next_to_boot":false},"most_recent_command":{"cmd_type":"prepare","cmd_status":"Success","timestamp":"2020-07-10T06:44:58.766493367Z","exit_status":0,"stderr":"Starting update to 0.4.0\n"}}`
+	statusReadyJSON     = `{"update_state":"Ready","available_updates":["0.4.0","0.3.4","0.3.3","0.3.2","0.3.1","0.3.0"],"chosen_update":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"active_partition":{"image":{"arch":"x86_64","version":"0.3.4","variant":"aws-k8s-1.15"},"next_to_boot":false},"staging_partition":{"image":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"next_to_boot":true},"most_recent_command":{"cmd_type":"activate","cmd_status":"Success","timestamp":"2020-07-10T06:47:19.903337270Z","exit_status":0,"stderr":""}}`
 )
 
 func TestUnmarshallUpdateStatus(t *testing.T) {
@@ -150,3 +174,846 @@ func TestUnmarshallUpdateStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusPartitions(t *testing.T) {
+	cases := []struct {
+		Name             string
+		UpdateStatusJSON string
+		Active           *platform.Partition
+		Staging          *platform.Partition
+	}{
+		{
+			Name:             "Update staged",
+			UpdateStatusJSON: statusStagedJSON,
+			Active: &platform.Partition{
+				Version:    "0.3.4",
+				Variant:    "aws-k8s-1.15",
+				Arch:       "x86_64",
+				NextToBoot: true,
+			},
+			Staging: &platform.Partition{
+				Version:    "0.4.0",
+				Variant:    "aws-k8s-1.15",
+				Arch:       "x86_64",
+				NextToBoot: false,
+			},
+		},
+		{
+			Name:             "Update ready",
+			UpdateStatusJSON: statusReadyJSON,
+			Active: &platform.Partition{
+				Version:    "0.3.4",
+				Variant:    "aws-k8s-1.15",
+				Arch:       "x86_64",
+				NextToBoot: false,
+			},
+			Staging: &platform.Partition{
+				Version:    "0.4.0",
+				Variant:    "aws-k8s-1.15",
+				Arch:       "x86_64",
+				NextToBoot: true,
+			},
+		},
+		{
+			Name:             "No update available after refresh",
+			UpdateStatusJSON: statusIdleJSON,
+			Active: &platform.Partition{
+				Version:    "0.4.0",
+				Variant:    "aws-k8s-1.15",
+				Arch:       "x86_64",
+				NextToBoot: true,
+			},
+			Staging: nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var status updateStatus
+			err := json.Unmarshal([]byte(tc.UpdateStatusJSON), &status)
+			assert.NoError(t, err, "failed to unmarshal into update status")
+			pr := &partitionsResponse{active: status.ActivePartition, staging: status.StagingPartition}
+			assert.Equal(t, tc.Active, pr.ActivePartition())
+			assert.Equal(t, tc.Staging, pr.StagingPartition())
+		})
+	}
+}
+
+func TestCheckNextToBoot(t *testing.T) {
+	cases := []struct {
+		Name        string
+		Mutate      func(*updateStatus)
+		ShouldError bool
+	}{
+		{
+			Name:        "Update ready",
+			Mutate:      func(*updateStatus) {},
+			ShouldError: false,
+		},
+		{
+			Name: "Active partition next to boot",
+			Mutate: func(s *updateStatus) {
+				s.ActivePartition.NextToBoot = true
+				s.StagingPartition.NextToBoot = false
+			},
+			ShouldError: true,
+		},
+		{
+			Name: "Both partitions next to boot",
+			Mutate: func(s *updateStatus) {
+				s.ActivePartition.NextToBoot = true
+			},
+			ShouldError: true,
+		},
+		{
+			Name: "No staging partition",
+			Mutate: func(s *updateStatus) {
+				s.StagingPartition = nil
+			},
+			ShouldError: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var status updateStatus
+			err := json.Unmarshal([]byte(statusReadyJSON), &status)
+			assert.NoError(t, err, "failed to unmarshal into update status")
+			tc.Mutate(&status)
+			err = checkNextToBoot(&status)
+			if tc.ShouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateStatus(t *testing.T) {
+	cases := []struct {
+		Name             string
+		UpdateStatusJSON string
+		Mutate           func(*updateStatus)
+		ShouldError      bool
+	}{
+		{Name: "Idle", UpdateStatusJSON: statusIdleJSON, Mutate: func(*updateStatus) {}},
+		{Name: "Available", UpdateStatusJSON: statusAvailableJSON, Mutate: func(*updateStatus) {}},
+		{Name: "Staged", UpdateStatusJSON: statusStagedJSON, Mutate: func(*updateStatus) {}},
+		{Name: "Ready", UpdateStatusJSON: statusReadyJSON, Mutate: func(*updateStatus) {}},
+		{
+			Name:             "Idle without active partition",
+			UpdateStatusJSON: statusIdleJSON,
+			Mutate:           func(s *updateStatus) { s.ActivePartition = nil },
+			ShouldError:      true,
+		},
+		{
+			Name:             "Available without chosen update",
+			UpdateStatusJSON: statusAvailableJSON,
+			Mutate:           func(s *updateStatus) { s.ChosenUpdate = nil },
+			ShouldError:      true,
+		},
+		{
+			Name:             "Staged without chosen update",
+			UpdateStatusJSON: statusStagedJSON,
+			Mutate:           func(s *updateStatus) { s.ChosenUpdate = nil },
+			ShouldError:      true,
+		},
+		{
+			Name:             "Staged without staging partition",
+			UpdateStatusJSON: statusStagedJSON,
+			Mutate:           func(s *updateStatus) { s.StagingPartition = nil },
+			ShouldError:      true,
+		},
+		{
+			Name:             "Ready without staging partition",
+			UpdateStatusJSON: statusReadyJSON,
+			Mutate:           func(s *updateStatus) { s.StagingPartition = nil },
+			ShouldError:      true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var status updateStatus
+			err := json.Unmarshal([]byte(tc.UpdateStatusJSON), &status)
+			assert.NoError(t, err, "failed to unmarshal into update status")
+			tc.Mutate(&status)
+			err = status.validate()
+			if tc.ShouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			w.WriteHeader(http.StatusLocked)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := &apiClient{
+		log:        logging.New("update-api"),
+		httpClient: server.Client(),
+		retryDelay: time.Millisecond,
+		clock:      clock.RealClock{},
+	}
+	action := http.MethodGet + " /retried"
+	before := testutil.ToFloat64(metrics.UpdateAPIRetries.WithLabelValues(action))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/retried", nil)
+	assert.NoError(t, err)
+	_, err = c.do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.UpdateAPIRetries.WithLabelValues(action))-before)
+}
+
+func TestRequestRetriesExhausted(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusLocked)
+	}))
+	defer server.Close()
+
+	c, err := newAPIClient(ClientConfig{MaxAttempts: 2, RetryDelay: time.Millisecond}, clock.RealClock{})
+	assert.NoError(t, err)
+	c.httpClient = server.Client()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/exhausted", nil)
+	assert.NoError(t, err)
+	_, err = c.do(req)
+	assert.Error(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestSocketPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "api.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/os", r.URL.Path)
+		w.Write([]byte(`{"version_id":"1.0.0"}`))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	c, err := newAPIClient(ClientConfig{SocketPath: socket}, clock.RealClock{})
+	assert.NoError(t, err)
+	info, err := c.GetOSInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", info.VersionID)
+}
+
+func TestRequestRetryPolicy(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Status   int
+		Config   ClientConfig
+		Requests int
+	}{
+		// Genuine errors fail without being retried.
+		{Name: "NotFound", Status: http.StatusNotFound, Config: ClientConfig{RetryDelay: time.Millisecond}, Requests: 1},
+		{Name: "ServerError", Status: http.StatusInternalServerError, Config: ClientConfig{RetryDelay: time.Millisecond}, Requests: 1},
+		// Busy responses are retried until the attempts are exhausted.
+		{Name: "Locked", Status: http.StatusLocked, Config: ClientConfig{MaxAttempts: 3, RetryDelay: time.Millisecond}, Requests: 3},
+		// Retries that would pass the deadline aren't made.
+		{Name: "Deadline", Status: http.StatusLocked, Config: ClientConfig{RetryDelay: time.Hour, RetryDeadline: time.Second}, Requests: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tc.Status)
+			}))
+			defer server.Close()
+
+			c, err := newAPIClient(tc.Config, clock.RealClock{})
+			assert.NoError(t, err)
+			c.httpClient = server.Client()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/policy", nil)
+			assert.NoError(t, err)
+			_, err = c.do(req)
+			assert.Error(t, err)
+			assert.Equal(t, tc.Requests, requests)
+		})
+	}
+}
+
+func TestRequestCanceled(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusLocked)
+	}))
+	defer server.Close()
+
+	c, err := newAPIClient(ClientConfig{RetryDelay: time.Hour, RetryDeadline: 2 * time.Hour}, clock.RealClock{})
+	assert.NoError(t, err)
+	c.httpClient = server.Client()
+
+	// The retry is abandoned once the request's context is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/canceled", nil)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := c.do(req)
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "canceled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("request should stop once canceled")
+	}
+	assert.True(t, requests <= 1, "canceled request should not be retried")
+}
+
+func TestRequestRetryClock(t *testing.T) {
+	defer func(fn func() float64) { randJitterFunc = fn }(randJitterFunc)
+	randJitterFunc = func() float64 { return 0.5 }
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusLocked)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clk := clock.NewFakeClock(time.Now())
+	c, err := newAPIClient(ClientConfig{RetryDelay: time.Hour, RetryDeadline: 2 * time.Hour}, clk)
+	assert.NoError(t, err)
+	c.httpClient = server.Client()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/clock", nil)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := c.do(req)
+		done <- err
+	}()
+
+	// The retry waits on the client's clock.
+	for attempt := 0; !clk.HasWaiters(); attempt++ {
+		assert.True(t, attempt < 100, "request should wait to be retried")
+		time.Sleep(10 * time.Millisecond)
+	}
+	clk.Step(time.Hour)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("request should be retried once its delay elapses")
+	}
+	assert.Equal(t, 2, requests)
+}
+
+func TestRequestRetriesNetworkError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// Drop the connection without responding.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.NoError(t, err)
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c, err := newAPIClient(ClientConfig{RetryDelay: time.Millisecond}, clock.RealClock{})
+	assert.NoError(t, err)
+	c.httpClient = server.Client()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/dropped", nil)
+	assert.NoError(t, err)
+	_, err = c.do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+}
+
+func TestBackoffDelay(t *testing.T) {
+	defer func(fn func() float64) { randJitterFunc = fn }(randJitterFunc)
+
+	randJitterFunc = func() float64 { return 0.5 }
+	for retry, expected := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second} {
+		assert.Equal(t, expected, backoffDelay(2*time.Second, retry+1))
+	}
+
+	// The delay is jittered by up to retryJitter either way.
+	randJitterFunc = func() float64 { return 0 }
+	assert.Equal(t, 8*time.Second, backoffDelay(10*time.Second, 1))
+	randJitterFunc = func() float64 { return 1 }
+	assert.Equal(t, 12*time.Second, backoffDelay(10*time.Second, 1))
+}
+
+func TestNewAPIClient(t *testing.T) {
+	c, err := newAPIClient(ClientConfig{}, clock.RealClock{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultTimeout, c.httpClient.Timeout)
+	assert.Equal(t, defaultMaxAttempts, c.maxAttempts)
+	assert.Equal(t, defaultRetryDelay, c.retryDelay)
+	assert.Equal(t, defaultRetryDeadline, c.retryDeadline)
+	assert.Equal(t, minimumRequiredOSVer, c.minimumOSVersion)
+
+	c, err = newAPIClient(ClientConfig{Timeout: time.Minute, MaxAttempts: 10, RetryDelay: time.Second, RetryDeadline: time.Hour, MinimumOSVersion: "1.1.0"}, clock.RealClock{})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, c.httpClient.Timeout)
+	assert.Equal(t, 10, c.maxAttempts)
+	assert.Equal(t, time.Second, c.retryDelay)
+	assert.Equal(t, time.Hour, c.retryDeadline)
+	assert.Equal(t, "1.1.0", c.minimumOSVersion)
+
+	for _, config := range []ClientConfig{
+		{Timeout: -time.Second},
+		{MaxAttempts: -1},
+		{RetryDelay: -time.Second},
+		{RetryDeadline: -time.Second},
+		{MinimumOSVersion: "latest"},
+		{MinimumOSVersion: "0.4.0"},
+	} {
+		_, err := newAPIClient(config, clock.RealClock{})
+		assert.Error(t, err, "config %+v should be invalid", config)
+	}
+}
+
+// testServerPlatform creates a platform of the update API served by the test
+// server.
+func testServerPlatform(server *httptest.Server) *apiPlatform {
+	return &apiPlatform{log: logging.New("platform"), apiClient: &apiClient{
+		log: logging.New("update-api"),
+		httpClient: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				dialer := net.Dialer{}
+				return dialer.DialContext(ctx, "tcp", server.Listener.Addr().String())
+			},
+		}},
+		retryDelay: time.Millisecond,
+		clock:      clock.RealClock{},
+	}}
+}
+
+func TestListAvailableRefresh(t *testing.T) {
+	var refreshes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/actions/refresh-updates":
+			refreshes++
+		case "/updates/status":
+			w.Write([]byte(statusAvailableJSON))
+		}
+	}))
+	defer server.Close()
+
+	p := testServerPlatform(server)
+
+	// Updates are refreshed as they're listed by default.
+	_, err := p.ListAvailable(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, refreshes)
+
+	// Once refreshed separately, listing leaves refreshing to Refresh.
+	p.RefreshSeparately()
+	available, err := p.ListAvailable(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, refreshes)
+	assert.Len(t, available.Updates(), 3)
+	assert.NoError(t, p.Refresh(context.Background()))
+	assert.Equal(t, 2, refreshes)
+}
+
+func TestListAvailableUpdates(t *testing.T) {
+	cases := []struct {
+		Name             string
+		UpdateStatusJSON string
+		Expected         []string
+	}{
+		// The running version is the latest.
+		{Name: "Idle", UpdateStatusJSON: statusIdleJSON, Expected: nil},
+		// The chosen update is preferred, followed by the other updates newer
+		// than the running version.
+		{Name: "Available", UpdateStatusJSON: statusAvailableJSON, Expected: []string{"0.4.0", "0.3.4", "0.3.3"}},
+		// No update is listed when the API chose none, such as while the
+		// host waits for its update wave, even with newer versions available.
+		{
+			Name:             "NoneChosen",
+			UpdateStatusJSON: `{"update_state":"Idle","available_updates":["0.4.0","0.3.4"],"chosen_update":null,"active_partition":{"image":{"arch":"x86_64","version":"0.3.2","variant":"aws-k8s-1.15"},"next_to_boot":true},"staging_partition":null,"most_recent_command":{"cmd_type":"refresh","cmd_status":"Success","timestamp":"2020-06-18T17:57:43.141433622Z","exit_status":0,"stderr":""}}`,
+			Expected:         nil,
+		},
+		// Versions differing only in build metadata are the same version:
+		// the chosen update isn't listed twice and the running version isn't
+		// newer than itself.
+		{
+			Name:             "BuildMetadata",
+			UpdateStatusJSON: `{"update_state":"Available","available_updates":["0.4.0","0.3.4"],"chosen_update":{"arch":"x86_64","version":"0.4.0+abc","variant":"aws-k8s-1.15"},"active_partition":{"image":{"arch":"x86_64","version":"0.3.4+def","variant":"aws-k8s-1.15"},"next_to_boot":true},"staging_partition":null,"most_recent_command":{"cmd_type":"refresh","cmd_status":"Success","timestamp":"2020-06-18T17:57:43.141433622Z","exit_status":0,"stderr":""}}`,
+			Expected:         []string{"0.4.0+abc"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var us updateStatus
+			assert.NoError(t, json.Unmarshal([]byte(tc.UpdateStatusJSON), &us))
+
+			var versions []string
+			for _, u := range newListAvailableResponse(&us).Updates() {
+				versions = append(versions, u.(platform.VersionedUpdate).TargetVersion())
+			}
+			assert.Equal(t, tc.Expected, versions)
+		})
+	}
+}
+
+func TestListAllUpdates(t *testing.T) {
+	var us updateStatus
+	assert.NoError(t, json.Unmarshal([]byte(`{"update_state":"Available","available_updates":["0.3.4","not-a-version","0.4.0","0.3.2"],"chosen_update":{"arch":"x86_64","version":"0.4.0","variant":"aws-k8s-1.15"},"active_partition":{"image":{"arch":"x86_64","version":"0.3.4","variant":"aws-k8s-1.15"},"next_to_boot":true},"staging_partition":null,"most_recent_command":{"cmd_type":"refresh","cmd_status":"Success","timestamp":"2020-06-18T17:57:43.141433622Z","exit_status":0,"stderr":""}}`), &us))
+
+	var available platform.Available = newListAvailableResponse(&us)
+	full, ok := available.(platform.FullAvailable)
+	if !assert.True(t, ok, "listing should report all updates") {
+		return
+	}
+	// All of the versions are listed, not only those newer than the running
+	// version, while the applicable updates are unchanged.
+	var versions []string
+	for _, u := range full.AllUpdates() {
+		iu := u.(platform.ImageUpdate)
+		assert.Equal(t, "x86_64", iu.TargetArch())
+		assert.Equal(t, "aws-k8s-1.15", iu.TargetVariant())
+		versions = append(versions, iu.TargetVersion())
+	}
+	assert.Equal(t, []string{"0.4.0", "0.3.4", "0.3.2", "not-a-version"}, versions)
+	assert.Len(t, full.Updates(), 1)
+}
+
+func TestChosenUpdateImage(t *testing.T) {
+	for name, statusJSON := range map[string]string{
+		"Available": statusAvailableJSON,
+		"Staged":    statusStagedJSON,
+		"Ready":     statusReadyJSON,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var us updateStatus
+			assert.NoError(t, json.Unmarshal([]byte(statusJSON), &us))
+
+			updates := newListAvailableResponse(&us).Updates()
+			if !assert.True(t, len(updates) > 0, "chosen update should be listed") {
+				return
+			}
+			chosen, ok := updates[0].(platform.ImageUpdate)
+			assert.True(t, ok, "chosen update should describe its image")
+			assert.Equal(t, "x86_64", chosen.TargetArch())
+			assert.Equal(t, "0.4.0", chosen.TargetVersion())
+			assert.Equal(t, "aws-k8s-1.15", chosen.TargetVariant())
+		})
+	}
+}
+
+func TestUpdateStatusIdle(t *testing.T) {
+	inProgress := func(s *updateStatus) { s.MostRecentCommand.CmdStatus = Unknown }
+	cases := []struct {
+		Name             string
+		UpdateStatusJSON string
+		Mutate           func(*updateStatus)
+		Idle             bool
+	}{
+		{Name: "Idle", UpdateStatusJSON: statusIdleJSON, Idle: true},
+		{Name: "Available", UpdateStatusJSON: statusAvailableJSON, Idle: true},
+		{Name: "Staged", UpdateStatusJSON: statusStagedJSON, Idle: true},
+		// An update was activated out of band.
+		{Name: "Ready", UpdateStatusJSON: statusReadyJSON, Idle: false},
+		{Name: "Idle refreshing", UpdateStatusJSON: statusIdleJSON, Mutate: inProgress, Idle: false},
+		{Name: "Available preparing", UpdateStatusJSON: statusAvailableJSON, Mutate: inProgress, Idle: false},
+		{
+			Name:             "No command run",
+			UpdateStatusJSON: statusAvailableJSON,
+			Mutate:           func(s *updateStatus) { s.MostRecentCommand = nil },
+			Idle:             true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var us updateStatus
+			assert.NoError(t, json.Unmarshal([]byte(tc.UpdateStatusJSON), &us))
+			if tc.Mutate != nil {
+				tc.Mutate(&us)
+			}
+			assert.Equal(t, tc.Idle, us.idle())
+		})
+	}
+}
+
+func TestStatusSupported(t *testing.T) {
+	cases := []struct {
+		OSVersion string
+		Supported bool
+	}{
+		{OSVersion: "0.3.4", Supported: false},
+		{OSVersion: "0.4.0", Supported: false},
+		{OSVersion: minimumRequiredOSVer, Supported: true},
+		{OSVersion: "1.0.0", Supported: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.OSVersion, func(t *testing.T) {
+			sr := &statusResponse{osVersion: semver.MustParse(tc.OSVersion)}
+			assert.Equal(t, tc.Supported, sr.Supported())
+			assert.Equal(t, tc.Supported, sr.OK())
+			assert.Equal(t, tc.OSVersion, sr.OSVersion())
+			assert.Equal(t, minimumRequiredOSVer, sr.MinimumOSVersion())
+		})
+	}
+}
+
+func TestStatusSupportedMinimum(t *testing.T) {
+	cases := []struct {
+		OSVersion string
+		Supported bool
+	}{
+		{OSVersion: "0.4.1", Supported: false},
+		{OSVersion: "1.0.9", Supported: false},
+		{OSVersion: "1.1.0", Supported: true},
+		{OSVersion: "1.2.0", Supported: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.OSVersion, func(t *testing.T) {
+			sr := &statusResponse{osVersion: semver.MustParse(tc.OSVersion), minimumOSVersion: "1.1.0"}
+			assert.Equal(t, tc.Supported, sr.Supported())
+			assert.Equal(t, tc.Supported, sr.OK())
+			assert.Equal(t, "1.1.0", sr.MinimumOSVersion())
+		})
+	}
+}
+
+func TestStatusApartFromPartitions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/os":
+			w.Write([]byte(`{"version_id": "1.0.0"}`))
+		case "/updates/status":
+			http.Error(w, "no update status", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := testServerPlatform(server)
+	status, err := p.Status(context.Background())
+	assert.NoError(t, err, "status should be reported without the update status")
+	assert.True(t, status.OK())
+
+	_, err = p.Partitions(context.Background())
+	assert.Error(t, err)
+}
+
+func TestPrepareTargetVersion(t *testing.T) {
+	var staged string
+	var refreshes, prepares int
+	// The host's version lock and each lock set.
+	locked := "latest"
+	var locks []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/settings":
+			var settings struct {
+				Updates struct {
+					VersionLock string `json:"version-lock"`
+				} `json:"updates"`
+			}
+			if r.Method == http.MethodGet {
+				settings.Updates.VersionLock = locked
+				assert.NoError(t, json.NewEncoder(w).Encode(&settings))
+				return
+			}
+			assert.Equal(t, http.MethodPatch, r.Method)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&settings))
+			locked = settings.Updates.VersionLock
+			locks = append(locks, locked)
+		case "/actions/refresh-updates":
+			refreshes++
+		case "/actions/prepare-update":
+			prepares++
+		case "/updates/status":
+			var us updateStatus
+			assert.NoError(t, json.Unmarshal([]byte(statusAvailableJSON), &us))
+			// The API chooses the locked version once refreshed.
+			if locked != "latest" && refreshes > 0 {
+				us.ChosenUpdate.Version = locked[1:]
+			}
+			// The chosen update is staged once prepared, unless another
+			// version was staged out of band.
+			if prepares > 0 {
+				us.MostRecentCommand.CmdType = commandPrepare
+				us.StagingPartition = &stagedImage{Image: *us.ChosenUpdate}
+				if staged != "" {
+					us.StagingPartition.Image.Version = staged
+				}
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(&us))
+		}
+	}))
+	defer server.Close()
+
+	p := testServerPlatform(server)
+
+	// The chosen update is prepared as is.
+	assert.NoError(t, p.Prepare(context.Background(), &updateImage{Version: "0.4.0"}))
+	assert.Empty(t, locks)
+	assert.Equal(t, 1, prepares)
+
+	// Versions that aren't available can't be targeted.
+	err := p.Prepare(context.Background(), &updateImage{Version: "0.5.0"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "version 0.5.0 is not among the available updates")
+	assert.Empty(t, locks)
+	assert.Equal(t, 1, prepares)
+
+	// Other available versions are chosen by locking the host to them, the
+	// host's lock is restored once prepared.
+	assert.NoError(t, p.Prepare(context.Background(), &updateImage{Version: "0.3.4"}))
+	assert.Equal(t, []string{"v0.3.4", "latest"}, locks)
+	assert.Equal(t, "latest", locked)
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, 2, prepares)
+
+	// Another version staged in place of the target fails the prepare, the
+	// lock is restored all the same.
+	staged = "0.3.3"
+	locks = nil
+	err = p.Prepare(context.Background(), &updateImage{Version: "0.3.4"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "staged version 0.3.3 does not match target version 0.3.4")
+	assert.Equal(t, []string{"v0.3.4", "latest"}, locks)
+	assert.Equal(t, 3, prepares)
+
+	// A lock the host had to another version is restored as it was.
+	locked = "v0.3.3"
+	locks = nil
+	staged = ""
+	assert.NoError(t, p.Prepare(context.Background(), &updateImage{Version: "0.3.4"}))
+	assert.Equal(t, []string{"v0.3.4", "v0.3.3"}, locks)
+}
+
+func TestCheckStagedVersion(t *testing.T) {
+	status := &updateStatus{StagingPartition: &stagedImage{Image: updateImage{Version: "0.4.0"}}}
+	assert.NoError(t, checkStagedVersion(status, &updateImage{Version: "0.4.0"}))
+	assert.NoError(t, checkStagedVersion(status, &updateImage{Version: "v0.4.0"}))
+
+	err := checkStagedVersion(status, &updateImage{Version: "0.4.1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "staged version 0.4.0 does not match target version 0.4.1")
+
+	err = checkStagedVersion(&updateStatus{}, &updateImage{Version: "0.4.0"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no update staged")
+
+	// Targets that don't identify their version aren't checked.
+	assert.NoError(t, checkStagedVersion(&updateStatus{}, &noop.Update{}))
+}
+
+func TestUpdateStagedVersion(t *testing.T) {
+	var activates int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/actions/activate-update":
+			activates++
+		case "/updates/status":
+			var us updateStatus
+			assert.NoError(t, json.Unmarshal([]byte(statusStagedJSON), &us))
+			if activates > 0 {
+				us.MostRecentCommand.CmdType = commandActivate
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(&us))
+		}
+	}))
+	defer server.Close()
+
+	p := testServerPlatform(server)
+
+	// A version other than the one staged isn't activated.
+	err := p.Update(context.Background(), &updateImage{Version: "0.5.0"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "staged version 0.4.0 does not match target version 0.5.0")
+	assert.Equal(t, 0, activates)
+
+	assert.NoError(t, p.Update(context.Background(), &updateImage{Version: "0.4.0"}))
+	assert.Equal(t, 1, activates)
+}
+
+func TestRollback(t *testing.T) {
+	// The host booted into 0.4.0, its prior image remains on the staging
+	// partition.
+	statusJSON := statusIdleJSON
+	var reboots int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/actions/reboot":
+			reboots++
+		case "/updates/status":
+			var us updateStatus
+			assert.NoError(t, json.Unmarshal([]byte(statusJSON), &us))
+			if us.UpdateState == stateIdle {
+				us.StagingPartition = &stagedImage{Image: updateImage{Arch: "x86_64", Version: "0.3.4", Variant: "aws-k8s-1.15"}}
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(&us))
+		}
+	}))
+	defer server.Close()
+
+	var marked int
+	p := testServerPlatform(server)
+	p.markInactive = func(context.Context) error {
+		marked++
+		return nil
+	}
+
+	assert.NoError(t, p.Rollback(context.Background()))
+	assert.Equal(t, 1, marked)
+	assert.Equal(t, 1, reboots)
+
+	// A pending update on the staging partition isn't rolled back to.
+	statusJSON = statusReadyJSON
+	err := p.Rollback(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "staging partition holds a pending update")
+	assert.Equal(t, 1, marked)
+	assert.Equal(t, 1, reboots)
+}
+
+func TestCheckRollback(t *testing.T) {
+	active := &stagedImage{Image: updateImage{Version: "0.4.0"}, NextToBoot: true}
+	assert.NoError(t, checkRollback(&updateStatus{
+		UpdateState:      stateIdle,
+		ActivePartition:  active,
+		StagingPartition: &stagedImage{Image: updateImage{Version: "0.3.4"}},
+	}))
+
+	err := checkRollback(&updateStatus{UpdateState: stateIdle, ActivePartition: active})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no prior image")
+
+	err = checkRollback(&updateStatus{
+		UpdateState:      stateAvailable,
+		ActivePartition:  active,
+		StagingPartition: &stagedImage{Image: updateImage{Version: "v0.4.0"}},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "nothing to roll back to")
+}
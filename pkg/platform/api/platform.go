@@ -1,41 +1,96 @@
 package api
 
 import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"github.com/Masterminds/semver"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
 
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/bottlerocket"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
 )
 
 // Assert Update-API as a platform implementor.
 var _ platform.Platform = (*apiPlatform)(nil)
+var _ platform.IdleChecker = (*apiPlatform)(nil)
+var _ platform.Refresher = (*apiPlatform)(nil)
+var _ platform.PartitionReporter = (*apiPlatform)(nil)
 
 type apiPlatform struct {
 	log       logging.Logger
 	apiClient *apiClient
+	// separateRefresh leaves refreshing the list of updates to Refresh rather
+	// than refreshing it on each listing.
+	separateRefresh bool
+	// markInactive marks the host's inactive partition to be booted next.
+	markInactive func(ctx context.Context) error
 }
 
-func New() (*apiPlatform, error) {
-	return &apiPlatform{log: logging.New("platform"), apiClient: newAPIClient()}, nil
+// New creates the Update API platform, its requests to the API are made as
+// configured.
+func New(config ClientConfig) (*apiPlatform, error) {
+	client, err := newAPIClient(config, clock.RealClock{})
+	if err != nil {
+		return nil, err
+	}
+	log := logging.New("platform")
+	log.WithField("minimum-os-version", client.minimumOSVersion).Info("requiring minimum OS version for the update API")
+	return &apiPlatform{log: log, apiClient: client, markInactive: signpostRollback}, nil
 }
 
+// signpostRollback marks the host's inactive partition to be booted next with
+// signpost, run on the host.
+func signpostRollback(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, filepath.Join(bottlerocket.PlatformBin, "signpost"), "rollback-to-inactive")
+	cmd.SysProcAttr = bottlerocket.ProcessAttrs()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "signpost rollback-to-inactive failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+var _ platform.SupportStatus = (*statusResponse)(nil)
+
 type statusResponse struct {
 	osVersion *semver.Version
+	// minimumOSVersion is the lowest OS version supported, the default
+	// minimum is used when it's unset.
+	minimumOSVersion string
 }
 
 func (sr *statusResponse) OK() bool {
+	return sr.Supported()
+}
+
+func (sr *statusResponse) Supported() bool {
 	// Bottlerocket OS version needs to be at least a certain version to support the Update API
-	constraint, err := semver.NewConstraint(">= " + minimumRequiredOSVer)
+	constraint, err := semver.NewConstraint(">= " + sr.MinimumOSVersion())
 	if err != nil {
 		return false
 	}
 	return constraint.Check(sr.osVersion)
 }
 
-func (p apiPlatform) Status() (platform.Status, error) {
+func (sr *statusResponse) OSVersion() string {
+	return sr.osVersion.Original()
+}
+
+func (sr *statusResponse) MinimumOSVersion() string {
+	if sr.minimumOSVersion == "" {
+		return minimumRequiredOSVer
+	}
+	return sr.minimumOSVersion
+}
+
+func (p apiPlatform) Status(ctx context.Context) (platform.Status, error) {
 	// Try to determine if the update API is supported in the Bottlerocket host
-	osInfo, err := p.apiClient.GetOSInfo()
+	osInfo, err := p.apiClient.GetOSInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -45,83 +100,285 @@ func (p apiPlatform) Status() (platform.Status, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse 'version_id' field as semver")
 	}
-	return &statusResponse{osVersion: osVersion}, nil
+
+	return &statusResponse{osVersion: osVersion, minimumOSVersion: p.apiClient.minimumOSVersion}, nil
+}
+
+var _ platform.PartitionStatus = (*partitionsResponse)(nil)
+
+type partitionsResponse struct {
+	active  *stagedImage
+	staging *stagedImage
+}
+
+func (pr *partitionsResponse) ActivePartition() *platform.Partition {
+	return pr.active.partition()
+}
+
+func (pr *partitionsResponse) StagingPartition() *platform.Partition {
+	return pr.staging.partition()
+}
+
+// Partitions reports the images installed to the host's partitions from its
+// update status.
+func (p apiPlatform) Partitions(ctx context.Context) (platform.PartitionStatus, error) {
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &partitionsResponse{active: updateStatus.ActivePartition, staging: updateStatus.StagingPartition}, nil
 }
 
+var _ platform.FullAvailable = (*listAvailableResponse)(nil)
+
 type listAvailableResponse struct {
 	chosenUpdate *updateImage
+	// newer are the other available updates that are newer than the active
+	// partition.
+	newer []*updateImage
+	// all are all of the available updates, newest first.
+	all []*updateImage
 }
 
+// Updates lists the API's chosen update, its preference, followed by the
+// other available updates newer than the running version. Nothing is listed
+// when the API chose no update, the host is held back from updating by its
+// update wave or version lock.
 func (lar *listAvailableResponse) Updates() []platform.Update {
 	if lar.chosenUpdate == nil {
 		return nil
 	}
+	updates := []platform.Update{lar.chosenUpdate}
+	for _, u := range lar.newer {
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+// AllUpdates lists every available update, including those that aren't newer
+// than the running version, from the newest version to the oldest.
+func (lar *listAvailableResponse) AllUpdates() []platform.Update {
+	var updates []platform.Update
+	for _, u := range lar.all {
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+// newListAvailableResponse lists the chosen update and the available updates
+// that are newer than the active partition.
+func newListAvailableResponse(us *updateStatus) *listAvailableResponse {
+	lar := &listAvailableResponse{chosenUpdate: us.ChosenUpdate, all: allUpdates(us)}
+	if us.ChosenUpdate == nil || us.ActivePartition == nil {
+		return lar
+	}
+	running, err := semver.NewVersion(us.ActivePartition.Image.Version)
+	if err != nil {
+		return lar
+	}
+	for _, version := range us.AvailableUpdates {
+		if platform.SameVersion(version, us.ChosenUpdate.Version) {
+			continue
+		}
+		v, err := semver.NewVersion(version)
+		if err != nil || !v.GreaterThan(running) {
+			continue
+		}
+		lar.newer = append(lar.newer, &updateImage{
+			Arch:    us.ActivePartition.Image.Arch,
+			Version: version,
+			Variant: us.ActivePartition.Image.Variant,
+		})
+	}
+	return lar
+}
 
-	return []platform.Update{lar.chosenUpdate}
+// allUpdates describes each of the available versions as an update to the
+// active partition's image, ordered from the newest version to the oldest.
+// Versions that aren't valid semver are ordered last.
+func allUpdates(us *updateStatus) []*updateImage {
+	var all []*updateImage
+	for _, version := range us.AvailableUpdates {
+		u := &updateImage{Version: version}
+		if us.ActivePartition != nil {
+			u.Arch = us.ActivePartition.Image.Arch
+			u.Variant = us.ActivePartition.Image.Variant
+		}
+		all = append(all, u)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		vi, erri := semver.NewVersion(all[i].Version)
+		vj, errj := semver.NewVersion(all[j].Version)
+		switch {
+		case erri != nil:
+			return false
+		case errj != nil:
+			return true
+		}
+		return vi.GreaterThan(vj)
+	})
+	return all
 }
 
-func (p apiPlatform) ListAvailable() (platform.Available, error) {
+func (p apiPlatform) ListAvailable(ctx context.Context) (platform.Available, error) {
 	p.log.Debug("fetching list of available updates")
 
-	// Refresh list of updates and check if there are any available
-	err := p.apiClient.RefreshUpdates()
-	if err != nil {
-		return nil, err
+	// Refresh list of updates, unless it's refreshed on its own schedule, and
+	// check if there are any available
+	if !p.separateRefresh {
+		err := p.apiClient.RefreshUpdates(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	updateStatus, err := p.apiClient.GetUpdateStatus()
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if updateStatus.MostRecentCommand.CmdType != commandRefresh && updateStatus.MostRecentCommand.CmdStatus != statusSuccess {
+	if !p.separateRefresh && updateStatus.MostRecentCommand.CmdType != commandRefresh && updateStatus.MostRecentCommand.CmdStatus != statusSuccess {
 		return nil, errors.New("failed to refresh updates or update action performed out of band")
 
 	}
-	return &listAvailableResponse{chosenUpdate: updateStatus.ChosenUpdate}, nil
+	return newListAvailableResponse(updateStatus), nil
+}
+
+func (p apiPlatform) Refresh(ctx context.Context) error {
+	p.log.Debug("refreshing list of available updates")
+	return p.apiClient.RefreshUpdates(ctx)
+}
+
+func (p *apiPlatform) RefreshSeparately() {
+	p.separateRefresh = true
+}
+
+func (p apiPlatform) Idle(ctx context.Context) (bool, error) {
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !updateStatus.idle() {
+		p.log.WithField("state", updateStatus.UpdateState).Debug("update API is busy")
+		return false, nil
+	}
+	return true, nil
 }
 
-func (p apiPlatform) Prepare(target platform.Update) error {
-	updateStatus, err := p.apiClient.GetUpdateStatus()
+func (p apiPlatform) Prepare(ctx context.Context, target platform.Update) (err error) {
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
 	if err != nil {
 		return err
 	}
 	if updateStatus.UpdateState != stateAvailable && updateStatus.UpdateState != stateStaged {
 		return errors.Errorf("unexpected update state: %s, expecting state to be 'Available' or 'Staged'. update action performed out of band?", updateStatus.UpdateState)
 	}
+	// The API prepares the update it has chosen, other available updates are
+	// targeted by locking the host to their version. The host's own lock is
+	// restored once prepared, leaving the host to take later updates as it
+	// would have.
+	if vu, ok := target.(platform.VersionedUpdate); ok && updateStatus.ChosenUpdate != nil && !platform.SameVersion(vu.TargetVersion(), updateStatus.ChosenUpdate.Version) {
+		previous, cerr := p.chooseVersion(ctx, updateStatus, vu.TargetVersion())
+		if previous != "" {
+			defer func() {
+				if rerr := p.restoreVersionLock(ctx, previous); rerr != nil && err == nil {
+					err = rerr
+				}
+			}()
+		}
+		if cerr != nil {
+			return cerr
+		}
+	}
 
 	// Download the update and apply it to the inactive partition
-	err = p.apiClient.PrepareUpdate()
+	err = p.apiClient.PrepareUpdate(ctx)
 	if err != nil {
 		return err
 	}
 
-	commandResult, err := p.apiClient.GetMostRecentCommand()
+	commandResult, err := p.apiClient.GetMostRecentCommand(ctx)
 	if err != nil {
 		return err
 	}
 	if commandResult.CmdType != commandPrepare || commandResult.CmdStatus != statusSuccess {
 		return errors.New("failed to prepare update or update action performed out of band")
 	}
+
+	// The update prepared must be the one targeted, another may have been
+	// chosen out of band.
+	updateStatus, err = p.apiClient.GetUpdateStatus(ctx)
+	if err != nil {
+		return err
+	}
+	return checkStagedVersion(updateStatus, target)
+}
+
+// chooseVersion has the API choose the available update to the given version
+// by locking the host to it. The host's previous version lock is returned
+// once replaced, even when the API then fails to choose the version, to be
+// restored by the caller.
+func (p apiPlatform) chooseVersion(ctx context.Context, updateStatus *updateStatus, version string) (string, error) {
+	available := false
+	for _, v := range updateStatus.AvailableUpdates {
+		if platform.SameVersion(v, version) {
+			available = true
+			break
+		}
+	}
+	if !available {
+		return "", errors.Errorf("version %s is not among the available updates: [%s]", version, strings.Join(updateStatus.AvailableUpdates, ", "))
+	}
+
+	previous, err := p.apiClient.GetVersionLock(ctx)
+	if err != nil {
+		return "", err
+	}
+	p.log.WithField("version", version).WithField("previous-lock", previous).Info("locking host to update version")
+	if err := p.apiClient.SetVersionLock(ctx, version); err != nil {
+		return "", err
+	}
+	if err := p.apiClient.RefreshUpdates(ctx); err != nil {
+		return previous, err
+	}
+	updateStatus, err = p.apiClient.GetUpdateStatus(ctx)
+	if err != nil {
+		return previous, err
+	}
+	if updateStatus.ChosenUpdate == nil || !platform.SameVersion(updateStatus.ChosenUpdate.Version, version) {
+		return previous, errors.Errorf("update API did not choose version %s once locked to it", version)
+	}
+	return previous, nil
+}
+
+// restoreVersionLock returns the host to its previous version lock.
+func (p apiPlatform) restoreVersionLock(ctx context.Context, previous string) error {
+	p.log.WithField("lock", previous).Info("restoring host version lock")
+	if err := p.apiClient.SetVersionLock(ctx, previous); err != nil {
+		return errors.WithMessage(err, "unable to restore version lock")
+	}
 	return nil
 }
 
-func (p apiPlatform) Update(target platform.Update) error {
-	updateStatus, err := p.apiClient.GetUpdateStatus()
+func (p apiPlatform) Update(ctx context.Context, target platform.Update) error {
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
 	if err != nil {
 		return err
 	}
 	if updateStatus.UpdateState != stateStaged {
 		return errors.Errorf("unexpected update state: %s, expecting state to be 'Staged'. update action performed out of band?", updateStatus.UpdateState)
 	}
+	if err := checkStagedVersion(updateStatus, target); err != nil {
+		return err
+	}
 
 	// Activate the prepared update
 
-	err = p.apiClient.ActivateUpdate()
+	err = p.apiClient.ActivateUpdate(ctx)
 	if err != nil {
 		return err
 	}
 
-	commandResult, err := p.apiClient.GetMostRecentCommand()
+	commandResult, err := p.apiClient.GetMostRecentCommand(ctx)
 	if err != nil {
 		return err
 	}
@@ -131,19 +388,94 @@ func (p apiPlatform) Update(target platform.Update) error {
 	return nil
 }
 
-func (p apiPlatform) BootUpdate(target platform.Update, rebootNow bool) error {
-	updateStatus, err := p.apiClient.GetUpdateStatus()
+func (p apiPlatform) BootUpdate(ctx context.Context, target platform.Update, rebootNow bool) error {
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
 	if err != nil {
 		return err
 	}
 	if updateStatus.UpdateState != stateReady {
 		return errors.Errorf("unexpected update state: %s, expecting state to be 'Ready'. update action performed out of band?", updateStatus.UpdateState)
 	}
+	if err := checkNextToBoot(updateStatus); err != nil {
+		return err
+	}
 
 	// Reboot the host into the activated update
-	err = p.apiClient.Reboot()
+	err = p.apiClient.Reboot(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkStagedVersion verifies that the update staged to the host's staging
+// partition is the version targeted. Targets that don't identify their version
+// aren't checked.
+func checkStagedVersion(updateStatus *updateStatus, target platform.Update) error {
+	vu, ok := target.(platform.VersionedUpdate)
+	if !ok {
+		return nil
+	}
+	staging := updateStatus.StagingPartition
+	if staging == nil {
+		return errors.Errorf("no update staged, expected version %s to be staged", vu.TargetVersion())
+	}
+	if !platform.SameVersion(staging.Image.Version, vu.TargetVersion()) {
+		return errors.Errorf("staged version %s does not match target version %s. update action performed out of band?", staging.Image.Version, vu.TargetVersion())
+	}
+	return nil
+}
+
+// checkNextToBoot verifies that the host will boot into its staging partition,
+// where the update was activated, rather than its active partition.
+func checkNextToBoot(updateStatus *updateStatus) error {
+	staging := updateStatus.StagingPartition
+	if staging == nil {
+		return errors.New("no staging partition to boot into")
+	}
+	if !staging.NextToBoot {
+		return errors.Errorf("staging partition (%s) is not marked next to boot. update action performed out of band?", staging.Image.Version)
+	}
+	if active := updateStatus.ActivePartition; active != nil && active.NextToBoot {
+		return errors.Errorf("active partition (%s) is marked next to boot. update action performed out of band?", active.Image.Version)
+	}
+	return nil
+}
+
+// Rollback boots the host back into the image on its staging partition, the
+// image it ran before its most recent update. The update API has no action to
+// roll back an update that's been booted into, so the partition is marked to
+// boot on the host before the API reboots into it.
+func (p apiPlatform) Rollback(ctx context.Context) error {
+	updateStatus, err := p.apiClient.GetUpdateStatus(ctx)
 	if err != nil {
 		return err
 	}
+	if err := checkRollback(updateStatus); err != nil {
+		return err
+	}
+	p.log.WithField("from", updateStatus.ActivePartition.Image.Version).
+		WithField("to", updateStatus.StagingPartition.Image.Version).
+		Info("rolling back to prior image")
+	if err := p.markInactive(ctx); err != nil {
+		return errors.WithMessage(err, "unable to mark prior image to boot")
+	}
+	return p.apiClient.Reboot(ctx)
+}
+
+// checkRollback verifies that the host's staging partition holds the image it
+// ran before its most recent update.
+func checkRollback(updateStatus *updateStatus) error {
+	switch updateStatus.UpdateState {
+	case stateStaged, stateReady:
+		return errors.Errorf("unexpected update state: %s, staging partition holds a pending update rather than a prior image", updateStatus.UpdateState)
+	}
+	staging := updateStatus.StagingPartition
+	if staging == nil || staging.Image.Version == "" {
+		return errors.New("no prior image on the staging partition to roll back to")
+	}
+	if platform.SameVersion(staging.Image.Version, updateStatus.ActivePartition.Image.Version) {
+		return errors.Errorf("staging partition holds the running version (%s), nothing to roll back to", staging.Image.Version)
+	}
 	return nil
 }
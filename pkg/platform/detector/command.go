@@ -0,0 +1,60 @@
+package detector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultCommandTimeout = 30 * time.Second
+
+// Command detects the available versions by running a command that prints
+// one version per line, most preferred first. Blank lines and lines starting
+// with '#' are ignored.
+type Command struct {
+	Path string
+	Args []string
+	// Timeout bounds the command's run time, a default is used when zero.
+	Timeout time.Duration
+}
+
+// Detect runs the command and parses its output.
+func (c *Command) Detect(ctx context.Context) ([]string, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "detector command failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return parseVersions(out)
+}
+
+// parseVersions reads a list of versions, one per line.
+func parseVersions(out []byte) ([]string, error) {
+	var versions []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		versions = append(versions, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to read detected versions")
+	}
+	return versions, nil
+}
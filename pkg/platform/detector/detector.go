@@ -0,0 +1,82 @@
+// Package detector provides a platform.Platform that determines the available
+// updates from a bespoke source, such as an air-gapped update repository,
+// while relying on an underlying platform to make progress on the update.
+package detector
+
+import (
+	"context"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+	"github.com/pkg/errors"
+)
+
+// Assert Platform as a platform implementor.
+var _ platform.Platform = (*Platform)(nil)
+var _ platform.PartitionReporter = (*Platform)(nil)
+
+// Detector determines the versions that are available to update to.
+type Detector interface {
+	// Detect returns the available versions ordered by preference, it's
+	// abandoned once the context is canceled.
+	Detect(ctx context.Context) ([]string, error)
+}
+
+// Platform defers to its Detector for the list of available updates and to
+// its underlying platform for everything else.
+type Platform struct {
+	platform.Platform
+	log      logging.Logger
+	detector Detector
+}
+
+// New wraps the platform to list the updates found by the detector. The
+// underlying platform must accept the detected updates, whose Identifier is
+// their version, as targets.
+func New(log logging.Logger, base platform.Platform, detector Detector) *Platform {
+	return &Platform{Platform: base, log: log, detector: detector}
+}
+
+// ListAvailable provides the updates found by the Detector.
+func (p *Platform) ListAvailable(ctx context.Context) (platform.Available, error) {
+	p.log.Debug("detecting available updates")
+	versions, err := p.detector.Detect(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to detect available updates")
+	}
+	var available available
+	for _, version := range versions {
+		available = append(available, Update(version))
+	}
+	return available, nil
+}
+
+// Partitions reports the underlying platform's partitions, none are reported
+// when it's unable to report them.
+func (p *Platform) Partitions(ctx context.Context) (platform.PartitionStatus, error) {
+	if pr, ok := p.Platform.(platform.PartitionReporter); ok {
+		return pr.Partitions(ctx)
+	}
+	return nil, nil
+}
+
+type available []platform.Update
+
+func (a available) Updates() []platform.Update {
+	return a
+}
+
+var _ platform.VersionedUpdate = Update("")
+
+// Update is a detected update to the version it names.
+type Update string
+
+// Identifier returns the version the update updates to.
+func (u Update) Identifier() interface{} {
+	return string(u)
+}
+
+// TargetVersion returns the version the update updates to.
+func (u Update) TargetVersion() string {
+	return string(u)
+}
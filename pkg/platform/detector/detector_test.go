@@ -0,0 +1,110 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+	"gotest.tools/assert"
+)
+
+type staticDetector struct {
+	versions []string
+	err      error
+}
+
+func (d *staticDetector) Detect(_ context.Context) ([]string, error) {
+	return d.versions, d.err
+}
+
+// basePlatform records the targets it's given.
+type basePlatform struct {
+	platform.Platform
+	prepared platform.Update
+}
+
+func (p *basePlatform) ListAvailable(_ context.Context) (platform.Available, error) {
+	return nil, fmt.Errorf("base platform should not be listed")
+}
+
+func (p *basePlatform) Prepare(_ context.Context, target platform.Update) error {
+	p.prepared = target
+	return nil
+}
+
+func TestPlatformListAvailable(t *testing.T) {
+	base := &basePlatform{}
+	p := New(testoutput.Logger(t, logging.New("detector")), base, &staticDetector{
+		versions: []string{"1.2.0", "1.1.0"},
+	})
+
+	available, err := p.ListAvailable(context.Background())
+	assert.NilError(t, err)
+	ups := available.Updates()
+	assert.Equal(t, len(ups), 2)
+	assert.Equal(t, ups[0].Identifier(), "1.2.0")
+	assert.Equal(t, ups[0].(platform.VersionedUpdate).TargetVersion(), "1.2.0")
+
+	// The rest of the flow is handled by the underlying platform.
+	assert.NilError(t, p.Prepare(context.Background(), ups[0]))
+	assert.Equal(t, base.prepared, ups[0])
+}
+
+func TestPlatformListAvailableError(t *testing.T) {
+	p := New(testoutput.Logger(t, logging.New("detector")), &basePlatform{}, &staticDetector{
+		err: fmt.Errorf("source unreachable"),
+	})
+	_, err := p.ListAvailable(context.Background())
+	assert.Check(t, err != nil)
+}
+
+func TestCommand(t *testing.T) {
+	d := &Command{Path: "printf", Args: []string{"# available\n1.2.0\n\n  1.1.0  \n"}}
+	versions, err := d.Detect(context.Background())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, versions, []string{"1.2.0", "1.1.0"})
+
+	versions, err = (&Command{Path: "true"}).Detect(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(versions), 0)
+
+	_, err = (&Command{Path: "false"}).Detect(context.Background())
+	assert.Check(t, err != nil)
+}
+
+func TestHTTP(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		body     string
+		expected []string
+		errors   bool
+	}{
+		{name: "versions", status: http.StatusOK, body: `["1.2.0","1.1.0"]`, expected: []string{"1.2.0", "1.1.0"}},
+		{name: "none", status: http.StatusOK, body: `[]`, expected: []string{}},
+		{name: "bad-status", status: http.StatusInternalServerError, body: `[]`, errors: true},
+		{name: "bad-body", status: http.StatusOK, body: `{"version":"1.2.0"}`, errors: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			versions, err := (&HTTP{URL: server.URL}).Detect(context.Background())
+			if tc.errors {
+				assert.Check(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, versions, tc.expected)
+		})
+	}
+}
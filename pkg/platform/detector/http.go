@@ -0,0 +1,52 @@
+package detector
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultHTTPTimeout = 10 * time.Second
+
+// HTTP detects the available versions by fetching a JSON list of versions,
+// most preferred first, from a URL. For example: ["1.2.0", "1.1.0"]
+type HTTP struct {
+	URL string
+	// Client is used to make the request, a client with a default timeout is
+	// used when nil.
+	Client *http.Client
+}
+
+// Detect fetches and parses the list of versions.
+func (h *HTTP) Detect(ctx context.Context) ([]string, error) {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid detector request")
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "detector request error")
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, errors.Errorf("bad http response, status code: %d", response.StatusCode)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read detector response")
+	}
+	var versions []string
+	err = json.Unmarshal(body, &versions)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid detector response")
+	}
+	return versions, nil
+}
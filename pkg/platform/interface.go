@@ -1,26 +1,34 @@
 package platform
 
-import "github.com/pkg/errors"
+import (
+	"context"
 
-// Platform is implemented by owners of progress makers.
+	"github.com/pkg/errors"
+)
+
+// Platform is implemented by owners of progress makers. Each of its methods is
+// given a context that, once canceled, aborts the platform's work in flight.
 type Platform interface {
 	// Status reports the underlying platform's health and metadata.
-	Status() (Status, error)
+	Status(ctx context.Context) (Status, error)
 	// ListAvailable provides the list of updates that a platform is offering
 	// for use. The list MUST be ordered in preference as well as recency.
-	ListAvailable() (Available, error)
+	ListAvailable(ctx context.Context) (Available, error)
 	// Prepare causes the platform to take steps towards an update without
 	// committing to it. For example, a platform may require steps to preform
 	// pre-flight checks or initialization migrations prior to executing an
 	// update.
-	Prepare(target Update) error
+	Prepare(ctx context.Context, target Update) error
 	// Update causes the platform to commit to an update taking potentially
 	// irreversible steps to do so.
-	Update(target Update) error
+	Update(ctx context.Context, target Update) error
 	// BootUpdate causes the platform to configure itself to use the update on
 	// next boot. Optionally, the caller may indicate that the update should be
 	// immediately rebooted to use.
-	BootUpdate(target Update, rebootNow bool) error
+	BootUpdate(ctx context.Context, target Update, rebootNow bool) error
+	// Rollback causes the platform to boot back into the image it ran before
+	// its most recent update, rebooting the host to do so.
+	Rollback(ctx context.Context) error
 }
 
 // Status reports the readiness of the underlying platform.
@@ -30,12 +38,83 @@ type Status interface {
 	OK() bool
 }
 
+// PartitionReporter is implemented by platforms that are able to report on the
+// images installed to the host's partitions.
+type PartitionReporter interface {
+	// Partitions reports the host's partitions. They're fetched apart from the
+	// platform's Status, which remains a cheap check of its support, and may
+	// fail without failing it. A nil PartitionStatus is returned when the
+	// platform is unable to report them.
+	Partitions(ctx context.Context) (PartitionStatus, error)
+}
+
+// PartitionStatus reports on the images installed to the host's partitions.
+type PartitionStatus interface {
+	// ActivePartition returns the partition that the host is currently running
+	// from.
+	ActivePartition() *Partition
+	// StagingPartition returns the partition that updates are written to, nil
+	// is returned when the host has no staging partition populated.
+	StagingPartition() *Partition
+}
+
+// IdleChecker is implemented by platforms that are able to report whether
+// they're busy with an update command made outside of the caller's control.
+type IdleChecker interface {
+	// Idle reports whether the platform is free to begin an update. A busy
+	// platform may be checked again later.
+	Idle(ctx context.Context) (bool, error)
+}
+
+// Refresher is implemented by platforms that refresh their source of updates
+// as the updates are listed and that are able to refresh it on a separate
+// schedule instead.
+type Refresher interface {
+	// Refresh refreshes the platform's source of available updates.
+	Refresh(ctx context.Context) error
+	// RefreshSeparately stops the platform refreshing its source of updates
+	// when listing them, leaving it to the caller to Refresh.
+	RefreshSeparately()
+}
+
+// SupportStatus is implemented by a Status for platforms that are only able to
+// update hosts running a minimum OS version.
+type SupportStatus interface {
+	Status
+	// Supported reports whether the host's OS version is able to be updated by
+	// the platform.
+	Supported() bool
+	// OSVersion returns the host's OS version.
+	OSVersion() string
+	// MinimumOSVersion returns the oldest OS version supported.
+	MinimumOSVersion() string
+}
+
+// Partition describes the image installed to one of the host's partitions.
+type Partition struct {
+	Version string
+	Variant string
+	Arch    string
+	// NextToBoot is true when the host will boot from this partition on its
+	// next boot.
+	NextToBoot bool
+}
+
 // Available is a listing of available Updates offered by the platform.
 type Available interface {
 	// Updates returns a list of Updates that may be applied.
 	Updates() []Update
 }
 
+// FullAvailable is implemented by an Available listing for platforms that are
+// able to report every update they offer, not only those that may be applied.
+type FullAvailable interface {
+	Available
+	// AllUpdates returns every update offered, including those not newer than
+	// the running version, ordered from the newest version to the oldest.
+	AllUpdates() []Update
+}
+
 // Update is a distinct update that may be applied.
 type Update interface {
 	// Identifier is an opaque identifier used by the platform to coordinate its
@@ -44,11 +123,27 @@ type Update interface {
 	Identifier() interface{}
 }
 
+// VersionedUpdate is an Update that identifies the version it updates to.
+type VersionedUpdate interface {
+	Update
+	// TargetVersion returns the semver version that the update updates to.
+	TargetVersion() string
+}
+
+// ImageUpdate is a VersionedUpdate that describes the image it updates to.
+type ImageUpdate interface {
+	VersionedUpdate
+	// TargetArch returns the architecture of the image updated to.
+	TargetArch() string
+	// TargetVariant returns the variant of the image updated to.
+	TargetVariant() string
+}
+
 // Ping the platform to verify its liveliness and general usability based on its
 // status. Platform consumers should utilize this method to consistently
 // validate the platform before use.
-func Ping(p Platform) error {
-	status, err := p.Status()
+func Ping(ctx context.Context, p Platform) error {
+	status, err := p.Status(ctx)
 	if err != nil {
 		return errors.Wrap(err, "could not retrieve platform status")
 	}
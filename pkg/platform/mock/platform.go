@@ -0,0 +1,343 @@
+// Package mock provides an in-memory platform that simulates a host's updates,
+// for testing the platform's callers without a Bottlerocket host.
+package mock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Masterminds/semver"
+	"github.com/pkg/errors"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+)
+
+// Assert the mock as a platform implementor.
+var _ platform.Platform = (*Platform)(nil)
+var _ platform.PartitionReporter = (*Platform)(nil)
+
+// Method names a method of the platform.
+type Method string
+
+const (
+	MethodStatus        Method = "Status"
+	MethodPartitions    Method = "Partitions"
+	MethodListAvailable Method = "ListAvailable"
+	MethodPrepare       Method = "Prepare"
+	MethodUpdate        Method = "Update"
+	MethodBootUpdate    Method = "BootUpdate"
+	MethodRollback      Method = "Rollback"
+)
+
+// State is the state of the host's update, as the update API reports it.
+type State string
+
+const (
+	// StateIdle is a host with no update in progress.
+	StateIdle State = "Idle"
+	// StateStaged is a host with an update prepared, written to its staging
+	// partition.
+	StateStaged State = "Staged"
+	// StateReady is a host with an update applied, its staging partition is
+	// booted next.
+	StateReady State = "Ready"
+)
+
+// Call is a call made to the platform, along with the version of the update
+// it was given and whether it was asked to reboot.
+type Call struct {
+	Method    Method
+	Version   string
+	RebootNow bool
+}
+
+// Platform simulates a host's updates in memory. The updates offered are
+// prepared, applied, and booted in turn, as with the update API, with the
+// partitions swapped as the host reboots into its update or rolls back. Each
+// call is recorded and fails when a failure is set for its method. It's safe
+// for concurrent use.
+type Platform struct {
+	mu        sync.Mutex
+	running   string
+	previous  string
+	staged    string
+	state     State
+	available []string
+	unhealthy bool
+	failures  map[Method]error
+	calls     []Call
+	reboots   int
+}
+
+// New creates a platform for a host running the version, with the versions
+// offered as updates in order of preference.
+func New(running string, available ...string) *Platform {
+	return &Platform{
+		running:   running,
+		state:     StateIdle,
+		available: available,
+		failures:  map[Method]error{},
+	}
+}
+
+// Fail sets the error that calls of the method return until the failure is
+// cleared by setting a nil error.
+func (p *Platform) Fail(method Method, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.failures, method)
+		return
+	}
+	p.failures[method] = err
+}
+
+// SetAvailable sets the versions offered as updates, in order of preference.
+func (p *Platform) SetAvailable(versions ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.available = versions
+}
+
+// SetHealthy sets whether the platform's status is reported OK.
+func (p *Platform) SetHealthy(healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unhealthy = !healthy
+}
+
+// Calls returns the calls made to the platform, in the order they were made.
+func (p *Platform) Calls() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call(nil), p.calls...)
+}
+
+// Running returns the version the host runs.
+func (p *Platform) Running() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// State returns the state of the host's update.
+func (p *Platform) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Reboots returns the number of times the host was rebooted.
+func (p *Platform) Reboots() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reboots
+}
+
+// call records the call, returning the failure set for its method. It's
+// called with the lock held.
+func (p *Platform) call(c Call) error {
+	p.calls = append(p.calls, c)
+	return p.failures[c.Method]
+}
+
+// Status reports the platform's status, it's OK unless set unhealthy.
+func (p *Platform) Status(_ context.Context) (platform.Status, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodStatus}); err != nil {
+		return nil, err
+	}
+	return &Status{ok: !p.unhealthy}, nil
+}
+
+// Partitions reports the host's partitions, the staging partition is reported
+// once an update was staged.
+func (p *Platform) Partitions(_ context.Context) (platform.PartitionStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodPartitions}); err != nil {
+		return nil, err
+	}
+	partitions := &Partitions{
+		active: &platform.Partition{Version: p.running, NextToBoot: p.state != StateReady},
+	}
+	if p.staged != "" {
+		partitions.staging = &platform.Partition{Version: p.staged, NextToBoot: p.state == StateReady}
+	}
+	return partitions, nil
+}
+
+// ListAvailable lists the offered updates newer than the running version.
+func (p *Platform) ListAvailable(_ context.Context) (platform.Available, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodListAvailable}); err != nil {
+		return nil, err
+	}
+	running, err := semver.NewVersion(p.running)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid running version %q", p.running)
+	}
+	var available Available
+	for _, version := range p.available {
+		v, err := semver.NewVersion(version)
+		if err != nil || !v.GreaterThan(running) {
+			continue
+		}
+		available = append(available, &Update{Version: version})
+	}
+	return available, nil
+}
+
+// Prepare stages the offered update.
+func (p *Platform) Prepare(_ context.Context, target platform.Update) error {
+	version := targetVersion(target)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodPrepare, Version: version}); err != nil {
+		return err
+	}
+	if !p.offered(version) {
+		return errors.Errorf("update %s not available", version)
+	}
+	p.staged = version
+	p.state = StateStaged
+	return nil
+}
+
+// Update applies the staged update, to be booted next.
+func (p *Platform) Update(_ context.Context, target platform.Update) error {
+	version := targetVersion(target)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodUpdate, Version: version}); err != nil {
+		return err
+	}
+	if p.state != StateStaged || p.staged != version {
+		return errors.Errorf("update %s not prepared", version)
+	}
+	p.state = StateReady
+	return nil
+}
+
+// BootUpdate reboots the host into the applied update when asked to reboot
+// now, swapping its partitions.
+func (p *Platform) BootUpdate(_ context.Context, target platform.Update, rebootNow bool) error {
+	version := targetVersion(target)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodBootUpdate, Version: version, RebootNow: rebootNow}); err != nil {
+		return err
+	}
+	if p.state != StateReady || p.staged != version {
+		return errors.Errorf("update %s not applied", version)
+	}
+	if rebootNow {
+		p.previous, p.running = p.running, p.staged
+		p.staged = p.previous
+		p.state = StateIdle
+		p.reboots++
+	}
+	return nil
+}
+
+// Rollback reboots the host back into the version it ran before its last
+// update.
+func (p *Platform) Rollback(_ context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.call(Call{Method: MethodRollback}); err != nil {
+		return err
+	}
+	if p.previous == "" {
+		return errors.New("no previous version to roll back to")
+	}
+	p.previous, p.running = p.running, p.previous
+	p.staged = p.previous
+	p.state = StateIdle
+	p.reboots++
+	return nil
+}
+
+// offered is true when the version is offered as an update. It's called with
+// the lock held.
+func (p *Platform) offered(version string) bool {
+	for _, v := range p.available {
+		if platform.SameVersion(v, version) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetVersion is the version the update updates to, updates that don't
+// identify their version are known by their identifier.
+func targetVersion(target platform.Update) string {
+	if up, ok := target.(platform.VersionedUpdate); ok {
+		return up.TargetVersion()
+	}
+	if target == nil {
+		return ""
+	}
+	return fmt.Sprint(target.Identifier())
+}
+
+var _ platform.Status = (*Status)(nil)
+
+// Status is the platform's status.
+type Status struct {
+	ok bool
+}
+
+// OK is true unless the platform was set unhealthy.
+func (s *Status) OK() bool {
+	return s.ok
+}
+
+var _ platform.PartitionStatus = (*Partitions)(nil)
+
+// Partitions are the host's partitions.
+type Partitions struct {
+	active  *platform.Partition
+	staging *platform.Partition
+}
+
+// ActivePartition returns the partition running the host's version.
+func (ps *Partitions) ActivePartition() *platform.Partition {
+	return ps.active
+}
+
+// StagingPartition returns the partition updates are written to, nil when no
+// update was staged.
+func (ps *Partitions) StagingPartition() *platform.Partition {
+	return ps.staging
+}
+
+var _ platform.Available = (Available)(nil)
+
+// Available are the updates offered, in order of preference.
+type Available []platform.Update
+
+// Updates returns the updates offered.
+func (a Available) Updates() []platform.Update {
+	return a
+}
+
+var _ platform.VersionedUpdate = (*Update)(nil)
+
+// Update is an update to a version.
+type Update struct {
+	Version string
+}
+
+// Identifier identifies the update by its version.
+func (u *Update) Identifier() interface{} {
+	return u.Version
+}
+
+// TargetVersion returns the version updated to.
+func (u *Update) TargetVersion() string {
+	return u.Version
+}
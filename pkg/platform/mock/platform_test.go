@@ -0,0 +1,91 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
+)
+
+func TestPlatformUpdate(t *testing.T) {
+	ctx := context.Background()
+	p := New("1.0.0", "1.1.0", "0.9.0", "1.0.0")
+
+	available, err := p.ListAvailable(ctx)
+	assert.NilError(t, err)
+	ups := available.Updates()
+	assert.Equal(t, len(ups), 1, "only newer versions are available")
+	target := ups[0]
+	assert.Equal(t, target.(platform.VersionedUpdate).TargetVersion(), "1.1.0")
+
+	assert.ErrorContains(t, p.Update(ctx, target), "not prepared")
+	assert.NilError(t, p.Prepare(ctx, target))
+	assert.Equal(t, p.State(), StateStaged)
+	assert.ErrorContains(t, p.BootUpdate(ctx, target, true), "not applied")
+	assert.NilError(t, p.Update(ctx, target))
+	assert.Equal(t, p.State(), StateReady)
+
+	status, err := p.Status(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, status.OK())
+	partitions, err := p.Partitions(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, partitions.ActivePartition(), &platform.Partition{Version: "1.0.0"})
+	assert.DeepEqual(t, partitions.StagingPartition(), &platform.Partition{Version: "1.1.0", NextToBoot: true})
+
+	assert.NilError(t, p.BootUpdate(ctx, target, true))
+	assert.Equal(t, p.Running(), "1.1.0")
+	assert.Equal(t, p.State(), StateIdle)
+	assert.Equal(t, p.Reboots(), 1)
+	available, err = p.ListAvailable(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(available.Updates()), 0)
+
+	assert.NilError(t, p.Rollback(ctx))
+	assert.Equal(t, p.Running(), "1.0.0")
+	assert.Equal(t, p.Reboots(), 2)
+
+	assert.DeepEqual(t, p.Calls(), []Call{
+		{Method: MethodListAvailable},
+		{Method: MethodUpdate, Version: "1.1.0"},
+		{Method: MethodPrepare, Version: "1.1.0"},
+		{Method: MethodBootUpdate, Version: "1.1.0", RebootNow: true},
+		{Method: MethodUpdate, Version: "1.1.0"},
+		{Method: MethodStatus},
+		{Method: MethodPartitions},
+		{Method: MethodBootUpdate, Version: "1.1.0", RebootNow: true},
+		{Method: MethodListAvailable},
+		{Method: MethodRollback},
+	})
+}
+
+func TestPlatformFail(t *testing.T) {
+	ctx := context.Background()
+	p := New("1.0.0", "1.1.0")
+	target := &Update{Version: "1.1.0"}
+
+	p.Fail(MethodPrepare, errors.New("disk full"))
+	assert.ErrorContains(t, p.Prepare(ctx, target), "disk full")
+	assert.Equal(t, p.State(), StateIdle, "failed calls leave the state unchanged")
+	p.Fail(MethodPrepare, nil)
+	assert.NilError(t, p.Prepare(ctx, target))
+
+	p.Fail(MethodStatus, errors.New("api unavailable"))
+	_, err := p.Status(ctx)
+	assert.ErrorContains(t, err, "api unavailable")
+	p.Fail(MethodStatus, nil)
+	p.Fail(MethodPartitions, errors.New("api unavailable"))
+	_, err = p.Partitions(ctx)
+	assert.ErrorContains(t, err, "api unavailable")
+	assert.NilError(t, platform.Ping(ctx, p), "status is reported apart from the partitions")
+	p.SetHealthy(false)
+	assert.ErrorContains(t, platform.Ping(ctx, p), "did not report OK")
+
+	assert.ErrorContains(t, p.Prepare(ctx, &Update{Version: "2.0.0"}), "not available")
+	p.SetAvailable("1.1.0", "2.0.0")
+	assert.NilError(t, p.Prepare(ctx, &Update{Version: "2.0.0"}))
+	assert.ErrorContains(t, p.Rollback(ctx), "no previous version")
+}
@@ -11,6 +11,7 @@ type Host interface {
 	PrepareUpdate(id UpdateID) (*prepareUpdateResponse, error)
 	ApplyUpdate(id UpdateID) (*applyUpdateResponse, error)
 	BootUpdate(id UpdateID, rebootNow bool) (*bootUpdateResponse, error)
+	Rollback() (*rollbackResponse, error)
 }
 
 // UpdateID is the type of the opaque Identifier used for this platform.
@@ -37,6 +38,7 @@ func (sr *statusResponse) OK() bool {
 type applyUpdateResponse actionResponse
 type prepareUpdateResponse actionResponse
 type bootUpdateResponse actionResponse
+type rollbackResponse actionResponse
 
 var _ platform.Available = (*listAvailableResponse)(nil)
 
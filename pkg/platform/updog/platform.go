@@ -1,6 +1,8 @@
 package updog
 
 import (
+	"context"
+
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
 	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/platform"
 	"github.com/pkg/errors"
@@ -9,6 +11,8 @@ import (
 // Assert Updog as a platform implementor.
 var _ platform.Platform = (*Platform)(nil)
 
+// Platform runs Updog on the host, its commands run to completion and aren't
+// canceled by the context given to its methods.
 type Platform struct {
 	log  logging.Logger
 	host Host
@@ -19,14 +23,14 @@ func New() (*Platform, error) {
 }
 
 // Status reports the underlying platform's health and metadata.
-func (p *Platform) Status() (platform.Status, error) {
+func (p *Platform) Status(_ context.Context) (platform.Status, error) {
 	p.log.Debug("querying status")
 	return p.host.Status()
 }
 
 // ListAvailable provides the list of updates that a platform is offering
 // for use. The list MUST be ordered in preference as well as recency.
-func (p *Platform) ListAvailable() (platform.Available, error) {
+func (p *Platform) ListAvailable(_ context.Context) (platform.Available, error) {
 	p.log.Debug("fetching list of available updates")
 	return p.host.ListAvailable()
 }
@@ -35,7 +39,7 @@ func (p *Platform) ListAvailable() (platform.Available, error) {
 // committing to it. For example, a platform may require steps to preform
 // pre-flight checks or initialization migrations prior to executing an
 // update.
-func (p *Platform) Prepare(target platform.Update) error {
+func (p *Platform) Prepare(_ context.Context, target platform.Update) error {
 	p.log.Debug("preparing update")
 	id, err := targetID(target)
 	if err != nil {
@@ -47,7 +51,7 @@ func (p *Platform) Prepare(target platform.Update) error {
 
 // Update causes the platform to commit to an update - potentially taking
 // irreversible steps to do so.
-func (p *Platform) Update(target platform.Update) error {
+func (p *Platform) Update(_ context.Context, target platform.Update) error {
 	p.log.Debug("performing update")
 	id, err := targetID(target)
 	if err != nil {
@@ -60,7 +64,7 @@ func (p *Platform) Update(target platform.Update) error {
 // BootUpdate causes the platform to configure itself to use the update on
 // next boot. Optionally, the caller may indicate that the update should be
 // immediately rebooted to use.
-func (p *Platform) BootUpdate(target platform.Update, rebootNow bool) error {
+func (p *Platform) BootUpdate(_ context.Context, target platform.Update, rebootNow bool) error {
 	if rebootNow {
 		p.log.Debug("marking update and rebooting")
 	} else {
@@ -74,6 +78,14 @@ func (p *Platform) BootUpdate(target platform.Update, rebootNow bool) error {
 	return err
 }
 
+// Rollback causes the platform to boot back into the image it ran before its
+// most recent update, rebooting the host to do so.
+func (p *Platform) Rollback(_ context.Context) error {
+	p.log.Debug("rolling back to inactive partition and rebooting")
+	_, err := p.host.Rollback()
+	return err
+}
+
 func targetID(target platform.Update) (UpdateID, error) {
 	id, ok := target.Identifier().(UpdateID)
 	if !ok {
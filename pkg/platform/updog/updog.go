@@ -13,7 +13,8 @@ import (
 )
 
 var (
-	updogBin = filepath.Join(bottlerocket.PlatformBin, "updog")
+	updogBin    = filepath.Join(bottlerocket.PlatformBin, "updog")
+	signpostBin = filepath.Join(bottlerocket.PlatformBin, "signpost")
 )
 
 const (
@@ -34,6 +35,7 @@ type command interface {
 	Update() error
 	UpdateImage() error
 	Reboot() error
+	RollbackToInactive() error
 	Status() (bool, error)
 }
 
@@ -98,6 +100,11 @@ func (e *executable) Reboot() error {
 	return err
 }
 
+func (e *executable) RollbackToInactive() error {
+	_, err := e.runOk(exec.Command(signpostBin, "rollback-to-inactive"))
+	return err
+}
+
 func (e *executable) Status() (bool, error) {
 	_, err := os.Stat(bottlerocket.RootFS + updogBin)
 	if err != nil {
@@ -157,3 +164,13 @@ func (u *updog) BootUpdate(id UpdateID, rebootNow bool) (*bootUpdateResponse, er
 	}
 	return &bootUpdateResponse{}, nil
 }
+
+func (u *updog) Rollback() (*rollbackResponse, error) {
+	if err := u.Bin.RollbackToInactive(); err != nil {
+		return nil, errors.Wrap(err, "unable to mark inactive partition to boot")
+	}
+	if err := u.Bin.Reboot(); err != nil {
+		return nil, err
+	}
+	return &rollbackResponse{}, nil
+}
@@ -0,0 +1,18 @@
+package platform
+
+import "github.com/Masterminds/semver"
+
+// SameVersion reports whether the versions are the same by semver precedence,
+// which ignores build metadata: "1.28.0" and "1.28.0+abc" are the same
+// version. Versions that aren't valid semver are compared as written.
+func SameVersion(a, b string) bool {
+	va, err := semver.NewVersion(a)
+	if err != nil {
+		return a == b
+	}
+	vb, err := semver.NewVersion(b)
+	if err != nil {
+		return a == b
+	}
+	return va.Equal(vb)
+}
@@ -0,0 +1,29 @@
+package platform
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestSameVersion(t *testing.T) {
+	cases := []struct {
+		a, b string
+		same bool
+	}{
+		{a: "1.28.0", b: "1.28.0", same: true},
+		// Build metadata is ignored in precedence.
+		{a: "1.28.0", b: "1.28.0+abc", same: true},
+		{a: "1.28.0+abc", b: "1.28.0+def", same: true},
+		{a: "v1.28.0", b: "1.28.0+abc", same: true},
+		{a: "1.28.0+abc", b: "1.28.1+abc", same: false},
+		{a: "1.28.0-rc1+abc", b: "1.28.0+abc", same: false},
+		// Invalid versions are compared as written.
+		{a: "latest", b: "latest", same: true},
+		{a: "latest", b: "1.28.0", same: false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, SameVersion(tc.a, tc.b), tc.same, "%s and %s", tc.a, tc.b)
+		assert.Equal(t, SameVersion(tc.b, tc.a), tc.same, "%s and %s", tc.b, tc.a)
+	}
+}
@@ -0,0 +1,120 @@
+// Package report writes structured records of the Nodes' update lifecycle
+// events, one line of JSON for each event, for log-based pipelines.
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/intent"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Event is a significant step in a Node's update lifecycle.
+type Event string
+
+const (
+	// UpdateAvailable is reported when the Node finds an update available.
+	UpdateAvailable Event = "update-available"
+	// Begin is reported when the Node is directed to begin its update.
+	Begin Event = "begin"
+	// Prepared is reported when the Node has prepared its update.
+	Prepared Event = "prepared"
+	// Rebooted is reported when the Node is rebooting into its update.
+	Rebooted Event = "rebooted"
+	// Success is reported when the Node has completed its update.
+	Success Event = "success"
+	// Failure is reported when the Node failed to take a step of its update.
+	Failure Event = "failure"
+	// Stuck is reported when the Node is detected as stuck and reset.
+	Stuck Event = "stuck"
+	// DrainBlocked is reported when the Node's drain is blocked by
+	// PodDisruptionBudgets permitting no disruptions.
+	DrainBlocked Event = "drain-blocked"
+)
+
+// Record is a reported event, written as a single line of JSON. Every Record
+// has the same fields, the error is only set for failures and blocked drains.
+type Record struct {
+	// Time is when the event was reported, in RFC 3339 format.
+	Time string `json:"time"`
+	// Event is the reported event.
+	Event Event `json:"event"`
+	// Component is the reporting component, the agent or controller.
+	Component string `json:"component"`
+	// Node is the name of the Node the event happened to.
+	Node string `json:"node"`
+	// Wanted, Active, and State are the Node's Intent at the event.
+	Wanted string `json:"wanted"`
+	Active string `json:"active"`
+	State  string `json:"state"`
+	// UpdateAvailable is the Node's update availability at the event.
+	UpdateAvailable string `json:"updateAvailable"`
+	// Error describes the failure or what blocked the drain, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Reporter writes Records for the events reported by a component. A nil
+// Reporter reports nothing.
+type Reporter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	ring      *Ring
+	component string
+	clock     clock.Clock
+}
+
+// New creates a Reporter writing the component's events to w. A nil w writes
+// nothing, for Reporters that only keep their Records.
+func New(w io.Writer, component string) *Reporter {
+	return &Reporter{
+		w:         w,
+		component: component,
+		clock:     clock.RealClock{},
+	}
+}
+
+// Keep also keeps the reported Records in the ring.
+func (r *Reporter) Keep(ring *Ring) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring = ring
+}
+
+// Report writes a Record of the event for the Node with the given Intent. The
+// error, if any, is the failure reported. Records that can't be written are
+// dropped, the events remain in the components' own logs.
+func (r *Reporter) Report(event Event, in *intent.Intent, err error) {
+	if r == nil {
+		return
+	}
+	rec := Record{
+		Time:            r.clock.Now().UTC().Format(time.RFC3339),
+		Event:           event,
+		Component:       r.component,
+		Node:            in.NodeName,
+		Wanted:          in.Wanted,
+		Active:          in.Active,
+		State:           in.State,
+		UpdateAvailable: in.UpdateAvailable,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ring != nil {
+		r.ring.Add(rec)
+	}
+	if r.w == nil {
+		return
+	}
+	data, merr := json.Marshal(rec)
+	if merr != nil {
+		return
+	}
+	r.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf, "controller")
+	r.clock = clock.NewFakeClock(time.Date(2020, 7, 10, 0, 0, 0, 0, time.UTC))
+
+	events := []Event{UpdateAvailable, Begin, Prepared, Rebooted, Success, Failure, Stuck, DrainBlocked}
+	for _, event := range events {
+		var err error
+		switch event {
+		case Failure:
+			err = errors.New("drain failed")
+		case DrainBlocked:
+			err = errors.New("drain blocked")
+		}
+		r.Report(event, intents.PendingPrepareUpdate(intents.WithNodeName(intents.NodeName)), err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, len(lines), len(events), "each event should be a single line")
+	for i, line := range lines {
+		var fields map[string]interface{}
+		assert.NilError(t, json.Unmarshal([]byte(line), &fields))
+		assert.Equal(t, fields["event"], string(events[i]))
+		assert.Equal(t, fields["time"], "2020-07-10T00:00:00Z")
+		assert.Equal(t, fields["component"], "controller")
+		assert.Equal(t, fields["node"], intents.NodeName)
+		assert.Equal(t, fields["wanted"], "prepare-update")
+		assert.Equal(t, fields["active"], "stabilize")
+		assert.Equal(t, fields["state"], "ready")
+		assert.Equal(t, fields["updateAvailable"], "true")
+		switch events[i] {
+		case Failure:
+			assert.Equal(t, fields["error"], "drain failed")
+		case DrainBlocked:
+			assert.Equal(t, fields["error"], "drain blocked")
+		default:
+			_, ok := fields["error"]
+			assert.Check(t, !ok, "only failures and blocked drains should have an error")
+		}
+	}
+}
+
+func TestReportNil(t *testing.T) {
+	var r *Reporter
+	r.Report(Success, intents.UpdateSuccess(), nil)
+}
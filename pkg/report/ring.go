@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Ring keeps the most recent Records in memory, dropping the oldest once it
+// reaches its capacity.
+type Ring struct {
+	mu      sync.Mutex
+	records []Record
+	next    int
+	full    bool
+}
+
+// NewRing creates a Ring holding up to capacity Records.
+func NewRing(capacity int) *Ring {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Ring{records: make([]Record, capacity)}
+}
+
+// Add keeps the Record, replacing the oldest kept Record if the Ring is full.
+func (r *Ring) Add(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Records returns the kept Records, oldest first.
+func (r *Ring) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Record{}, r.records[:r.next]...)
+	}
+	recs := make([]Record, 0, len(r.records))
+	recs = append(recs, r.records[r.next:]...)
+	return append(recs, r.records[:r.next]...)
+}
+
+// ServeHTTP responds with the kept Records as a JSON list, oldest first. The
+// "node" query parameter limits the list to the named Node's Records.
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	recs := r.Records()
+	if node := req.URL.Query().Get("node"); node != "" {
+		matched := []Record{}
+		for _, rec := range recs {
+			if rec.Node == node {
+				matched = append(matched, rec)
+			}
+		}
+		recs = matched
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recs)
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/intents"
+	"gotest.tools/assert"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing(3)
+	assert.Equal(t, len(r.Records()), 0)
+
+	for i := 0; i < 2; i++ {
+		r.Add(Record{Node: fmt.Sprintf("node-%d", i)})
+	}
+	recs := r.Records()
+	assert.Equal(t, len(recs), 2)
+	assert.Equal(t, recs[0].Node, "node-0")
+	assert.Equal(t, recs[1].Node, "node-1")
+
+	// Once at capacity, the oldest Records are dropped.
+	for i := 2; i < 7; i++ {
+		r.Add(Record{Node: fmt.Sprintf("node-%d", i)})
+	}
+	recs = r.Records()
+	assert.Equal(t, len(recs), 3)
+	assert.Equal(t, recs[0].Node, "node-4")
+	assert.Equal(t, recs[1].Node, "node-5")
+	assert.Equal(t, recs[2].Node, "node-6")
+}
+
+func TestRingServeHTTP(t *testing.T) {
+	r := NewRing(4)
+	r.Add(Record{Node: "node-a", Event: Begin})
+	r.Add(Record{Node: "node-b", Event: Begin})
+	r.Add(Record{Node: "node-a", Event: Success})
+
+	serve := func(target string) []Record {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+		var recs []Record
+		assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &recs))
+		return recs
+	}
+
+	assert.Equal(t, len(serve("/events")), 3)
+	recs := serve("/events?node=node-a")
+	assert.Equal(t, len(recs), 2)
+	assert.Equal(t, recs[0].Event, Begin)
+	assert.Equal(t, recs[1].Event, Success)
+	assert.Equal(t, len(serve("/events?node=node-c")), 0)
+}
+
+func TestReportKeep(t *testing.T) {
+	ring := NewRing(2)
+	r := New(nil, "controller")
+	r.Keep(ring)
+
+	r.Report(Begin, intents.PendingPrepareUpdate(), nil)
+	r.Report(Success, intents.UpdateSuccess(intents.WithNodeName(intents.NodeName)), nil)
+	r.Report(Stuck, intents.Unknown(), nil)
+
+	recs := ring.Records()
+	assert.Equal(t, len(recs), 2)
+	assert.Equal(t, recs[0].Event, Success)
+	assert.Equal(t, recs[0].Node, intents.NodeName)
+	assert.Equal(t, recs[1].Event, Stuck)
+}
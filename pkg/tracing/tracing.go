@@ -0,0 +1,352 @@
+// Package tracing traces the update flow across the operator's processes,
+// exporting the spans to an OpenTelemetry collector as OTLP over HTTP. Trace
+// context is carried between processes in the W3C traceparent format so that
+// the Controller's and Agents' spans join into one trace.
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+)
+
+const (
+	// flushInterval is the time between exports of the ended spans.
+	flushInterval = 5 * time.Second
+	// exportTimeout bounds each export to the collector.
+	exportTimeout = 10 * time.Second
+	// maxBuffered bounds the ended spans held for export, spans ended while
+	// the buffer is full are dropped.
+	maxBuffered = 2048
+
+	// scopeName identifies the operator's instrumentation to the collector.
+	scopeName = "github.com/bottlerocket-os/bottlerocket-update-operator"
+
+	// spanKindInternal and statusCodeError are the OTLP enumeration values
+	// for the spans' kind and failed status.
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// SpanContext identifies a span within its trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// Valid is true when the SpanContext identifies a span.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the SpanContext as a W3C traceparent, or returns an
+// empty string when it isn't valid.
+func (sc SpanContext) Traceparent() string {
+	if !sc.Valid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", sc.TraceID[:], sc.SpanID[:])
+}
+
+// ParseTraceparent parses a W3C traceparent, returning an invalid SpanContext
+// when the value isn't one.
+func ParseTraceparent(s string) SpanContext {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[3]) != 2 {
+		return SpanContext{}
+	}
+	var sc SpanContext
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return SpanContext{}
+	}
+	return sc
+}
+
+func decodeHex(into []byte, s string) bool {
+	if hex.DecodedLen(len(s)) != len(into) {
+		return false
+	}
+	_, err := hex.Decode(into, []byte(s))
+	return err == nil
+}
+
+// Tracer records spans and exports them to the collector. A nil Tracer, as
+// created when no endpoint is configured, records nothing.
+type Tracer struct {
+	log      logging.Logger
+	service  string
+	endpoint string
+	client   *http.Client
+
+	mu      sync.Mutex
+	ended   []*Span
+	dropped int
+}
+
+// NewTracer creates a Tracer for the named service that exports to the
+// collector's OTLP/HTTP traces endpoint, such as
+// http://collector:4318/v1/traces. No Tracer is created when the endpoint is
+// empty.
+func NewTracer(log logging.Logger, service string, endpoint string) (*Tracer, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("invalid tracing endpoint %q", endpoint)
+	}
+	return &Tracer{
+		log:      log,
+		service:  service,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: exportTimeout},
+	}, nil
+}
+
+// Start begins a span as a child of the parent, or of a new trace when the
+// parent isn't valid.
+func (t *Tracer) Start(name string, parent SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{tracer: t, name: name, start: time.Now()}
+	if parent.Valid() {
+		s.context.TraceID = parent.TraceID
+		s.parent = parent.SpanID
+	} else {
+		rand.Read(s.context.TraceID[:])
+	}
+	rand.Read(s.context.SpanID[:])
+	return s
+}
+
+// Run exports the ended spans periodically until the context is canceled,
+// exporting those remaining before it returns. Failed exports are logged and
+// their spans dropped, tracing never stops the process.
+func (t *Tracer) Run(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			defer cancel()
+			t.flush(flushCtx)
+			return nil
+		case <-ticker.C:
+			t.flush(ctx)
+		}
+	}
+}
+
+// Flush exports the ended spans now, such as before the process is stopped.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans, dropped := t.ended, t.dropped
+	t.ended, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		t.log.WithField("dropped", dropped).Warn("dropped spans, too many waiting to be exported")
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(t.payload(spans))
+	if err != nil {
+		return errors.Wrap(err, "unable to encode spans")
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create export request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	response, err := t.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "export request error")
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return errors.Errorf("bad http response, status code: %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (t *Tracer) flush(ctx context.Context) {
+	if err := t.Flush(ctx); err != nil {
+		t.log.WithError(err).Warn("unable to export spans")
+	}
+}
+
+func (t *Tracer) record(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.ended) >= maxBuffered {
+		t.dropped++
+		return
+	}
+	t.ended = append(t.ended, s)
+}
+
+// Span is an operation within a trace. A nil Span, as started by a nil
+// Tracer, records nothing.
+type Span struct {
+	tracer  *Tracer
+	name    string
+	context SpanContext
+	parent  [8]byte
+	start   time.Time
+
+	mu         sync.Mutex
+	attributes []attribute
+	end        time.Time
+	err        error
+}
+
+type attribute struct {
+	key   string
+	value string
+}
+
+// Context returns the Span's SpanContext, for its children and to propagate
+// to other processes.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// Child begins a span as a child of the Span.
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.Start(name, s.context)
+}
+
+// SetAttribute sets the attribute on the Span, replacing its prior value.
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.attributes {
+		if s.attributes[i].key == key {
+			s.attributes[i].value = value
+			return
+		}
+	}
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// End ends the Span, marking it failed when given an error. Only the first
+// End is recorded.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	s.mu.Unlock()
+	s.tracer.record(s)
+}
+
+// The OTLP/HTTP JSON encoding of the exported spans.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []spanData `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	spanData struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            *status    `json:"status,omitempty"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func (t *Tracer) payload(spans []*Span) exportRequest {
+	data := make([]spanData, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		d := spanData{
+			TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			d.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attributes {
+			d.Attributes = append(d.Attributes, keyValue{Key: a.key, Value: anyValue{StringValue: a.value}})
+		}
+		if s.err != nil {
+			d.Status = &status{Code: statusCodeError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		data = append(data, d)
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: []keyValue{
+			{Key: "service.name", Value: anyValue{StringValue: t.service}},
+		}},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: data}},
+	}}}
+}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gotest.tools/assert"
+
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/internal/testoutput"
+	"github.com/bottlerocket-os/bottlerocket-update-operator/pkg/logging"
+)
+
+func TestTraceparent(t *testing.T) {
+	tracer := &Tracer{}
+	sc := tracer.Start("span", SpanContext{}).Context()
+	assert.Check(t, sc.Valid())
+	assert.Equal(t, ParseTraceparent(sc.Traceparent()), sc)
+
+	for _, invalid := range []string{
+		"",
+		"garbage",
+		"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b71692033-01",
+		"00-0af7651916cd43dd8448eb211c80319c-zzad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+	} {
+		assert.Check(t, !ParseTraceparent(invalid).Valid(), invalid)
+	}
+	assert.Equal(t, SpanContext{}.Traceparent(), "")
+}
+
+func TestTracerExport(t *testing.T) {
+	exported := make(chan exportRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req exportRequest
+		assert.Check(t, json.NewDecoder(r.Body).Decode(&req))
+		exported <- req
+	}))
+	defer collector.Close()
+
+	tracer, err := NewTracer(testoutput.Logger(t, logging.New("tracing")), "controller", collector.URL+"/v1/traces")
+	assert.NilError(t, err)
+	parent := ParseTraceparent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	span := tracer.Start("intent", parent)
+	span.SetAttribute("k8s.node.name", "node-a")
+	child := span.Child("drain")
+	child.End(errors.New("drain blocked"))
+	child.End(nil)
+	span.End(nil)
+	assert.NilError(t, tracer.Flush(context.Background()))
+
+	req := <-exported
+	assert.Equal(t, len(req.ResourceSpans), 1)
+	assert.DeepEqual(t, req.ResourceSpans[0].Resource.Attributes, []keyValue{
+		{Key: "service.name", Value: anyValue{StringValue: "controller"}},
+	})
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, len(spans), 2, "span should only be recorded once")
+	drain, intent := spans[0], spans[1]
+	assert.Equal(t, drain.Name, "drain")
+	assert.Equal(t, drain.ParentSpanID, intent.SpanID)
+	assert.DeepEqual(t, drain.Status, &status{Code: statusCodeError, Message: "drain blocked"})
+	assert.Equal(t, intent.TraceID, "0af7651916cd43dd8448eb211c80319c", "span should join its parent's trace")
+	assert.Equal(t, intent.ParentSpanID, "b7ad6b7169203331")
+	assert.Check(t, intent.Status == nil)
+	assert.DeepEqual(t, intent.Attributes, []keyValue{
+		{Key: "k8s.node.name", Value: anyValue{StringValue: "node-a"}},
+	})
+
+	assert.NilError(t, tracer.Flush(context.Background()), "nothing to export")
+}
+
+func TestTracerDisabled(t *testing.T) {
+	tracer, err := NewTracer(testoutput.Logger(t, logging.New("tracing")), "agent", "")
+	assert.NilError(t, err)
+	assert.Check(t, tracer == nil)
+	span := tracer.Start("realize", SpanContext{})
+	span.SetAttribute("key", "value")
+	span.Child("update").End(nil)
+	span.End(nil)
+	assert.Check(t, !span.Context().Valid())
+	assert.NilError(t, tracer.Flush(context.Background()))
+	assert.NilError(t, tracer.Run(context.Background()))
+
+	_, err = NewTracer(testoutput.Logger(t, logging.New("tracing")), "agent", "collector:4318")
+	assert.ErrorContains(t, err, "invalid tracing endpoint")
+}